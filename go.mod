@@ -1,11 +1,12 @@
 module github.com/CrunchyData/pg_featureserv
 
-go 1.13
+go 1.16
 
 require (
 	github.com/antlr/antlr4/runtime/Go/antlr v0.0.0-20220209173558-ad29539cd2e9
 	github.com/getkin/kin-openapi v0.104.0
 	github.com/go-http-utils/headers v0.0.0-20181008091004-fed159eddc2a
+	github.com/go-jose/go-jose/v3 v3.0.5
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/gorilla/handlers v1.4.2
 	github.com/gorilla/mux v1.8.0
@@ -14,7 +15,14 @@ require (
 	github.com/jackc/pgx/v4 v4.1.2
 	github.com/paulmach/orb v0.7.1
 	github.com/pborman/getopt v0.0.0-20190409184431-ee0cd42419d3
+	github.com/prometheus/client_golang v1.11.1
+	github.com/prometheus/common v0.26.0
 	github.com/sirupsen/logrus v1.7.0
 	github.com/spf13/viper v1.6.1
 	github.com/theckman/httpforwarded v0.4.0
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.7.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
 )
@@ -34,6 +34,7 @@ const (
 	TagConformance = "conformance"
 	TagAPI         = "api"
 	TagFunctions   = "functions"
+	TagSchema      = "schema"
 
 	OrderByDirSep = ":"
 	OrderByDirD   = "d"
@@ -47,6 +48,8 @@ const (
 	RelData        = "data"
 	RelFunctions   = "functions"
 	RelItems       = "items"
+	RelNext        = "next"
+	RelPrev        = "prev"
 
 	TitleFeaturesGeoJSON = "Features as GeoJSON"
 	TitleDataJSON        = "Data as JSON"
@@ -54,6 +57,10 @@ const (
 	TitleDocument        = "This document"
 	TitleAsJSON          = " as JSON"
 	TitleAsHTML          = " as HTML"
+	TitleAsFlatGeobuf    = " as FlatGeobuf"
+	TitleAsGML           = " as GML"
+	TitleNextPage        = "Next page"
+	TitlePrevPage        = "Previous page"
 
 	GeoJSONFeatureCollection = "FeatureCollection"
 )
@@ -87,6 +94,17 @@ const (
 	ErrMsgMalformedEtag                  = "Malformed etag detected %v"
 	ErrMsgCacheCleaningFailed            = "Server cache could not be cleaned"
 	ErrMsgWrongCrs                       = "CRS SRID invalid or unknown: %s"
+	ErrMsgCrsLatLon                      = "CRS in latitude/longitude order not supported for %v: %v (use CRS84)"
+	ErrMsgInvalidCursor                  = "Invalid cursor: %v"
+	ErrMsgTileMatrixSetNotFound          = "Tile matrix set not found: %v"
+	ErrMsgInvalidTile                    = "Invalid tile: %v"
+	ErrMsgUnauthorized                   = "Authentication required"
+	ErrMsgWriteNotAuthenticated          = "Write requests are not allowed without authentication"
+	ErrMsgInvalidToken                   = "Invalid access token: %v"
+	ErrMsgMissingAPIKey                  = "API key required"
+	ErrMsgInvalidAPIKey                  = "Invalid API key"
+	ErrMsgRateLimited                    = "Too many requests"
+	ErrMsgFilterTooLarge                 = "Filter too large (maximum %v bytes)"
 )
 
 // ==================================================
@@ -94,12 +112,16 @@ const (
 
 const (
 	ParamCrs                = "crs"
+	ParamCursor             = "cursor"
+	ParamDateTime           = "datetime"
 	ParamLimit              = "limit"
 	ParamOffset             = "offset"
 	ParamBbox               = "bbox"
 	ParamBboxCrs            = "bbox-crs"
 	ParamFilter             = "filter"
 	ParamFilterCrs          = "filter-crs"
+	ParamFilterLang         = "filter-lang"
+	ParamForce2D            = "force2d"
 	ParamGroupBy            = "groupby"
 	ParamOrderBy            = "orderby"
 	ParamPrecision          = "precision"
@@ -110,14 +132,24 @@ const (
 	ParamMaxAllowableOffset = "max-allowable-offset"
 )
 
+// filter languages
+const (
+	FilterLangCQL2Text = "cql2-text"
+	FilterLangCQL2JSON = "cql2-json"
+)
+
 // known query parameter name
 var ParamReservedNames = []string{
 	ParamCrs,
+	ParamCursor,
+	ParamDateTime,
 	ParamLimit,
 	ParamOffset,
 	ParamBbox,
 	ParamBboxCrs,
 	ParamFilter,
+	ParamFilterLang,
+	ParamForce2D,
 	ParamGroupBy,
 	ParamOrderBy,
 	ParamPrecision,
@@ -163,10 +195,11 @@ func NewRootInfo(conf *conf.Config) *RootInfo {
 
 // Link for links
 type Link struct {
-	Href  string `json:"href"`
-	Rel   string `json:"rel"`
-	Type  string `json:"type"`
-	Title string `json:"title"`
+	Href      string `json:"href"`
+	Rel       string `json:"rel"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Templated bool   `json:"templated,omitempty"`
 }
 
 func NewLink(href string, rel string, conType string, title string) *Link {
@@ -181,9 +214,21 @@ func NewLink(href string, rel string, conType string, title string) *Link {
 // =======================================================
 // =======================================================
 
-// Extent of a table
+// Extent of a table, or a bbox parameter.
+// Z bounds are present only if HasZ is set
 type Extent struct {
 	Minx, Miny, Maxx, Maxy float64
+	Minz, Maxz             float64
+	HasZ                   bool
+}
+
+// DateTime is a time interval, as ISO 8601 timestamps.
+// An instant has the same Start and End. An empty Start or End is an open bound
+type DateTime struct {
+	Start string
+	End   string
+	// EndExclusive excludes the End time, as for the day after a date
+	EndExclusive bool
 }
 
 type Sorting struct {
@@ -220,9 +265,16 @@ type Bbox struct {
 	Extent []float64 `json:"bbox"`
 }
 
+// OAPIF temporal extent. A nil bound is open
+type TemporalExtent struct {
+	Interval [][]*string `json:"interval"`
+	Trs      string      `json:"trs"`
+}
+
 // Extent OAPIF Extent structure (partial)
 type CollectionExtent struct {
-	Spatial *Bbox `json:"spatial"`
+	Spatial  *Bbox           `json:"spatial"`
+	Temporal *TemporalExtent `json:"temporal,omitempty"`
 }
 
 // CollectionsInfo for all collections
@@ -244,6 +296,7 @@ type CollectionInfo struct {
 	Description  string            `json:"description,omitempty"`
 	Extent       *CollectionExtent `json:"extent,omitempty"`
 	Crs          []string          `json:"crs,omitempty"`
+	StorageCrs   string            `json:"storageCrs,omitempty"`
 	GeometryType *string           `json:"geometrytype,omitempty"`
 
 	// these are omitempty so they don't show in summary metadata
@@ -276,13 +329,30 @@ type GeojsonFeatureData struct {
 	Geom     *geojson.Geometry      `json:"geometry"`
 	Props    map[string]interface{} `json:"properties"`
 	WeakEtag *WeakEtagData          `json:"-"`
+	// GeomJSON is the GeoJSON geometry read from the database (if any).
+	// It is output instead of Geom, since it keeps Z and M values
+	GeomJSON json.RawMessage `json:"-"`
+}
+
+// MarshalJSON encodes the feature, with the geometry read from the database if present
+func (feat GeojsonFeatureData) MarshalJSON() ([]byte, error) {
+	type featureData GeojsonFeatureData
+	if len(feat.GeomJSON) == 0 {
+		return json.Marshal(featureData(feat))
+	}
+	return json.Marshal(struct {
+		Type  string                 `json:"type"`
+		ID    string                 `json:"id,omitempty"`
+		Geom  json.RawMessage        `json:"geometry"`
+		Props map[string]interface{} `json:"properties"`
+	}{feat.Type, feat.ID, feat.GeomJSON, feat.Props})
 }
 
 // Define a FeatureCollection structure for parsing test data
 type FeatureCollection struct {
 	Type           string                `json:"type"`
 	Features       []*GeojsonFeatureData `json:"features"`
-	NumberMatched  uint                  `json:"numberMatched,omitempty"`
+	NumberMatched  *uint                 `json:"numberMatched,omitempty"`
 	NumberReturned uint                  `json:"numberReturned"`
 	TimeStamp      string                `json:"timeStamp,omitempty"`
 	Links          []*Link               `json:"links"`
@@ -318,13 +388,45 @@ func NewFeatureCollectionInfo(features []*GeojsonFeatureData) *FeatureCollection
 	doc := FeatureCollection{
 		Type:           GeoJSONFeatureCollection,
 		Features:       features,
-		NumberMatched:  0,
 		NumberReturned: uint(len(features)),
 		TimeStamp:      ts,
 	}
 	return &doc
 }
 
+// featureCollectionTail holds the FeatureCollection members
+// which follow the features array in a streamed response.
+// It must be kept in synch with the FeatureCollection type
+type featureCollectionTail struct {
+	NumberMatched  *uint   `json:"numberMatched,omitempty"`
+	NumberReturned uint    `json:"numberReturned"`
+	TimeStamp      string  `json:"timeStamp,omitempty"`
+	Links          []*Link `json:"links"`
+}
+
+// StreamHead returns the JSON text opening a FeatureCollection,
+// up to the start of the features array
+func (fc *FeatureCollection) StreamHead() []byte {
+	return []byte(fmt.Sprintf(`{"type":%q,"features":[`, fc.Type))
+}
+
+// StreamTail returns the JSON text closing the features array and the FeatureCollection.
+// It is computed once all features have been written, so it can report their number
+func (fc *FeatureCollection) StreamTail() ([]byte, error) {
+	tail := featureCollectionTail{
+		NumberMatched:  fc.NumberMatched,
+		NumberReturned: fc.NumberReturned,
+		TimeStamp:      fc.TimeStamp,
+		Links:          fc.Links,
+	}
+	encoded, err := json.Marshal(tail)
+	if err != nil {
+		return nil, err
+	}
+	// replace the opening brace of the tail object with the end of the features array
+	return append([]byte("],"), encoded[1:]...), nil
+}
+
 // =================================================
 // ================== Conformance ==================
 
@@ -338,6 +440,7 @@ var conformance = Conformance{
 		"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/oas3",
 		"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/geojson",
 		"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/html",
+		"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/gmlsf0",
 		"http://www.opengis.net/spec/ogcapi-common-1/1.0/conf/core",
 		"http://www.opengis.net/spec/ogcapi-common-1/1.0/conf/landing-page",
 		"http://www.opengis.net/spec/ogcapi-common-1/1.0/conf/json",
@@ -348,13 +451,24 @@ var conformance = Conformance{
 		"http://www.opengis.net/spec/ogcapi-features-2/1.0/conf/crs",
 		"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/filter",
 		"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/features-filter",
+		"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/queryables",
+		"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/queryables-query-parameters",
 		"http://www.opengis.net/spec/cql2/1.0/conf/basic-cql2",
+		"http://www.opengis.net/spec/cql2/1.0/conf/cql2-text",
+		"http://www.opengis.net/spec/cql2/1.0/conf/cql2-json",
 		"http://www.opengis.net/spec/cql2/1.0/conf/basic-spatial-operators",
 		"http://www.opengis.net/spec/cql2/1.0/conf/spatial-operators",
 		"http://www.opengis.net/spec/cql2/1.0/conf/temporal-operators",
 		"http://www.opengis.net/spec/cql2/1.0/conf/arithmetic",
 		"http://www.opengis.net/spec/ogcapi-features-4/1.0/conf/create-replace-delete",
 		"http://www.opengis.net/spec/ogcapi-features-4/1.0/conf/update",
+		"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/core",
+		"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/tileset",
+		"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/tilesets-list",
+		"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/geodata-tilesets",
+		"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/mvt",
+		"http://www.opengis.net/spec/tms/2.0/conf/tilematrixset",
+		"http://www.opengis.net/spec/tms/2.0/conf/json-tilematrixset",
 	},
 }
 
@@ -373,6 +487,10 @@ func PathCollectionItems(name string) string {
 	return fmt.Sprintf("%v/%v/%v", TagCollections, name, TagItems)
 }
 
+func PathCollectionSchema(name string) string {
+	return fmt.Sprintf("%v/%v/%v", TagCollections, name, TagSchema)
+}
+
 func PathFunction(name string) string {
 	return fmt.Sprintf("%v/%v", TagFunctions, name)
 }
@@ -0,0 +1,108 @@
+package api
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Coordinate reference systems identifiers (OGC API - Features - Part 2)
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HeaderContentCrs is the header for the CRS of a request or response body
+	HeaderContentCrs = "Content-Crs"
+	// HeaderAcceptCrs is the header for the CRS requested for a response body
+	HeaderAcceptCrs = "Accept-Crs"
+
+	// CrsURICRS84 is the default CRS, WGS 84 in longitude/latitude order
+	CrsURICRS84 = "http://www.opengis.net/def/crs/OGC/1.3/CRS84"
+	// CrsURIPrefixEPSG prefixes EPSG codes in CRS URIs
+	CrsURIPrefixEPSG = "http://www.opengis.net/def/crs/EPSG/0/"
+	// CrsURIEPSG4326 is WGS 84 in the latitude/longitude order of the EPSG definition
+	CrsURIEPSG4326 = CrsURIPrefixEPSG + "4326"
+
+	sridCRS84 = 4326
+	// maximum SRID value allowed by PostGIS
+	sridMax = 999999
+)
+
+// CrsURI is the URI for an SRID in x/y order. 4326 is CRS84
+func CrsURI(srid int) string {
+	if srid == sridCRS84 {
+		return CrsURICRS84
+	}
+	return CrsURIPrefixEPSG + strconv.Itoa(srid)
+}
+
+// CrsURIs is the list of CRS URIs for SRIDs, without duplicates
+func CrsURIs(srids ...int) []string {
+	var uris []string
+	isDone := make(map[int]bool)
+	for _, srid := range srids {
+		if isDone[srid] {
+			continue
+		}
+		isDone[srid] = true
+		uris = append(uris, CrsURI(srid))
+	}
+	return uris
+}
+
+/*
+ParseCrs parses a CRS to an SRID.
+The CRS is an SRID, an EPSG code (EPSG:3857), an OGC CRS URI
+or a safe CURIE ([EPSG:3857]), possibly in angle brackets as in a Content-Crs header.
+*/
+func ParseCrs(val string) (int, error) {
+	crs := trimCrs(val)
+	switch {
+	case crs == CrsURICRS84, strings.EqualFold(crs, "OGC:CRS84"):
+		return sridCRS84, nil
+	case strings.HasPrefix(crs, CrsURIPrefixEPSG):
+		crs = crs[len(CrsURIPrefixEPSG):]
+	case len(crs) > 5 && strings.EqualFold(crs[:5], "EPSG:"):
+		crs = crs[5:]
+	}
+	srid, err := strconv.Atoi(crs)
+	if err != nil || srid <= 0 || srid > sridMax {
+		return 0, fmt.Errorf(ErrMsgWrongCrs, val)
+	}
+	return srid, nil
+}
+
+// CrsURIAxis is the URI for an SRID, in latitude/longitude order if isLatLon is set
+func CrsURIAxis(srid int, isLatLon bool) string {
+	if isLatLon && srid == sridCRS84 {
+		return CrsURIEPSG4326
+	}
+	return CrsURI(srid)
+}
+
+/*
+IsCrsLatLon tests whether a CRS is WGS 84 in the latitude/longitude order of the EPSG definition,
+as EPSG:4326 and its URI are. An SRID alone (4326) is in longitude/latitude order, as CRS84.
+*/
+func IsCrsLatLon(val string) bool {
+	crs := trimCrs(val)
+	return crs == CrsURIEPSG4326 || strings.EqualFold(crs, "EPSG:4326")
+}
+
+// trimCrs removes the spaces and brackets around a CRS
+func trimCrs(val string) string {
+	crs := strings.TrimSpace(val)
+	crs = strings.TrimSuffix(strings.TrimPrefix(crs, "<"), ">")
+	return strings.TrimSuffix(strings.TrimPrefix(crs, "["), "]")
+}
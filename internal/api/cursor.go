@@ -0,0 +1,129 @@
+package api
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Cursor holds the keyset column values of the last feature of a page.
+// It is passed to the request for the next page as an opaque token,
+// so that the page starts after this feature without using OFFSET.
+// A nil value is a NULL
+type Cursor struct {
+	Columns []string  `json:"c"`
+	Values  []*string `json:"v"`
+}
+
+// KeysetColumns returns the columns providing a unique ordering of features:
+// the sort column (if any) followed by the ID column.
+// It returns nil if there is no ID column
+func KeysetColumns(sortBy []Sorting, idColumn string) []string {
+	if idColumn == "" {
+		return nil
+	}
+	// only a single sort column is supported (as in ORDER BY)
+	if len(sortBy) > 0 && sortBy[0].Name != idColumn {
+		return []string{sortBy[0].Name, idColumn}
+	}
+	return []string{idColumn}
+}
+
+// MakeCursor creates the cursor positioned after a feature.
+// It returns nil if a keyset value is not available in the feature
+func MakeCursor(columns []string, idColumn string, feature *GeojsonFeatureData) *Cursor {
+	values := make([]*string, len(columns))
+	for i, col := range columns {
+		if col == idColumn {
+			if feature.ID == "" {
+				return nil
+			}
+			id := feature.ID
+			values[i] = &id
+			continue
+		}
+		prop, ok := feature.Props[col]
+		if !ok {
+			return nil
+		}
+		if prop == nil {
+			continue
+		}
+		val, ok := cursorValue(prop)
+		if !ok {
+			return nil
+		}
+		values[i] = &val
+	}
+	return &Cursor{Columns: columns, Values: values}
+}
+
+// cursorValue converts a property value to the text representation used in queries.
+// Times are in RFC 3339 format, with the full precision of the database.
+// Structured values (arrays and JSON) cannot be used in a cursor
+func cursorValue(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case int, int16, int32, int64, bool:
+		return fmt.Sprintf("%v", v), true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+// ToEncodedString returns the opaque token for a cursor
+func (cursor *Cursor) ToEncodedString() string {
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// DecodeCursor decodes a cursor token
+func DecodeCursor(encoded string) (*Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("cursor is not correctly encoded")
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, errors.New("cursor is not correctly encoded")
+	}
+	if len(cursor.Columns) == 0 || len(cursor.Columns) != len(cursor.Values) {
+		return nil, errors.New("cursor contains a wrong number of elements")
+	}
+	return &cursor, nil
+}
+
+// IsForColumns tests whether a cursor was created for the given keyset columns
+func (cursor *Cursor) IsForColumns(columns []string) bool {
+	if len(cursor.Columns) != len(columns) {
+		return false
+	}
+	for i, col := range columns {
+		if cursor.Columns[i] != col {
+			return false
+		}
+	}
+	return true
+}
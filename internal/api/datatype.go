@@ -108,6 +108,15 @@ func (dbType PGType) ToJSONType() JSONType {
 	}
 }
 
+// tests if PGType holds dates or timestamps
+func (dbType PGType) IsTemporal() bool {
+	switch dbType {
+	case PGTypeDate, PGTypeTimeStamp, PGTypeTimeStampTZ:
+		return true
+	}
+	return false
+}
+
 // creates openapi schema type according to PGType
 func (dbType PGType) ToOpenApiSchema() *openapi3.Schema {
 	//fmt.Printf("ToOpenApiType: %v\n", pgType)
@@ -162,6 +171,29 @@ func (dbType PGType) ToOpenApiSchema() *openapi3.Schema {
 	}
 }
 
+// returns the XML Schema type matching PGType.
+// Arrays and JSON values are encoded as text
+func (dbType PGType) ToXSDType() string {
+	switch dbType {
+	case PGTypeBool:
+		return "xs:boolean"
+	case PGTypeInt, PGTypeInt4:
+		return "xs:int"
+	case PGTypeInt8, PGTypeBigInt:
+		return "xs:long"
+	case PGTypeFloat4:
+		return "xs:float"
+	case PGTypeFloat8, PGTypeNumeric:
+		return "xs:double"
+	case PGTypeDate:
+		return "xs:date"
+	case PGTypeTimeStamp, PGTypeTimeStampTZ:
+		return "xs:dateTime"
+	default:
+		return "xs:string"
+	}
+}
+
 // converts json marshalled interface to valid object according to PGType
 func (dbType PGType) ParseJSONInterface(val interface{}) (interface{}, error) {
 	var convVal interface{}
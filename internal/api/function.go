@@ -98,6 +98,8 @@ type Function struct {
 	Types          map[string]PGType
 	GeometryColumn string
 	IDColumn       string
+	// Source is the name of the data source holding the function, blank for the default source
+	Source string
 }
 
 func (fun *Function) IsGeometryFunction() bool {
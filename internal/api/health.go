@@ -0,0 +1,65 @@
+package api
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+
+	// names of the components checked for readiness
+	HealthDatabase = "database"
+	HealthCatalog  = "catalog"
+	HealthListener = "listener"
+	HealthCache    = "cache"
+)
+
+// Health is the status of the service, and of the components it depends on
+type Health struct {
+	Status     string                      `json:"status"`
+	Components map[string]*HealthComponent `json:"components,omitempty"`
+}
+
+// HealthComponent is the status of a component
+type HealthComponent struct {
+	Status string `json:"status"`
+	// Message describes the component state, or the failure
+	Message string `json:"message,omitempty"`
+}
+
+// NewHealth creates the health status of a set of components.
+// The service is healthy if all components are
+func NewHealth(components map[string]*HealthComponent) *Health {
+	health := &Health{Status: HealthStatusOK, Components: components}
+	for _, comp := range components {
+		if comp.Status != HealthStatusOK {
+			health.Status = HealthStatusFail
+		}
+	}
+	return health
+}
+
+// HealthOK is a healthy component status
+func HealthOK(message string) *HealthComponent {
+	return &HealthComponent{Status: HealthStatusOK, Message: message}
+}
+
+// HealthFail is a failed component status
+func HealthFail(message string) *HealthComponent {
+	return &HealthComponent{Status: HealthStatusFail, Message: message}
+}
+
+// IsOK tests whether the service is healthy
+func (h *Health) IsOK() bool {
+	return h.Status == HealthStatusOK
+}
@@ -34,6 +34,12 @@ const (
 	// ContentTypeGML
 	ContentTypeGML = "application/gml+xml"
 
+	// ContentTypeGMLSF0 is GML using the Simple Features Level 0 profile
+	ContentTypeGMLSF0 = "application/gml+xml; version=3.2; profile=http://www.opengis.net/def/profile/ogc/2.0/gml-sf0"
+
+	// ContentTypeXSD
+	ContentTypeXSD = "application/xml"
+
 	// ContentTypeSchemaJSON
 	ContentTypeSchemaJSON = "application/schema+json"
 
@@ -49,6 +55,21 @@ const (
 	// ContentTypeSVG
 	ContentTypeSVG = "image/svg+xml"
 
+	// ContentTypeMVT
+	ContentTypeMVT = "application/vnd.mapbox-vector-tile"
+
+	// ContentTypeFlatGeobuf
+	ContentTypeFlatGeobuf = "application/flatgeobuf"
+
+	// ContentTypeCSV
+	ContentTypeCSV = "text/csv"
+
+	// ContentTypeCQL2JSON is a CQL2-JSON filter in a request body
+	ContentTypeCQL2JSON = "application/cql2+json"
+
+	// ContentTypeCQL2Text is a CQL2-Text filter in a request body
+	ContentTypeCQL2Text = "text/cql2"
+
 	// ContentTypeHTML
 	ContentTypeOpenAPI = "application/vnd.oai.openapi+json;version=3.0"
 
@@ -69,6 +90,15 @@ const (
 
 	// FormatXML code and extension for XML/GML
 	FormatXML = "xml"
+
+	// FormatXSD code and extension for XML Schema
+	FormatXSD = "xsd"
+
+	// FormatFlatGeobuf code and extension for FlatGeobuf
+	FormatFlatGeobuf = "fgb"
+
+	// FormatCSV code and extension for CSV
+	FormatCSV = "csv"
 )
 
 // RequestedFormat gets the format for a request from extension or headers
@@ -88,6 +118,14 @@ func RequestedFormat(r *http.Request) string {
 			return FormatText
 		case "svg":
 			return FormatSVG
+		case "fgb":
+			return FormatFlatGeobuf
+		case "csv":
+			return FormatCSV
+		case "xml", "gml":
+			return FormatXML
+		case "xsd":
+			return FormatXSD
 		}
 
 	}
@@ -103,10 +141,11 @@ func RequestedFormat(r *http.Request) string {
 		// "Accept: text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
 		preferredFormats := strings.Split(hdrAcceptValue, ",")
 		for _, value := range preferredFormats {
-			mediaTypeValue := value
-			lastSemicolon := strings.LastIndex(value, ";")
-			if lastSemicolon > 0 {
-				mediaTypeValue = value[:lastSemicolon] // 'q' quality parameter not used
+			mediaTypeValue := strings.TrimSpace(value)
+			firstSemicolon := strings.Index(mediaTypeValue, ";")
+			if firstSemicolon > 0 {
+				// media type parameters and 'q' quality parameter not used
+				mediaTypeValue = strings.TrimSpace(mediaTypeValue[:firstSemicolon])
 			}
 			switch mediaTypeValue {
 			case ContentTypeJSON:
@@ -119,6 +158,12 @@ func RequestedFormat(r *http.Request) string {
 				return FormatText
 			case ContentTypeSVG:
 				return FormatSVG
+			case ContentTypeFlatGeobuf:
+				return FormatFlatGeobuf
+			case ContentTypeCSV:
+				return FormatCSV
+			case ContentTypeGML:
+				return FormatXML
 			}
 		}
 	}
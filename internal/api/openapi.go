@@ -352,10 +352,50 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 			AllowEmptyValue: false,
 		},
 	}
+	paramTileMatrixSetID := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:            "tileMatrixSetId",
+			Description:     "Identifier of the tile matrix set (only WebMercatorQuad is supported).",
+			In:              "path",
+			Required:        true,
+			Schema:          &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+			AllowEmptyValue: false,
+		},
+	}
+	paramTileMatrix := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:            "tileMatrix",
+			Description:     "Zoom level of the tile.",
+			In:              "path",
+			Required:        true,
+			Schema:          &openapi3.SchemaRef{Value: openapi3.NewIntegerSchema()},
+			AllowEmptyValue: false,
+		},
+	}
+	paramTileRow := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:            "tileRow",
+			Description:     "Row index of the tile in the tile matrix.",
+			In:              "path",
+			Required:        true,
+			Schema:          &openapi3.SchemaRef{Value: openapi3.NewIntegerSchema()},
+			AllowEmptyValue: false,
+		},
+	}
+	paramTileCol := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:            "tileCol",
+			Description:     "Column index of the tile in the tile matrix.",
+			In:              "path",
+			Required:        true,
+			Schema:          &openapi3.SchemaRef{Value: openapi3.NewIntegerSchema()},
+			AllowEmptyValue: false,
+		},
+	}
 	paramBbox := openapi3.ParameterRef{
 		Value: &openapi3.Parameter{
 			Name:        "bbox",
-			Description: "Bounding box to restrict results to given extent (as minLon,minLat,maxLon,maxLat).",
+			Description: "Bounding box to restrict results to given extent (as minLon,minLat,maxLon,maxLat or minLon,minLat,minZ,maxLon,maxLat,maxZ).",
 			In:          "query",
 			Required:    false,
 			Explode:     openapi3.BoolPtr(false),
@@ -364,7 +404,7 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 				Value: &openapi3.Schema{
 					Type:     "array",
 					MinItems: 4,
-					MaxItems: openapi3.Uint64Ptr(4),
+					MaxItems: openapi3.Uint64Ptr(6),
 					Items:    openapi3.NewSchemaRef("", openapi3.NewFloat64Schema().WithMin(-180).WithMax(180)),
 				},
 			},
@@ -374,19 +414,29 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 	paramBboxCrs := openapi3.ParameterRef{
 		Value: &openapi3.Parameter{
 			Name:        "bbox-crs",
-			Description: "SRID for coordinate reference system of bbox parameter.",
+			Description: "Coordinate reference system of bbox parameter (as CRS URI, EPSG code or SRID).",
 			In:          "query",
 			Required:    false,
 			Schema: &openapi3.SchemaRef{
 				Value: &openapi3.Schema{
-					Type:    "integer",
-					Min:     openapi3.Float64Ptr(1),
-					Default: 4326,
+					Type:    "string",
+					Default: CrsURICRS84,
 				},
 			},
 			AllowEmptyValue: false,
 		},
 	}
+	paramDateTime := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:            "datetime",
+			Description:     "Time instant or interval (as start/end, with .. for an open bound) to restrict results to.",
+			In:              "query",
+			Required:        false,
+			Example:         "2020-01-01T00:00:00Z/..",
+			Schema:          &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+			AllowEmptyValue: false,
+		},
+	}
 	paramFilter := openapi3.ParameterRef{
 		Value: &openapi3.Parameter{
 			Name:            "filter",
@@ -397,17 +447,32 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 			AllowEmptyValue: false,
 		},
 	}
+	paramFilterLang := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:        "filter-lang",
+			Description: "Language of the filter: cql2-text or cql2-json.",
+			In:          "query",
+			Required:    false,
+			Schema: &openapi3.SchemaRef{
+				Value: &openapi3.Schema{
+					Type:    "string",
+					Enum:    []interface{}{FilterLangCQL2Text, FilterLangCQL2JSON},
+					Default: FilterLangCQL2Text,
+				},
+			},
+			AllowEmptyValue: false,
+		},
+	}
 	paramFilterCrs := openapi3.ParameterRef{
 		Value: &openapi3.Parameter{
 			Name:        "filter-crs",
-			Description: "SRID for filter geometry literals.",
+			Description: "Coordinate reference system of filter geometry literals (as CRS URI, EPSG code or SRID).",
 			In:          "query",
 			Required:    false,
 			Schema: &openapi3.SchemaRef{
 				Value: &openapi3.Schema{
-					Type:    "integer",
-					Min:     openapi3.Float64Ptr(1),
-					Default: 4326,
+					Type:    "string",
+					Default: CrsURICRS84,
 				},
 			},
 			AllowEmptyValue: false,
@@ -454,14 +519,13 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 	paramCrs := openapi3.ParameterRef{
 		Value: &openapi3.Parameter{
 			Name:        "crs",
-			Description: "SRID for coordinate reference system of output features.",
+			Description: "Coordinate reference system of output features (as CRS URI, EPSG code or SRID).",
 			In:          "query",
 			Required:    false,
 			Schema: &openapi3.SchemaRef{
 				Value: &openapi3.Schema{
-					Type:    "integer",
-					Min:     openapi3.Float64Ptr(1),
-					Default: 4326,
+					Type:    "string",
+					Default: CrsURICRS84,
 				},
 			},
 			AllowEmptyValue: false,
@@ -470,19 +534,42 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 	paramContentCrs := openapi3.ParameterRef{
 		Value: &openapi3.Parameter{
 			Name:        "Content-Crs",
-			Description: "SRID for coordinate reference system of output features.",
+			Description: "Coordinate reference system of input features (as CRS URI, EPSG code or SRID).",
 			In:          "header",
 			Required:    false,
 			Schema: &openapi3.SchemaRef{
 				Value: &openapi3.Schema{
-					Type:    "integer",
-					Min:     openapi3.Float64Ptr(1),
-					Default: 4326,
+					Type:    "string",
+					Default: CrsURICRS84,
+				},
+			},
+			AllowEmptyValue: false,
+		},
+	}
+	paramForce2D := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:        "force2d",
+			Description: "Drop the Z and M values of output geometries.",
+			In:          "query",
+			Required:    false,
+			Schema: &openapi3.SchemaRef{
+				Value: &openapi3.Schema{
+					Type:    "boolean",
+					Default: false,
 				},
 			},
 			AllowEmptyValue: false,
 		},
 	}
+	paramAcceptCrs := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:        "Accept-Crs",
+			Description: "Coordinate reference system of output features, if the crs parameter is not present.",
+			In:          "header",
+			Required:    false,
+			Schema:      &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+		},
+	}
 	paramLimit := openapi3.ParameterRef{
 		Value: &openapi3.Parameter{
 			Name:        "limit",
@@ -493,8 +580,8 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 				Value: &openapi3.Schema{
 					Type:    "integer",
 					Min:     openapi3.Float64Ptr(0),
-					Max:     openapi3.Float64Ptr(float64(conf.Configuration.Paging.LimitMax)),
-					Default: conf.Configuration.Paging.LimitDefault,
+					Max:     openapi3.Float64Ptr(float64(conf.Current().Paging.LimitMax)),
+					Default: conf.Current().Paging.LimitDefault,
 				},
 			},
 			AllowEmptyValue: false,
@@ -510,8 +597,8 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 				Value: &openapi3.Schema{
 					Type:    "number",
 					Min:     openapi3.Float64Ptr(0),
-					Max:     openapi3.Float64Ptr(float64(conf.Configuration.Paging.LimitMax)),
-					Default: conf.Configuration.Paging.LimitDefault,
+					Max:     openapi3.Float64Ptr(float64(conf.Current().Paging.LimitMax)),
+					Default: conf.Current().Paging.LimitDefault,
 				},
 			},
 			AllowEmptyValue: false,
@@ -527,13 +614,23 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 				Value: &openapi3.Schema{
 					Type: "integer",
 					Min:  openapi3.Float64Ptr(0),
-					//Max:     openapi3.Float64Ptr(float64(conf.Configuration.Paging.LimitMax)),
+					//Max:     openapi3.Float64Ptr(float64(conf.Current().Paging.LimitMax)),
 					Default: 0,
 				},
 			},
 			AllowEmptyValue: false,
 		},
 	}
+	paramCursor := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:            "cursor",
+			Description:     "Opaque token of the feature after which results start, as provided in the next link.",
+			In:              "query",
+			Required:        false,
+			Schema:          &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+			AllowEmptyValue: false,
+		},
+	}
 	paramSortBy := openapi3.ParameterRef{
 		Value: &openapi3.Parameter{
 			Name:            "sortby",
@@ -566,6 +663,38 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 		},
 	}
 
+	// the format of items can be given by the path extension, instead of the Accept header
+	paramItemsFormat := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:        "format",
+			Description: "Format of the features: json (GeoJSON), html, fgb (FlatGeobuf), csv, xml or gml (GML).",
+			In:          "path",
+			Required:    true,
+			Schema: &openapi3.SchemaRef{
+				Value: &openapi3.Schema{
+					Type: "string",
+					Enum: []interface{}{"json", "html", FormatFlatGeobuf, FormatCSV, "xml", "gml"},
+				},
+			},
+			AllowEmptyValue: false,
+		},
+	}
+	paramFunctionItemsFormat := openapi3.ParameterRef{
+		Value: &openapi3.Parameter{
+			Name:        "format",
+			Description: "Format of the function results: json (GeoJSON or JSON), html, txt, svg, fgb (FlatGeobuf, for geometry functions) or csv.",
+			In:          "path",
+			Required:    true,
+			Schema: &openapi3.SchemaRef{
+				Value: &openapi3.Schema{
+					Type: "string",
+					Enum: []interface{}{"json", "html", "txt", "svg", FormatFlatGeobuf, FormatCSV},
+				},
+			},
+			AllowEmptyValue: false,
+		},
+	}
+
 	rootDesc := "Results for root of API"
 	apiDesc := "openapi content"
 	conformanceDesc := "Results for conformance classes"
@@ -579,6 +708,10 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 	getItemResponseDesc := "GeoJSON Feature document containing feature data"
 	getItemEtagResponseDesc := "Strong etag value associated to the requested feature"
 	getItemDateResponseDesc := "Last modification date for the returned feature (Http date format)"
+	getTileResponseDesc := "Mapbox Vector Tile containing the features of the collection in the tile"
+	getTileEtagResponseDesc := "Strong etag value associated to the requested tile"
+	queryablesResponseDesc := "JSON Schema document describing the queryable properties"
+	sortablesResponseDesc := "JSON Schema document describing the sortable properties"
 	responseHttp204Desc := "No Content : feature updated"
 	responseHttp400Desc := "Malformed feature ID or unsuitable query parameters"
 	responseHttp404Desc := "Resource not found"
@@ -586,11 +719,108 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 	getFunctionResponseDesc := "Results for details about the specified function"
 	getFunctionResultResponseDesc := "GeoJSON or JSON document containing function results"
 
+	opCollectionFeatures := &openapi3.Operation{
+		OperationID: "getCollectionFeatures",
+		Parameters: openapi3.Parameters{
+			&paramCollectionID,
+			&paramBbox,
+			&paramBboxCrs,
+			&paramDateTime,
+			&paramFilter,
+			&paramFilterLang,
+			&paramFilterCrs,
+			&paramTransform,
+			&paramProperties,
+			&paramSortBy,
+			&paramCrs,
+			&paramAcceptCrs,
+			&paramForce2D,
+			&paramLimit,
+			&paramOffset,
+			&paramCursor,
+			&paramMaxAllowableOffset,
+			/* TODO
+			&openapi3.ParameterRef{
+				Value: &openapi3.Parameter{
+					Name:            "<prop-name>",
+					Description:     "Any feature property name may be filtered on by including it as a query parameter",
+					In:              "query",
+					Required:        false,
+					Schema:          &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+					AllowEmptyValue: false,
+				},
+			},
+			*/
+		},
+		Responses: openapi3.Responses{
+			"200": &openapi3.ResponseRef{
+				Value: &openapi3.Response{
+					Description: &collectionFeatureResponseDesc,
+					Content: openapi3.Content{
+						ContentTypeGeoJSON:    openapi3.NewMediaType().WithSchemaRef(&openapi3.SchemaRef{Ref: "http://geojson.org/schema/FeatureCollection.json"}),
+						ContentTypeFlatGeobuf: openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema().WithFormat("binary")),
+						ContentTypeCSV:        openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema()),
+						ContentTypeGML:        openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema()),
+						ContentTypeHTML:       openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema()),
+					},
+				},
+			},
+		},
+	}
+	opFunctionFeatures := &openapi3.Operation{
+		OperationID: "getFunctionFeatures",
+		Parameters: openapi3.Parameters{
+			&paramFunctionID,
+			&paramBbox,
+			&paramBboxCrs,
+			&paramFilter,
+			&paramFilterLang,
+			&paramFilterCrs,
+			&paramTransform,
+			&paramProperties,
+			&paramSortBy,
+			&paramCrs,
+			&paramAcceptCrs,
+			&paramForce2D,
+			&paramLimit,
+			&paramOffset,
+
+			/* TODO
+			&openapi3.ParameterRef{
+				Value: &openapi3.Parameter{
+					Name:            "<prop-name>",
+					Description:     "Any feature property name may be filtered on by including it as a query parameter",
+					In:              "query",
+					Required:        false,
+					Schema:          &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+					AllowEmptyValue: false,
+				},
+			},
+			*/
+		},
+		Responses: openapi3.Responses{
+			"200": &openapi3.ResponseRef{
+				Value: &openapi3.Response{
+					Description: &getFunctionResultResponseDesc,
+					Content: openapi3.Content{
+						ContentTypeGeoJSON:    openapi3.NewMediaType().WithSchemaRef(&openapi3.SchemaRef{Ref: "http://geojson.org/schema/FeatureCollection.json"}),
+						ContentTypeJSON:       openapi3.NewMediaType().WithSchema(openapi3.NewArraySchema()),
+						ContentTypeFlatGeobuf: openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema().WithFormat("binary")),
+						ContentTypeCSV:        openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema()),
+						ContentTypeText:       openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema()),
+						ContentTypeSVG:        openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema()),
+						ContentTypeHTML:       openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema()),
+					},
+				},
+			},
+		},
+	}
+
 	return &openapi3.T{
 		OpenAPI: "3.0.0",
 		Info: &openapi3.Info{
-			Title:       conf.Configuration.Metadata.Title,
-			Description: conf.Configuration.Metadata.Description,
+			Title:       conf.Current().Metadata.Title,
+			Description: conf.Current().Metadata.Description,
 			Version:     conf.AppConfig.Version,
 			License: &openapi3.License{
 				Name: "Apache 2.0",
@@ -713,50 +943,7 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 			apiBase + "collections/{collectionId}/items": &openapi3.PathItem{
 				Summary:     "Feature data for collection",
 				Description: "Provides paged access to data for all features in specified collection",
-				Get: &openapi3.Operation{
-					OperationID: "getCollectionFeatures",
-					Parameters: openapi3.Parameters{
-						&paramCollectionID,
-						&paramBbox,
-						&paramBboxCrs,
-						&paramFilter,
-						&paramFilterCrs,
-						&paramTransform,
-						&paramProperties,
-						&paramSortBy,
-						&paramCrs,
-						&paramLimit,
-						&paramOffset,
-						&paramMaxAllowableOffset,
-						/* TODO
-						&openapi3.ParameterRef{
-							Value: &openapi3.Parameter{
-								Name:            "<prop-name>",
-								Description:     "Any feature property name may be filtered on by including it as a query parameter",
-								In:              "query",
-								Required:        false,
-								Schema:          &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
-								AllowEmptyValue: false,
-							},
-						},
-						*/
-					},
-					Responses: openapi3.Responses{
-						"200": &openapi3.ResponseRef{
-							Value: &openapi3.Response{
-								Description: &collectionFeatureResponseDesc,
-								/*
-									// TODO: create schema for result?
-									Content: openapi3.NewContentWithJSONSchemaRef(
-										&openapi3.SchemaRef{
-											Ref: "http://geojson.org/schema/FeatureCollection.json",
-										},
-									),
-								*/
-							},
-						},
-					},
-				},
+				Get:         opCollectionFeatures,
 				Post: &openapi3.Operation{
 					OperationID: "createCollectionFeature",
 					Parameters: openapi3.Parameters{
@@ -815,6 +1002,45 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 					},
 				},
 			},
+			apiBase + "collections/{collectionId}/queryables": &openapi3.PathItem{
+				Summary:     "Queryable properties of collection",
+				Description: "Provides the JSON Schema of the properties which can be used in filters",
+				Get: &openapi3.Operation{
+					OperationID: "getCollectionQueryables",
+					Parameters: openapi3.Parameters{
+						&paramCollectionID,
+					},
+					Responses: openapi3.Responses{
+						"200": &openapi3.ResponseRef{
+							Value: &openapi3.Response{
+								Description: &queryablesResponseDesc,
+							},
+						},
+					},
+				},
+			},
+			apiBase + "collections/{collectionId}/sortables": &openapi3.PathItem{
+				Summary:     "Sortable properties of collection",
+				Description: "Provides the JSON Schema of the properties which can be used to sort features",
+				Get: &openapi3.Operation{
+					OperationID: "getCollectionSortables",
+					Parameters: openapi3.Parameters{
+						&paramCollectionID,
+					},
+					Responses: openapi3.Responses{
+						"200": &openapi3.ResponseRef{
+							Value: &openapi3.Response{
+								Description: &sortablesResponseDesc,
+							},
+						},
+					},
+				},
+			},
+			apiBase + "collections/{collectionId}/items.{format}": &openapi3.PathItem{
+				Summary:     "Feature data for collection, in a format",
+				Description: "Provides paged access to data for all features in specified collection, in the format given by the path extension",
+				Get:         withFormatParam(opCollectionFeatures, &paramItemsFormat),
+			},
 			apiBase + "collections/{collectionId}/items/{featureId}": &openapi3.PathItem{
 				Summary:     "Single feature data from collection",
 				Description: "Provides access to a single feature identitfied by {featureId} from the specified collection",
@@ -826,6 +1052,8 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 						&paramProperties,
 						&paramTransform,
 						&paramCrs,
+						&paramAcceptCrs,
+						&paramForce2D,
 						&paramMaxAllowableOffset,
 					},
 					Responses: openapi3.Responses{
@@ -952,6 +1180,53 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 					},
 				},
 			},
+			apiBase + "collections/{collectionId}/tiles/{tileMatrixSetId}/{tileMatrix}/{tileCol}/{tileRow}": &openapi3.PathItem{
+				Summary:     "Vector tile of collection",
+				Description: "Provides the features of the specified collection in a tile, as a Mapbox Vector Tile",
+				Get: &openapi3.Operation{
+					OperationID: "getCollectionTile",
+					Parameters: openapi3.Parameters{
+						&paramCollectionID,
+						&paramTileMatrixSetID,
+						&paramTileMatrix,
+						&paramTileCol,
+						&paramTileRow,
+						&paramProperties,
+						&paramDateTime,
+						&paramFilter,
+						&paramFilterLang,
+					},
+					Responses: openapi3.Responses{
+						"200": &openapi3.ResponseRef{
+							Value: &openapi3.Response{
+								Description: &getTileResponseDesc,
+								Headers: map[string]*openapi3.HeaderRef{
+									"Etag": {
+										Value: &openapi3.Header{
+											Parameter: openapi3.Parameter{
+												Description: getTileEtagResponseDesc,
+												Schema: &openapi3.SchemaRef{
+													Value: openapi3.NewBytesSchema(),
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+						"400": &openapi3.ResponseRef{
+							Value: &openapi3.Response{
+								Description: &responseHttp400Desc,
+							},
+						},
+						"404": &openapi3.ResponseRef{
+							Value: &openapi3.Response{
+								Description: &responseHttp404Desc,
+							},
+						},
+					},
+				},
+			},
 			apiBase + "functions": &openapi3.PathItem{
 				Summary:     "Functions metadata",
 				Description: "Provides details about functions served",
@@ -989,57 +1264,62 @@ func GetOpenAPIContent(urlBase string) *openapi3.T {
 					},
 				},
 			},
-			apiBase + "functions/{functionId}/items": &openapi3.PathItem{
-				Summary:     "Features or data for a function result",
-				Description: "Provides paged access to data in specified function result",
+			apiBase + "functions/{functionId}/queryables": &openapi3.PathItem{
+				Summary:     "Queryable properties of function",
+				Description: "Provides the JSON Schema of the function properties which can be used in filters",
 				Get: &openapi3.Operation{
-					OperationID: "getFunctionFeatures",
+					OperationID: "getFunctionQueryables",
 					Parameters: openapi3.Parameters{
 						&paramFunctionID,
-						&paramBbox,
-						&paramBboxCrs,
-						&paramFilter,
-						&paramFilterCrs,
-						&paramTransform,
-						&paramProperties,
-						&paramSortBy,
-						&paramCrs,
-						&paramLimit,
-						&paramOffset,
-
-						/* TODO
-						&openapi3.ParameterRef{
-							Value: &openapi3.Parameter{
-								Name:            "<prop-name>",
-								Description:     "Any feature property name may be filtered on by including it as a query parameter",
-								In:              "query",
-								Required:        false,
-								Schema:          &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
-								AllowEmptyValue: false,
+					},
+					Responses: openapi3.Responses{
+						"200": &openapi3.ResponseRef{
+							Value: &openapi3.Response{
+								Description: &queryablesResponseDesc,
 							},
 						},
-						*/
+					},
+				},
+			},
+			apiBase + "functions/{functionId}/sortables": &openapi3.PathItem{
+				Summary:     "Sortable properties of function",
+				Description: "Provides the JSON Schema of the function properties which can be used to sort results",
+				Get: &openapi3.Operation{
+					OperationID: "getFunctionSortables",
+					Parameters: openapi3.Parameters{
+						&paramFunctionID,
 					},
 					Responses: openapi3.Responses{
 						"200": &openapi3.ResponseRef{
 							Value: &openapi3.Response{
-								Description: &getFunctionResultResponseDesc,
-								/*
-									Content: openapi3.NewContentWithJSONSchemaRef(
-										&openapi3.SchemaRef{
-											Ref: "http://geojson.org/schema/FeatureCollection.json",
-										},
-									),
-								*/
+								Description: &sortablesResponseDesc,
 							},
 						},
 					},
 				},
 			},
+			apiBase + "functions/{functionId}/items": &openapi3.PathItem{
+				Summary:     "Features or data for a function result",
+				Description: "Provides paged access to data in specified function result",
+				Get:         opFunctionFeatures,
+			},
+			apiBase + "functions/{functionId}/items.{format}": &openapi3.PathItem{
+				Summary:     "Features or data for a function result, in a format",
+				Description: "Provides paged access to data in specified function result, in the format given by the path extension",
+				Get:         withFormatParam(opFunctionFeatures, &paramFunctionItemsFormat),
+			},
 		},
 	}
 }
 
+// withFormatParam copies an operation for the path ending with a format extension
+func withFormatParam(op *openapi3.Operation, paramFormat *openapi3.ParameterRef) *openapi3.Operation {
+	opFormat := *op
+	opFormat.OperationID = op.OperationID + "Format"
+	opFormat.Parameters = append(openapi3.Parameters{paramFormat}, op.Parameters...)
+	return &opFormat
+}
+
 func makeGeojsonSchemaRefs() map[string]*openapi3.SchemaRef {
 	out := make(map[string]*openapi3.SchemaRef)
 
@@ -0,0 +1,161 @@
+package api
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import "fmt"
+
+const (
+	TagQueryables = "queryables"
+	TagSortables  = "sortables"
+
+	RelQueryables = "http://www.opengis.net/def/rel/ogc/1.0/queryables"
+	RelSortables  = "http://www.opengis.net/def/rel/ogc/1.0/sortables"
+
+	TitleQueryables = "Queryable properties"
+	TitleSortables  = "Sortable properties"
+
+	// JSONSchemaDialect is the JSON Schema version of queryables and sortables
+	JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+)
+
+// PropertiesSchema is a JSON Schema document describing the properties
+// which can be used in filters (queryables) or to sort (sortables)
+type PropertiesSchema struct {
+	Schema               string                      `json:"$schema"`
+	ID                   string                      `json:"$id"`
+	Type                 string                      `json:"type"`
+	Title                string                      `json:"title,omitempty"`
+	Properties           map[string]*QueryableSchema `json:"properties"`
+	AdditionalProperties bool                        `json:"additionalProperties"`
+}
+
+// QueryableSchema is the JSON Schema of a queryable or sortable property
+type QueryableSchema struct {
+	Ref    string           `json:"$ref,omitempty"`
+	Title  string           `json:"title,omitempty"`
+	Type   string           `json:"type,omitempty"`
+	Format string           `json:"format,omitempty"`
+	Items  *QueryableSchema `json:"items,omitempty"`
+}
+
+func newPropertiesSchema(id string, title string) *PropertiesSchema {
+	return &PropertiesSchema{
+		Schema:     JSONSchemaDialect,
+		ID:         id,
+		Type:       "object",
+		Title:      title,
+		Properties: map[string]*QueryableSchema{},
+	}
+}
+
+// TableQueryables creates the queryables of a table, including its geometry
+func (tbl *Table) TableQueryables(id string) *PropertiesSchema {
+	doc := newPropertiesSchema(id, tbl.Title)
+	if tbl.GeometryColumn != "" {
+		doc.Properties[tbl.GeometryColumn] = GeometryPropertySchema(tbl.GeometryType)
+	}
+	for i, name := range tbl.Columns {
+		prop := tbl.JSONTypes[i].ToPropertySchema(tbl.DbTypes[name].Type)
+		prop.Title = tbl.ColDesc[i]
+		doc.Properties[name] = prop
+	}
+	return doc
+}
+
+// TableSortables creates the sortables of a table, which are its properties with a scalar value
+func (tbl *Table) TableSortables(id string) *PropertiesSchema {
+	doc := newPropertiesSchema(id, tbl.Title)
+	for i, name := range tbl.Columns {
+		if !tbl.JSONTypes[i].IsSortable() {
+			continue
+		}
+		prop := tbl.JSONTypes[i].ToPropertySchema(tbl.DbTypes[name].Type)
+		prop.Title = tbl.ColDesc[i]
+		doc.Properties[name] = prop
+	}
+	return doc
+}
+
+// FunctionQueryables creates the queryables of a function, from its output properties
+func (fn *Function) FunctionQueryables(id string) *PropertiesSchema {
+	doc := newPropertiesSchema(id, fn.ID)
+	for i, name := range fn.OutNames {
+		doc.Properties[name] = fn.OutJSONTypes[i].ToPropertySchema(PGType(fn.OutDbTypes[i]))
+	}
+	return doc
+}
+
+// FunctionSortables creates the sortables of a function, which are its output properties with a scalar value
+func (fn *Function) FunctionSortables(id string) *PropertiesSchema {
+	doc := newPropertiesSchema(id, fn.ID)
+	for i, name := range fn.OutNames {
+		if !fn.OutJSONTypes[i].IsSortable() {
+			continue
+		}
+		doc.Properties[name] = fn.OutJSONTypes[i].ToPropertySchema(PGType(fn.OutDbTypes[i]))
+	}
+	return doc
+}
+
+// GeometryPropertySchema references the GeoJSON schema of a geometry type
+func GeometryPropertySchema(geomType string) *QueryableSchema {
+	switch geomType {
+	case GeometryTypePoint, GeometryTypeMultiPoint, GeometryTypeLineString,
+		GeometryTypeMultiLineString, GeometryTypePolygon, GeometryTypeMultiPolygon:
+	default:
+		geomType = GeometryTypeGeometry
+	}
+	return &QueryableSchema{Ref: fmt.Sprintf("https://geojson.org/schema/%v.json", geomType)}
+}
+
+// returns the JSON Schema of a property of JSONType.
+// The PGType refines the type of numbers and dates
+func (jsonType JSONType) ToPropertySchema(dbType PGType) *QueryableSchema {
+	switch jsonType {
+	case JSONTypeNumber:
+		switch dbType {
+		case PGTypeInt, PGTypeInt4, PGTypeInt8, PGTypeBigInt:
+			return &QueryableSchema{Type: "integer"}
+		}
+		return &QueryableSchema{Type: "number"}
+	case JSONTypeBoolean:
+		return &QueryableSchema{Type: "boolean"}
+	case JSONTypeDate:
+		if dbType == PGTypeDate {
+			return &QueryableSchema{Type: "string", Format: "date"}
+		}
+		return &QueryableSchema{Type: "string", Format: "date-time"}
+	case JSONTypeJSON:
+		return &QueryableSchema{Type: "object"}
+	case JSONTypeGeometry:
+		return GeometryPropertySchema(GeometryTypeGeometry)
+	case JSONTypeBooleanArray:
+		return &QueryableSchema{Type: "array", Items: &QueryableSchema{Type: "boolean"}}
+	case JSONTypeStringArray:
+		return &QueryableSchema{Type: "array", Items: &QueryableSchema{Type: "string"}}
+	case JSONTypeNumberArray:
+		return &QueryableSchema{Type: "array", Items: &QueryableSchema{Type: "number"}}
+	default:
+		return &QueryableSchema{Type: "string"}
+	}
+}
+
+// IsSortable tests if values of the JSONType can be ordered
+func (jsonType JSONType) IsSortable() bool {
+	switch jsonType {
+	case JSONTypeString, JSONTypeNumber, JSONTypeBoolean, JSONTypeDate:
+		return true
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package api
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 /*
  Copyright 2022 Crunchy Data Solutions, Inc.
@@ -25,6 +28,27 @@ type Column struct {
 	IsRequired bool
 }
 
+// SourceSeparator separates the name of the data source from the name
+// of a table or function in collection and function ids
+const SourceSeparator = ":"
+
+// SourceID is the id of a table or function of a data source.
+// The tables and functions of the default (unnamed) source are not prefixed
+func SourceID(source string, id string) string {
+	if source == "" {
+		return id
+	}
+	return source + SourceSeparator + id
+}
+
+// SplitSourceID splits a table or function id into the source name and the id in the source
+func SplitSourceID(id string) (string, string) {
+	if i := strings.Index(id, SourceSeparator); i >= 0 {
+		return id[:i], id[i+len(SourceSeparator):]
+	}
+	return "", id
+}
+
 // Table holds metadata for table/view objects
 type Table struct {
 	ID              string
@@ -42,6 +66,19 @@ type Table struct {
 	JSONTypes       []JSONType
 	ColDesc         []string
 	IDColHasDefault bool
+	// HasRowVersions is set if the rows have versions (xmin), which is not the case of views and foreign tables
+	HasRowVersions bool
+	// TimeColumns holds the instant column, or the start and end columns, used by datetime queries
+	TimeColumns []string
+	// TimeExtent holds the first and last times of the data, empty if unknown
+	TimeExtent DateTime
+	// Source is the name of the data source holding the table, blank for the default source
+	Source string
+}
+
+// DbID is the qualified name of the table in the database of its source
+func (tbl *Table) DbID() string {
+	return strings.TrimPrefix(tbl.ID, SourceID(tbl.Source, ""))
 }
 
 // Check the existence of table fields from json data
@@ -67,21 +104,42 @@ func (tbl *Table) CheckTableFields(props map[string]interface{}) (bool, error) {
 
 func (tbl *Table) extendAsBbox() *Bbox {
 	// extent bbox is always in 4326 for now
-	crs := "http://www.opengis.net/def/crs/EPSG/0/4326"
 	return &Bbox{
-		Crs:    crs,
+		Crs:    CrsURICRS84,
 		Extent: []float64{tbl.Extent.Minx, tbl.Extent.Miny, tbl.Extent.Maxx, tbl.Extent.Maxy},
 	}
 }
 
+// extentAsTemporal is the temporal extent of the table data,
+// or nil if it has no time columns or the extent is unknown (it is loaded with the table extent)
+func (tbl *Table) extentAsTemporal() *TemporalExtent {
+	if len(tbl.TimeColumns) == 0 || (tbl.TimeExtent.Start == "" && tbl.TimeExtent.End == "") {
+		return nil
+	}
+	var start, end *string
+	if tbl.TimeExtent.Start != "" {
+		start = &tbl.TimeExtent.Start
+	}
+	if tbl.TimeExtent.End != "" {
+		end = &tbl.TimeExtent.End
+	}
+	return &TemporalExtent{
+		Interval: [][]*string{{start, end}},
+		Trs:      "http://www.opengis.net/def/uom/ISO-8601/0/Gregorian",
+	}
+}
+
 func (tbl *Table) NewCollectionInfo() *CollectionInfo {
 	doc := CollectionInfo{
 		Name:        tbl.ID,
 		Title:       tbl.Title,
 		Description: tbl.Description,
 		Extent: &CollectionExtent{
-			Spatial: tbl.extendAsBbox(),
+			Spatial:  tbl.extendAsBbox(),
+			Temporal: tbl.extentAsTemporal(),
 		},
+		Crs:        append(CrsURIs(sridCRS84, tbl.Srid), CrsURIEPSG4326),
+		StorageCrs: CrsURI(tbl.Srid),
 	}
 	return &doc
 }
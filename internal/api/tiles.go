@@ -0,0 +1,278 @@
+package api
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	TagTiles          = "tiles"
+	TagTileMatrixSets = "tileMatrixSets"
+
+	// TileMatrixSetWebMercatorQuad is the only tile matrix set supported
+	TileMatrixSetWebMercatorQuad = "WebMercatorQuad"
+
+	RelTilingScheme   = "http://www.opengis.net/def/rel/ogc/1.0/tiling-scheme"
+	RelTilesetsVector = "http://www.opengis.net/def/rel/ogc/1.0/tilesets-vector"
+	RelItem           = "item"
+
+	TitleTilesets     = "Vector tilesets"
+	TitleTilingScheme = "Tile matrix set"
+	TitleTileMVT      = "Vector tiles as MVT"
+
+	// TileExtent is the size of a tile in MVT coordinates
+	TileExtent = 4096
+	// TileBuffer is the size of the buffer around a tile in MVT coordinates
+	TileBuffer = 256
+	// TileMaxZoom is the maximum zoom level of the tile matrix set
+	TileMaxZoom = 24
+
+	// webMercatorMax is the half-width of the Web Mercator world extent
+	webMercatorMax = 20037508.3427892
+	// standardized rendering pixel size (in metres)
+	tileMatrixPixelSize = 0.00028
+	tilePixels          = 256
+
+	uriCRS3857          = "http://www.opengis.net/def/crs/EPSG/0/3857"
+	uriWebMercatorQuad  = "http://www.opengis.net/def/tilematrixset/OGC/1.0/WebMercatorQuad"
+	uriGoogleMapsCompat = "http://www.opengis.net/def/wkss/OGC/1.0/GoogleMapsCompatible"
+)
+
+// Tile identifies a tile of the WebMercatorQuad tile matrix set
+type Tile struct {
+	Zoom int
+	X    int
+	Y    int
+}
+
+// ParseTile parses tile coordinates, checking they are in the tile matrix
+func ParseTile(zStr string, xStr string, yStr string) (*Tile, error) {
+	z, errZ := strconv.Atoi(zStr)
+	x, errX := strconv.Atoi(xStr)
+	y, errY := strconv.Atoi(yStr)
+	if errZ != nil || errX != nil || errY != nil {
+		return nil, fmt.Errorf("invalid tile coordinates: %v/%v/%v", zStr, xStr, yStr)
+	}
+	tile := Tile{Zoom: z, X: x, Y: y}
+	if !tile.IsValid() {
+		return nil, fmt.Errorf("tile out of range: %v", tile)
+	}
+	return &tile, nil
+}
+
+// IsValid tests whether a tile is in the tile matrix set
+func (tile Tile) IsValid() bool {
+	if tile.Zoom < 0 || tile.Zoom > TileMaxZoom {
+		return false
+	}
+	size := 1 << uint(tile.Zoom)
+	return tile.X >= 0 && tile.X < size && tile.Y >= 0 && tile.Y < size
+}
+
+func (tile Tile) String() string {
+	return fmt.Sprintf("%v/%v/%v", tile.Zoom, tile.X, tile.Y)
+}
+
+// Size is the width of the tile in Web Mercator units
+func (tile Tile) Size() float64 {
+	return 2 * webMercatorMax / float64(int64(1)<<uint(tile.Zoom))
+}
+
+// Bounds returns the extent of the tile in Web Mercator coordinates
+func (tile Tile) Bounds() Extent {
+	size := tile.Size()
+	minx := -webMercatorMax + float64(tile.X)*size
+	maxy := webMercatorMax - float64(tile.Y)*size
+	return Extent{Minx: minx, Miny: maxy - size, Maxx: minx + size, Maxy: maxy}
+}
+
+// BufferSize is the width of the tile buffer in Web Mercator units
+func (tile Tile) BufferSize() float64 {
+	return tile.Size() * TileBuffer / TileExtent
+}
+
+// LonLatToWebMercator converts geographic coordinates to Web Mercator
+func LonLatToWebMercator(lon float64, lat float64) (float64, float64) {
+	x := lon * webMercatorMax / 180
+	y := math.Log(math.Tan((90+lat)*math.Pi/360)) * webMercatorMax / math.Pi
+	return x, y
+}
+
+// =======================================================
+// ================== TileMatrixSet ======================
+
+// TileMatrixSet is a tile matrix set document (OGC Two Dimensional Tile Matrix Set)
+type TileMatrixSet struct {
+	ID                string        `json:"id"`
+	Title             string        `json:"title"`
+	URI               string        `json:"uri"`
+	CRS               string        `json:"crs"`
+	OrderedAxes       []string      `json:"orderedAxes"`
+	WellKnownScaleSet string        `json:"wellKnownScaleSet"`
+	TileMatrices      []*TileMatrix `json:"tileMatrices"`
+	Links             []*Link       `json:"links,omitempty"`
+}
+
+// TileMatrix is a tile matrix of a tile matrix set
+type TileMatrix struct {
+	ID               string     `json:"id"`
+	ScaleDenominator float64    `json:"scaleDenominator"`
+	CellSize         float64    `json:"cellSize"`
+	CornerOfOrigin   string     `json:"cornerOfOrigin"`
+	PointOfOrigin    [2]float64 `json:"pointOfOrigin"`
+	TileWidth        int        `json:"tileWidth"`
+	TileHeight       int        `json:"tileHeight"`
+	MatrixWidth      int        `json:"matrixWidth"`
+	MatrixHeight     int        `json:"matrixHeight"`
+}
+
+// TileMatrixSetRef references a tile matrix set in a list
+type TileMatrixSetRef struct {
+	ID    string  `json:"id"`
+	Title string  `json:"title"`
+	URI   string  `json:"uri"`
+	Links []*Link `json:"links"`
+}
+
+// TileMatrixSets is the list of tile matrix sets
+type TileMatrixSets struct {
+	TileMatrixSets []*TileMatrixSetRef `json:"tileMatrixSets"`
+}
+
+// NewWebMercatorQuad creates the WebMercatorQuad tile matrix set document
+func NewWebMercatorQuad() *TileMatrixSet {
+	tms := TileMatrixSet{
+		ID:                TileMatrixSetWebMercatorQuad,
+		Title:             "Google Maps Compatible for the World",
+		URI:               uriWebMercatorQuad,
+		CRS:               uriCRS3857,
+		OrderedAxes:       []string{"X", "Y"},
+		WellKnownScaleSet: uriGoogleMapsCompat,
+	}
+	for z := 0; z <= TileMaxZoom; z++ {
+		size := 1 << uint(z)
+		cellSize := 2 * webMercatorMax / tilePixels / float64(size)
+		tms.TileMatrices = append(tms.TileMatrices, &TileMatrix{
+			ID:               strconv.Itoa(z),
+			ScaleDenominator: cellSize / tileMatrixPixelSize,
+			CellSize:         cellSize,
+			CornerOfOrigin:   "topLeft",
+			PointOfOrigin:    [2]float64{-webMercatorMax, webMercatorMax},
+			TileWidth:        tilePixels,
+			TileHeight:       tilePixels,
+			MatrixWidth:      size,
+			MatrixHeight:     size,
+		})
+	}
+	return &tms
+}
+
+// NewTileMatrixSetRef creates the reference to a tile matrix set
+func (tms *TileMatrixSet) NewTileMatrixSetRef() *TileMatrixSetRef {
+	return &TileMatrixSetRef{
+		ID:    tms.ID,
+		Title: tms.Title,
+		URI:   tms.URI,
+	}
+}
+
+// =======================================================
+// ================== Tileset ============================
+
+// Tileset is the metadata of the vector tiles of a collection (OGC API - Tiles)
+type Tileset struct {
+	Title            string          `json:"title,omitempty"`
+	DataType         string          `json:"dataType"`
+	CRS              string          `json:"crs"`
+	TileMatrixSetURI string          `json:"tileMatrixSetURI"`
+	Layers           []*TilesetLayer `json:"layers,omitempty"`
+	Links            []*Link         `json:"links"`
+}
+
+// TilesetLayer describes a layer of vector tiles
+type TilesetLayer struct {
+	ID           string `json:"id"`
+	DataType     string `json:"dataType"`
+	GeometryType string `json:"geometryType,omitempty"`
+	MinZoom      int    `json:"minTileMatrix,string"`
+	MaxZoom      int    `json:"maxTileMatrix,string"`
+}
+
+// Tilesets is the list of tilesets of a collection
+type Tilesets struct {
+	Tilesets []*Tileset `json:"tilesets"`
+	Links    []*Link    `json:"links"`
+}
+
+// NewTileset creates the tileset metadata of a collection
+func NewTileset(tbl *Table) *Tileset {
+	tileset := Tileset{
+		Title:            tbl.Title,
+		DataType:         "vector",
+		CRS:              uriCRS3857,
+		TileMatrixSetURI: uriWebMercatorQuad,
+	}
+	tileset.Layers = []*TilesetLayer{
+		{
+			ID:           tbl.ID,
+			DataType:     "vector",
+			GeometryType: tileGeometryType(tbl.GeometryType),
+			MinZoom:      0,
+			MaxZoom:      TileMaxZoom,
+		},
+	}
+	return &tileset
+}
+
+// tileGeometryType maps a PostGIS geometry type to an OGC API - Tiles layer geometry type
+func tileGeometryType(geomType string) string {
+	switch geomType {
+	case "Point", "MultiPoint":
+		return "points"
+	case "LineString", "MultiLineString":
+		return "lines"
+	case "Polygon", "MultiPolygon":
+		return "polygons"
+	}
+	return ""
+}
+
+// TileData holds an encoded tile and the hash of the feature versions it contains
+type TileData struct {
+	Data []byte
+	Etag string
+}
+
+// =================================================
+// ================== Path helper ==================
+
+func PathCollectionTilesets(name string) string {
+	return fmt.Sprintf("%v/%v/%v", TagCollections, name, TagTiles)
+}
+
+func PathCollectionTileset(name string, tms string) string {
+	return fmt.Sprintf("%v/%v/%v/%v", TagCollections, name, TagTiles, tms)
+}
+
+// PathCollectionTileTemplate is the path template of the tiles of a collection
+func PathCollectionTileTemplate(name string, tms string) string {
+	return fmt.Sprintf("%v/%v/%v/%v/{tileMatrix}/{tileCol}/{tileRow}", TagCollections, name, TagTiles, tms)
+}
+
+func PathTileMatrixSet(tms string) string {
+	return fmt.Sprintf("%v/%v", TagTileMatrixSets, tms)
+}
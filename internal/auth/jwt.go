@@ -0,0 +1,201 @@
+package auth
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Verification of JSON Web Tokens (RFC 7519) used as OAuth2/OIDC bearer tokens
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	log "github.com/sirupsen/logrus"
+)
+
+// allowed difference between the server clock and token times
+const clockSkew = 60 * time.Second
+
+// minimum interval between reloads of the JWKS URL, when a token has an unknown key id
+const jwksReloadInterval = 60 * time.Second
+
+// supported signature algorithms. Symmetric algorithms and "none" are not supported
+var supportedAlgs = map[jose.SignatureAlgorithm]bool{
+	jose.RS256: true, jose.RS384: true, jose.RS512: true,
+	jose.PS256: true, jose.PS384: true, jose.PS512: true,
+	jose.ES256: true, jose.ES384: true, jose.ES512: true,
+}
+
+// Options sets how tokens are verified
+type Options struct {
+	// JwksFile is a file containing the JWKS
+	JwksFile string
+	// JwksURL is the URL of the JWKS, used if no file is set
+	JwksURL string
+	// Issuer is the required value of the iss claim, if set
+	Issuer string
+	// Audience is a required value of the aud claim, if set
+	Audience string
+}
+
+// Claims holds the claims of a verified token
+type Claims map[string]interface{}
+
+// Subject is the sub claim
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// String is a claim value as a string, or blank if it is absent or not a string
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Verifier verifies tokens signed by the keys of a JWKS
+type Verifier struct {
+	opts  Options
+	mutex sync.RWMutex
+	keys  KeySet
+	// loadTime is the time of the last load of the keys, successful or not
+	loadTime    time.Time
+	isReloading bool
+}
+
+// NewVerifier creates a verifier, loading the JWKS
+func NewVerifier(opts Options) (*Verifier, error) {
+	v := &Verifier{opts: opts}
+	keys, err := v.readKeys()
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.loadTime = time.Now()
+	return v, nil
+}
+
+func (v *Verifier) readKeys() (KeySet, error) {
+	var data []byte
+	var err error
+	if v.opts.JwksFile != "" {
+		data, err = ioutil.ReadFile(v.opts.JwksFile)
+	} else {
+		data, err = fetchURL(v.opts.JwksURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read JWKS: %v", err)
+	}
+	return ParseKeySet(data)
+}
+
+func fetchURL(url string) ([]byte, error) {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v returned %v", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// key finds the key for a key id.
+// For an unknown key id, the JWKS URL is reloaded in the background, in case the keys were rotated,
+// so that the key is known to the next requests
+func (v *Verifier) key(kid string) (crypto.PublicKey, bool) {
+	v.mutex.RLock()
+	key, ok := v.keys[kid]
+	v.mutex.RUnlock()
+	if !ok && v.opts.JwksURL != "" && v.opts.JwksFile == "" {
+		v.reloadKeys()
+	}
+	return key, ok
+}
+
+// reloadKeys starts a reload of the keys, unless one is running
+// or the keys were loaded less than jwksReloadInterval ago
+func (v *Verifier) reloadKeys() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.isReloading || time.Since(v.loadTime) < jwksReloadInterval {
+		return
+	}
+	v.isReloading = true
+	go func() {
+		keys, err := v.readKeys()
+		if err != nil {
+			log.Warnf("Reloading JWKS: %v", err)
+		}
+		v.mutex.Lock()
+		defer v.mutex.Unlock()
+		if err == nil {
+			v.keys = keys
+		}
+		v.loadTime = time.Now()
+		v.isReloading = false
+	}()
+}
+
+// Verify checks the signature and times of a token, and its issuer and audience if required.
+// It returns the token claims
+func (v *Verifier) Verify(token string) (Claims, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %v", err)
+	}
+	if len(tok.Headers) != 1 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	header := tok.Headers[0]
+	if !supportedAlgs[jose.SignatureAlgorithm(header.Algorithm)] {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Algorithm)
+	}
+	key, ok := v.key(header.KeyID)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", header.KeyID)
+	}
+	var std jwt.Claims
+	var claims Claims
+	if err := tok.Claims(key, &std, &claims); err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	expected := jwt.Expected{Issuer: v.opts.Issuer, Time: time.Now()}
+	if v.opts.Audience != "" {
+		expected.Audience = jwt.Audience{v.opts.Audience}
+	}
+	if err := std.ValidateWithLeeway(expected, clockSkew); err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+	return claims, nil
+}
+
+type contextKey struct{}
+
+// WithClaims returns a context holding the claims of a verified token
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the request token, or nil if the request is not authenticated
+func ClaimsFromContext(ctx context.Context) Claims {
+	claims, _ := ctx.Value(contextKey{}).(Claims)
+	return claims
+}
@@ -0,0 +1,204 @@
+package auth
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+func TestVerifyRSA(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v := makeVerifier(t, Options{}, map[string]crypto.PublicKey{"k1": &rsaKey.PublicKey})
+
+	claims, err := v.Verify(signRS256(rsaKey, "k1", map[string]interface{}{"sub": "alice", "exp": future()}))
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	util.Equals(t, "alice", claims.Subject(), "subject")
+
+	_, err = v.Verify(signRS256(rsaKey, "k1", map[string]interface{}{"sub": "alice", "exp": past()}))
+	util.AssertIsError(t, err, "expired token")
+	_, err = v.Verify(signRS256(rsaKey, "k1", map[string]interface{}{"nbf": future()}))
+	util.AssertIsError(t, err, "token not valid yet")
+	_, err = v.Verify(signRS256(rsaKey, "k2", map[string]interface{}{"sub": "alice"}))
+	util.AssertIsError(t, err, "unknown key id")
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, err = v.Verify(signRS256(otherKey, "k1", map[string]interface{}{"sub": "alice"}))
+	util.AssertIsError(t, err, "wrong signature")
+}
+
+func TestVerifyEC(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	v := makeVerifier(t, Options{}, map[string]crypto.PublicKey{"ec": &ecKey.PublicKey})
+
+	claims, err := v.Verify(signES256(ecKey, "ec", map[string]interface{}{"sub": "bob"}))
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	util.Equals(t, "bob", claims.Subject(), "subject")
+}
+
+func TestVerifyIssuerAudience(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v := makeVerifier(t, Options{Issuer: "https://idp", Audience: "features"},
+		map[string]crypto.PublicKey{"k1": &rsaKey.PublicKey})
+
+	_, err := v.Verify(signRS256(rsaKey, "k1", map[string]interface{}{"iss": "https://idp", "aud": "features"}))
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	_, err = v.Verify(signRS256(rsaKey, "k1", map[string]interface{}{"iss": "https://idp", "aud": []string{"other", "features"}}))
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	_, err = v.Verify(signRS256(rsaKey, "k1", map[string]interface{}{"iss": "https://other", "aud": "features"}))
+	util.AssertIsError(t, err, "wrong issuer")
+	_, err = v.Verify(signRS256(rsaKey, "k1", map[string]interface{}{"iss": "https://idp"}))
+	util.AssertIsError(t, err, "missing audience")
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v := makeVerifier(t, Options{}, map[string]crypto.PublicKey{"k1": &rsaKey.PublicKey})
+
+	_, err := v.Verify("abc")
+	util.AssertIsError(t, err, "not a token")
+	_, err = v.Verify(encodeSegment(map[string]string{"alg": "none", "kid": "k1"}) + "." + encodeSegment(map[string]string{"sub": "x"}) + ".")
+	util.AssertIsError(t, err, "alg none")
+}
+
+func TestParseKeySet(t *testing.T) {
+	_, err := ParseKeySet([]byte(`{"keys":[]}`))
+	util.AssertIsError(t, err, "empty JWKS")
+	_, err = ParseKeySet([]byte(`{"keys":[{"kty":"RSA","kid":"x","n":"!!","e":"AQAB"}]}`))
+	util.AssertIsError(t, err, "invalid modulus")
+	_, err = ParseKeySet([]byte(`{"keys":[{"kty":"oct","kid":"x","k":"c2VjcmV0"}]}`))
+	util.AssertIsError(t, err, "symmetric keys only")
+}
+
+func TestVerifyReloadKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var mutex sync.Mutex
+	jwks := makeJWKS(map[string]crypto.PublicKey{"k1": &rsaKey.PublicKey})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		_, _ = w.Write(jwks)
+	}))
+	defer server.Close()
+
+	v, err := NewVerifier(Options{JwksURL: server.URL})
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	mutex.Lock()
+	jwks = makeJWKS(map[string]crypto.PublicKey{"k1": &rsaKey.PublicKey, "k2": &newKey.PublicKey})
+	mutex.Unlock()
+
+	// keys loaded recently are not reloaded
+	_, err = v.Verify(signRS256(newKey, "k2", map[string]interface{}{"sub": "alice"}))
+	util.AssertIsError(t, err, "unknown key id")
+	util.Assert(t, !v.isReloadingKeys(), "keys are not reloaded")
+
+	v.mutex.Lock()
+	v.loadTime = time.Now().Add(-jwksReloadInterval)
+	v.mutex.Unlock()
+	_, err = v.Verify(signRS256(newKey, "k2", map[string]interface{}{"sub": "alice"}))
+	util.AssertIsError(t, err, "unknown key id")
+	for i := 0; i < 100 && v.isReloadingKeys(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	claims, err := v.Verify(signRS256(newKey, "k2", map[string]interface{}{"sub": "alice"}))
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	util.Equals(t, "alice", claims.Subject(), "subject")
+}
+
+func (v *Verifier) isReloadingKeys() bool {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.isReloading
+}
+
+func makeVerifier(t *testing.T, opts Options, keys map[string]crypto.PublicKey) *Verifier {
+	file, err := ioutil.TempFile("", "jwks*.json")
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	defer os.Remove(file.Name())
+	_, _ = file.Write(makeJWKS(keys))
+	file.Close()
+
+	opts.JwksFile = file.Name()
+	v, err := NewVerifier(opts)
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	return v
+}
+
+func makeJWKS(keys map[string]crypto.PublicKey) []byte {
+	var jwks []map[string]string
+	for kid, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, map[string]string{"kty": "RSA", "kid": kid,
+				"n": encodeBigInt(k.N), "e": encodeBigInt(big.NewInt(int64(k.E)))})
+		case *ecdsa.PublicKey:
+			jwks = append(jwks, map[string]string{"kty": "EC", "kid": kid, "crv": "P-256",
+				"x": encodeBigInt(k.X), "y": encodeBigInt(k.Y)})
+		}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": jwks})
+	return data
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims interface{}) string {
+	signed := encodeSegment(map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signES256(key *ecdsa.PrivateKey, kid string, claims interface{}) string {
+	signed := encodeSegment(map[string]string{"alg": "ES256", "kid": kid}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func encodeSegment(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func future() int64 {
+	return time.Now().Add(time.Hour).Unix()
+}
+
+func past() int64 {
+	return time.Now().Add(-time.Hour).Unix()
+}
@@ -32,6 +32,8 @@ type AppConfiguration struct {
 	EnvCacheNaiveSize     string
 	EnvCacheRedisUrl      string
 	EnvCacheRedisPassword string
+	// OpenTelemetry collector endpoint
+	EnvOtlpEndpoint string
 }
 
 var AppConfig = AppConfiguration{
@@ -43,4 +45,5 @@ var AppConfig = AppConfiguration{
 	EnvCacheNaiveSize:     "PGFS_CACHE_NAIVE_SIZE",
 	EnvCacheRedisUrl:      "PGFS_CACHE_REDIS_URL",
 	EnvCacheRedisPassword: "PGFS_CACHE_REDIS_PASSWORD",
+	EnvOtlpEndpoint:       "OTEL_EXPORTER_OTLP_ENDPOINT",
 }
@@ -43,11 +43,11 @@ func (cache *Cache) InitFromEnvVariables() {
 		log.Fatal(fmt.Errorf("Invalid cache type: Disabled, Naive and Redis are supported. %v defined", cache.Type))
 	}
 
-	log.Infof("Using cache type %s set from %s", Configuration.Cache.Type, origin)
+	log.Infof("Using cache type %s set from %s", cache.Type, origin)
 
-	if Configuration.Cache.Type == "Naive" {
+	if cache.Type == "Naive" {
 		cache.Naive.InitFromEnvVariables()
-	} else if Configuration.Cache.Type == "Redis" {
+	} else if cache.Type == "Redis" {
 		cache.Redis.InitFromEnvVariables()
 	}
 }
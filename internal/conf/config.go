@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -30,9 +31,41 @@ const (
 	originConfFile = "config file"
 )
 
-// Configuration for system
+// Values for Paging.NumberMatched
+const (
+	NumberMatchedNone     = "none"
+	NumberMatchedExact    = "exact"
+	NumberMatchedEstimate = "estimate"
+)
+
+// Configuration for system, as read at startup.
+// The service reads it with Current, which returns the reloaded configuration after a reload
 var Configuration Config
 
+// current is the reloaded configuration, or nil if it has not been reloaded
+var current struct {
+	sync.RWMutex
+	config *Config
+}
+
+// Current returns the current configuration.
+// A reload replaces it with a new configuration, so it is never modified while it is used
+func Current() *Config {
+	current.RLock()
+	defer current.RUnlock()
+	if current.config != nil {
+		return current.config
+	}
+	return &Configuration
+}
+
+// setCurrent publishes a configuration, or the startup configuration if it is nil
+func setCurrent(config *Config) {
+	current.Lock()
+	defer current.Unlock()
+	current.config = config
+}
+
 func setDefaultConfig() {
 	viper.SetDefault("Server.HttpHost", "0.0.0.0")
 	viper.SetDefault("Server.HttpPort", 9000)
@@ -46,6 +79,8 @@ func setDefaultConfig() {
 	viper.SetDefault("Server.AssetsPath", "./assets")
 	viper.SetDefault("Server.ReadTimeoutSec", 5)
 	viper.SetDefault("Server.WriteTimeoutSec", 30)
+	viper.SetDefault("Server.StreamTimeoutSec", 300)
+	viper.SetDefault("Server.EnableMetrics", false)
 
 	viper.SetDefault("Database.DbPoolMaxConnLifeTime", "1h")
 	viper.SetDefault("Database.DbPoolMaxConns", 4)
@@ -53,6 +88,29 @@ func setDefaultConfig() {
 	viper.SetDefault("Database.TableExcludes", []string{})
 	viper.SetDefault("Database.FunctionIncludes", []string{"postgisftw"})
 	viper.SetDefault("Database.AllowWrite", false)
+	viper.SetDefault("Database.ReplicaMaxLagSec", 30)
+
+	viper.SetDefault("Auth.JwksFile", "")
+	viper.SetDefault("Auth.JwksUrl", "")
+	viper.SetDefault("Auth.Issuer", "")
+	viper.SetDefault("Auth.Audience", "")
+	viper.SetDefault("Auth.RoleClaim", "role")
+	viper.SetDefault("Auth.AnonRole", "")
+	viper.SetDefault("Auth.AllowAnonymousWrite", false)
+
+	viper.SetDefault("ApiKeys.File", "")
+	viper.SetDefault("ApiKeys.Table", "")
+	viper.SetDefault("ApiKeys.Column", "key")
+
+	viper.SetDefault("RateLimit.RequestsPerSec", 0)
+	viper.SetDefault("RateLimit.Burst", 0)
+	viper.SetDefault("RateLimit.Backend", RateLimitBackendMemory)
+
+	viper.SetDefault("Tracing.Exporter", "")
+	viper.SetDefault("Tracing.Endpoint", "http://localhost:4318")
+	viper.SetDefault("Tracing.File", "spans.json")
+	viper.SetDefault("Tracing.ServiceName", "pg_featureserv")
+	viper.SetDefault("Tracing.SampleRatio", 1.0)
 
 	viper.SetDefault("Cache.Type", "Naive")
 	viper.SetDefault("Cache.Naive.MapSize", 400000)
@@ -61,6 +119,8 @@ func setDefaultConfig() {
 
 	viper.SetDefault("Paging.LimitDefault", 10)
 	viper.SetDefault("Paging.LimitMax", 1000)
+	viper.SetDefault("Paging.NumberMatched", NumberMatchedNone)
+	viper.SetDefault("Paging.UseCursor", false)
 
 	viper.SetDefault("Metadata.Title", "pg-featureserv")
 	viper.SetDefault("Metadata.Description", "Crunchy Data Feature Server for PostGIS")
@@ -70,12 +130,16 @@ func setDefaultConfig() {
 
 // Config for system
 type Config struct {
-	Server   Server
-	Paging   Paging
-	Metadata Metadata
-	Database Database
-	Cache    Cache
-	Website  Website
+	Server    Server
+	Paging    Paging
+	Metadata  Metadata
+	Database  Database
+	Auth      Auth
+	ApiKeys   ApiKeys
+	RateLimit RateLimit
+	Tracing   Tracing
+	Cache     Cache
+	Website   Website
 }
 
 // Server config
@@ -92,13 +156,22 @@ type Server struct {
 	AssetsPath               string
 	ReadTimeoutSec           int
 	WriteTimeoutSec          int
-	TransformFunctions       []string
+	// StreamTimeoutSec is the maximum duration of streamed responses, WriteTimeoutSec if not set
+	StreamTimeoutSec   int
+	TransformFunctions []string
+	FilterFunctions    []string
+	// EnableMetrics exposes Prometheus metrics at /metrics
+	EnableMetrics bool
 }
 
 // Paging config
 type Paging struct {
 	LimitDefault int
 	LimitMax     int
+	// NumberMatched sets how numberMatched is computed: none, exact or estimate
+	NumberMatched string
+	// UseCursor enables keyset paging in the next links of collections
+	UseCursor bool
 }
 
 // Database config
@@ -110,6 +183,132 @@ type Database struct {
 	TableExcludes         []string
 	FunctionIncludes      []string
 	AllowWrite            bool
+	// TimeColumns sets the time columns of tables, instead of detecting them
+	TimeColumns []TableTimeColumns
+	// Sources are other databases published in the catalog.
+	// Their collection and function ids are prefixed by the source name
+	Sources []DataSource
+	// ReplicaConnections are read replicas of the database, used for the queries of read requests
+	ReplicaConnections []string
+	// ReplicaMaxLagSec is the replication lag above which a replica is not used
+	ReplicaMaxLagSec int
+	// StatementTimeoutSec is the statement_timeout of the request queries, Server.WriteTimeoutSec if not set
+	StatementTimeoutSec int
+	// IdleInTransactionTimeoutSec is the idle_in_transaction_session_timeout of the database sessions, if set
+	IdleInTransactionTimeoutSec int
+	// StatementTimeouts set the statement timeout of the queries of collections
+	StatementTimeouts []TableStatementTimeout
+}
+
+// DataSource config: a database with its own connection pool, tables and functions
+type DataSource struct {
+	Name         string
+	DbConnection string
+	// DbPoolMaxConns is the maximum size of the source pool, Database.DbPoolMaxConns if not set
+	DbPoolMaxConns int
+	TableIncludes  []string
+	TableExcludes  []string
+	// FunctionIncludes are the function schemas of the source, Database.FunctionIncludes if not set
+	FunctionIncludes []string
+	// ReplicaConnections are read replicas of the source database
+	ReplicaConnections []string
+}
+
+// TableTimeColumns sets the instant column, or the start and end columns, of a table
+type TableTimeColumns struct {
+	Table   string
+	Columns []string
+}
+
+// TableStatementTimeout sets the statement timeout of the queries of a table
+type TableStatementTimeout struct {
+	Table      string
+	TimeoutSec int
+}
+
+// Auth config
+type Auth struct {
+	// JwksFile or JwksUrl provide the keys verifying bearer tokens.
+	// Authentication is disabled if neither is set
+	JwksFile string
+	JwksUrl  string
+	Issuer   string
+	Audience string
+	// RoleClaim is the token claim holding the database role of a user
+	RoleClaim string
+	// AnonRole is the database role of requests without a token, if set
+	AnonRole string
+	// AllowAnonymousWrite allows write requests without authentication when no JWKS is set.
+	// Otherwise they are rejected
+	AllowAnonymousWrite bool
+}
+
+// IsEnabled tests whether requests can be authenticated
+func (auth *Auth) IsEnabled() bool {
+	return auth.JwksFile != "" || auth.JwksUrl != ""
+}
+
+// ApiKeys config
+type ApiKeys struct {
+	// File lists the valid keys, one per line
+	File string
+	// Table and Column hold the valid keys in the database
+	Table  string
+	Column string
+}
+
+// IsEnabled tests whether requests require an API key
+func (keys *ApiKeys) IsEnabled() bool {
+	return keys.File != "" || keys.Table != ""
+}
+
+// Rate limit backends
+const (
+	RateLimitBackendMemory = "Memory"
+	RateLimitBackendRedis  = "Redis"
+)
+
+// RateLimit config
+type RateLimit struct {
+	// RequestsPerSec is the rate allowed for each API key or client IP.
+	// Rate limiting is disabled if it is 0
+	RequestsPerSec float64
+	// Burst is the number of requests allowed in a burst
+	Burst int
+	// Backend is Memory, or Redis to share limits between service instances
+	Backend string
+	// TrustedProxies are the IP addresses or CIDR networks of the proxies
+	// whose Forwarded or X-Forwarded-For headers provide the client IP
+	TrustedProxies []string
+}
+
+// IsEnabled tests whether requests are rate limited
+func (limit *RateLimit) IsEnabled() bool {
+	return limit.RequestsPerSec > 0
+}
+
+// Tracing exporters
+const (
+	TracingExporterOTLP = "OTLP"
+	TracingExporterFile = "File"
+)
+
+// Tracing config
+type Tracing struct {
+	// Exporter is OTLP or File. Tracing is disabled if it is blank
+	Exporter string
+	// Endpoint is the URL of the OTLP/HTTP collector
+	Endpoint string
+	// File is the file spans are appended to by the File exporter
+	File        string
+	ServiceName string
+	// SampleRatio is the ratio of requests traced, when not set by the caller
+	SampleRatio float64
+}
+
+// IsEnabled tests whether requests are traced
+func (tracing *Tracing) IsEnabled() bool {
+	return tracing.Exporter != ""
 }
 
 // Metadata config
@@ -132,8 +331,9 @@ func InitConfig(configFilename string, isDebug bool) {
 	// --- defaults
 	setDefaultConfig()
 
+	// kept when the configuration is reloaded
 	if isDebug {
-		viper.Set("Debug", true)
+		viper.Set("Server.Debug", true)
 	}
 
 	viper.SetEnvPrefix(AppConfig.EnvPrefix)
@@ -164,41 +364,89 @@ func InitConfig(configFilename string, isDebug bool) {
 	}
 
 	log.Infof("Using config file: %s", viper.ConfigFileUsed())
-	errUnM := viper.Unmarshal(&Configuration)
+	errUnM := decodeConfig(&Configuration)
 	if errUnM != nil {
 		log.Fatal(fmt.Errorf("fatal error decoding config file: %v", errUnM))
 	}
+	setCurrent(nil)
+}
+
+// decodeConfig decodes the configuration read from the config file,
+// and sets the values provided by environment variables
+func decodeConfig(config *Config) error {
+	errUnM := viper.Unmarshal(config)
+	if errUnM != nil {
+		return errUnM
+	}
 
 	// Read environment variable database configuration
 	// It takes precedence over config file (if any)
 	// A blank value is ignored
 	dbconnSrc := originConfFile
 	if dbURL := os.Getenv(AppConfig.EnvDBURL); dbURL != "" {
-		Configuration.Database.DbConnection = dbURL
+		config.Database.DbConnection = dbURL
 		dbconnSrc = originEnvVar + " " + AppConfig.EnvDBURL
 	}
 	log.Infof("Using database connection info from %v", dbconnSrc)
 
 	// Cache initialization
-	Configuration.Cache.InitFromEnvVariables()
+	config.Cache.InitFromEnvVariables()
+	// the Redis rate limit backend uses the Redis cache connection settings
+	if config.RateLimit.Backend == RateLimitBackendRedis && config.Cache.Type != "Redis" {
+		config.Cache.Redis.InitFromEnvVariables()
+	}
+
+	// the standard OpenTelemetry variable takes precedence over the config file
+	if endpoint := os.Getenv(AppConfig.EnvOtlpEndpoint); endpoint != "" {
+		config.Tracing.Endpoint = endpoint
+	}
 
 	// sanitize the configuration
-	Configuration.Server.BasePath = strings.TrimRight(Configuration.Server.BasePath, "/")
+	config.Server.BasePath = strings.TrimRight(config.Server.BasePath, "/")
+	return nil
 }
 
 func DumpConfig() {
+	config := Current()
 	log.Debugf("--- Configuration ---")
 	//fmt.Printf("Viper: %v\n", viper.AllSettings())
-	//fmt.Printf("Config: %v\n", Configuration)
-	var basemapURL = Configuration.Website.BasemapUrl
+	//fmt.Printf("Config: %v\n", config)
+	var basemapURL = config.Website.BasemapUrl
 	if basemapURL == "" {
 		basemapURL = "*** NO URL PROVIDED ***"
 	}
 	log.Debugf("  BasemapUrl = %v", basemapURL)
-	log.Debugf("  TableIncludes = %v", Configuration.Database.TableIncludes)
-	log.Debugf("  TableExcludes = %v", Configuration.Database.TableExcludes)
-	log.Debugf("  FunctionIncludes = %v", Configuration.Database.FunctionIncludes)
-	log.Debugf("  TransformFunctions = %v", Configuration.Server.TransformFunctions)
+	log.Debugf("  TableIncludes = %v", config.Database.TableIncludes)
+	log.Debugf("  TableExcludes = %v", config.Database.TableExcludes)
+	log.Debugf("  FunctionIncludes = %v", config.Database.FunctionIncludes)
+	log.Debugf("  TimeColumns = %v", config.Database.TimeColumns)
+	log.Debugf("  StatementTimeoutSec = %v, IdleInTransactionTimeoutSec = %v", config.Database.StatementTimeoutSec, config.Database.IdleInTransactionTimeoutSec)
+	log.Debugf("  StatementTimeouts = %v", config.Database.StatementTimeouts)
+	log.Debugf("  Replicas = %v, ReplicaMaxLagSec = %v", len(config.Database.ReplicaConnections), config.Database.ReplicaMaxLagSec)
+	for _, src := range config.Database.Sources {
+		log.Debugf("  Source %v: TableIncludes = %v, TableExcludes = %v, FunctionIncludes = %v",
+			src.Name, src.TableIncludes, src.TableExcludes, src.FunctionIncludes)
+	}
+	log.Debugf("  TransformFunctions = %v", config.Server.TransformFunctions)
+	log.Debugf("  FilterFunctions = %v", config.Server.FilterFunctions)
+	log.Debugf("  NumberMatched = %v", config.Paging.NumberMatched)
+	log.Debugf("  EnableMetrics = %v", config.Server.EnableMetrics)
+	log.Debugf("  Auth.JwksFile = %v", config.Auth.JwksFile)
+	log.Debugf("  Auth.JwksUrl = %v", config.Auth.JwksUrl)
+	log.Debugf("  Auth.Issuer = %v", config.Auth.Issuer)
+	log.Debugf("  Auth.Audience = %v", config.Auth.Audience)
+	log.Debugf("  Auth.RoleClaim = %v", config.Auth.RoleClaim)
+	log.Debugf("  Auth.AnonRole = %v", config.Auth.AnonRole)
+	log.Debugf("  Auth.AllowAnonymousWrite = %v", config.Auth.AllowAnonymousWrite)
+	log.Debugf("  ApiKeys.File = %v", config.ApiKeys.File)
+	log.Debugf("  ApiKeys.Table = %v", config.ApiKeys.Table)
+	log.Debugf("  RateLimit.RequestsPerSec = %v", config.RateLimit.RequestsPerSec)
+	log.Debugf("  RateLimit.Burst = %v", config.RateLimit.Burst)
+	log.Debugf("  RateLimit.Backend = %v", config.RateLimit.Backend)
+	log.Debugf("  RateLimit.TrustedProxies = %v", config.RateLimit.TrustedProxies)
+	log.Debugf("  Tracing.Exporter = %v", config.Tracing.Exporter)
+	log.Debugf("  Tracing.Endpoint = %v", config.Tracing.Endpoint)
+	log.Debugf("  Tracing.SampleRatio = %v", config.Tracing.SampleRatio)
 
-	Configuration.Cache.DumpConfig()
+	config.Cache.DumpConfig()
 }
@@ -0,0 +1,78 @@
+package conf
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/viper"
+)
+
+// ReloadConfig reads the config file and environment variables again,
+// and applies the settings which can change while the service runs.
+// The new configuration is published as a whole, replacing the current one.
+// It returns the names of the changed settings which are only applied after a restart
+func ReloadConfig() ([]string, error) {
+	err := viper.ReadInConfig()
+	// the default configuration is used if there is no config file
+	if _, isConfigFileNotFound := err.(viper.ConfigFileNotFoundError); err != nil && !isConfigFileNotFound {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+	var reloaded Config
+	err = decodeConfig(&reloaded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding config file: %v", err)
+	}
+
+	config := *Current()
+	applyReloadable(&config, &reloaded)
+	restart := changedSettings("", reflect.ValueOf(config), reflect.ValueOf(reloaded))
+	setCurrent(&config)
+	return restart, nil
+}
+
+// applyReloadable sets the settings which can change while the service runs
+func applyReloadable(config *Config, reloaded *Config) {
+	config.Server.CORSOrigins = reloaded.Server.CORSOrigins
+	config.Server.Debug = reloaded.Server.Debug
+	config.Server.TransformFunctions = reloaded.Server.TransformFunctions
+	config.Server.FilterFunctions = reloaded.Server.FilterFunctions
+	config.Database.TableIncludes = reloaded.Database.TableIncludes
+	config.Database.TableExcludes = reloaded.Database.TableExcludes
+	config.Database.FunctionIncludes = reloaded.Database.FunctionIncludes
+	config.Database.StatementTimeouts = reloaded.Database.StatementTimeouts
+	config.Paging = reloaded.Paging
+	config.Metadata = reloaded.Metadata
+	config.Website = reloaded.Website
+}
+
+// changedSettings lists the names of the settings which differ between two configurations
+func changedSettings(name string, current reflect.Value, reloaded reflect.Value) []string {
+	if current.Kind() != reflect.Struct {
+		if reflect.DeepEqual(current.Interface(), reloaded.Interface()) {
+			return nil
+		}
+		return []string{name}
+	}
+	var changed []string
+	for i := 0; i < current.NumField(); i++ {
+		fieldName := current.Type().Field(i).Name
+		if name != "" {
+			fieldName = name + "." + fieldName
+		}
+		changed = append(changed, changedSettings(fieldName, current.Field(i), reloaded.Field(i))...)
+	}
+	return changed
+}
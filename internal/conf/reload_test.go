@@ -0,0 +1,138 @@
+package conf_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+)
+
+const configInitial = `
+[Server]
+HttpPort = 9000
+CORSOrigins = "*"
+
+[Database]
+TableIncludes = [ "public" ]
+
+[Paging]
+LimitMax = 1000
+
+[Metadata]
+Title = "Initial"
+`
+
+const configReloaded = `
+[Server]
+HttpPort = 9500
+CORSOrigins = "https://example.com"
+TransformFunctions = [ "ST_Centroid" ]
+
+[Database]
+TableIncludes = [ "public", "other" ]
+
+[Paging]
+LimitMax = 50
+
+[Metadata]
+Title = "Reloaded"
+`
+
+func TestReloadConfig(t *testing.T) {
+	file, err := ioutil.TempFile("", "pg_featureserv*.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	writeConfig(t, file.Name(), configInitial)
+	conf.InitConfig(file.Name(), false)
+
+	writeConfig(t, file.Name(), configReloaded)
+	restart, err := conf.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := conf.Current()
+	if config.Metadata.Title != "Reloaded" {
+		t.Errorf("Metadata.Title: expected Reloaded, got %v", config.Metadata.Title)
+	}
+	if config.Paging.LimitMax != 50 {
+		t.Errorf("Paging.LimitMax: expected 50, got %v", config.Paging.LimitMax)
+	}
+	if config.Server.CORSOrigins != "https://example.com" {
+		t.Errorf("Server.CORSOrigins: expected https://example.com, got %v", config.Server.CORSOrigins)
+	}
+	if !reflect.DeepEqual(config.Database.TableIncludes, []string{"public", "other"}) {
+		t.Errorf("Database.TableIncludes: got %v", config.Database.TableIncludes)
+	}
+	if !reflect.DeepEqual(config.Server.TransformFunctions, []string{"ST_Centroid"}) {
+		t.Errorf("Server.TransformFunctions: got %v", config.Server.TransformFunctions)
+	}
+	// the port is only changed by a restart
+	if config.Server.HttpPort != 9000 {
+		t.Errorf("Server.HttpPort: expected 9000, got %v", config.Server.HttpPort)
+	}
+	if !reflect.DeepEqual(restart, []string{"Server.HttpPort"}) {
+		t.Errorf("settings requiring a restart: expected [Server.HttpPort], got %v", restart)
+	}
+}
+
+// checks that the configuration is read while it is reloaded (run with -race to detect unguarded accesses)
+func TestReloadConfigConcurrentReads(t *testing.T) {
+	file, err := ioutil.TempFile("", "pg_featureserv*.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	writeConfig(t, file.Name(), configInitial)
+	conf.InitConfig(file.Name(), false)
+	writeConfig(t, file.Name(), configReloaded)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if _, err := conf.ReloadConfig(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if conf.Current().Paging.LimitMax != 50 {
+				t.Errorf("Paging.LimitMax: expected 50, got %v", conf.Current().Paging.LimitMax)
+			}
+			return
+		default:
+		}
+		config := conf.Current()
+		if limitMax := config.Paging.LimitMax; limitMax != 1000 && limitMax != 50 {
+			t.Fatalf("Paging.LimitMax: unexpected %v", limitMax)
+		}
+		_ = len(config.Database.TableIncludes)
+	}
+}
+
+func writeConfig(t *testing.T, filename string, content string) {
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,8 @@
 /*
 # CQL2 Antlr grammar, with small modifications.
 # - Additions: ILIKE
+# - CASEI, ACCENTI and the array predicates are parsed as function calls
+#   (they are transpiled in cql.go and cql_functions.go)
 
 # Build: in this dir: antlr -Dlanguage=Go -package cql CQLParser.g4 CqlLexer.g4
 #
@@ -36,7 +38,7 @@ predicate : comparisonPredicate
           | spatialPredicate
           | distancePredicate
 //          | temporalPredicate
-//          | arrayPredicate
+          | function
           ;
 
 /*============================================================================
@@ -55,7 +57,7 @@ comparisonPredicate : binaryComparisonPredicate # PredicateBinaryComp
 
 binaryComparisonPredicate : left=scalarExpression op=ComparisonOperator right=scalarExpression;
 
-isLikePredicate :  propertyName (NOT)? ( LIKE | ILIKE ) characterLiteral;
+isLikePredicate :  (propertyName | function) (NOT)? ( LIKE | ILIKE ) (characterLiteral | function);
 
 isBetweenPredicate : scalarExpression (NOT)? BETWEEN
                              scalarExpression AND scalarExpression ;
@@ -89,7 +91,7 @@ scalarValue : propertyName      # LiteralName
             | numericLiteral    # LiteralNumeric
             | booleanLiteral    # LiteralBoolean
             | temporalLiteral   # LiteralTemporal
-//                   | function
+            | function          # ScalarFunction
              ;
 
 propertyName: Identifier;
@@ -114,7 +116,7 @@ distancePredicate :  DistanceOperator LEFTPAREN geomExpression COMMA geomExpress
 */
 geomExpression : propertyName
                | geomLiteral
-               /*| function*/;
+               | function;
 
 /*============================================================================
 # Definition of GEOMETRIC literals
@@ -143,3 +145,18 @@ envelope: ENVELOPE LEFTPAREN NumericLiteral COMMA NumericLiteral COMMA NumericLi
 
 coordList: LEFTPAREN coordinate (COMMA coordinate)* RIGHTPAREN;
 coordinate : NumericLiteral NumericLiteral;
+
+/*============================================================================
+# A function call, including CASEI, ACCENTI and the array predicates.
+# An array literal has zero or at least two elements,
+# since a single value in parentheses is a scalar expression.
+#============================================================================*/
+
+function : Identifier LEFTPAREN (argument (COMMA argument)*)? RIGHTPAREN;
+
+argument : scalarExpression
+         | geomLiteral
+         | arrayLiteral
+         ;
+
+arrayLiteral : LEFTPAREN (scalarExpression (COMMA scalarExpression)+)? RIGHTPAREN;
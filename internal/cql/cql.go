@@ -54,6 +54,9 @@ func TranspileToSQL(cqlStr string, filterSRID int, sourceSRID int) (string, erro
 		err := fmt.Errorf("CQL syntax error: %s", msg)
 		return "", err
 	}
+	if listener.err != nil {
+		return "", listener.err
+	}
 	return listener.GetSQL(), nil
 }
 
@@ -113,13 +116,12 @@ func (l *CqlErrorListener) ReportContextSensitivity(recognizer antlr.Parser, dfa
 
 type cqlListener struct {
 	*BaseCQLParserListener
-	// SRID for filter CRS
-	filterSRID int
-	// SRID for source CRS
-	sourceSRID int
+	cqlCrs
 
 	// final result SQL
 	sql string
+	// first error found in the parse tree
+	err error
 }
 
 func NewCqlListener(filterSRID int, sourceSRID int) *cqlListener {
@@ -132,20 +134,35 @@ func (l *cqlListener) GetSQL() string {
 	return l.sql
 }
 
-func (l *cqlListener) sqlGeometryLiteral(wkt string) string {
-	sql := fmt.Sprintf("'SRID=%d;%s'::geometry", l.filterSRID, wkt)
+// setError records an error, keeping the first one found
+func (l *cqlListener) setError(err error) {
+	if l.err == nil {
+		l.err = err
+	}
+}
+
+// cqlCrs holds the coordinate systems used to transpile geometry literals
+type cqlCrs struct {
+	// SRID for filter CRS
+	filterSRID int
+	// SRID for source CRS
+	sourceSRID int
+}
+
+func (c cqlCrs) sqlGeometryLiteral(wkt string) string {
+	sql := fmt.Sprintf("'SRID=%d;%s'::geometry", c.filterSRID, wkt)
 	return sql
 }
 
-func (l *cqlListener) sqlEnvelopeLiteral(xmin string, ymin string, xmax string, ymax string) string {
-	return fmt.Sprintf("ST_MakeEnvelope(%s,%s,%s,%s,%d)", xmin, ymin, xmax, ymax, l.filterSRID)
+func (c cqlCrs) sqlEnvelopeLiteral(xmin string, ymin string, xmax string, ymax string) string {
+	return fmt.Sprintf("ST_MakeEnvelope(%s,%s,%s,%s,%d)", xmin, ymin, xmax, ymax, c.filterSRID)
 }
 
-func (l *cqlListener) sqlTransformCrs(sql string) string {
-	if l.sourceSRID == l.filterSRID {
+func (c cqlCrs) sqlTransformCrs(sql string) string {
+	if c.sourceSRID == c.filterSRID {
 		return sql
 	}
-	return fmt.Sprintf("ST_Transform(%s,%d)", sql, l.sourceSRID)
+	return fmt.Sprintf("ST_Transform(%s,%d)", sql, c.sourceSRID)
 }
 
 // helper function to avoid nil pointer problems
@@ -260,6 +277,13 @@ func (l *cqlListener) ExitPredicate(ctx *PredicateContext) {
 		sql = sqlFor(ctx.SpatialPredicate())
 	} else if ctx.DistancePredicate() != nil {
 		sql = sqlFor(ctx.DistancePredicate())
+	} else if ctx.Function() != nil {
+		// CASEI and ACCENTI return a string, not a boolean
+		fun := ctx.Function().(*FunctionContext)
+		if name := getNodeText(fun.Identifier()); isInsensitiveOp(strings.ToLower(name)) {
+			l.setError(fmt.Errorf("function %s is not a predicate", name))
+		}
+		sql = sqlFor(fun)
 		//	} else if ctx.TemporalPredicate() != nil {
 		//		sql = sqlFor(ctx.TemporalPredicate())
 	}
@@ -324,6 +348,11 @@ func (l *cqlListener) ExitLiteralTemporal(ctx *LiteralTemporalContext) {
 	ctx.SetSql(sql)
 }
 
+func (l *cqlListener) ExitScalarFunction(ctx *ScalarFunctionContext) {
+	sql := sqlFor(ctx.Function())
+	ctx.SetSql(sql)
+}
+
 func (l *cqlListener) ExitScalarVal(ctx *ScalarValContext) {
 	sql := sqlFor(ctx.val)
 	ctx.SetSql(sql)
@@ -344,7 +373,13 @@ func (l *cqlListener) ExitScalarExpr(ctx *ScalarExprContext) {
 
 func (l *cqlListener) ExitIsLikePredicate(ctx *IsLikePredicateContext) {
 	var sb strings.Builder
-	sb.WriteString(quotedName(getText(ctx.PropertyName())))
+	funs := ctx.AllFunction()
+	if ctx.PropertyName() != nil {
+		sb.WriteString(quotedName(getText(ctx.PropertyName())))
+	} else if len(funs) > 0 {
+		sb.WriteString(sqlFor(funs[0]))
+		funs = funs[1:]
+	}
 	if ctx.NOT() != nil {
 		sb.WriteString(" NOT")
 	}
@@ -353,7 +388,16 @@ func (l *cqlListener) ExitIsLikePredicate(ctx *IsLikePredicateContext) {
 		op = " ILIKE "
 	}
 	sb.WriteString(op)
-	sb.WriteString(quotedText(getText(ctx.CharacterLiteral())))
+	if ctx.CharacterLiteral() != nil {
+		sb.WriteString(quotedText(getText(ctx.CharacterLiteral())))
+	} else if len(funs) > 0 {
+		//-- a pattern can only be wrapped in CASEI or ACCENTI
+		fun := funs[0].(*FunctionContext)
+		if !isInsensitiveOp(strings.ToLower(getNodeText(fun.Identifier()))) {
+			l.setError(fmt.Errorf("invalid pattern: %s", fun.GetText()))
+		}
+		sb.WriteString(sqlFor(fun))
+	}
 	ctx.SetSql(sb.String())
 }
 
@@ -365,7 +409,7 @@ func (l *cqlListener) ExitIsBetweenPredicate(ctx *IsBetweenPredicateContext) {
 	}
 	expr1 := sqlFor(ctx.ScalarExpression(1))
 	expr2 := sqlFor(ctx.ScalarExpression(2))
-	sql := " " + lhs + not + " BETWEEN " + expr1 + " AND " + expr2
+	sql := lhs + not + " BETWEEN " + expr1 + " AND " + expr2
 	ctx.SetSql(sql)
 }
 
@@ -375,7 +419,7 @@ func (l *cqlListener) ExitIsNullPredicate(ctx *IsNullPredicateContext) {
 	if ctx.NOT() != nil {
 		not = " NOT"
 	}
-	sql := " " + prop + " IS" + not + " NULL"
+	sql := prop + " IS" + not + " NULL"
 	ctx.SetSql(sql)
 }
 
@@ -387,7 +431,7 @@ func (l *cqlListener) ExitIsInListPredicate(ctx *IsInListPredicateContext) {
 	}
 	sb.WriteString(" IN (")
 	inPredValueList(ctx, &sb)
-	sb.WriteString(") ")
+	sb.WriteString(")")
 	sql := sb.String()
 	ctx.SetSql(sql)
 }
@@ -444,12 +488,84 @@ func (l *cqlListener) ExitGeomExpression(ctx *GeomExpressionContext) {
 	var sb strings.Builder
 	if ctx.PropertyName() != nil {
 		sb.WriteString(quotedName(getText(ctx.PropertyName())))
+	} else if ctx.Function() != nil {
+		sb.WriteString(sqlFor(ctx.Function()))
 	} else {
 		sb.WriteString(sqlFor(ctx.GeomLiteral()))
 	}
 	ctx.SetSql(sb.String())
 }
 
+func (l *cqlListener) ExitFunction(ctx *FunctionContext) {
+	name := getNodeText(ctx.Identifier())
+	nameLow := strings.ToLower(name)
+	args := ctx.AllArgument()
+	var sql string
+	var err error
+	switch {
+	case isInsensitiveOp(nameLow):
+		if err = checkFunctionArgs(nameLow, args, 1); err == nil {
+			sql = sqlFor(args[0])
+			if nameLow == "casei" {
+				sql = sqlCaseInsensitive(sql)
+			} else {
+				sql = sqlAccentInsensitive(sql)
+			}
+		}
+	case pgOperatorForArrayOp[nameLow] != "":
+		if err = checkFunctionArgs(nameLow, args, 2); err == nil {
+			sql, err = sqlArrayPredicate(nameLow, sqlForArray(args[0]), sqlForArray(args[1]))
+		}
+	default:
+		vals := make([]string, len(args))
+		for i, arg := range args {
+			vals[i] = sqlFor(arg)
+		}
+		sql, err = sqlFunctionCall(name, vals)
+	}
+	if err != nil {
+		l.setError(err)
+	}
+	ctx.SetSql(sql)
+}
+
+func checkFunctionArgs(name string, args []IArgumentContext, num int) error {
+	if len(args) != num {
+		return fmt.Errorf("operator %s requires %d arguments", name, num)
+	}
+	return nil
+}
+
+// sqlForArray is the SQL for an array predicate argument.
+// A single value in parentheses is an array with one element
+func sqlForArray(arg IArgumentContext) string {
+	if paren, ok := arg.(*ArgumentContext).ScalarExpression().(*ScalarParenContext); ok {
+		return sqlArrayLiteral([]string{sqlFor(paren.expr)})
+	}
+	return sqlFor(arg)
+}
+
+func (l *cqlListener) ExitArgument(ctx *ArgumentContext) {
+	var sql string
+	if ctx.ScalarExpression() != nil {
+		sql = sqlFor(ctx.ScalarExpression())
+	} else if ctx.GeomLiteral() != nil {
+		sql = sqlFor(ctx.GeomLiteral())
+	} else {
+		sql = sqlFor(ctx.ArrayLiteral())
+	}
+	ctx.SetSql(sql)
+}
+
+func (l *cqlListener) ExitArrayLiteral(ctx *ArrayLiteralContext) {
+	exprs := ctx.AllScalarExpression()
+	vals := make([]string, len(exprs))
+	for i, expr := range exprs {
+		vals[i] = sqlFor(expr)
+	}
+	ctx.SetSql(sqlArrayLiteral(vals))
+}
+
 func (l *cqlListener) ExitTemporalLiteral(ctx *TemporalLiteralContext) {
 	val := strings.ToUpper(ctx.GetText())
 	//var sql string
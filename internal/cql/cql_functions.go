@@ -0,0 +1,95 @@
+package cql
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Transpilation of CQL2 functions, advanced comparison operators and array operators.
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// filterFunctionAllowlist holds the map of the database functions which can be called in filters,
+// keyed by lowercase name. It is replaced as a whole when the functions are set again
+var filterFunctionAllowlist atomic.Value
+
+// InitFunctions sets the database functions which can be called in filters
+func InitFunctions(funNames []string) {
+	allowlist := make(map[string]string)
+	for _, name := range funNames {
+		nameLow := strings.ToLower(name)
+		allowlist[nameLow] = name
+	}
+	filterFunctionAllowlist.Store(allowlist)
+}
+
+// allowedFunctions returns the map of the functions which can be called in filters
+func allowedFunctions() map[string]string {
+	allowlist, _ := filterFunctionAllowlist.Load().(map[string]string)
+	return allowlist
+}
+
+// isFunctionAllowed tests if a function name is in the allowlist
+func isFunctionAllowed(name string) bool {
+	_, ok := allowedFunctions()[strings.ToLower(name)]
+	return ok
+}
+
+// sqlFunctionCall is the SQL for a call to a database function from the allowlist.
+// The name is replaced by the configured one, so it can never be injected
+func sqlFunctionCall(name string, args []string) (string, error) {
+	actual, ok := allowedFunctions()[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("function not allowed: %s", name)
+	}
+	return actual + "(" + strings.Join(args, ",") + ")", nil
+}
+
+// sqlCaseInsensitive is the SQL for the CQL2 CASEI function
+func sqlCaseInsensitive(sql string) string {
+	return "lower(" + sql + ")"
+}
+
+// sqlAccentInsensitive is the SQL for the CQL2 ACCENTI function.
+// It requires the unaccent extension in the database
+func sqlAccentInsensitive(sql string) string {
+	return "unaccent(" + sql + ")"
+}
+
+var pgOperatorForArrayOp = map[string]string{
+	"a_equals":      "=",
+	"a_contains":    "@>",
+	"a_containedby": "<@",
+	"a_overlaps":    "&&",
+}
+
+// sqlArrayPredicate is the SQL for a CQL2 array predicate.
+// It is parenthesized, as the array operators bind tighter than NOT in CQL2 but not in SQL
+func sqlArrayPredicate(cqlOp string, left string, right string) (string, error) {
+	op, ok := pgOperatorForArrayOp[strings.ToLower(cqlOp)]
+	if !ok {
+		return "", fmt.Errorf("unsupported array operator: %s", cqlOp)
+	}
+	return "(" + left + " " + op + " " + right + ")", nil
+}
+
+// sqlArrayLiteral is the SQL for an array of literal values.
+// An empty array gets its type from the other operand
+func sqlArrayLiteral(values []string) string {
+	if len(values) == 0 {
+		return "'{}'"
+	}
+	return "ARRAY[" + strings.Join(values, ",") + "]"
+}
@@ -0,0 +1,531 @@
+package cql
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// CQL2-JSON encoding of filters, as per https://docs.ogc.org/is/21-065r2/21-065r2.html#cql2-json
+// The generated SQL is the same as for the equivalent CQL2-Text expression.
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/paulmach/orb/encoding/wkt"
+	"github.com/paulmach/orb/geojson"
+	log "github.com/sirupsen/logrus"
+)
+
+// TranspileJSONToSQL converts a CQL2-JSON filter into a SQL expression
+func TranspileJSONToSQL(cqlJSON string, filterSRID int, sourceSRID int) (string, error) {
+	if len(strings.TrimSpace(cqlJSON)) < 1 {
+		return "", nil
+	}
+	dec := json.NewDecoder(strings.NewReader(cqlJSON))
+	//-- keep number literals as written
+	dec.UseNumber()
+	var expr interface{}
+	if err := dec.Decode(&expr); err != nil {
+		log.Debug("CQL2-JSON parser error = " + err.Error())
+		return "", fmt.Errorf("CQL2-JSON syntax error: %v", err)
+	}
+	if dec.More() {
+		return "", fmt.Errorf("CQL2-JSON syntax error: %s", "unexpected content after filter")
+	}
+	t := &cqlJSONTranspiler{cqlCrs{filterSRID: filterSRID, sourceSRID: sourceSRID}}
+	sql, err := t.booleanExpression(expr)
+	if err != nil {
+		return "", fmt.Errorf("CQL2-JSON error: %v", err)
+	}
+	return sql, nil
+}
+
+type cqlJSONTranspiler struct {
+	cqlCrs
+}
+
+// precedence of operators, used to parenthesize nested expressions
+var cqlJSONPrecedence = map[string]int{
+	"or":  1,
+	"and": 2,
+	"+":   2,
+	"-":   2,
+	"*":   3,
+	"/":   3,
+	"%":   3,
+	"^":   4,
+}
+
+var cqlJSONComparison = map[string]string{
+	"=":  "=",
+	"<>": "<>",
+	"<":  "<",
+	">":  ">",
+	"<=": "<=",
+	">=": ">=",
+}
+
+// temporal literals are checked, since they are inserted in the SQL text
+var reTemporalLiteral = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}([Tt ][0-9]{2}:[0-9]{2}(:[0-9]{2}(\.[0-9]+)?)?([Zz]|[+-][0-9]{2}(:?[0-9]{2})?)?)?$`)
+
+// an operation is a JSON object with an op and its args
+type cqlJSONOp struct {
+	op   string
+	args []interface{}
+}
+
+func asOp(expr interface{}) (*cqlJSONOp, bool) {
+	obj, ok := expr.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	op, ok := obj["op"].(string)
+	if !ok {
+		return nil, false
+	}
+	args, _ := obj["args"].([]interface{})
+	return &cqlJSONOp{op: strings.ToLower(op), args: args}, true
+}
+
+func (op *cqlJSONOp) checkArgs(num int) error {
+	if len(op.args) != num {
+		return fmt.Errorf("operator %s requires %d arguments", op.op, num)
+	}
+	return nil
+}
+
+func (t *cqlJSONTranspiler) booleanExpression(expr interface{}) (string, error) {
+	if b, ok := expr.(bool); ok {
+		return booleanLiteral(b), nil
+	}
+	op, ok := asOp(expr)
+	if !ok {
+		return "", fmt.Errorf("invalid boolean expression: %v", jsonText(expr))
+	}
+	switch op.op {
+	case "and", "or":
+		if len(op.args) < 2 {
+			return "", fmt.Errorf("operator %s requires at least 2 arguments", op.op)
+		}
+		terms := make([]string, len(op.args))
+		for i, arg := range op.args {
+			sql, err := t.booleanExpression(arg)
+			if err != nil {
+				return "", err
+			}
+			if argOp, ok := asOp(arg); ok && cqlJSONPrecedence[argOp.op] > 0 && cqlJSONPrecedence[argOp.op] < cqlJSONPrecedence[op.op] {
+				sql = "(" + sql + ")"
+			}
+			terms[i] = sql
+		}
+		return strings.Join(terms, " "+strings.ToUpper(op.op)+" "), nil
+	case "not":
+		if err := op.checkArgs(1); err != nil {
+			return "", err
+		}
+		sql, err := t.booleanExpression(op.args[0])
+		if err != nil {
+			return "", err
+		}
+		if argOp, ok := asOp(op.args[0]); ok && (argOp.op == "and" || argOp.op == "or") {
+			sql = "(" + sql + ")"
+		}
+		return "NOT " + sql, nil
+	}
+	return t.predicate(op)
+}
+
+func (t *cqlJSONTranspiler) predicate(op *cqlJSONOp) (string, error) {
+	if sqlOp, ok := cqlJSONComparison[op.op]; ok {
+		if err := op.checkArgs(2); err != nil {
+			return "", err
+		}
+		return t.binaryExpression(op.args[0], sqlOp, op.args[1])
+	}
+	switch op.op {
+	case "like", "ilike":
+		if err := op.checkArgs(2); err != nil {
+			return "", err
+		}
+		expr, err := t.scalarExpression(op.args[0], 0)
+		if err != nil {
+			return "", err
+		}
+		pattern, err := t.patternExpression(op.args[1])
+		if err != nil {
+			return "", err
+		}
+		return expr + " " + strings.ToUpper(op.op) + " " + pattern, nil
+	case "between":
+		if err := op.checkArgs(3); err != nil {
+			return "", err
+		}
+		vals := make([]string, 3)
+		for i, arg := range op.args {
+			sql, err := t.scalarExpression(arg, 0)
+			if err != nil {
+				return "", err
+			}
+			vals[i] = sql
+		}
+		return vals[0] + " BETWEEN " + vals[1] + " AND " + vals[2], nil
+	case "in":
+		if err := op.checkArgs(2); err != nil {
+			return "", err
+		}
+		return t.inPredicate(op.args[0], op.args[1])
+	case "isnull":
+		if err := op.checkArgs(1); err != nil {
+			return "", err
+		}
+		prop, err := t.propertyName(op.args[0])
+		if err != nil {
+			return "", err
+		}
+		return prop + " IS NULL", nil
+	}
+	if fun, ok := pgFunctionForCql[strings.TrimPrefix(op.op, "s_")]; ok {
+		return t.spatialPredicate(op, fun)
+	}
+	if _, ok := pgOperatorForArrayOp[op.op]; ok {
+		return t.arrayPredicate(op)
+	}
+	//-- a function returning a boolean
+	if isFunctionAllowed(op.op) {
+		return t.functionCall(op)
+	}
+	return "", fmt.Errorf("unsupported operator: %s", op.op)
+}
+
+// patternExpression transpiles a LIKE pattern, which is a string
+// possibly wrapped in CASEI or ACCENTI
+func (t *cqlJSONTranspiler) patternExpression(expr interface{}) (string, error) {
+	if pattern, ok := expr.(string); ok {
+		return stringLiteral(pattern), nil
+	}
+	if op, ok := asOp(expr); ok && isInsensitiveOp(op.op) {
+		return t.insensitiveExpression(op, t.patternExpression)
+	}
+	return "", fmt.Errorf("invalid pattern: %v", jsonText(expr))
+}
+
+// isInsensitiveOp tests if an operator is a case-insensitive or accent-insensitive function
+func isInsensitiveOp(op string) bool {
+	return op == "casei" || op == "accenti"
+}
+
+// insensitiveExpression transpiles a CASEI or ACCENTI function,
+// with the argument transpiled by a function depending on where it is used
+func (t *cqlJSONTranspiler) insensitiveExpression(op *cqlJSONOp, argExpression func(interface{}) (string, error)) (string, error) {
+	if err := op.checkArgs(1); err != nil {
+		return "", err
+	}
+	sql, err := argExpression(op.args[0])
+	if err != nil {
+		return "", err
+	}
+	if op.op == "casei" {
+		return sqlCaseInsensitive(sql), nil
+	}
+	return sqlAccentInsensitive(sql), nil
+}
+
+func (t *cqlJSONTranspiler) arrayPredicate(op *cqlJSONOp) (string, error) {
+	if err := op.checkArgs(2); err != nil {
+		return "", err
+	}
+	vals := make([]string, 2)
+	for i, arg := range op.args {
+		sql, err := t.arrayExpression(arg)
+		if err != nil {
+			return "", err
+		}
+		vals[i] = sql
+	}
+	return sqlArrayPredicate(op.op, vals[0], vals[1])
+}
+
+// arrayExpression transpiles an array literal, or an expression with an array value
+func (t *cqlJSONTranspiler) arrayExpression(expr interface{}) (string, error) {
+	items, ok := expr.([]interface{})
+	if !ok {
+		return t.scalarExpression(expr, 0)
+	}
+	vals := make([]string, len(items))
+	for i, item := range items {
+		sql, err := scalarLiteral(item)
+		if err != nil {
+			return "", err
+		}
+		vals[i] = sql
+	}
+	return sqlArrayLiteral(vals), nil
+}
+
+func (t *cqlJSONTranspiler) functionCall(op *cqlJSONOp) (string, error) {
+	args := make([]string, len(op.args))
+	for i, arg := range op.args {
+		sql, err := t.argExpression(arg)
+		if err != nil {
+			return "", err
+		}
+		args[i] = sql
+	}
+	return sqlFunctionCall(op.op, args)
+}
+
+// argExpression transpiles a function argument, which can be of any type
+func (t *cqlJSONTranspiler) argExpression(expr interface{}) (string, error) {
+	switch val := expr.(type) {
+	case []interface{}:
+		return t.arrayExpression(val)
+	case map[string]interface{}:
+		if isGeometryLiteral(val) {
+			return t.geomExpression(val)
+		}
+	}
+	return t.scalarExpression(expr, 0)
+}
+
+// isGeometryLiteral tests if an object is a GeoJSON geometry or an envelope
+func isGeometryLiteral(obj map[string]interface{}) bool {
+	_, hasBbox := obj["bbox"]
+	_, hasCoords := obj["coordinates"]
+	_, hasGeoms := obj["geometries"]
+	return hasBbox || hasCoords || hasGeoms
+}
+
+func (t *cqlJSONTranspiler) inPredicate(value interface{}, list interface{}) (string, error) {
+	prop, err := t.scalarExpression(value, 0)
+	if err != nil {
+		return "", err
+	}
+	items, ok := list.([]interface{})
+	if !ok || len(items) == 0 {
+		return "", fmt.Errorf("operator in requires a list of values")
+	}
+	var sb strings.Builder
+	sb.WriteString(prop)
+	sb.WriteString(" IN (")
+	for i, item := range items {
+		sql, err := t.scalarExpression(item, 0)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(sql)
+	}
+	sb.WriteString(")")
+	return sb.String(), nil
+}
+
+func (t *cqlJSONTranspiler) spatialPredicate(op *cqlJSONOp, fun string) (string, error) {
+	numArgs := 2
+	if fun == "ST_DWithin" {
+		numArgs = 3
+	}
+	if err := op.checkArgs(numArgs); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(fun)
+	sb.WriteString("(")
+	for i := 0; i < 2; i++ {
+		sql, err := t.geomExpression(op.args[i])
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(sql)
+	}
+	if numArgs == 3 {
+		dist, ok := op.args[2].(json.Number)
+		if !ok {
+			return "", fmt.Errorf("operator %s requires a numeric distance", op.op)
+		}
+		sb.WriteString(",")
+		sb.WriteString(dist.String())
+	}
+	sb.WriteString(")")
+	return sb.String(), nil
+}
+
+func (t *cqlJSONTranspiler) geomExpression(expr interface{}) (string, error) {
+	obj, ok := expr.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("invalid geometry expression: %v", jsonText(expr))
+	}
+	if _, ok := obj["property"]; ok {
+		return t.propertyName(obj)
+	}
+	if op, ok := asOp(obj); ok {
+		return t.functionCall(op)
+	}
+	if bbox, ok := obj["bbox"]; ok {
+		return t.envelopeLiteral(bbox)
+	}
+	if !isGeometryLiteral(obj) {
+		return "", fmt.Errorf("invalid geometry: %v", jsonText(obj))
+	}
+	//-- re-parse the object as GeoJSON
+	geomJSON, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	geom, err := geojson.UnmarshalGeometry(geomJSON)
+	if err != nil {
+		return "", fmt.Errorf("invalid geometry: %v", string(geomJSON))
+	}
+	sql := t.sqlGeometryLiteral(wkt.MarshalString(geom.Geometry()))
+	return t.sqlTransformCrs(sql), nil
+}
+
+func (t *cqlJSONTranspiler) envelopeLiteral(bbox interface{}) (string, error) {
+	nums, ok := bbox.([]interface{})
+	if !ok || len(nums) != 4 {
+		return "", fmt.Errorf("bbox requires 4 numbers")
+	}
+	vals := make([]string, 4)
+	for i, num := range nums {
+		n, ok := num.(json.Number)
+		if !ok {
+			return "", fmt.Errorf("bbox requires 4 numbers")
+		}
+		vals[i] = n.String()
+	}
+	sql := t.sqlEnvelopeLiteral(vals[0], vals[1], vals[2], vals[3])
+	return t.sqlTransformCrs(sql), nil
+}
+
+func (t *cqlJSONTranspiler) binaryExpression(left interface{}, op string, right interface{}) (string, error) {
+	expr1, err := t.scalarExpression(left, 0)
+	if err != nil {
+		return "", err
+	}
+	expr2, err := t.scalarExpression(right, 0)
+	if err != nil {
+		return "", err
+	}
+	return expr1 + " " + op + " " + expr2, nil
+}
+
+// scalarExpression transpiles a value or arithmetic expression.
+// The expression is parenthesized if it binds less than its parent operator.
+func (t *cqlJSONTranspiler) scalarExpression(expr interface{}, parentPrec int) (string, error) {
+	op, ok := asOp(expr)
+	if !ok {
+		return t.scalarValue(expr)
+	}
+	if isInsensitiveOp(op.op) {
+		return t.insensitiveExpression(op, func(arg interface{}) (string, error) {
+			return t.scalarExpression(arg, 0)
+		})
+	}
+	prec, ok := cqlJSONPrecedence[op.op]
+	if !ok && isFunctionAllowed(op.op) {
+		return t.functionCall(op)
+	}
+	if !ok || op.op == "and" || op.op == "or" {
+		return "", fmt.Errorf("unsupported operator in scalar expression: %s", op.op)
+	}
+	if err := op.checkArgs(2); err != nil {
+		return "", err
+	}
+	expr1, err := t.scalarExpression(op.args[0], prec)
+	if err != nil {
+		return "", err
+	}
+	//-- operators are left-associative
+	expr2, err := t.scalarExpression(op.args[1], prec+1)
+	if err != nil {
+		return "", err
+	}
+	sql := expr1 + " " + op.op + " " + expr2
+	if prec < parentPrec {
+		sql = "(" + sql + ")"
+	}
+	return sql, nil
+}
+
+func (t *cqlJSONTranspiler) scalarValue(expr interface{}) (string, error) {
+	if obj, ok := expr.(map[string]interface{}); ok {
+		if _, ok := obj["property"]; ok {
+			return t.propertyName(obj)
+		}
+		return temporalLiteral(obj)
+	}
+	return scalarLiteral(expr)
+}
+
+func (t *cqlJSONTranspiler) propertyName(expr interface{}) (string, error) {
+	obj, ok := expr.(map[string]interface{})
+	if ok {
+		if name, ok := obj["property"].(string); ok && len(name) > 0 {
+			return "\"" + strings.ReplaceAll(name, "\"", "\"\"") + "\"", nil
+		}
+	}
+	return "", fmt.Errorf("invalid property: %v", jsonText(expr))
+}
+
+func scalarLiteral(expr interface{}) (string, error) {
+	switch val := expr.(type) {
+	case string:
+		return stringLiteral(val), nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return booleanLiteral(val), nil
+	}
+	return "", fmt.Errorf("invalid literal: %v", jsonText(expr))
+}
+
+func temporalLiteral(obj map[string]interface{}) (string, error) {
+	val, ok := obj["timestamp"].(string)
+	if !ok {
+		val, ok = obj["date"].(string)
+	}
+	if !ok {
+		return "", fmt.Errorf("invalid value: %v", jsonText(obj))
+	}
+	if strings.EqualFold(val, "now") {
+		val = "NOW"
+	} else if !reTemporalLiteral.MatchString(val) {
+		return "", fmt.Errorf("invalid temporal value: %v", val)
+	}
+	return fmt.Sprintf("timestamp '%s'", val), nil
+}
+
+func stringLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func booleanLiteral(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// jsonText formats a JSON value for error messages
+func jsonText(expr interface{}) string {
+	b, err := json.Marshal(expr)
+	if err != nil {
+		return fmt.Sprintf("%v", expr)
+	}
+	return string(b)
+}
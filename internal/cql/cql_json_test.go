@@ -0,0 +1,182 @@
+package cql
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"testing"
+
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+func TestJSONComparisonPredicate(t *testing.T) {
+	checkCQLJSON(t, ``, "")
+	checkCQLJSON(t, `{"op":">","args":[{"property":"id"},{"property":"tt"}]}`, "id > tt")
+	checkCQLJSON(t, `{"op":">=","args":[{"property":"id"},1]}`, "id >= 1")
+	checkCQLJSON(t, `{"op":"<>","args":[{"property":"id"},1]}`, "id <> 1")
+	checkCQLJSON(t, `{"op":"=","args":[{"property":"id"},-1.2345]}`, "id = -1.2345")
+	checkCQLJSON(t, `{"op":"=","args":[{"property":"id"},"foo"]}`, "id = 'foo'")
+	checkCQLJSON(t, `{"op":">","args":[{"property":"p"},1.0E+1]}`, "p > 1.0E+1")
+}
+
+func TestJSONPredicates(t *testing.T) {
+	checkCQLJSON(t, `{"op":"like","args":[{"property":"id"},"foo"]}`, "id LIKE 'foo'")
+	checkCQLJSON(t, `{"op":"ilike","args":[{"property":"id"},"%Ca%"]}`, "id ILIKE '%Ca%'")
+	checkCQLJSON(t, `{"op":"between","args":[{"property":"id"},1,2]}`, "id BETWEEN 1 AND 2")
+	checkCQLJSON(t, `{"op":"not","args":[{"op":"between","args":[{"property":"id"},1,2]}]}`, "NOT id BETWEEN 1 AND 2")
+	checkCQLJSON(t, `{"op":"in","args":[{"property":"id"},[1,2,3]]}`, "id IN (1,2,3)")
+	checkCQLJSON(t, `{"op":"in","args":[{"property":"id"},["a","b","c"]]}`, "id IN ('a','b','c')")
+	checkCQLJSON(t, `{"op":"isNull","args":[{"property":"id"}]}`, "id IS NULL")
+	checkCQLJSON(t, `{"op":"not","args":[{"op":"isNull","args":[{"property":"x"}]}]}`, "NOT x IS NULL")
+}
+
+func TestJSONSpatialPredicate(t *testing.T) {
+	checkCQLJSON(t, `{"op":"s_intersects","args":[{"property":"geom"},{"type":"Point","coordinates":[0,0]}]}`,
+		"INTERSECTS(geom, POINT(0 0))")
+	checkCQLJSON(t, `{"op":"s_within","args":[{"property":"geom"},{"type":"Polygon","coordinates":[[[0,0],[0,9],[9,0],[0,0]]]}]}`,
+		"WITHIN(geom, POLYGON((0 0, 0 9, 9 0, 0 0)))")
+	checkCQLJSON(t, `{"op":"s_equals","args":[{"property":"geom"},{"type":"MultiPoint","coordinates":[[0,0],[0,9]]}]}`,
+		"EQUALS(geom, MULTIPOINT((0 0), (0 9)))")
+	checkCQLJSON(t, `{"op":"s_equals","args":[{"property":"geom"},{"bbox":[1,2,3,4]}]}`,
+		"EQUALS(geom, ENVELOPE(1,2,3,4))")
+	checkCQLJSON(t, `{"op":"dwithin","args":[{"property":"geom"},{"type":"Point","coordinates":[0,0]},100]}`,
+		"DWITHIN(geom, POINT(0 0), 100)")
+
+	sql, err := TranspileJSONToSQL(`{"op":"s_equals","args":[{"property":"geom"},{"bbox":[1,2,3,4]}]}`, 1111, 2222)
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	util.Equals(t, "ST_Equals(\"geom\",ST_Transform(ST_MakeEnvelope(1,2,3,4,1111),2222))", sql, "")
+}
+
+func TestJSONArithmetic(t *testing.T) {
+	checkCQLJSON(t, `{"op":">","args":[{"property":"p"},{"op":"+","args":[{"op":"*","args":[2,3]},{"property":"x"}]}]}`,
+		"p > 2 * 3 + x")
+	checkCQLJSON(t, `{"op":">","args":[{"property":"p"},{"op":"*","args":[2,{"op":"+","args":[3,{"property":"x"}]}]}]}`,
+		"p > 2 * (3 + x)")
+	checkCQLJSON(t, `{"op":">","args":[{"property":"p"},{"op":"/","args":[{"op":"+","args":[{"property":"y"},5]},{"op":"-","args":[3,{"property":"x"}]}]}]}`,
+		"p > (y + 5) / (3 - x)")
+}
+
+func TestJSONBooleanExpression(t *testing.T) {
+	checkCQLJSON(t, `{"op":"and","args":[{"op":">","args":[{"property":"x"},1]},{"op":"<","args":[{"property":"x"},9]}]}`,
+		"x > 1 AND x < 9")
+	checkCQLJSON(t, `{"op":"and","args":[{"op":"or","args":[{"op":"=","args":[{"property":"x"},1]},{"op":"=","args":[{"property":"x"},2]}]},{"op":"<","args":[{"property":"y"},4]}]}`,
+		"(x = 1 OR x = 2) AND y < 4")
+	checkCQLJSON(t, `{"op":"or","args":[{"op":"=","args":[{"property":"x"},1]},{"op":"not","args":[{"op":"and","args":[{"op":"=","args":[{"property":"x"},2]},{"op":"<","args":[{"property":"y"},4]}]}]}]}`,
+		"x = 1 OR NOT (x = 2 AND y < 4)")
+	checkCQLJSON(t, `{"op":"or","args":[{"op":"not","args":[true]},false]}`, "NOT TRUE OR FALSE")
+}
+
+func TestJSONTemporal(t *testing.T) {
+	checkCQLJSON(t, `{"op":"between","args":[{"property":"p"},{"date":"1991-01-01"},{"timestamp":"2000-12-31T01:59:59"}]}`,
+		"p BETWEEN 1991-01-01 AND 2000-12-31T01:59:59")
+	checkCQLJSON(t, `{"op":">","args":[{"property":"p"},{"timestamp":"now"}]}`, "p > NOW()")
+}
+
+func TestJSONQuoting(t *testing.T) {
+	checkCQLJSONSQL(t, `{"op":"=","args":[{"property":"a\"b"},"it's"]}`, `"a""b" = 'it''s'`)
+}
+
+func TestJSONErrors(t *testing.T) {
+	checkCQLJSONError(t, `{"op":"=","args":[{"property":"x"},1]`)
+	checkCQLJSONError(t, `{"op":"=","args":[{"property":"x"},1]} {}`)
+	checkCQLJSONError(t, `{"op":"=","args":[{"property":"x"}]}`)
+	checkCQLJSONError(t, `{"op":"foo","args":[{"property":"x"},1]}`)
+	checkCQLJSONError(t, `{"op":"=","args":[{"property":"p"},{"op":"||","args":["a",{"property":"x"}]}]}`)
+	checkCQLJSONError(t, `{"op":"and","args":[{"op":"+","args":[1,2]},true]}`)
+	checkCQLJSONError(t, `{"op":"like","args":[{"property":"x"},1]}`)
+	checkCQLJSONError(t, `{"op":"in","args":[{"property":"x"},[]]}`)
+	checkCQLJSONError(t, `{"op":"s_intersects","args":[{"property":"geom"},{"type":"Point"}]}`)
+	checkCQLJSONError(t, `{"op":">","args":[{"property":"p"},{"timestamp":"2000-01-01'; DROP"}]}`)
+	checkCQLJSONError(t, `"x"`)
+}
+
+func TestJSONAdvancedComparison(t *testing.T) {
+	checkCQLJSONSQL(t, `{"op":"=","args":[{"op":"casei","args":[{"property":"name"}]},{"op":"casei","args":["Paris"]}]}`,
+		`lower("name") = lower('Paris')`)
+	checkCQLJSONSQL(t, `{"op":"like","args":[{"op":"accenti","args":[{"op":"casei","args":[{"property":"name"}]}]},{"op":"accenti","args":[{"op":"casei","args":["%été%"]}]}]}`,
+		`unaccent(lower("name")) LIKE unaccent(lower('%été%'))`)
+	checkCQLJSONSQL(t, `{"op":"in","args":[{"op":"casei","args":[{"property":"name"}]},[{"op":"casei","args":["a"]},"b"]]}`,
+		`lower("name") IN (lower('a'),'b')`)
+	checkCQLJSONError(t, `{"op":"like","args":[{"property":"name"},{"op":"casei","args":[1]}]}`)
+	checkCQLJSONError(t, `{"op":"=","args":[{"op":"casei","args":[{"property":"name"},"a"]},"b"]}`)
+	// the non-standard object form is not supported
+	checkCQLJSONError(t, `{"op":"=","args":[{"casei":{"property":"name"}},"b"]}`)
+}
+
+func TestJSONArrayPredicate(t *testing.T) {
+	checkCQLJSONSQL(t, `{"op":"a_contains","args":[{"property":"tags"},["a","b"]]}`, `("tags" @> ARRAY['a','b'])`)
+	checkCQLJSONSQL(t, `{"op":"a_containedBy","args":[{"property":"vals"},[1,2,3]]}`, `("vals" <@ ARRAY[1,2,3])`)
+	checkCQLJSONSQL(t, `{"op":"A_OVERLAPS","args":[{"property":"vals"},{"property":"vals2"}]}`, `("vals" && "vals2")`)
+	checkCQLJSONSQL(t, `{"op":"a_equals","args":[{"property":"vals"},[]]}`, `("vals" = '{}')`)
+	checkCQLJSONError(t, `{"op":"a_contains","args":[{"property":"tags"}]}`)
+	checkCQLJSONError(t, `{"op":"a_contains","args":[{"property":"tags"},[["a"]]]}`)
+}
+
+func TestJSONFunction(t *testing.T) {
+	InitFunctions([]string{"upper", "ST_Buffer", "is_valid_code"})
+	defer InitFunctions(nil)
+
+	checkCQLJSONSQL(t, `{"op":"=","args":[{"op":"upper","args":[{"property":"name"}]},"PARIS"]}`,
+		`upper("name") = 'PARIS'`)
+	checkCQLJSONSQL(t, `{"op":"s_intersects","args":[{"property":"geom"},{"op":"st_buffer","args":[{"type":"Point","coordinates":[0,0]},10]}]}`,
+		`ST_Intersects("geom",ST_Buffer('SRID=4326;POINT(0 0)'::geometry,10))`)
+	checkCQLJSONSQL(t, `{"op":"and","args":[{"op":"is_valid_code","args":[{"property":"code"}]},{"op":">","args":[{"op":"+","args":[{"op":"upper","args":["a"]},1]},2]}]}`,
+		`is_valid_code("code") AND upper('a') + 1 > 2`)
+	checkCQLJSONError(t, `{"op":"=","args":[{"op":"lower","args":[{"property":"name"}]},"paris"]}`)
+	checkCQLJSONError(t, `{"op":"pg_sleep","args":[10]}`)
+}
+
+// checks that filters are transpiled while the functions are set again (run with -race to detect unguarded accesses)
+func TestJSONFunctionReload(t *testing.T) {
+	InitFunctions([]string{"upper"})
+	defer InitFunctions(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			InitFunctions([]string{"upper", "lower"})
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			checkCQLJSONSQL(t, `{"op":"=","args":[{"op":"lower","args":[{"property":"name"}]},"paris"]}`,
+				`lower("name") = 'paris'`)
+			return
+		default:
+		}
+		checkCQLJSONSQL(t, `{"op":"=","args":[{"op":"upper","args":[{"property":"name"}]},"PARIS"]}`,
+			`upper("name") = 'PARIS'`)
+	}
+}
+
+// checkCQLJSON checks that a CQL2-JSON filter transpiles to the same SQL as its CQL2-Text equivalent
+func checkCQLJSON(t *testing.T, cqlJSON string, cqlStr string) {
+	expected, err := TranspileToSQL(cqlStr, 4326, 4326)
+	util.Assert(t, err == nil, "CQL text error: %v", err)
+	actual, err := TranspileJSONToSQL(cqlJSON, 4326, 4326)
+	util.Assert(t, err == nil, "CQL2-JSON error: %v", err)
+	util.Equals(t, expected, actual, cqlJSON)
+}
+
+func checkCQLJSONSQL(t *testing.T, cqlJSON string, sql string) {
+	actual, err := TranspileJSONToSQL(cqlJSON, 4326, 4326)
+	util.Assert(t, err == nil, "CQL2-JSON error: %v", err)
+	util.Equals(t, sql, actual, cqlJSON)
+}
+
+func checkCQLJSONError(t *testing.T, cqlJSON string) {
+	_, err := TranspileJSONToSQL(cqlJSON, 4326, 4326)
+	util.AssertIsError(t, err, cqlJSON)
+}
@@ -15,143 +15,164 @@ var _ = reflect.Copy
 var _ = strconv.Itoa
 
 var parserATN = []uint16{
-	3, 24715, 42794, 33075, 47597, 16764, 15335, 30598, 22884, 3, 85, 322,
-	4, 2, 9, 2, 4, 3, 9, 3, 4, 4, 9, 4, 4, 5, 9, 5, 4, 6, 9, 6, 4, 7, 9, 7,
-	4, 8, 9, 8, 4, 9, 9, 9, 4, 10, 9, 10, 4, 11, 9, 11, 4, 12, 9, 12, 4, 13,
-	9, 13, 4, 14, 9, 14, 4, 15, 9, 15, 4, 16, 9, 16, 4, 17, 9, 17, 4, 18, 9,
-	18, 4, 19, 9, 19, 4, 20, 9, 20, 4, 21, 9, 21, 4, 22, 9, 22, 4, 23, 9, 23,
-	4, 24, 9, 24, 4, 25, 9, 25, 4, 26, 9, 26, 4, 27, 9, 27, 4, 28, 9, 28, 4,
-	29, 9, 29, 4, 30, 9, 30, 4, 31, 9, 31, 4, 32, 9, 32, 4, 33, 9, 33, 4, 34,
-	9, 34, 3, 2, 3, 2, 3, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	5, 3, 80, 10, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 7, 3, 88, 10, 3, 12,
-	3, 14, 3, 91, 11, 3, 3, 4, 3, 4, 5, 4, 95, 10, 4, 3, 5, 3, 5, 3, 5, 5,
-	5, 100, 10, 5, 3, 6, 3, 6, 3, 6, 3, 6, 3, 6, 5, 6, 107, 10, 6, 3, 7, 3,
-	7, 3, 7, 3, 7, 3, 8, 3, 8, 5, 8, 115, 10, 8, 3, 8, 3, 8, 3, 8, 3, 9, 3,
-	9, 5, 9, 122, 10, 9, 3, 9, 3, 9, 3, 9, 3, 9, 3, 9, 3, 10, 3, 10, 5, 10,
-	131, 10, 10, 3, 10, 3, 10, 3, 10, 3, 10, 3, 10, 7, 10, 138, 10, 10, 12,
-	10, 14, 10, 141, 11, 10, 3, 10, 3, 10, 3, 10, 7, 10, 146, 10, 10, 12, 10,
-	14, 10, 149, 11, 10, 5, 10, 151, 10, 10, 3, 10, 3, 10, 3, 11, 3, 11, 3,
-	11, 5, 11, 158, 10, 11, 3, 11, 3, 11, 3, 12, 3, 12, 3, 12, 3, 12, 3, 12,
-	3, 12, 5, 12, 168, 10, 12, 3, 12, 3, 12, 3, 12, 7, 12, 173, 10, 12, 12,
-	12, 14, 12, 176, 11, 12, 3, 13, 3, 13, 3, 13, 3, 13, 3, 13, 5, 13, 183,
-	10, 13, 3, 14, 3, 14, 3, 15, 3, 15, 3, 16, 3, 16, 3, 17, 3, 17, 3, 18,
-	3, 18, 3, 19, 3, 19, 3, 19, 3, 19, 3, 19, 3, 19, 3, 19, 3, 20, 3, 20, 3,
-	20, 3, 20, 3, 20, 3, 20, 3, 20, 3, 20, 3, 20, 3, 21, 3, 21, 5, 21, 213,
-	10, 21, 3, 22, 3, 22, 3, 22, 3, 22, 3, 22, 3, 22, 3, 22, 3, 22, 5, 22,
-	223, 10, 22, 3, 23, 3, 23, 3, 23, 3, 24, 3, 24, 3, 24, 3, 24, 3, 25, 3,
-	25, 3, 25, 3, 26, 3, 26, 3, 26, 3, 27, 3, 27, 3, 27, 3, 27, 7, 27, 242,
-	10, 27, 12, 27, 14, 27, 245, 11, 27, 3, 27, 3, 27, 3, 28, 3, 28, 3, 28,
-	3, 28, 3, 28, 7, 28, 254, 10, 28, 12, 28, 14, 28, 257, 11, 28, 3, 28, 3,
-	28, 3, 29, 3, 29, 3, 29, 3, 29, 3, 29, 7, 29, 266, 10, 29, 12, 29, 14,
-	29, 269, 11, 29, 3, 29, 3, 29, 3, 30, 3, 30, 3, 30, 3, 30, 3, 30, 7, 30,
-	278, 10, 30, 12, 30, 14, 30, 281, 11, 30, 3, 30, 3, 30, 3, 31, 3, 31, 3,
-	31, 3, 31, 3, 31, 7, 31, 290, 10, 31, 12, 31, 14, 31, 293, 11, 31, 3, 31,
-	3, 31, 3, 32, 3, 32, 3, 32, 3, 32, 3, 32, 3, 32, 3, 32, 3, 32, 3, 32, 3,
-	32, 3, 32, 3, 33, 3, 33, 3, 33, 3, 33, 7, 33, 312, 10, 33, 12, 33, 14,
-	33, 315, 11, 33, 3, 33, 3, 33, 3, 34, 3, 34, 3, 34, 3, 34, 2, 4, 4, 22,
-	35, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22, 24, 26, 28, 30, 32, 34, 36,
-	38, 40, 42, 44, 46, 48, 50, 52, 54, 56, 58, 60, 62, 64, 66, 2, 3, 3, 2,
-	14, 15, 2, 326, 2, 68, 3, 2, 2, 2, 4, 79, 3, 2, 2, 2, 6, 94, 3, 2, 2, 2,
-	8, 99, 3, 2, 2, 2, 10, 106, 3, 2, 2, 2, 12, 108, 3, 2, 2, 2, 14, 112, 3,
-	2, 2, 2, 16, 119, 3, 2, 2, 2, 18, 128, 3, 2, 2, 2, 20, 154, 3, 2, 2, 2,
-	22, 167, 3, 2, 2, 2, 24, 182, 3, 2, 2, 2, 26, 184, 3, 2, 2, 2, 28, 186,
-	3, 2, 2, 2, 30, 188, 3, 2, 2, 2, 32, 190, 3, 2, 2, 2, 34, 192, 3, 2, 2,
-	2, 36, 194, 3, 2, 2, 2, 38, 201, 3, 2, 2, 2, 40, 212, 3, 2, 2, 2, 42, 222,
-	3, 2, 2, 2, 44, 224, 3, 2, 2, 2, 46, 227, 3, 2, 2, 2, 48, 231, 3, 2, 2,
-	2, 50, 234, 3, 2, 2, 2, 52, 237, 3, 2, 2, 2, 54, 248, 3, 2, 2, 2, 56, 260,
-	3, 2, 2, 2, 58, 272, 3, 2, 2, 2, 60, 284, 3, 2, 2, 2, 62, 296, 3, 2, 2,
-	2, 64, 307, 3, 2, 2, 2, 66, 318, 3, 2, 2, 2, 68, 69, 5, 4, 3, 2, 69, 70,
-	7, 2, 2, 3, 70, 3, 3, 2, 2, 2, 71, 72, 8, 3, 1, 2, 72, 73, 7, 44, 2, 2,
-	73, 74, 5, 4, 3, 2, 74, 75, 7, 45, 2, 2, 75, 80, 3, 2, 2, 2, 76, 77, 7,
-	13, 2, 2, 77, 80, 5, 4, 3, 4, 78, 80, 5, 6, 4, 2, 79, 71, 3, 2, 2, 2, 79,
-	76, 3, 2, 2, 2, 79, 78, 3, 2, 2, 2, 80, 89, 3, 2, 2, 2, 81, 82, 12, 6,
-	2, 2, 82, 83, 7, 11, 2, 2, 83, 88, 5, 4, 3, 7, 84, 85, 12, 5, 2, 2, 85,
-	86, 7, 12, 2, 2, 86, 88, 5, 4, 3, 6, 87, 81, 3, 2, 2, 2, 87, 84, 3, 2,
-	2, 2, 88, 91, 3, 2, 2, 2, 89, 87, 3, 2, 2, 2, 89, 90, 3, 2, 2, 2, 90, 5,
-	3, 2, 2, 2, 91, 89, 3, 2, 2, 2, 92, 95, 5, 8, 5, 2, 93, 95, 5, 32, 17,
-	2, 94, 92, 3, 2, 2, 2, 94, 93, 3, 2, 2, 2, 95, 7, 3, 2, 2, 2, 96, 100,
-	5, 10, 6, 2, 97, 100, 5, 36, 19, 2, 98, 100, 5, 38, 20, 2, 99, 96, 3, 2,
-	2, 2, 99, 97, 3, 2, 2, 2, 99, 98, 3, 2, 2, 2, 100, 9, 3, 2, 2, 2, 101,
-	107, 5, 12, 7, 2, 102, 107, 5, 14, 8, 2, 103, 107, 5, 16, 9, 2, 104, 107,
-	5, 18, 10, 2, 105, 107, 5, 20, 11, 2, 106, 101, 3, 2, 2, 2, 106, 102, 3,
-	2, 2, 2, 106, 103, 3, 2, 2, 2, 106, 104, 3, 2, 2, 2, 106, 105, 3, 2, 2,
-	2, 107, 11, 3, 2, 2, 2, 108, 109, 5, 22, 12, 2, 109, 110, 7, 3, 2, 2, 110,
-	111, 5, 22, 12, 2, 111, 13, 3, 2, 2, 2, 112, 114, 5, 26, 14, 2, 113, 115,
-	7, 13, 2, 2, 114, 113, 3, 2, 2, 2, 114, 115, 3, 2, 2, 2, 115, 116, 3, 2,
-	2, 2, 116, 117, 9, 2, 2, 2, 117, 118, 5, 28, 15, 2, 118, 15, 3, 2, 2, 2,
-	119, 121, 5, 22, 12, 2, 120, 122, 7, 13, 2, 2, 121, 120, 3, 2, 2, 2, 121,
-	122, 3, 2, 2, 2, 122, 123, 3, 2, 2, 2, 123, 124, 7, 16, 2, 2, 124, 125,
-	5, 22, 12, 2, 125, 126, 7, 11, 2, 2, 126, 127, 5, 22, 12, 2, 127, 17, 3,
-	2, 2, 2, 128, 130, 5, 26, 14, 2, 129, 131, 7, 13, 2, 2, 130, 129, 3, 2,
-	2, 2, 130, 131, 3, 2, 2, 2, 131, 132, 3, 2, 2, 2, 132, 133, 7, 19, 2, 2,
-	133, 150, 7, 44, 2, 2, 134, 139, 5, 28, 15, 2, 135, 136, 7, 50, 2, 2, 136,
-	138, 5, 28, 15, 2, 137, 135, 3, 2, 2, 2, 138, 141, 3, 2, 2, 2, 139, 137,
-	3, 2, 2, 2, 139, 140, 3, 2, 2, 2, 140, 151, 3, 2, 2, 2, 141, 139, 3, 2,
-	2, 2, 142, 147, 5, 30, 16, 2, 143, 144, 7, 50, 2, 2, 144, 146, 5, 30, 16,
-	2, 145, 143, 3, 2, 2, 2, 146, 149, 3, 2, 2, 2, 147, 145, 3, 2, 2, 2, 147,
-	148, 3, 2, 2, 2, 148, 151, 3, 2, 2, 2, 149, 147, 3, 2, 2, 2, 150, 134,
-	3, 2, 2, 2, 150, 142, 3, 2, 2, 2, 151, 152, 3, 2, 2, 2, 152, 153, 7, 45,
-	2, 2, 153, 19, 3, 2, 2, 2, 154, 155, 5, 26, 14, 2, 155, 157, 7, 17, 2,
-	2, 156, 158, 7, 13, 2, 2, 157, 156, 3, 2, 2, 2, 157, 158, 3, 2, 2, 2, 158,
-	159, 3, 2, 2, 2, 159, 160, 7, 18, 2, 2, 160, 21, 3, 2, 2, 2, 161, 162,
-	8, 12, 1, 2, 162, 168, 5, 24, 13, 2, 163, 164, 7, 44, 2, 2, 164, 165, 5,
-	22, 12, 2, 165, 166, 7, 45, 2, 2, 166, 168, 3, 2, 2, 2, 167, 161, 3, 2,
-	2, 2, 167, 163, 3, 2, 2, 2, 168, 174, 3, 2, 2, 2, 169, 170, 12, 3, 2, 2,
-	170, 171, 7, 20, 2, 2, 171, 173, 5, 22, 12, 4, 172, 169, 3, 2, 2, 2, 173,
-	176, 3, 2, 2, 2, 174, 172, 3, 2, 2, 2, 174, 175, 3, 2, 2, 2, 175, 23, 3,
-	2, 2, 2, 176, 174, 3, 2, 2, 2, 177, 183, 5, 26, 14, 2, 178, 183, 5, 28,
-	15, 2, 179, 183, 5, 30, 16, 2, 180, 183, 5, 32, 17, 2, 181, 183, 5, 34,
-	18, 2, 182, 177, 3, 2, 2, 2, 182, 178, 3, 2, 2, 2, 182, 179, 3, 2, 2, 2,
-	182, 180, 3, 2, 2, 2, 182, 181, 3, 2, 2, 2, 183, 25, 3, 2, 2, 2, 184, 185,
-	7, 32, 2, 2, 185, 27, 3, 2, 2, 2, 186, 187, 7, 84, 2, 2, 187, 29, 3, 2,
-	2, 2, 188, 189, 7, 31, 2, 2, 189, 31, 3, 2, 2, 2, 190, 191, 7, 10, 2, 2,
-	191, 33, 3, 2, 2, 2, 192, 193, 7, 71, 2, 2, 193, 35, 3, 2, 2, 2, 194, 195,
-	7, 21, 2, 2, 195, 196, 7, 44, 2, 2, 196, 197, 5, 40, 21, 2, 197, 198, 7,
-	50, 2, 2, 198, 199, 5, 40, 21, 2, 199, 200, 7, 45, 2, 2, 200, 37, 3, 2,
-	2, 2, 201, 202, 7, 22, 2, 2, 202, 203, 7, 44, 2, 2, 203, 204, 5, 40, 21,
-	2, 204, 205, 7, 50, 2, 2, 205, 206, 5, 40, 21, 2, 206, 207, 7, 50, 2, 2,
-	207, 208, 7, 31, 2, 2, 208, 209, 7, 45, 2, 2, 209, 39, 3, 2, 2, 2, 210,
-	213, 5, 26, 14, 2, 211, 213, 5, 42, 22, 2, 212, 210, 3, 2, 2, 2, 212, 211,
-	3, 2, 2, 2, 213, 41, 3, 2, 2, 2, 214, 223, 5, 44, 23, 2, 215, 223, 5, 48,
-	25, 2, 216, 223, 5, 50, 26, 2, 217, 223, 5, 54, 28, 2, 218, 223, 5, 56,
-	29, 2, 219, 223, 5, 58, 30, 2, 220, 223, 5, 60, 31, 2, 221, 223, 5, 62,
-	32, 2, 222, 214, 3, 2, 2, 2, 222, 215, 3, 2, 2, 2, 222, 216, 3, 2, 2, 2,
-	222, 217, 3, 2, 2, 2, 222, 218, 3, 2, 2, 2, 222, 219, 3, 2, 2, 2, 222,
-	220, 3, 2, 2, 2, 222, 221, 3, 2, 2, 2, 223, 43, 3, 2, 2, 2, 224, 225, 7,
-	23, 2, 2, 225, 226, 5, 46, 24, 2, 226, 45, 3, 2, 2, 2, 227, 228, 7, 44,
-	2, 2, 228, 229, 5, 66, 34, 2, 229, 230, 7, 45, 2, 2, 230, 47, 3, 2, 2,
-	2, 231, 232, 7, 24, 2, 2, 232, 233, 5, 64, 33, 2, 233, 49, 3, 2, 2, 2,
+	3, 24715, 42794, 33075, 47597, 16764, 15335, 30598, 22884, 3, 85, 368, 4,
+	2, 9, 2, 4, 3, 9, 3, 4, 4, 9, 4, 4, 5, 9, 5, 4, 6, 9, 6, 4, 7, 9, 7, 4, 8,
+	9, 8, 4, 9, 9, 9, 4, 10, 9, 10, 4, 11, 9, 11, 4, 12, 9, 12, 4, 13, 9, 13,
+	4, 14, 9, 14, 4, 15, 9, 15, 4, 16, 9, 16, 4, 17, 9, 17, 4, 18, 9, 18, 4,
+	19, 9, 19, 4, 20, 9, 20, 4, 21, 9, 21, 4, 22, 9, 22, 4, 23, 9, 23, 4, 24,
+	9, 24, 4, 25, 9, 25, 4, 26, 9, 26, 4, 27, 9, 27, 4, 28, 9, 28, 4, 29, 9,
+	29, 4, 30, 9, 30, 4, 31, 9, 31, 4, 32, 9, 32, 4, 33, 9, 33, 4, 34, 9, 34,
+	3, 2, 3, 2, 3, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 5, 3,
+	80, 10, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 7, 3, 88, 10, 3, 12, 3, 14,
+	3, 91, 11, 3, 3, 4, 3, 4, 5, 4, 95, 10, 4, 3, 5, 3, 5, 3, 5, 5, 5, 100,
+	10, 5, 3, 6, 3, 6, 3, 6, 3, 6, 3, 6, 5, 6, 107, 10, 6, 3, 7, 3, 7, 3, 7,
+	3, 7, 3, 8, 3, 8, 5, 8, 115, 10, 8, 3, 8, 3, 8, 3, 8, 3, 9, 3, 9, 5, 9,
+	122, 10, 9, 3, 9, 3, 9, 3, 9, 3, 9, 3, 9, 3, 10, 3, 10, 5, 10, 131, 10,
+	10, 3, 10, 3, 10, 3, 10, 3, 10, 3, 10, 7, 10, 138, 10, 10, 12, 10, 14, 10,
+	141, 11, 10, 3, 10, 3, 10, 3, 10, 7, 10, 146, 10, 10, 12, 10, 14, 10, 149,
+	11, 10, 5, 10, 151, 10, 10, 3, 10, 3, 10, 3, 11, 3, 11, 3, 11, 5, 11, 158,
+	10, 11, 3, 11, 3, 11, 3, 12, 3, 12, 3, 12, 3, 12, 3, 12, 3, 12, 5, 12,
+	168, 10, 12, 3, 12, 3, 12, 3, 12, 7, 12, 173, 10, 12, 12, 12, 14, 12, 176,
+	11, 12, 3, 13, 3, 13, 3, 13, 3, 13, 3, 13, 5, 13, 183, 10, 13, 3, 14, 3,
+	14, 3, 15, 3, 15, 3, 16, 3, 16, 3, 17, 3, 17, 3, 18, 3, 18, 3, 19, 3, 19,
+	3, 19, 3, 19, 3, 19, 3, 19, 3, 19, 3, 20, 3, 20, 3, 20, 3, 20, 3, 20, 3,
+	20, 3, 20, 3, 20, 3, 20, 3, 21, 3, 21, 5, 21, 213, 10, 21, 3, 22, 3, 22,
+	3, 22, 3, 22, 3, 22, 3, 22, 3, 22, 3, 22, 5, 22, 223, 10, 22, 3, 23, 3,
+	23, 3, 23, 3, 24, 3, 24, 3, 24, 3, 24, 3, 25, 3, 25, 3, 25, 3, 26, 3, 26,
+	3, 26, 3, 27, 3, 27, 3, 27, 3, 27, 7, 27, 242, 10, 27, 12, 27, 14, 27,
+	245, 11, 27, 3, 27, 3, 27, 3, 28, 3, 28, 3, 28, 3, 28, 3, 28, 7, 28, 254,
+	10, 28, 12, 28, 14, 28, 257, 11, 28, 3, 28, 3, 28, 3, 29, 3, 29, 3, 29, 3,
+	29, 3, 29, 7, 29, 266, 10, 29, 12, 29, 14, 29, 269, 11, 29, 3, 29, 3, 29,
+	3, 30, 3, 30, 3, 30, 3, 30, 3, 30, 7, 30, 278, 10, 30, 12, 30, 14, 30,
+	281, 11, 30, 3, 30, 3, 30, 3, 31, 3, 31, 3, 31, 3, 31, 3, 31, 7, 31, 290,
+	10, 31, 12, 31, 14, 31, 293, 11, 31, 3, 31, 3, 31, 3, 32, 3, 32, 3, 32, 3,
+	32, 3, 32, 3, 32, 3, 32, 3, 32, 3, 32, 3, 32, 3, 32, 3, 33, 3, 33, 3, 33,
+	3, 33, 7, 33, 312, 10, 33, 12, 33, 14, 33, 315, 11, 33, 3, 33, 3, 33, 3,
+	34, 3, 34, 3, 34, 3, 34, 4, 35, 9, 35, 4, 36, 9, 36, 4, 37, 9, 37, 3, 5,
+	3, 13, 3, 21, 5, 8, 332, 10, 8, 3, 8, 5, 8, 335, 10, 8, 3, 8, 3, 35, 3,
+	35, 5, 35, 340, 10, 35, 3, 35, 12, 35, 7, 35, 344, 10, 35, 3, 35, 3, 35,
+	14, 35, 348, 11, 35, 3, 35, 3, 35, 5, 36, 352, 10, 36, 3, 36, 3, 36, 3,
+	36, 3, 37, 5, 37, 358, 10, 37, 3, 37, 6, 37, 361, 10, 37, 3, 37, 3, 37,
+	13, 37, 14, 37, 364, 3, 37, 3, 37, 2, 4, 4, 22, 38, 2, 4, 6, 8, 10, 12,
+	14, 16, 18, 20, 22, 24, 26, 28, 30, 32, 34, 36, 38, 40, 42, 44, 46, 48,
+	50, 52, 54, 56, 58, 60, 62, 64, 66, 322, 324, 326, 2, 3, 3, 2, 14, 15, 2,
+	380, 2, 68, 3, 2, 2, 2, 4, 79, 3, 2, 2, 2, 6, 94, 3, 2, 2, 2, 8, 99, 3, 2,
+	2, 2, 10, 106, 3, 2, 2, 2, 12, 108, 3, 2, 2, 2, 14, 331, 3, 2, 2, 2, 16,
+	119, 3, 2, 2, 2, 18, 128, 3, 2, 2, 2, 20, 154, 3, 2, 2, 2, 22, 167, 3, 2,
+	2, 2, 24, 182, 3, 2, 2, 2, 26, 184, 3, 2, 2, 2, 28, 186, 3, 2, 2, 2, 30,
+	188, 3, 2, 2, 2, 32, 190, 3, 2, 2, 2, 34, 192, 3, 2, 2, 2, 36, 194, 3, 2,
+	2, 2, 38, 201, 3, 2, 2, 2, 40, 212, 3, 2, 2, 2, 42, 222, 3, 2, 2, 2, 44,
+	224, 3, 2, 2, 2, 46, 227, 3, 2, 2, 2, 48, 231, 3, 2, 2, 2, 50, 234, 3, 2,
+	2, 2, 52, 237, 3, 2, 2, 2, 54, 248, 3, 2, 2, 2, 56, 260, 3, 2, 2, 2, 58,
+	272, 3, 2, 2, 2, 60, 284, 3, 2, 2, 2, 62, 296, 3, 2, 2, 2, 64, 307, 3, 2,
+	2, 2, 66, 318, 3, 2, 2, 2, 68, 69, 5, 4, 3, 2, 69, 70, 7, 2, 2, 3, 70, 3,
+	3, 2, 2, 2, 71, 72, 8, 3, 1, 2, 72, 73, 7, 44, 2, 2, 73, 74, 5, 4, 3, 2,
+	74, 75, 7, 45, 2, 2, 75, 80, 3, 2, 2, 2, 76, 77, 7, 13, 2, 2, 77, 80, 5,
+	4, 3, 4, 78, 80, 5, 6, 4, 2, 79, 71, 3, 2, 2, 2, 79, 76, 3, 2, 2, 2, 79,
+	78, 3, 2, 2, 2, 80, 89, 3, 2, 2, 2, 81, 82, 12, 6, 2, 2, 82, 83, 7, 11, 2,
+	2, 83, 88, 5, 4, 3, 7, 84, 85, 12, 5, 2, 2, 85, 86, 7, 12, 2, 2, 86, 88,
+	5, 4, 3, 6, 87, 81, 3, 2, 2, 2, 87, 84, 3, 2, 2, 2, 88, 91, 3, 2, 2, 2,
+	89, 87, 3, 2, 2, 2, 89, 90, 3, 2, 2, 2, 90, 5, 3, 2, 2, 2, 91, 89, 3, 2,
+	2, 2, 92, 95, 5, 8, 5, 2, 93, 95, 5, 32, 17, 2, 94, 92, 3, 2, 2, 2, 94,
+	93, 3, 2, 2, 2, 95, 7, 3, 2, 2, 2, 96, 100, 5, 10, 6, 2, 97, 100, 5, 36,
+	19, 2, 98, 100, 5, 38, 20, 2, 99, 96, 3, 2, 2, 2, 99, 97, 3, 2, 2, 2, 99,
+	98, 3, 2, 2, 2, 99, 328, 3, 2, 2, 2, 100, 9, 3, 2, 2, 2, 101, 107, 5, 12,
+	7, 2, 102, 107, 5, 14, 8, 2, 103, 107, 5, 16, 9, 2, 104, 107, 5, 18, 10,
+	2, 105, 107, 5, 20, 11, 2, 106, 101, 3, 2, 2, 2, 106, 102, 3, 2, 2, 2,
+	106, 103, 3, 2, 2, 2, 106, 104, 3, 2, 2, 2, 106, 105, 3, 2, 2, 2, 107, 11,
+	3, 2, 2, 2, 108, 109, 5, 22, 12, 2, 109, 110, 7, 3, 2, 2, 110, 111, 5, 22,
+	12, 2, 111, 13, 3, 2, 2, 2, 112, 332, 5, 26, 14, 2, 113, 115, 7, 13, 2, 2,
+	114, 113, 3, 2, 2, 2, 114, 115, 3, 2, 2, 2, 115, 116, 3, 2, 2, 2, 116,
+	334, 9, 2, 2, 2, 117, 335, 5, 28, 15, 2, 118, 15, 3, 2, 2, 2, 119, 121, 5,
+	22, 12, 2, 120, 122, 7, 13, 2, 2, 121, 120, 3, 2, 2, 2, 121, 122, 3, 2, 2,
+	2, 122, 123, 3, 2, 2, 2, 123, 124, 7, 16, 2, 2, 124, 125, 5, 22, 12, 2,
+	125, 126, 7, 11, 2, 2, 126, 127, 5, 22, 12, 2, 127, 17, 3, 2, 2, 2, 128,
+	130, 5, 26, 14, 2, 129, 131, 7, 13, 2, 2, 130, 129, 3, 2, 2, 2, 130, 131,
+	3, 2, 2, 2, 131, 132, 3, 2, 2, 2, 132, 133, 7, 19, 2, 2, 133, 150, 7, 44,
+	2, 2, 134, 139, 5, 28, 15, 2, 135, 136, 7, 50, 2, 2, 136, 138, 5, 28, 15,
+	2, 137, 135, 3, 2, 2, 2, 138, 141, 3, 2, 2, 2, 139, 137, 3, 2, 2, 2, 139,
+	140, 3, 2, 2, 2, 140, 151, 3, 2, 2, 2, 141, 139, 3, 2, 2, 2, 142, 147, 5,
+	30, 16, 2, 143, 144, 7, 50, 2, 2, 144, 146, 5, 30, 16, 2, 145, 143, 3, 2,
+	2, 2, 146, 149, 3, 2, 2, 2, 147, 145, 3, 2, 2, 2, 147, 148, 3, 2, 2, 2,
+	148, 151, 3, 2, 2, 2, 149, 147, 3, 2, 2, 2, 150, 134, 3, 2, 2, 2, 150,
+	142, 3, 2, 2, 2, 151, 152, 3, 2, 2, 2, 152, 153, 7, 45, 2, 2, 153, 19, 3,
+	2, 2, 2, 154, 155, 5, 26, 14, 2, 155, 157, 7, 17, 2, 2, 156, 158, 7, 13,
+	2, 2, 157, 156, 3, 2, 2, 2, 157, 158, 3, 2, 2, 2, 158, 159, 3, 2, 2, 2,
+	159, 160, 7, 18, 2, 2, 160, 21, 3, 2, 2, 2, 161, 162, 8, 12, 1, 2, 162,
+	168, 5, 24, 13, 2, 163, 164, 7, 44, 2, 2, 164, 165, 5, 22, 12, 2, 165,
+	166, 7, 45, 2, 2, 166, 168, 3, 2, 2, 2, 167, 161, 3, 2, 2, 2, 167, 163, 3,
+	2, 2, 2, 168, 174, 3, 2, 2, 2, 169, 170, 12, 3, 2, 2, 170, 171, 7, 20, 2,
+	2, 171, 173, 5, 22, 12, 4, 172, 169, 3, 2, 2, 2, 173, 176, 3, 2, 2, 2,
+	174, 172, 3, 2, 2, 2, 174, 175, 3, 2, 2, 2, 175, 23, 3, 2, 2, 2, 176, 174,
+	3, 2, 2, 2, 177, 183, 5, 26, 14, 2, 178, 183, 5, 28, 15, 2, 179, 183, 5,
+	30, 16, 2, 180, 183, 5, 32, 17, 2, 181, 183, 5, 34, 18, 2, 182, 177, 3, 2,
+	2, 2, 182, 178, 3, 2, 2, 2, 182, 179, 3, 2, 2, 2, 182, 180, 3, 2, 2, 2,
+	182, 181, 3, 2, 2, 2, 182, 329, 3, 2, 2, 2, 183, 25, 3, 2, 2, 2, 184, 185,
+	7, 32, 2, 2, 185, 27, 3, 2, 2, 2, 186, 187, 7, 84, 2, 2, 187, 29, 3, 2, 2,
+	2, 188, 189, 7, 31, 2, 2, 189, 31, 3, 2, 2, 2, 190, 191, 7, 10, 2, 2, 191,
+	33, 3, 2, 2, 2, 192, 193, 7, 71, 2, 2, 193, 35, 3, 2, 2, 2, 194, 195, 7,
+	21, 2, 2, 195, 196, 7, 44, 2, 2, 196, 197, 5, 40, 21, 2, 197, 198, 7, 50,
+	2, 2, 198, 199, 5, 40, 21, 2, 199, 200, 7, 45, 2, 2, 200, 37, 3, 2, 2, 2,
+	201, 202, 7, 22, 2, 2, 202, 203, 7, 44, 2, 2, 203, 204, 5, 40, 21, 2, 204,
+	205, 7, 50, 2, 2, 205, 206, 5, 40, 21, 2, 206, 207, 7, 50, 2, 2, 207, 208,
+	7, 31, 2, 2, 208, 209, 7, 45, 2, 2, 209, 39, 3, 2, 2, 2, 210, 213, 5, 26,
+	14, 2, 211, 213, 5, 42, 22, 2, 212, 210, 3, 2, 2, 2, 212, 211, 3, 2, 2, 2,
+	212, 330, 3, 2, 2, 2, 213, 41, 3, 2, 2, 2, 214, 223, 5, 44, 23, 2, 215,
+	223, 5, 48, 25, 2, 216, 223, 5, 50, 26, 2, 217, 223, 5, 54, 28, 2, 218,
+	223, 5, 56, 29, 2, 219, 223, 5, 58, 30, 2, 220, 223, 5, 60, 31, 2, 221,
+	223, 5, 62, 32, 2, 222, 214, 3, 2, 2, 2, 222, 215, 3, 2, 2, 2, 222, 216,
+	3, 2, 2, 2, 222, 217, 3, 2, 2, 2, 222, 218, 3, 2, 2, 2, 222, 219, 3, 2, 2,
+	2, 222, 220, 3, 2, 2, 2, 222, 221, 3, 2, 2, 2, 223, 43, 3, 2, 2, 2, 224,
+	225, 7, 23, 2, 2, 225, 226, 5, 46, 24, 2, 226, 45, 3, 2, 2, 2, 227, 228,
+	7, 44, 2, 2, 228, 229, 5, 66, 34, 2, 229, 230, 7, 45, 2, 2, 230, 47, 3, 2,
+	2, 2, 231, 232, 7, 24, 2, 2, 232, 233, 5, 64, 33, 2, 233, 49, 3, 2, 2, 2,
 	234, 235, 7, 25, 2, 2, 235, 236, 5, 52, 27, 2, 236, 51, 3, 2, 2, 2, 237,
 	238, 7, 44, 2, 2, 238, 243, 5, 64, 33, 2, 239, 240, 7, 50, 2, 2, 240, 242,
-	5, 64, 33, 2, 241, 239, 3, 2, 2, 2, 242, 245, 3, 2, 2, 2, 243, 241, 3,
-	2, 2, 2, 243, 244, 3, 2, 2, 2, 244, 246, 3, 2, 2, 2, 245, 243, 3, 2, 2,
-	2, 246, 247, 7, 45, 2, 2, 247, 53, 3, 2, 2, 2, 248, 249, 7, 26, 2, 2, 249,
+	5, 64, 33, 2, 241, 239, 3, 2, 2, 2, 242, 245, 3, 2, 2, 2, 243, 241, 3, 2,
+	2, 2, 243, 244, 3, 2, 2, 2, 244, 246, 3, 2, 2, 2, 245, 243, 3, 2, 2, 2,
+	246, 247, 7, 45, 2, 2, 247, 53, 3, 2, 2, 2, 248, 249, 7, 26, 2, 2, 249,
 	250, 7, 44, 2, 2, 250, 255, 5, 46, 24, 2, 251, 252, 7, 50, 2, 2, 252, 254,
-	5, 46, 24, 2, 253, 251, 3, 2, 2, 2, 254, 257, 3, 2, 2, 2, 255, 253, 3,
-	2, 2, 2, 255, 256, 3, 2, 2, 2, 256, 258, 3, 2, 2, 2, 257, 255, 3, 2, 2,
-	2, 258, 259, 7, 45, 2, 2, 259, 55, 3, 2, 2, 2, 260, 261, 7, 27, 2, 2, 261,
+	5, 46, 24, 2, 253, 251, 3, 2, 2, 2, 254, 257, 3, 2, 2, 2, 255, 253, 3, 2,
+	2, 2, 255, 256, 3, 2, 2, 2, 256, 258, 3, 2, 2, 2, 257, 255, 3, 2, 2, 2,
+	258, 259, 7, 45, 2, 2, 259, 55, 3, 2, 2, 2, 260, 261, 7, 27, 2, 2, 261,
 	262, 7, 44, 2, 2, 262, 267, 5, 64, 33, 2, 263, 264, 7, 50, 2, 2, 264, 266,
-	5, 64, 33, 2, 265, 263, 3, 2, 2, 2, 266, 269, 3, 2, 2, 2, 267, 265, 3,
-	2, 2, 2, 267, 268, 3, 2, 2, 2, 268, 270, 3, 2, 2, 2, 269, 267, 3, 2, 2,
-	2, 270, 271, 7, 45, 2, 2, 271, 57, 3, 2, 2, 2, 272, 273, 7, 28, 2, 2, 273,
+	5, 64, 33, 2, 265, 263, 3, 2, 2, 2, 266, 269, 3, 2, 2, 2, 267, 265, 3, 2,
+	2, 2, 267, 268, 3, 2, 2, 2, 268, 270, 3, 2, 2, 2, 269, 267, 3, 2, 2, 2,
+	270, 271, 7, 45, 2, 2, 271, 57, 3, 2, 2, 2, 272, 273, 7, 28, 2, 2, 273,
 	274, 7, 44, 2, 2, 274, 279, 5, 52, 27, 2, 275, 276, 7, 50, 2, 2, 276, 278,
-	5, 52, 27, 2, 277, 275, 3, 2, 2, 2, 278, 281, 3, 2, 2, 2, 279, 277, 3,
-	2, 2, 2, 279, 280, 3, 2, 2, 2, 280, 282, 3, 2, 2, 2, 281, 279, 3, 2, 2,
-	2, 282, 283, 7, 45, 2, 2, 283, 59, 3, 2, 2, 2, 284, 285, 7, 29, 2, 2, 285,
+	5, 52, 27, 2, 277, 275, 3, 2, 2, 2, 278, 281, 3, 2, 2, 2, 279, 277, 3, 2,
+	2, 2, 279, 280, 3, 2, 2, 2, 280, 282, 3, 2, 2, 2, 281, 279, 3, 2, 2, 2,
+	282, 283, 7, 45, 2, 2, 283, 59, 3, 2, 2, 2, 284, 285, 7, 29, 2, 2, 285,
 	286, 7, 44, 2, 2, 286, 291, 5, 42, 22, 2, 287, 288, 7, 50, 2, 2, 288, 290,
-	5, 42, 22, 2, 289, 287, 3, 2, 2, 2, 290, 293, 3, 2, 2, 2, 291, 289, 3,
-	2, 2, 2, 291, 292, 3, 2, 2, 2, 292, 294, 3, 2, 2, 2, 293, 291, 3, 2, 2,
-	2, 294, 295, 7, 45, 2, 2, 295, 61, 3, 2, 2, 2, 296, 297, 7, 30, 2, 2, 297,
+	5, 42, 22, 2, 289, 287, 3, 2, 2, 2, 290, 293, 3, 2, 2, 2, 291, 289, 3, 2,
+	2, 2, 291, 292, 3, 2, 2, 2, 292, 294, 3, 2, 2, 2, 293, 291, 3, 2, 2, 2,
+	294, 295, 7, 45, 2, 2, 295, 61, 3, 2, 2, 2, 296, 297, 7, 30, 2, 2, 297,
 	298, 7, 44, 2, 2, 298, 299, 7, 31, 2, 2, 299, 300, 7, 50, 2, 2, 300, 301,
 	7, 31, 2, 2, 301, 302, 7, 50, 2, 2, 302, 303, 7, 31, 2, 2, 303, 304, 7,
-	50, 2, 2, 304, 305, 7, 31, 2, 2, 305, 306, 7, 45, 2, 2, 306, 63, 3, 2,
-	2, 2, 307, 308, 7, 44, 2, 2, 308, 313, 5, 66, 34, 2, 309, 310, 7, 50, 2,
-	2, 310, 312, 5, 66, 34, 2, 311, 309, 3, 2, 2, 2, 312, 315, 3, 2, 2, 2,
-	313, 311, 3, 2, 2, 2, 313, 314, 3, 2, 2, 2, 314, 316, 3, 2, 2, 2, 315,
-	313, 3, 2, 2, 2, 316, 317, 7, 45, 2, 2, 317, 65, 3, 2, 2, 2, 318, 319,
-	7, 31, 2, 2, 319, 320, 7, 31, 2, 2, 320, 67, 3, 2, 2, 2, 26, 79, 87, 89,
-	94, 99, 106, 114, 121, 130, 139, 147, 150, 157, 167, 174, 182, 212, 222,
-	243, 255, 267, 279, 291, 313,
+	50, 2, 2, 304, 305, 7, 31, 2, 2, 305, 306, 7, 45, 2, 2, 306, 63, 3, 2, 2,
+	2, 307, 308, 7, 44, 2, 2, 308, 313, 5, 66, 34, 2, 309, 310, 7, 50, 2, 2,
+	310, 312, 5, 66, 34, 2, 311, 309, 3, 2, 2, 2, 312, 315, 3, 2, 2, 2, 313,
+	311, 3, 2, 2, 2, 313, 314, 3, 2, 2, 2, 314, 316, 3, 2, 2, 2, 315, 313, 3,
+	2, 2, 2, 316, 317, 7, 45, 2, 2, 317, 65, 3, 2, 2, 2, 318, 319, 7, 31, 2,
+	2, 319, 320, 7, 31, 2, 2, 320, 67, 3, 2, 2, 2, 322, 337, 3, 2, 2, 2, 324,
+	351, 3, 2, 2, 2, 326, 356, 3, 2, 2, 2, 328, 100, 5, 322, 35, 2, 329, 183,
+	5, 322, 35, 2, 330, 213, 5, 322, 35, 2, 331, 112, 3, 2, 2, 2, 331, 333, 3,
+	2, 2, 2, 332, 114, 3, 2, 2, 2, 333, 332, 5, 322, 35, 2, 334, 117, 3, 2, 2,
+	2, 334, 336, 3, 2, 2, 2, 335, 118, 3, 2, 2, 2, 336, 335, 5, 322, 35, 2,
+	337, 338, 7, 32, 2, 2, 338, 339, 7, 44, 2, 2, 339, 341, 3, 2, 2, 2, 339,
+	340, 3, 2, 2, 2, 340, 349, 3, 2, 2, 2, 341, 342, 5, 324, 36, 2, 342, 343,
+	3, 2, 2, 2, 342, 347, 3, 2, 2, 2, 343, 345, 3, 2, 2, 2, 344, 348, 3, 2, 2,
+	2, 345, 346, 7, 50, 2, 2, 346, 344, 5, 324, 36, 2, 347, 340, 3, 2, 2, 2,
+	348, 342, 3, 2, 2, 2, 349, 350, 7, 45, 2, 2, 350, 323, 3, 2, 2, 2, 351,
+	353, 3, 2, 2, 2, 351, 354, 3, 2, 2, 2, 351, 355, 3, 2, 2, 2, 352, 325, 3,
+	2, 2, 2, 353, 352, 5, 22, 12, 2, 354, 352, 5, 42, 22, 2, 355, 352, 5, 326,
+	37, 2, 356, 357, 7, 44, 2, 2, 357, 359, 3, 2, 2, 2, 357, 358, 3, 2, 2, 2,
+	358, 366, 3, 2, 2, 2, 359, 360, 5, 22, 12, 2, 360, 362, 3, 2, 2, 2, 361,
+	364, 3, 2, 2, 2, 362, 363, 7, 50, 2, 2, 363, 361, 5, 22, 12, 2, 364, 360,
+	3, 2, 2, 2, 364, 365, 3, 2, 2, 2, 365, 358, 3, 2, 2, 2, 366, 367, 7, 45,
+	2, 2, 367, 327, 3, 2, 2, 2, 33, 79, 87, 89, 94, 99, 106, 114, 121, 130,
+	139, 147, 150, 157, 167, 174, 182, 212, 222, 243, 255, 267, 279, 291, 313,
+	331, 334, 339, 342, 351, 357, 364,
 }
 var deserializer = antlr.NewATNDeserializer(nil)
 var deserializedATN = deserializer.DeserializeFromUInt16(parserATN)
@@ -189,6 +210,7 @@ var ruleNames = []string{
 	"distancePredicate", "geomExpression", "geomLiteral", "point", "pointList",
 	"linestring", "polygon", "polygonDef", "multiPoint", "multiLinestring",
 	"multiPolygon", "geometryCollection", "envelope", "coordList", "coordinate",
+	"function", "argument", "arrayLiteral",
 }
 var decisionToDFA = make([]*antlr.DFA, len(deserializedATN.DecisionToState))
 
@@ -339,6 +361,9 @@ const (
 	CQLParserRULE_envelope                  = 30
 	CQLParserRULE_coordList                 = 31
 	CQLParserRULE_coordinate                = 32
+	CQLParserRULE_function                  = 33
+	CQLParserRULE_argument                  = 34
+	CQLParserRULE_arrayLiteral              = 35
 )
 
 // ICqlFilterContext is an interface to support dynamic dispatch.
@@ -1095,6 +1120,16 @@ func (s *PredicateContext) DistancePredicate() IDistancePredicateContext {
 	return t.(IDistancePredicateContext)
 }
 
+func (s *PredicateContext) Function() IFunctionContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IFunctionContext)(nil)).Elem(), 0)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IFunctionContext)
+}
+
 func (s *PredicateContext) GetRuleContext() antlr.RuleContext {
 	return s
 }
@@ -1137,31 +1172,35 @@ func (p *CQLParser) Predicate() (localctx IPredicateContext) {
 
 	p.SetState(97)
 	p.GetErrorHandler().Sync(p)
-
-	switch p.GetTokenStream().LA(1) {
-	case CQLParserBooleanLiteral, CQLParserNumericLiteral, CQLParserIdentifier, CQLParserLEFTPAREN, CQLParserTemporalLiteral, CQLParserCharacterStringLiteral:
+	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 4, p.GetParserRuleContext()) {
+	case 1:
 		p.EnterOuterAlt(localctx, 1)
 		{
 			p.SetState(94)
 			p.ComparisonPredicate()
 		}
 
-	case CQLParserSpatialOperator:
+	case 2:
 		p.EnterOuterAlt(localctx, 2)
 		{
 			p.SetState(95)
 			p.SpatialPredicate()
 		}
 
-	case CQLParserDistanceOperator:
+	case 3:
 		p.EnterOuterAlt(localctx, 3)
 		{
 			p.SetState(96)
 			p.DistancePredicate()
 		}
 
-	default:
-		panic(antlr.NewNoViableAltException(p, nil, nil, nil, nil, nil))
+	case 4:
+		p.EnterOuterAlt(localctx, 4)
+		{
+			p.SetState(326)
+			p.Function()
+		}
+
 	}
 
 	return localctx
@@ -1697,6 +1736,29 @@ func (s *IsLikePredicateContext) PropertyName() IPropertyNameContext {
 	return t.(IPropertyNameContext)
 }
 
+func (s *IsLikePredicateContext) AllFunction() []IFunctionContext {
+	var ts = s.GetTypedRuleContexts(reflect.TypeOf((*IFunctionContext)(nil)).Elem())
+	var tst = make([]IFunctionContext, len(ts))
+
+	for i, t := range ts {
+		if t != nil {
+			tst[i] = t.(IFunctionContext)
+		}
+	}
+
+	return tst
+}
+
+func (s *IsLikePredicateContext) Function(i int) IFunctionContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IFunctionContext)(nil)).Elem(), i)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IFunctionContext)
+}
+
 func (s *IsLikePredicateContext) CharacterLiteral() ICharacterLiteralContext {
 	var t = s.GetTypedRuleContext(reflect.TypeOf((*ICharacterLiteralContext)(nil)).Elem(), 0)
 
@@ -1761,9 +1823,21 @@ func (p *CQLParser) IsLikePredicate() (localctx IIsLikePredicateContext) {
 	}()
 
 	p.EnterOuterAlt(localctx, 1)
-	{
-		p.SetState(110)
-		p.PropertyName()
+	p.SetState(329)
+	p.GetErrorHandler().Sync(p)
+	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 24, p.GetParserRuleContext()) {
+	case 1:
+		{
+			p.SetState(110)
+			p.PropertyName()
+		}
+
+	case 2:
+		{
+			p.SetState(331)
+			p.Function()
+		}
+
 	}
 	p.SetState(112)
 	p.GetErrorHandler().Sync(p)
@@ -1785,9 +1859,24 @@ func (p *CQLParser) IsLikePredicate() (localctx IIsLikePredicateContext) {
 		p.GetErrorHandler().ReportMatch(p)
 		p.Consume()
 	}
-	{
-		p.SetState(115)
-		p.CharacterLiteral()
+	p.SetState(332)
+	p.GetErrorHandler().Sync(p)
+
+	switch p.GetTokenStream().LA(1) {
+	case CQLParserCharacterStringLiteral:
+		{
+			p.SetState(115)
+			p.CharacterLiteral()
+		}
+
+	case CQLParserIdentifier:
+		{
+			p.SetState(334)
+			p.Function()
+		}
+
+	default:
+		panic(antlr.NewNoViableAltException(p, nil, nil, nil, nil, nil))
 	}
 
 	return localctx
@@ -2904,6 +2993,46 @@ func (s *LiteralBooleanContext) ExitRule(listener antlr.ParseTreeListener) {
 	}
 }
 
+type ScalarFunctionContext struct {
+	*ScalarValueContext
+}
+
+func NewScalarFunctionContext(parser antlr.Parser, ctx antlr.ParserRuleContext) *ScalarFunctionContext {
+	var p = new(ScalarFunctionContext)
+
+	p.ScalarValueContext = NewEmptyScalarValueContext()
+	p.parser = parser
+	p.CopyFrom(ctx.(*ScalarValueContext))
+
+	return p
+}
+
+func (s *ScalarFunctionContext) GetRuleContext() antlr.RuleContext {
+	return s
+}
+
+func (s *ScalarFunctionContext) Function() IFunctionContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IFunctionContext)(nil)).Elem(), 0)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IFunctionContext)
+}
+
+func (s *ScalarFunctionContext) EnterRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(CQLParserListener); ok {
+		listenerT.EnterScalarFunction(s)
+	}
+}
+
+func (s *ScalarFunctionContext) ExitRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(CQLParserListener); ok {
+		listenerT.ExitScalarFunction(s)
+	}
+}
+
 func (p *CQLParser) ScalarValue() (localctx IScalarValueContext) {
 	localctx = NewScalarValueContext(p, p.GetParserRuleContext(), p.GetState())
 	p.EnterRule(localctx, 22, CQLParserRULE_scalarValue)
@@ -2926,9 +3055,8 @@ func (p *CQLParser) ScalarValue() (localctx IScalarValueContext) {
 
 	p.SetState(180)
 	p.GetErrorHandler().Sync(p)
-
-	switch p.GetTokenStream().LA(1) {
-	case CQLParserIdentifier:
+	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 15, p.GetParserRuleContext()) {
+	case 1:
 		localctx = NewLiteralNameContext(p, localctx)
 		p.EnterOuterAlt(localctx, 1)
 		{
@@ -2936,7 +3064,7 @@ func (p *CQLParser) ScalarValue() (localctx IScalarValueContext) {
 			p.PropertyName()
 		}
 
-	case CQLParserCharacterStringLiteral:
+	case 2:
 		localctx = NewLiteralStringContext(p, localctx)
 		p.EnterOuterAlt(localctx, 2)
 		{
@@ -2944,7 +3072,7 @@ func (p *CQLParser) ScalarValue() (localctx IScalarValueContext) {
 			p.CharacterLiteral()
 		}
 
-	case CQLParserNumericLiteral:
+	case 3:
 		localctx = NewLiteralNumericContext(p, localctx)
 		p.EnterOuterAlt(localctx, 3)
 		{
@@ -2952,7 +3080,7 @@ func (p *CQLParser) ScalarValue() (localctx IScalarValueContext) {
 			p.NumericLiteral()
 		}
 
-	case CQLParserBooleanLiteral:
+	case 4:
 		localctx = NewLiteralBooleanContext(p, localctx)
 		p.EnterOuterAlt(localctx, 4)
 		{
@@ -2960,7 +3088,7 @@ func (p *CQLParser) ScalarValue() (localctx IScalarValueContext) {
 			p.BooleanLiteral()
 		}
 
-	case CQLParserTemporalLiteral:
+	case 5:
 		localctx = NewLiteralTemporalContext(p, localctx)
 		p.EnterOuterAlt(localctx, 5)
 		{
@@ -2968,8 +3096,14 @@ func (p *CQLParser) ScalarValue() (localctx IScalarValueContext) {
 			p.TemporalLiteral()
 		}
 
-	default:
-		panic(antlr.NewNoViableAltException(p, nil, nil, nil, nil, nil))
+	case 6:
+		localctx = NewScalarFunctionContext(p, localctx)
+		p.EnterOuterAlt(localctx, 6)
+		{
+			p.SetState(327)
+			p.Function()
+		}
+
 	}
 
 	return localctx
@@ -3796,6 +3930,16 @@ func (s *GeomExpressionContext) GeomLiteral() IGeomLiteralContext {
 	return t.(IGeomLiteralContext)
 }
 
+func (s *GeomExpressionContext) Function() IFunctionContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IFunctionContext)(nil)).Elem(), 0)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IFunctionContext)
+}
+
 func (s *GeomExpressionContext) GetRuleContext() antlr.RuleContext {
 	return s
 }
@@ -3838,24 +3982,28 @@ func (p *CQLParser) GeomExpression() (localctx IGeomExpressionContext) {
 
 	p.SetState(210)
 	p.GetErrorHandler().Sync(p)
-
-	switch p.GetTokenStream().LA(1) {
-	case CQLParserIdentifier:
+	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 16, p.GetParserRuleContext()) {
+	case 1:
 		p.EnterOuterAlt(localctx, 1)
 		{
 			p.SetState(208)
 			p.PropertyName()
 		}
 
-	case CQLParserPOINT, CQLParserLINESTRING, CQLParserPOLYGON, CQLParserMULTIPOINT, CQLParserMULTILINESTRING, CQLParserMULTIPOLYGON, CQLParserGEOMETRYCOLLECTION, CQLParserENVELOPE:
+	case 2:
 		p.EnterOuterAlt(localctx, 2)
 		{
 			p.SetState(209)
 			p.GeomLiteral()
 		}
 
-	default:
-		panic(antlr.NewNoViableAltException(p, nil, nil, nil, nil, nil))
+	case 3:
+		p.EnterOuterAlt(localctx, 3)
+		{
+			p.SetState(328)
+			p.Function()
+		}
+
 	}
 
 	return localctx
@@ -5714,6 +5862,471 @@ func (p *CQLParser) Coordinate() (localctx ICoordinateContext) {
 	return localctx
 }
 
+// IFunctionContext is an interface to support dynamic dispatch.
+type IFunctionContext interface {
+	antlr.ParserRuleContext
+
+	// GetParser returns the parser.
+	GetParser() antlr.Parser
+
+	// IsFunctionContext differentiates from other interfaces.
+	IsFunctionContext()
+}
+
+type FunctionContext struct {
+	*CqlContext
+	parser antlr.Parser
+}
+
+func NewEmptyFunctionContext() *FunctionContext {
+	var p = new(FunctionContext)
+	p.CqlContext = NewCqlContext(nil, -1)
+	p.RuleIndex = CQLParserRULE_function
+	return p
+}
+
+func (*FunctionContext) IsFunctionContext() {}
+
+func NewFunctionContext(parser antlr.Parser, parent antlr.ParserRuleContext, invokingState int) *FunctionContext {
+	var p = new(FunctionContext)
+
+	p.CqlContext = NewCqlContext(parent, invokingState)
+
+	p.parser = parser
+	p.RuleIndex = CQLParserRULE_function
+
+	return p
+}
+
+func (s *FunctionContext) GetParser() antlr.Parser { return s.parser }
+
+func (s *FunctionContext) Identifier() antlr.TerminalNode {
+	return s.GetToken(CQLParserIdentifier, 0)
+}
+
+func (s *FunctionContext) LEFTPAREN() antlr.TerminalNode {
+	return s.GetToken(CQLParserLEFTPAREN, 0)
+}
+
+func (s *FunctionContext) RIGHTPAREN() antlr.TerminalNode {
+	return s.GetToken(CQLParserRIGHTPAREN, 0)
+}
+
+func (s *FunctionContext) AllArgument() []IArgumentContext {
+	var ts = s.GetTypedRuleContexts(reflect.TypeOf((*IArgumentContext)(nil)).Elem())
+	var tst = make([]IArgumentContext, len(ts))
+
+	for i, t := range ts {
+		if t != nil {
+			tst[i] = t.(IArgumentContext)
+		}
+	}
+
+	return tst
+}
+
+func (s *FunctionContext) Argument(i int) IArgumentContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IArgumentContext)(nil)).Elem(), i)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IArgumentContext)
+}
+
+func (s *FunctionContext) AllCOMMA() []antlr.TerminalNode {
+	return s.GetTokens(CQLParserCOMMA)
+}
+
+func (s *FunctionContext) COMMA(i int) antlr.TerminalNode {
+	return s.GetToken(CQLParserCOMMA, i)
+}
+
+func (s *FunctionContext) GetRuleContext() antlr.RuleContext {
+	return s
+}
+
+func (s *FunctionContext) ToStringTree(ruleNames []string, recog antlr.Recognizer) string {
+	return antlr.TreesStringTree(s, ruleNames, recog)
+}
+
+func (s *FunctionContext) EnterRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(CQLParserListener); ok {
+		listenerT.EnterFunction(s)
+	}
+}
+
+func (s *FunctionContext) ExitRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(CQLParserListener); ok {
+		listenerT.ExitFunction(s)
+	}
+}
+
+func (p *CQLParser) Function() (localctx IFunctionContext) {
+	localctx = NewFunctionContext(p, p.GetParserRuleContext(), p.GetState())
+	p.EnterRule(localctx, 320, CQLParserRULE_function)
+	var _la int
+
+	defer func() {
+		p.ExitRule()
+	}()
+
+	defer func() {
+		if err := recover(); err != nil {
+			if v, ok := err.(antlr.RecognitionException); ok {
+				localctx.SetException(v)
+				p.GetErrorHandler().ReportError(p, v)
+				p.GetErrorHandler().Recover(p, v)
+			} else {
+				panic(err)
+			}
+		}
+	}()
+
+	p.EnterOuterAlt(localctx, 1)
+	{
+		p.SetState(335)
+		p.Match(CQLParserIdentifier)
+	}
+	{
+		p.SetState(336)
+		p.Match(CQLParserLEFTPAREN)
+	}
+	p.SetState(337)
+	p.GetErrorHandler().Sync(p)
+	_la = p.GetTokenStream().LA(1)
+
+	if (((_la)&-(0x1f+1)) == 0 && ((1<<uint(_la))&((1<<CQLParserBooleanLiteral)|(1<<CQLParserPOINT)|(1<<CQLParserLINESTRING)|(1<<CQLParserPOLYGON)|(1<<CQLParserMULTIPOINT)|(1<<CQLParserMULTILINESTRING)|(1<<CQLParserMULTIPOLYGON)|(1<<CQLParserGEOMETRYCOLLECTION)|(1<<CQLParserENVELOPE)|(1<<CQLParserNumericLiteral)|(1<<CQLParserIdentifier))) != 0) || (((_la-42)&-(0x1f+1)) == 0 && ((1<<uint((_la-42)))&((1<<(CQLParserLEFTPAREN-42))|(1<<(CQLParserTemporalLiteral-42)))) != 0) || _la == CQLParserCharacterStringLiteral {
+		{
+			p.SetState(339)
+			p.Argument()
+		}
+		p.SetState(340)
+		p.GetErrorHandler().Sync(p)
+		_la = p.GetTokenStream().LA(1)
+
+		for _la == CQLParserCOMMA {
+			{
+				p.SetState(343)
+				p.Match(CQLParserCOMMA)
+			}
+			{
+				p.SetState(344)
+				p.Argument()
+			}
+
+			p.SetState(346)
+			p.GetErrorHandler().Sync(p)
+			_la = p.GetTokenStream().LA(1)
+		}
+
+	}
+	{
+		p.SetState(347)
+		p.Match(CQLParserRIGHTPAREN)
+	}
+
+	return localctx
+}
+
+// IArgumentContext is an interface to support dynamic dispatch.
+type IArgumentContext interface {
+	antlr.ParserRuleContext
+
+	// GetParser returns the parser.
+	GetParser() antlr.Parser
+
+	// IsArgumentContext differentiates from other interfaces.
+	IsArgumentContext()
+}
+
+type ArgumentContext struct {
+	*CqlContext
+	parser antlr.Parser
+}
+
+func NewEmptyArgumentContext() *ArgumentContext {
+	var p = new(ArgumentContext)
+	p.CqlContext = NewCqlContext(nil, -1)
+	p.RuleIndex = CQLParserRULE_argument
+	return p
+}
+
+func (*ArgumentContext) IsArgumentContext() {}
+
+func NewArgumentContext(parser antlr.Parser, parent antlr.ParserRuleContext, invokingState int) *ArgumentContext {
+	var p = new(ArgumentContext)
+
+	p.CqlContext = NewCqlContext(parent, invokingState)
+
+	p.parser = parser
+	p.RuleIndex = CQLParserRULE_argument
+
+	return p
+}
+
+func (s *ArgumentContext) GetParser() antlr.Parser { return s.parser }
+
+func (s *ArgumentContext) ScalarExpression() IScalarExpressionContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IScalarExpressionContext)(nil)).Elem(), 0)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IScalarExpressionContext)
+}
+
+func (s *ArgumentContext) GeomLiteral() IGeomLiteralContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IGeomLiteralContext)(nil)).Elem(), 0)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IGeomLiteralContext)
+}
+
+func (s *ArgumentContext) ArrayLiteral() IArrayLiteralContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IArrayLiteralContext)(nil)).Elem(), 0)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IArrayLiteralContext)
+}
+
+func (s *ArgumentContext) GetRuleContext() antlr.RuleContext {
+	return s
+}
+
+func (s *ArgumentContext) ToStringTree(ruleNames []string, recog antlr.Recognizer) string {
+	return antlr.TreesStringTree(s, ruleNames, recog)
+}
+
+func (s *ArgumentContext) EnterRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(CQLParserListener); ok {
+		listenerT.EnterArgument(s)
+	}
+}
+
+func (s *ArgumentContext) ExitRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(CQLParserListener); ok {
+		listenerT.ExitArgument(s)
+	}
+}
+
+func (p *CQLParser) Argument() (localctx IArgumentContext) {
+	localctx = NewArgumentContext(p, p.GetParserRuleContext(), p.GetState())
+	p.EnterRule(localctx, 322, CQLParserRULE_argument)
+
+	defer func() {
+		p.ExitRule()
+	}()
+
+	defer func() {
+		if err := recover(); err != nil {
+			if v, ok := err.(antlr.RecognitionException); ok {
+				localctx.SetException(v)
+				p.GetErrorHandler().ReportError(p, v)
+				p.GetErrorHandler().Recover(p, v)
+			} else {
+				panic(err)
+			}
+		}
+	}()
+
+	p.SetState(349)
+	p.GetErrorHandler().Sync(p)
+	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 28, p.GetParserRuleContext()) {
+	case 1:
+		p.EnterOuterAlt(localctx, 1)
+		{
+			p.SetState(351)
+			p.scalarExpression(0)
+		}
+
+	case 2:
+		p.EnterOuterAlt(localctx, 2)
+		{
+			p.SetState(352)
+			p.GeomLiteral()
+		}
+
+	case 3:
+		p.EnterOuterAlt(localctx, 3)
+		{
+			p.SetState(353)
+			p.ArrayLiteral()
+		}
+
+	}
+
+	return localctx
+}
+
+// IArrayLiteralContext is an interface to support dynamic dispatch.
+type IArrayLiteralContext interface {
+	antlr.ParserRuleContext
+
+	// GetParser returns the parser.
+	GetParser() antlr.Parser
+
+	// IsArrayLiteralContext differentiates from other interfaces.
+	IsArrayLiteralContext()
+}
+
+type ArrayLiteralContext struct {
+	*CqlContext
+	parser antlr.Parser
+}
+
+func NewEmptyArrayLiteralContext() *ArrayLiteralContext {
+	var p = new(ArrayLiteralContext)
+	p.CqlContext = NewCqlContext(nil, -1)
+	p.RuleIndex = CQLParserRULE_arrayLiteral
+	return p
+}
+
+func (*ArrayLiteralContext) IsArrayLiteralContext() {}
+
+func NewArrayLiteralContext(parser antlr.Parser, parent antlr.ParserRuleContext, invokingState int) *ArrayLiteralContext {
+	var p = new(ArrayLiteralContext)
+
+	p.CqlContext = NewCqlContext(parent, invokingState)
+
+	p.parser = parser
+	p.RuleIndex = CQLParserRULE_arrayLiteral
+
+	return p
+}
+
+func (s *ArrayLiteralContext) GetParser() antlr.Parser { return s.parser }
+
+func (s *ArrayLiteralContext) LEFTPAREN() antlr.TerminalNode {
+	return s.GetToken(CQLParserLEFTPAREN, 0)
+}
+
+func (s *ArrayLiteralContext) RIGHTPAREN() antlr.TerminalNode {
+	return s.GetToken(CQLParserRIGHTPAREN, 0)
+}
+
+func (s *ArrayLiteralContext) AllScalarExpression() []IScalarExpressionContext {
+	var ts = s.GetTypedRuleContexts(reflect.TypeOf((*IScalarExpressionContext)(nil)).Elem())
+	var tst = make([]IScalarExpressionContext, len(ts))
+
+	for i, t := range ts {
+		if t != nil {
+			tst[i] = t.(IScalarExpressionContext)
+		}
+	}
+
+	return tst
+}
+
+func (s *ArrayLiteralContext) ScalarExpression(i int) IScalarExpressionContext {
+	var t = s.GetTypedRuleContext(reflect.TypeOf((*IScalarExpressionContext)(nil)).Elem(), i)
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IScalarExpressionContext)
+}
+
+func (s *ArrayLiteralContext) AllCOMMA() []antlr.TerminalNode {
+	return s.GetTokens(CQLParserCOMMA)
+}
+
+func (s *ArrayLiteralContext) COMMA(i int) antlr.TerminalNode {
+	return s.GetToken(CQLParserCOMMA, i)
+}
+
+func (s *ArrayLiteralContext) GetRuleContext() antlr.RuleContext {
+	return s
+}
+
+func (s *ArrayLiteralContext) ToStringTree(ruleNames []string, recog antlr.Recognizer) string {
+	return antlr.TreesStringTree(s, ruleNames, recog)
+}
+
+func (s *ArrayLiteralContext) EnterRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(CQLParserListener); ok {
+		listenerT.EnterArrayLiteral(s)
+	}
+}
+
+func (s *ArrayLiteralContext) ExitRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(CQLParserListener); ok {
+		listenerT.ExitArrayLiteral(s)
+	}
+}
+
+func (p *CQLParser) ArrayLiteral() (localctx IArrayLiteralContext) {
+	localctx = NewArrayLiteralContext(p, p.GetParserRuleContext(), p.GetState())
+	p.EnterRule(localctx, 324, CQLParserRULE_arrayLiteral)
+	var _la int
+
+	defer func() {
+		p.ExitRule()
+	}()
+
+	defer func() {
+		if err := recover(); err != nil {
+			if v, ok := err.(antlr.RecognitionException); ok {
+				localctx.SetException(v)
+				p.GetErrorHandler().ReportError(p, v)
+				p.GetErrorHandler().Recover(p, v)
+			} else {
+				panic(err)
+			}
+		}
+	}()
+
+	p.EnterOuterAlt(localctx, 1)
+	{
+		p.SetState(354)
+		p.Match(CQLParserLEFTPAREN)
+	}
+	p.SetState(355)
+	p.GetErrorHandler().Sync(p)
+	_la = p.GetTokenStream().LA(1)
+
+	if (((_la)&-(0x1f+1)) == 0 && ((1<<uint(_la))&((1<<CQLParserBooleanLiteral)|(1<<CQLParserNumericLiteral)|(1<<CQLParserIdentifier))) != 0) || (((_la-42)&-(0x1f+1)) == 0 && ((1<<uint((_la-42)))&((1<<(CQLParserLEFTPAREN-42))|(1<<(CQLParserTemporalLiteral-42)))) != 0) || _la == CQLParserCharacterStringLiteral {
+		{
+			p.SetState(357)
+			p.scalarExpression(0)
+		}
+		p.SetState(358)
+		p.GetErrorHandler().Sync(p)
+		_la = p.GetTokenStream().LA(1)
+
+		for ok := true; ok; ok = _la == CQLParserCOMMA {
+			{
+				p.SetState(360)
+				p.Match(CQLParserCOMMA)
+			}
+			{
+				p.SetState(361)
+				p.scalarExpression(0)
+			}
+
+			p.SetState(362)
+			p.GetErrorHandler().Sync(p)
+			_la = p.GetTokenStream().LA(1)
+		}
+
+	}
+	{
+		p.SetState(364)
+		p.Match(CQLParserRIGHTPAREN)
+	}
+
+	return localctx
+}
+
 func (p *CQLParser) Sempred(localctx antlr.RuleContext, ruleIndex, predIndex int) bool {
 	switch ruleIndex {
 	case 1:
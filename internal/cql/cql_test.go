@@ -15,7 +15,6 @@ package cql
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 
 	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
@@ -129,7 +128,7 @@ func TestBooleanExpression(t *testing.T) {
 	//-- allow multiple boolean ops
 	checkCQL(t, "x = 1 AND y = 2 AND z = 3 OR a = 4", "\"x\" = 1 AND \"y\" = 2 AND \"z\" = 3 OR \"a\" = 4")
 
-	checkCQL(t, "NOT x IS NOT NULL", "NOT  \"x\" IS NOT NULL")
+	checkCQL(t, "NOT x IS NOT NULL", "NOT \"x\" IS NOT NULL")
 	checkCQL(t, "NOT TRUE OR FALSE", "NOT TRUE OR FALSE")
 	checkCQL(t, "NOT true OR false", "NOT true OR false")
 	checkCQL(t, "x = 1 OR NOT (x = 2 AND y < 4)", "\"x\" = 1 OR NOT (\"x\" = 2 AND \"y\" < 4)")
@@ -146,6 +145,39 @@ func TestTemporal(t *testing.T) {
 	checkCQL(t, "p > NOW()", "\"p\" > timestamp 'NOW'")
 }
 
+func TestAdvancedComparison(t *testing.T) {
+	checkCQL(t, "CASEI(name) = casei('Paris')", "lower(\"name\") = lower('Paris')")
+	checkCQL(t, "ACCENTI(CASEI(name)) LIKE ACCENTI(CASEI('%été%'))", "unaccent(lower(\"name\")) LIKE unaccent(lower('%été%'))")
+	checkCQL(t, "CASEI(name) NOT LIKE 'a%'", "lower(\"name\") NOT LIKE 'a%'")
+	checkCQL(t, "id + CASEI(x) > 1", "\"id\" + lower(\"x\") > 1")
+	checkCQLError(t, "CASEI(name, 'a') = 'b'")
+	checkCQLError(t, "name LIKE A_CONTAINS(tags, ('a','b'))")
+	checkCQLError(t, "CASEI(name)")
+	checkCQLError(t, "NOT ACCENTI(name) AND x = 1")
+}
+
+func TestArrayPredicate(t *testing.T) {
+	checkCQL(t, "A_CONTAINS(tags, ('a','b'))", "(\"tags\" @> ARRAY['a','b'])")
+	checkCQL(t, "a_containedBy(vals, (1,2,3)) AND x = 1", "(\"vals\" <@ ARRAY[1,2,3]) AND \"x\" = 1")
+	checkCQL(t, "A_OVERLAPS(vals, vals2)", "(\"vals\" && \"vals2\")")
+	checkCQL(t, "NOT A_OVERLAPS(tags, ('a'))", "NOT (\"tags\" && ARRAY['a'])")
+	checkCQL(t, "A_EQUALS(vals, ())", "(\"vals\" = '{}')")
+	checkCQLError(t, "A_CONTAINS(tags)")
+	checkCQLError(t, "A_CONTAINS(tags, ('a', ('b', 'c')))")
+}
+
+func TestFunction(t *testing.T) {
+	InitFunctions([]string{"upper", "ST_Buffer", "is_valid_code"})
+	defer InitFunctions(nil)
+
+	checkCQL(t, "upper(name) = 'PARIS'", "upper(\"name\") = 'PARIS'")
+	checkCQL(t, "INTERSECTS(geom, st_buffer(POINT(0 0), 10))", "ST_Intersects(\"geom\",ST_Buffer('SRID=4326;POINT(0 0)'::geometry,10))")
+	checkCQL(t, "is_valid_code(code) AND upper('a') + 1 > 2", "is_valid_code(\"code\") AND upper('a') + 1 > 2")
+	checkCQLError(t, "name LIKE upper('a%')")
+	checkCQLError(t, "lower(name) = 'paris'")
+	checkCQLError(t, "pg_sleep(10)")
+}
+
 func TestSyntaxErrors(t *testing.T) {
 	checkCQLError(t, "x y")
 	checkCQLError(t, "x == y")
@@ -167,7 +199,6 @@ func checkCQL(t *testing.T, cqlStr string, sql string) {
 		fmt.Printf("%v\n", err)
 		t.FailNow()
 	}
-	actual = strings.TrimSpace(actual)
 	util.Equals(t, sql, actual, "")
 }
 
@@ -177,7 +208,6 @@ func checkCQLWithSRID(t *testing.T, cqlStr string, filterSRID int, sourceSRID in
 		fmt.Printf("%v\n", err)
 		t.FailNow()
 	}
-	actual = strings.TrimSpace(actual)
 	util.Equals(t, sql, actual, "")
 }
 
@@ -172,6 +172,12 @@ func (s *BaseCQLParserListener) EnterLiteralBoolean(ctx *LiteralBooleanContext)
 // ExitLiteralBoolean is called when production LiteralBoolean is exited.
 func (s *BaseCQLParserListener) ExitLiteralBoolean(ctx *LiteralBooleanContext) {}
 
+// EnterScalarFunction is called when production ScalarFunction is entered.
+func (s *BaseCQLParserListener) EnterScalarFunction(ctx *ScalarFunctionContext) {}
+
+// ExitScalarFunction is called when production ScalarFunction is exited.
+func (s *BaseCQLParserListener) ExitScalarFunction(ctx *ScalarFunctionContext) {}
+
 // EnterLiteralTemporal is called when production LiteralTemporal is entered.
 func (s *BaseCQLParserListener) EnterLiteralTemporal(ctx *LiteralTemporalContext) {}
 
@@ -303,3 +309,21 @@ func (s *BaseCQLParserListener) EnterCoordinate(ctx *CoordinateContext) {}
 
 // ExitCoordinate is called when production coordinate is exited.
 func (s *BaseCQLParserListener) ExitCoordinate(ctx *CoordinateContext) {}
+
+// EnterFunction is called when production function is entered.
+func (s *BaseCQLParserListener) EnterFunction(ctx *FunctionContext) {}
+
+// ExitFunction is called when production function is exited.
+func (s *BaseCQLParserListener) ExitFunction(ctx *FunctionContext) {}
+
+// EnterArgument is called when production argument is entered.
+func (s *BaseCQLParserListener) EnterArgument(ctx *ArgumentContext) {}
+
+// ExitArgument is called when production argument is exited.
+func (s *BaseCQLParserListener) ExitArgument(ctx *ArgumentContext) {}
+
+// EnterArrayLiteral is called when production arrayLiteral is entered.
+func (s *BaseCQLParserListener) EnterArrayLiteral(ctx *ArrayLiteralContext) {}
+
+// ExitArrayLiteral is called when production arrayLiteral is exited.
+func (s *BaseCQLParserListener) ExitArrayLiteral(ctx *ArrayLiteralContext) {}
@@ -82,6 +82,9 @@ type CQLParserListener interface {
 	// EnterLiteralBoolean is called when entering the LiteralBoolean production.
 	EnterLiteralBoolean(c *LiteralBooleanContext)
 
+	// EnterScalarFunction is called when entering the ScalarFunction production.
+	EnterScalarFunction(c *ScalarFunctionContext)
+
 	// EnterLiteralTemporal is called when entering the LiteralTemporal production.
 	EnterLiteralTemporal(c *LiteralTemporalContext)
 
@@ -148,6 +151,15 @@ type CQLParserListener interface {
 	// EnterCoordinate is called when entering the coordinate production.
 	EnterCoordinate(c *CoordinateContext)
 
+	// EnterFunction is called when entering the function production.
+	EnterFunction(c *FunctionContext)
+
+	// EnterArgument is called when entering the argument production.
+	EnterArgument(c *ArgumentContext)
+
+	// EnterArrayLiteral is called when entering the arrayLiteral production.
+	EnterArrayLiteral(c *ArrayLiteralContext)
+
 	// ExitCqlFilter is called when exiting the cqlFilter production.
 	ExitCqlFilter(c *CqlFilterContext)
 
@@ -223,6 +235,9 @@ type CQLParserListener interface {
 	// ExitLiteralBoolean is called when exiting the LiteralBoolean production.
 	ExitLiteralBoolean(c *LiteralBooleanContext)
 
+	// ExitScalarFunction is called when exiting the ScalarFunction production.
+	ExitScalarFunction(c *ScalarFunctionContext)
+
 	// ExitLiteralTemporal is called when exiting the LiteralTemporal production.
 	ExitLiteralTemporal(c *LiteralTemporalContext)
 
@@ -288,4 +303,13 @@ type CQLParserListener interface {
 
 	// ExitCoordinate is called when exiting the coordinate production.
 	ExitCoordinate(c *CoordinateContext)
+
+	// ExitFunction is called when exiting the function production.
+	ExitFunction(c *FunctionContext)
+
+	// ExitArgument is called when exiting the argument production.
+	ExitArgument(c *ArgumentContext)
+
+	// ExitArrayLiteral is called when exiting the arrayLiteral production.
+	ExitArrayLiteral(c *ArrayLiteralContext)
 }
@@ -1,6 +1,10 @@
 package data
 
-import "github.com/CrunchyData/pg_featureserv/internal/api"
+import (
+	"context"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+)
 
 /*
  Copyright 2022 Crunchy Data Solutions, Inc.
@@ -23,10 +27,12 @@ type CacheDisabled struct {
 }
 
 func (cache CacheDisabled) GetWeakEtag(etag interface{}) (*api.WeakEtagData, error) {
+	recordCacheLookup(cache, false)
 	return nil, nil
 }
 
 func (cache CacheDisabled) ContainsEtag(etag interface{}) (bool, error) {
+	recordCacheLookup(cache, false)
 	return false, nil
 }
 
@@ -38,6 +44,10 @@ func (cache CacheDisabled) RemoveWeakEtag(weakEtag string) (bool, error) {
 	return false, nil
 }
 
+func (cache CacheDisabled) RemoveCollectionEtags(collection string) (int, error) {
+	return 0, nil
+}
+
 func (cache CacheDisabled) String() string {
 	return ""
 }
@@ -50,6 +60,10 @@ func (cache CacheDisabled) Size() int {
 	return 0
 }
 
+func (cache CacheDisabled) Ping(ctx context.Context) error {
+	return nil
+}
+
 func (cache CacheDisabled) Reset() (bool, error) {
 	return true, nil
 }
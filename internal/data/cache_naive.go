@@ -18,6 +18,7 @@ package data
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"sync"
 
@@ -40,6 +41,7 @@ func (cache CacheNaive) GetWeakEtag(etag interface{}) (*api.WeakEtagData, error)
 	var out interface{}
 	out, present := cache.entries[weakEtagValue.CacheKey()]
 	mutex.Unlock()
+	recordCacheLookup(cache, present)
 	if present {
 		return out.(*api.WeakEtagData), nil
 	}
@@ -54,6 +56,7 @@ func (cache CacheNaive) ContainsEtag(etag interface{}) (bool, error) {
 	mutex.Lock()
 	_, present := cache.entries[weakEtagValue.CacheKey()]
 	mutex.Unlock()
+	recordCacheLookup(cache, present)
 	return present, nil
 }
 
@@ -71,6 +74,19 @@ func (cache CacheNaive) RemoveWeakEtag(etagKey string) (bool, error) {
 	return true, nil
 }
 
+func (cache CacheNaive) RemoveCollectionEtags(collection string) (int, error) {
+	removed := 0
+	mutex.Lock()
+	for key, entry := range cache.entries {
+		if etag, ok := entry.(*api.WeakEtagData); ok && etag.Collection == collection {
+			delete(cache.entries, key)
+			removed++
+		}
+	}
+	mutex.Unlock()
+	return removed, nil
+}
+
 func (cache CacheNaive) String() string {
 	b := new(bytes.Buffer)
 	for key, value := range cache.entries {
@@ -87,6 +103,10 @@ func (cache CacheNaive) Size() int {
 	return len(cache.entries)
 }
 
+func (cache CacheNaive) Ping(ctx context.Context) error {
+	return nil
+}
+
 func (cache CacheNaive) Reset() (bool, error) {
 	mutex.Lock()
 	for k := range cache.entries {
@@ -58,8 +58,10 @@ func (cache CacheRedis) GetWeakEtag(etag interface{}) (*api.WeakEtagData, error)
 	etagStr, err = cache.client.Get(cache.ctx, weakEtagValue.CacheKey()).Result()
 	// redis.Nil error means that the value is not available : https://redis.uptrace.dev/guide/go-redis.html#redis-nil
 	if err == redis.Nil {
+		recordCacheLookup(cache, false)
 		return nil, nil
 	} else {
+		recordCacheLookup(cache, err == nil)
 		var out *api.WeakEtagData
 		err = json.Unmarshal([]byte(etagStr), out)
 		return out, err
@@ -75,8 +77,10 @@ func (cache CacheRedis) ContainsEtag(etag interface{}) (bool, error) {
 	_, err = cache.client.Get(cache.ctx, weakEtagValue.CacheKey()).Result()
 	// redis.Nil error means that the value is not available : https://redis.uptrace.dev/guide/go-redis.html#redis-nil
 	if err == nil {
+		recordCacheLookup(cache, true)
 		return true, nil
 	} else if err == redis.Nil {
+		recordCacheLookup(cache, false)
 		return false, nil
 	} else {
 		return false, err
@@ -84,13 +88,69 @@ func (cache CacheRedis) ContainsEtag(etag interface{}) (bool, error) {
 }
 
 func (cache CacheRedis) AddWeakEtag(etagKey string, etag *api.WeakEtagData) (bool, error) {
-	err := cache.client.Set(cache.ctx, etagKey, *etag, 0).Err()
+	_, err := cache.client.TxPipelined(cache.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(cache.ctx, etagKey, *etag, 0)
+		if etag.Collection != "" {
+			pipe.SAdd(cache.ctx, collectionIndexKey(etag.Collection), etagKey)
+		}
+		return nil
+	})
 	return err == nil, err
 }
 
 func (cache CacheRedis) RemoveWeakEtag(etagKey string) (bool, error) {
-	nb_deleted, err := cache.client.Del(cache.ctx, etagKey).Result()
-	return nb_deleted > 0, err
+	//-- the etag is read to remove its key from the index of its collection
+	var etag api.WeakEtagData
+	etagStr, err := cache.client.Get(cache.ctx, etagKey).Result()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	_ = json.Unmarshal([]byte(etagStr), &etag)
+	var del *redis.IntCmd
+	_, err = cache.client.TxPipelined(cache.ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(cache.ctx, etagKey)
+		if etag.Collection != "" {
+			pipe.SRem(cache.ctx, collectionIndexKey(etag.Collection), etagKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return del.Val() > 0, nil
+}
+
+// the keys of the etags of a collection are held in a set,
+// so that they are removed without scanning the whole cache
+func (cache CacheRedis) RemoveCollectionEtags(collection string) (int, error) {
+	indexKey := collectionIndexKey(collection)
+	keys, err := cache.client.SMembers(cache.ctx, indexKey).Result()
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	var del *redis.IntCmd
+	_, err = cache.client.TxPipelined(cache.ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(cache.ctx, keys...)
+		//-- keys added since the index was read are kept in it
+		pipe.SRem(cache.ctx, indexKey, members...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(del.Val()), nil
+}
+
+// collectionIndexKey is the key of the set of the etag keys of a collection.
+// Etag keys start with ET- or CF-
+func collectionIndexKey(collection string) string {
+	return fmt.Sprintf("CI-%s", collection)
 }
 
 func (cache CacheRedis) String() string {
@@ -110,6 +170,10 @@ func (cache CacheRedis) Size() int {
 	}
 }
 
+func (cache CacheRedis) Ping(ctx context.Context) error {
+	return cache.client.Ping(ctx).Err()
+}
+
 func (cache CacheRedis) Reset() (bool, error) {
 	_, err := cache.client.FlushDB(cache.ctx).Result()
 	if err == nil {
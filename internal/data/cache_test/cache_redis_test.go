@@ -156,3 +156,41 @@ func (t *CacheTests) TestRedisRemoveWeakEtag() {
 		util.Assert(t, res == false, wValidWeakEtag+" Etag should not be available in Redis cache")
 	})
 }
+
+func (t *CacheTests) TestRedisRemoveCollectionEtags() {
+	url := t.RedisUrl
+	t.Test.Run("TestRedisRemoveCollectionEtags", func(t *testing.T) {
+
+		cache := data.CacheRedis{}
+		err := cache.Init(url, "")
+		util.Assert(t, err == nil, NoRedisErrorExpected)
+
+		etagA1 := api.MakeWeakEtag("collection_a", "1", "etag_a1", "")
+		etagA2 := api.MakeWeakEtag("collection_a", "2", "etag_a2", "")
+		etagB1 := api.MakeWeakEtag("collection_b", "1", "etag_b1", "")
+		for _, etag := range []*api.WeakEtagData{etagA1, etagA2, etagB1} {
+			_, err = cache.AddWeakEtag(etag.CacheKey(), etag)
+			util.Assert(t, err == nil, NoEtagErrorExpected)
+		}
+		// an etag removed on its own is removed from the index of its collection
+		_, err = cache.RemoveWeakEtag(etagA2.CacheKey())
+		util.Assert(t, err == nil, NoEtagErrorExpected)
+		_, err = cache.AddWeakEtag(etagA2.AlternateCacheKey(), etagA2)
+		util.Assert(t, err == nil, NoEtagErrorExpected)
+
+		removed, err := cache.RemoveCollectionEtags("collection_a")
+		util.Assert(t, err == nil, "No error expected when removing the etags of a collection")
+		util.Equals(t, 2, removed, "# removed etags")
+
+		res, err := cache.ContainsEtag(etagA1)
+		util.Assert(t, err == nil, NoEtagErrorExpected)
+		util.Assert(t, !res, "etag of the collection should be removed")
+		res, err = cache.ContainsEtag(etagB1)
+		util.Assert(t, err == nil, NoEtagErrorExpected)
+		util.Assert(t, res, "etag of another collection should be kept")
+
+		removed, err = cache.RemoveCollectionEtags("collection_a")
+		util.Assert(t, err == nil, "No error expected when removing the etags of a collection")
+		util.Equals(t, 0, removed, "# removed etags when none")
+	})
+}
@@ -50,6 +50,7 @@ func TestRunnerCache(t *testing.T) {
 		m.TestRedisContainsEtag()
 		m.TestRedisAddWeakEtag()
 		m.TestRedisRemoveWeakEtag()
+		m.TestRedisRemoveCollectionEtags()
 		afterEachRun()
 	})
 
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"reflect"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
@@ -42,6 +43,9 @@ type Cacher interface {
 	// returns false if error occurs during the operation
 	RemoveWeakEtag(weakEtag string) (bool, error)
 
+	// removes the weak etags of a collection from the cache and returns the number removed
+	RemoveCollectionEtags(collection string) (int, error)
+
 	// Stringer: returns a string representation of the cache for dev purpose
 	String() string
 
@@ -53,6 +57,9 @@ type Cacher interface {
 
 	// clean all cache content
 	Reset() (bool, error)
+
+	// checks that the cache storage is reachable
+	Ping(ctx context.Context) error
 }
 
 // IsOneEtagInCache checks if the weak value of at least one of the etags provided is present into the cache
@@ -34,12 +34,24 @@ const (
 type Catalog interface {
 	Initialize(includeList []string, excludeList []string)
 
+	// Reload updates the published tables and functions after a configuration change
+	Reload(includeList []string, excludeList []string)
+
 	Tables() ([]*api.Table, error)
 
 	// TableByName returns the table with given name.
 	// It returns nil if the table does not exist
 	TableByName(name string) (*api.Table, error)
 
+	// TablesForSession returns the tables which can be selected by the role of the request session.
+	// All tables are returned if the request has no session role
+	TablesForSession(ctx context.Context) ([]*api.Table, error)
+
+	// TableForSession returns the table with given name,
+	// if it can be selected by the role of the request session.
+	// It returns nil if the table is not visible to the role
+	TableForSession(ctx context.Context, name string) (*api.Table, error)
+
 	// TableReload reloads volatile table data
 	TableReload(name string)
 
@@ -47,6 +59,27 @@ type Catalog interface {
 	// It returns nil if the table does not exist
 	TableFeatures(ctx context.Context, name string, param *QueryParam) ([]*api.GeojsonFeatureData, error)
 
+	// TableFeaturesIterator returns an iterator over the features in a table,
+	// allowing them to be processed without reading them all into memory.
+	// It returns nil if the table does not exist
+	TableFeaturesIterator(ctx context.Context, name string, param *QueryParam) (FeatureIterator, error)
+
+	// TableFeaturesMatched returns the number of features in a table matched by the query filters,
+	// ignoring the limit and offset.
+	// If isEstimate is true the number is estimated by the query planner, which is faster but inexact
+	TableFeaturesMatched(ctx context.Context, name string, param *QueryParam, isEstimate bool) (int, error)
+
+	// TableTile returns the features of a table in a tile, encoded as a Mapbox Vector Tile.
+	// The query filters and properties are applied, but not the limit and offset.
+	// It returns nil if the table does not exist
+	TableTile(ctx context.Context, name string, tile *api.Tile, param *QueryParam) (*api.TileData, error)
+
+	// TableTileEtag returns the etag of a tile, computed from the versions of the features it contains,
+	// so that it can be checked without building the tile.
+	// It returns an empty string if the table rows have no versions (e.g. views),
+	// in which case the etag of the tile is computed from its data
+	TableTileEtag(ctx context.Context, name string, tile *api.Tile, param *QueryParam) (string, error)
+
 	// TableFeature returns the JSON text for a table feature with given id, along with its weak etag value
 	// It returns an empty string if the table or feature does not exist
 	TableFeature(ctx context.Context, name string, id string, param *QueryParam) (*api.GeojsonFeatureData, error)
@@ -72,11 +105,43 @@ type Catalog interface {
 
 	FunctionFeatures(ctx context.Context, name string, args map[string]string, param *QueryParam) ([]*api.GeojsonFeatureData, error)
 
+	// FunctionFeaturesIterator returns an iterator over the features produced by a function.
+	// It returns nil if the function does not exist
+	FunctionFeaturesIterator(ctx context.Context, name string, args map[string]string, param *QueryParam) (FeatureIterator, error)
+
+	// FunctionFeaturesMatched returns the number of features produced by a function and matched by the query filters,
+	// ignoring the limit and offset.
+	// If isEstimate is true the number is estimated by the query planner, which is faster but inexact
+	FunctionFeaturesMatched(ctx context.Context, name string, args map[string]string, param *QueryParam, isEstimate bool) (int, error)
+
 	FunctionData(ctx context.Context, name string, args map[string]string, param *QueryParam) ([]map[string]interface{}, error)
 
+	// APIKeys returns the API keys held in a column of a table
+	APIKeys(table string, column string) ([]string, error)
+
 	// GetCache returns a copy of the cache
 	GetCache() Cacher
 
+	// Health checks the components the catalog depends on
+	Health(ctx context.Context) map[string]*api.HealthComponent
+
+	Close()
+}
+
+// FeatureIterator provides sequential access to the features read by a query.
+// Close must be called once iteration is finished, to release the query resources.
+type FeatureIterator interface {
+	// Next advances to the next feature.
+	// It returns false when there are no more features or an error occurred
+	Next() bool
+
+	// Feature returns the current feature
+	Feature() *api.GeojsonFeatureData
+
+	// Err returns the error (if any) which stopped the iteration
+	Err() error
+
+	// Close releases the resources held by the iterator
 	Close()
 }
 
@@ -94,6 +159,10 @@ type QueryParam struct {
 	BboxCrs   int
 	FilterSql string
 	Filter    []*PropertyFilter
+	// CrsLatLon outputs the geometries in latitude/longitude order (EPSG:4326)
+	CrsLatLon bool
+	// DateTime selects features by time, if the table has time columns
+	DateTime *api.DateTime
 	// Columns is the list of columns to return
 	Columns            []string
 	GroupBy            []string
@@ -101,4 +170,13 @@ type QueryParam struct {
 	Precision          int
 	TransformFuns      []api.TransformFunction
 	MaxAllowableOffset float64
+	// Force2D drops the Z and M values of the output geometries
+	Force2D bool
+	// KeysetColumns provide a unique ordering of features for keyset paging.
+	// If empty, the features are ordered by SortBy only
+	KeysetColumns []string
+	KeysetIsDesc  bool
+	// Cursor holds the keyset column values after which features start (if any).
+	// A nil value is a NULL
+	Cursor []*string
 }
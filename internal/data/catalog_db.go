@@ -27,28 +27,36 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
 	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
 	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/log/logrusadapter"
-	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/paulmach/orb/geojson"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type catalogDB struct {
-	dbconn        *pgxpool.Pool
-	tableIncludes map[string]string
-	tableExcludes map[string]string
-	tables        []*api.Table
-	tableMap      map[string]*api.Table
-	functions     []*api.Function
-	functionMap   map[string]*api.Function
-	cache         Cacher
-	listener      *listenerDB
+	sources []*dataSource
+	// the tables and functions are replaced as a whole when they are reloaded
+	tablesMutex sync.RWMutex
+	tables      []*api.Table
+	tableMap    map[string]*api.Table
+	functions   []*api.Function
+	functionMap map[string]*api.Function
+	// errors of the last reloads of the tables and functions, which keep the previous ones
+	tablesErr    error
+	functionsErr error
+	cache        Cacher
+	// serializes the reloads after schema or configuration changes
+	reloadMutex sync.Mutex
+	// tables visible to session roles
+	roleTablesMutex sync.Mutex
+	roleTablesCache map[string]*roleTables
 }
 
 var isStartup bool
@@ -72,108 +80,144 @@ func CatDBInstance() Catalog {
 }
 
 func newCatalogDB() *catalogDB {
-	conn := dbConnect()
 	cache := makeCache()
-
-	var listener = newListenerDB(conn, cache)
+	sources := newDataSources(cache)
 
 	cat := &catalogDB{
-		dbconn:   conn,
-		cache:    cache,
-		listener: listener,
+		sources: sources,
+		cache:   cache,
+	}
+	for _, src := range sources {
+		src.listener.onSchemaChange = cat.handleSchemaChange
 	}
+	registerPoolMetrics(sources)
+	registerReplicaMetrics(sources)
+	registerCacheMetrics(cache)
 
 	return cat
 }
 
 // etags cache
 func makeCache() Cacher {
-	if conf.Configuration.Cache.Type == "Naive" {
-		cache_size := conf.Configuration.Cache.Naive.MapSize
+	if conf.Current().Cache.Type == "Naive" {
+		cache_size := conf.Current().Cache.Naive.MapSize
 		return &CacheNaive{make(map[string]interface{}, cache_size)}
-	} else if conf.Configuration.Cache.Type == "Redis" {
+	} else if conf.Current().Cache.Type == "Redis" {
 		cache := CacheRedis{}
-		err := cache.Init(conf.Configuration.Cache.Redis.Url, conf.Configuration.Cache.Redis.Password)
+		err := cache.Init(conf.Current().Cache.Redis.Url, conf.Current().Cache.Redis.Password)
 		if err != nil {
 			log.Fatalf("Error in CacheRedis init: %v", err)
 		}
 		return &cache
-	} else if conf.Configuration.Cache.Type == "Disabled" || conf.Configuration.Cache.Type == "" {
+	} else if conf.Current().Cache.Type == "Disabled" || conf.Current().Cache.Type == "" {
 		return &CacheDisabled{}
 	} else {
-		log.Fatal(fmt.Errorf("Invalid cache type: Disabled, Naive and Redis are supported. %v defined", conf.Configuration.Cache.Type))
+		log.Fatal(fmt.Errorf("Invalid cache type: Disabled, Naive and Redis are supported. %v defined", conf.Current().Cache.Type))
 		return &CacheDisabled{}
 	}
 }
 
-func dbConnect() *pgxpool.Pool {
-	dbconfig := dbConfig()
+// Initialize sets the include and exclude lists of the default source,
+// and starts the listeners of all sources
+func (cat *catalogDB) Initialize(includeList []string, excludeList []string) {
+	if src := cat.source(""); src != nil {
+		//-- include schemas / tables
+		src.tableIncludes = nameMap(includeList)
+		//-- excluded schemas / tables
+		src.tableExcludes = nameMap(excludeList)
+	}
 
-	db, err := pgxpool.ConnectConfig(context.Background(), dbconfig)
-	if err != nil {
-		log.Fatal(err)
+	// Init the listeners
+	for _, src := range cat.sources {
+		src.listener.Initialize(src.tableIncludes, src.tableExcludes)
+	}
+	// the catalog must be readable at startup
+	if err := cat.loadTables(); err != nil {
+		log.Fatalf("Error loading table catalog: %v", err)
 	}
-	dbName := dbconfig.ConnConfig.Config.Database
-	dbUser := dbconfig.ConnConfig.Config.User
-	dbHost := dbconfig.ConnConfig.Config.Host
-	log.Infof("Connected as %s to %s @ %s", dbUser, dbName, dbHost)
-	return db
 }
 
-func dbConfig() *pgxpool.Config {
-	dbconf := conf.Configuration.Database.DbConnection
-	// disallow blank config for safety
-	if dbconf == "" {
-		log.Fatal("Blank DbConnection is disallowed for security reasons")
+// Reload sets the include and exclude lists of the default source.
+// The lists of the other sources are only read at startup
+func (cat *catalogDB) Reload(includeList []string, excludeList []string) {
+	cat.reloadMutex.Lock()
+	if src := cat.source(""); src != nil {
+		src.tableIncludes = nameMap(includeList)
+		src.tableExcludes = nameMap(excludeList)
+		src.listener.tableIncludes = src.tableIncludes
+		src.listener.tableExcludes = src.tableExcludes
 	}
+	cat.reloadMutex.Unlock()
 
-	dbconfig, err := pgxpool.ParseConfig(conf.Configuration.Database.DbConnection)
-	if err != nil {
-		log.Fatal(err)
+	if err := cat.reloadTables(); err != nil {
+		log.Warnf("Error reloading table catalog, keeping the current tables: %v", err)
 	}
-	// Read and parse connection lifetime
-	dbPoolMaxLifeTime, errt := time.ParseDuration(conf.Configuration.Database.DbPoolMaxConnLifeTime)
-	if errt != nil {
-		log.Fatal(errt)
+	if err := cat.refreshFunctions(true); err != nil {
+		log.Warnf("Error reloading function catalog, keeping the current functions: %v", err)
 	}
-	dbconfig.MaxConnLifetime = dbPoolMaxLifeTime
+}
 
-	// Read and parse max connections
-	dbPoolMaxConns := conf.Configuration.Database.DbPoolMaxConns
-	if dbPoolMaxConns > 0 {
-		dbconfig.MaxConns = int32(dbPoolMaxConns)
+// nameMap maps the lowercase names of a list of schemas and tables
+func nameMap(names []string) map[string]string {
+	nameMap := make(map[string]string)
+	for _, name := range names {
+		nameLow := strings.ToLower(name)
+		nameMap[nameLow] = nameLow
 	}
+	return nameMap
+}
 
-	// Read current log level and use one less-fine level
-	dbconfig.ConnConfig.Logger = logrusadapter.NewLogger(log.New())
-	levelString, _ := (log.GetLevel() - 1).MarshalText()
-	pgxLevel, _ := pgx.LogLevelFromString(string(levelString))
-	dbconfig.ConnConfig.LogLevel = pgxLevel
+// query checking the database connection
+const sqlHealth = "SELECT 1"
 
-	return dbconfig
-}
+// Health checks the database and listener of each source, the tables and the cache.
+// The components of named sources are suffixed by the source name
+func (cat *catalogDB) Health(ctx context.Context) map[string]*api.HealthComponent {
+	components := make(map[string]*api.HealthComponent)
 
-func (cat *catalogDB) Initialize(includeList []string, excludeList []string) {
-	//-- include schemas / tables
-	cat.tableIncludes = make(map[string]string)
-	for _, name := range includeList {
-		nameLow := strings.ToLower(name)
-		cat.tableIncludes[nameLow] = nameLow
+	isDbOK := true
+	for _, src := range cat.sources {
+		_, err := src.dbconn.Exec(ctx, sqlHealth)
+		if err != nil {
+			components[src.healthName(api.HealthDatabase)] = api.HealthFail(err.Error())
+			isDbOK = false
+		} else {
+			components[src.healthName(api.HealthDatabase)] = api.HealthOK("")
+		}
+		if src.listener.IsListening() {
+			components[src.healthName(api.HealthListener)] = api.HealthOK("")
+		} else {
+			components[src.healthName(api.HealthListener)] = api.HealthFail("not listening to table changes")
+		}
 	}
-	//-- excluded schemas / tables
-	cat.tableExcludes = make(map[string]string)
-	for _, name := range excludeList {
-		nameLow := strings.ToLower(name)
-		cat.tableExcludes[nameLow] = nameLow
+	// tables are loaded on first use
+	if isDbOK {
+		cat.refreshTables(false) //nolint:errcheck
+	}
+
+	if tables, tableMap := cat.tableSnapshot(); tableMap == nil {
+		components[api.HealthCatalog] = api.HealthFail("tables are not loaded")
+	} else if err := cat.lastReloadError(); err != nil {
+		components[api.HealthCatalog] = api.HealthFail(fmt.Sprintf("reload failed: %v", err))
+	} else {
+		components[api.HealthCatalog] = api.HealthOK(fmt.Sprintf("%v tables", len(tables)))
 	}
 
-	// Init the listener
-	cat.listener.Initialize(cat.tableIncludes, cat.tableExcludes)
+	if err := cat.cache.Ping(ctx); err != nil {
+		components[api.HealthCache] = api.HealthFail(fmt.Sprintf("%v: %v", cat.cache.Type(), err))
+	} else {
+		components[api.HealthCache] = api.HealthOK(cat.cache.Type())
+	}
+	return components
 }
 
 func (cat *catalogDB) Close() {
-	cat.listener.Close()
-	cat.dbconn.Close()
+	for _, src := range cat.sources {
+		src.listener.Close()
+		src.closeReplicas()
+		src.requestPool.Close()
+		src.dbconn.Close()
+	}
 }
 
 func (cat *catalogDB) GetCache() Cacher {
@@ -181,8 +225,11 @@ func (cat *catalogDB) GetCache() Cacher {
 }
 
 func (cat *catalogDB) Tables() ([]*api.Table, error) {
-	cat.refreshTables(true)
-	return cat.tables, nil
+	if err := cat.refreshTables(true); err != nil {
+		return nil, err
+	}
+	tables, _ := cat.tableSnapshot()
+	return tables, nil
 }
 
 func (cat *catalogDB) TableReload(name string) {
@@ -198,6 +245,21 @@ func (cat *catalogDB) TableReload(name string) {
 		sqlExtentExact := sqlExtentExact(tbl)
 		cat.loadExtent(sqlExtentExact, tbl)
 	}
+	if len(tbl.TimeColumns) > 0 {
+		cat.loadTimeExtent(sqlTimeExtent(tbl), tbl)
+	}
+}
+
+func (cat *catalogDB) loadTimeExtent(sql string, tbl *api.Table) {
+	var start, end pgtype.Text
+	log.Debug("Time extent query: " + sql)
+	err := cat.source(tbl.Source).dbconn.QueryRow(context.Background(), sql).Scan(&start, &end)
+	if err != nil {
+		log.Debugf("Error querying time extent for %s: %v", tbl.ID, err)
+		return
+	}
+	tbl.TimeExtent.Start = start.String
+	tbl.TimeExtent.End = end.String
 }
 
 func (cat *catalogDB) loadExtent(sql string, tbl *api.Table) bool {
@@ -208,7 +270,7 @@ func (cat *catalogDB) loadExtent(sql string, tbl *api.Table) bool {
 		ymax pgtype.Float8
 	)
 	log.Debug("Extent query: " + sql)
-	err := cat.dbconn.QueryRow(context.Background(), sql).Scan(&xmin, &ymin, &xmax, &ymax)
+	err := cat.source(tbl.Source).dbconn.QueryRow(context.Background(), sql).Scan(&xmin, &ymin, &xmax, &ymax)
 	if err != nil {
 		log.Debugf("Error querying Extent for %s: %v", tbl.ID, err)
 	}
@@ -224,11 +286,14 @@ func (cat *catalogDB) loadExtent(sql string, tbl *api.Table) bool {
 }
 
 func (cat *catalogDB) TableByName(name string) (*api.Table, error) {
-	cat.refreshTables(false)
-	var tbl *api.Table
-	tbl, ok := cat.tableMap[name]
+	if err := cat.refreshTables(false); err != nil {
+		return nil, err
+	}
+	_, tableMap := cat.tableSnapshot()
+	tbl, ok := tableMap[name]
 	if !ok {
-		tbl, ok = cat.tableMap["public."+name]
+		source, id := api.SplitSourceID(name)
+		tbl, ok = tableMap[api.SourceID(source, "public."+id)]
 		if !ok {
 			return nil, fmt.Errorf("Unknown table '%v'", name)
 		}
@@ -241,19 +306,83 @@ func (cat *catalogDB) TableFeatures(ctx context.Context, name string, param *Que
 	if err != nil || tbl == nil {
 		return nil, err
 	}
+	ctx = withTableTimeout(ctx, tbl)
 	cols := param.Columns
 	sql, argValues := sqlFeatures(tbl, param)
 	log.Debug("Features query: " + sql)
 	idColIndex := indexOfName(cols, tbl.IDColumn)
-	features, err := readFeaturesWithArgs(ctx, cat.dbconn, sql, argValues, name, idColIndex, cols, cat.cache)
+	features, err := readFeaturesWithArgs(ctx, cat.source(tbl.Source).reader(ctx), sql, argValues, name, idColIndex, cols, cat.cache)
 	return features, err
 }
 
+func (cat *catalogDB) TableFeaturesIterator(ctx context.Context, name string, param *QueryParam) (FeatureIterator, error) {
+	tbl, err := cat.TableByName(name)
+	if err != nil || tbl == nil {
+		return nil, err
+	}
+	ctx = withTableTimeout(ctx, tbl)
+	cols := param.Columns
+	sql, argValues := sqlFeatures(tbl, param)
+	log.Debug("Features query: " + sql)
+	idColIndex := indexOfName(cols, tbl.IDColumn)
+	return readFeaturesIteratorWithArgs(ctx, cat.source(tbl.Source).reader(ctx), sql, argValues, name, idColIndex, cols, cat.cache)
+}
+
+func (cat *catalogDB) TableFeaturesMatched(ctx context.Context, name string, param *QueryParam, isEstimate bool) (int, error) {
+	tbl, err := cat.TableByName(name)
+	if err != nil || tbl == nil {
+		return 0, err
+	}
+	ctx = withTableTimeout(ctx, tbl)
+	sql, argValues := sqlFeaturesMatched(tbl, param)
+	return readFeaturesMatched(ctx, cat.source(tbl.Source).reader(ctx), sql, argValues, isEstimate)
+}
+
+func (cat *catalogDB) TableTile(ctx context.Context, name string, tile *api.Tile, param *QueryParam) (*api.TileData, error) {
+	tbl, err := cat.TableByName(name)
+	if err != nil || tbl == nil {
+		return nil, err
+	}
+	ctx = withTableTimeout(ctx, tbl)
+	sql, argValues := sqlTile(tbl, tile, param)
+	log.Debug("Tile query: " + sql)
+
+	start := time.Now()
+	var tileData api.TileData
+	err = cat.source(tbl.Source).reader(ctx).QueryRow(ctx, sql, argValues...).Scan(&tileData.Data, &tileData.Etag)
+	if err != nil {
+		log.Warnf("Error running 'Tile' (query: '%v'): %v", sql, err)
+		return nil, err
+	}
+	recordQuery(queryTile, start)
+	log.Debugf("Database tile query: %v bytes in %v", len(tileData.Data), time.Since(start))
+	return &tileData, nil
+}
+
+func (cat *catalogDB) TableTileEtag(ctx context.Context, name string, tile *api.Tile, param *QueryParam) (string, error) {
+	tbl, err := cat.TableByName(name)
+	if err != nil || tbl == nil || !tbl.HasRowVersions {
+		return "", err
+	}
+	ctx = withTableTimeout(ctx, tbl)
+	sql, argValues := sqlTileEtag(tbl, tile, param)
+	log.Debug("Tile etag query: " + sql)
+
+	var etag string
+	err = cat.source(tbl.Source).reader(ctx).QueryRow(ctx, sql, argValues...).Scan(&etag)
+	if err != nil {
+		log.Warnf("Error running 'Tile etag' (query: '%v'): %v", sql, err)
+		return "", err
+	}
+	return etag, nil
+}
+
 func (cat *catalogDB) TableFeature(ctx context.Context, name string, id string, param *QueryParam) (*api.GeojsonFeatureData, error) {
 	tbl, err := cat.TableByName(name)
 	if err != nil {
 		return nil, err
 	}
+	ctx = withTableTimeout(ctx, tbl)
 	cols := param.Columns
 	sql := sqlFeature(tbl, param)
 	log.Debug("Feature query: " + sql)
@@ -263,7 +392,7 @@ func (cat *catalogDB) TableFeature(ctx context.Context, name string, id string,
 	//--- Add a SQL arg for the feature ID
 	argValues := make([]interface{}, 0)
 	argValues = append(argValues, id)
-	features, err := readFeaturesWithArgs(ctx, cat.dbconn, sql, argValues, name, idColIndex, cols, cat.cache)
+	features, err := readFeaturesWithArgs(ctx, cat.source(tbl.Source).reader(ctx), sql, argValues, name, idColIndex, cols, cat.cache)
 
 	if len(features) == 0 {
 		return nil, err
@@ -331,13 +460,14 @@ func (cat *catalogDB) AddTableFeature(ctx context.Context, tableName string, jso
 		INSERT INTO %s (%s)
 		VALUES (%s)
 		RETURNING %s`,
-		tbl.ID, strings.Join(columnStr, ", "), strings.Join(placementStr, ", "), tbl.IDColumn)
+		tbl.DbID(), strings.Join(columnStr, ", "), strings.Join(placementStr, ", "), tbl.IDColumn)
 
 	var id int64 = -1
-	err = cat.dbconn.QueryRow(ctx, sqlStatement, values...).Scan(&id)
+	err = cat.source(tbl.Source).QueryRow(ctx, sqlStatement, values...).Scan(&id)
 	if err != nil {
 		return -9999, err
 	}
+	cat.source(tbl.Source).recordWrite(ctx)
 
 	return id, nil
 }
@@ -409,14 +539,15 @@ func (cat *catalogDB) PartialUpdateTableFeature(ctx context.Context, tableName s
 		SET    %s
 		WHERE  %s=%s
 		RETURNING %s
-	`, tbl.ID, setStr, tbl.IDColumn, id, tbl.IDColumn)
+	`, tbl.DbID(), setStr, tbl.IDColumn, id, tbl.IDColumn)
 
-	row := cat.dbconn.QueryRow(ctx, sqlStatement, values...)
+	row := cat.source(tbl.Source).QueryRow(ctx, sqlStatement, values...)
 
 	errQuery := row.Scan(&idx)
 	if errQuery != nil {
 		return errQuery
 	}
+	cat.source(tbl.Source).recordWrite(ctx)
 
 	return nil
 }
@@ -473,12 +604,13 @@ func (cat *catalogDB) ReplaceTableFeature(ctx context.Context, tableName string,
 		SET %s
 		WHERE %s=%s
 		RETURNING %s
-		`, tbl.ID, strings.Join(colValueStr, ", "), tbl.IDColumn, id, tbl.IDColumn)
+		`, tbl.DbID(), strings.Join(colValueStr, ", "), tbl.IDColumn, id, tbl.IDColumn)
 
-	err = cat.dbconn.QueryRow(ctx, sqlStatement, values...).Scan(&idx)
+	err = cat.source(tbl.Source).QueryRow(ctx, sqlStatement, values...).Scan(&idx)
 	if err != nil && err != pgx.ErrNoRows {
 		return err
 	}
+	cat.source(tbl.Source).recordWrite(ctx)
 
 	return nil
 }
@@ -492,29 +624,178 @@ func (cat *catalogDB) DeleteTableFeature(ctx context.Context, tableName string,
 	sqlStatement := fmt.Sprintf(`
 		DELETE FROM %s
 		WHERE %s=%s`,
-		tableName, tbl.IDColumn, fid)
+		tbl.DbID(), tbl.IDColumn, fid)
 
 	var id int64 = -1
-	err = cat.dbconn.QueryRow(ctx, sqlStatement).Scan(&id)
+	err = cat.source(tbl.Source).QueryRow(ctx, sqlStatement).Scan(&id)
 
 	if err != nil && err != pgx.ErrNoRows {
 		return err
 	}
+	cat.source(tbl.Source).recordWrite(ctx)
 
 	return nil
 }
 
-func (cat *catalogDB) refreshTables(force bool) {
+func (cat *catalogDB) APIKeys(table string, column string) ([]string, error) {
+	sql := sqlAPIKeys(table, column)
+	log.Debug("API keys query: " + sql)
+	// keys are read from the first source
+	rows, err := cat.sources[0].dbconn.Query(context.Background(), sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// refreshTables reads the tables if they are not loaded yet, or if forced.
+// If they cannot be read the current tables are kept.
+// An error is returned only if no tables are loaded
+func (cat *catalogDB) refreshTables(force bool) error {
 	// TODO: refresh on timed basis?
-	if force || isStartup {
-		cat.loadTables()
-		isStartup = false
+	cat.tablesMutex.RLock()
+	isLoaded := !isStartup
+	cat.tablesMutex.RUnlock()
+	if force || !isLoaded {
+		err := cat.loadTables()
+		if err != nil && isLoaded {
+			log.Warnf("Error reloading table catalog, keeping the current tables: %v", err)
+			return nil
+		}
+		return err
 	}
+	return nil
 }
 
-func (cat *catalogDB) loadTables() {
-	cat.tableMap = cat.readTables(cat.dbconn)
-	cat.tables = tablesSorted(cat.tableMap)
+func (cat *catalogDB) loadTables() error {
+	cat.reloadMutex.Lock()
+	defer cat.reloadMutex.Unlock()
+	tableMap, err := cat.readTables()
+	if err != nil {
+		cat.setTablesError(err)
+		return err
+	}
+	cat.setTables(tableMap)
+	return nil
+}
+
+// tableSnapshot returns the current tables, sorted and by id.
+// They are not modified by reloads, which replace them
+func (cat *catalogDB) tableSnapshot() ([]*api.Table, map[string]*api.Table) {
+	cat.tablesMutex.RLock()
+	defer cat.tablesMutex.RUnlock()
+	return cat.tables, cat.tableMap
+}
+
+// setTables replaces the tables
+func (cat *catalogDB) setTables(tableMap map[string]*api.Table) {
+	tables := tablesSorted(tableMap)
+	cat.tablesMutex.Lock()
+	defer cat.tablesMutex.Unlock()
+	cat.tableMap = tableMap
+	cat.tables = tables
+	cat.tablesErr = nil
+	isStartup = false
+}
+
+// setTablesError records the failure of a reload of the tables, reported by the health check
+func (cat *catalogDB) setTablesError(err error) {
+	cat.tablesMutex.Lock()
+	defer cat.tablesMutex.Unlock()
+	cat.tablesErr = err
+}
+
+// lastReloadError is the error of the last reload of the tables or functions, if it failed
+func (cat *catalogDB) lastReloadError() error {
+	cat.tablesMutex.RLock()
+	defer cat.tablesMutex.RUnlock()
+	if cat.tablesErr != nil {
+		return cat.tablesErr
+	}
+	return cat.functionsErr
+}
+
+// handleSchemaChange updates the catalog after a change of the definition of a table, view or function
+func (cat *catalogDB) handleSchemaChange(event ddlNotification) {
+	log.Infof("Reloading catalog after %v", event)
+	if event.ObjectType == "function" {
+		if err := cat.refreshFunctions(true); err != nil {
+			log.Warnf("Error reloading function catalog, keeping the current functions: %v", err)
+		}
+		return
+	}
+	if err := cat.reloadTables(); err != nil {
+		log.Warnf("Error reloading table catalog, keeping the current tables: %v", err)
+	}
+}
+
+// reloadTables reloads the tables, keeping those which have not changed (and their extents).
+// Tables added to the catalog get the notify trigger, tables removed lose it,
+// and the cached etags of changed or removed tables are purged.
+// If the tables cannot be read the current tables are kept
+func (cat *catalogDB) reloadTables() error {
+	cat.reloadMutex.Lock()
+	defer cat.reloadMutex.Unlock()
+	_, prevTableMap := cat.tableSnapshot()
+	tableMap, err := cat.readTables()
+	if err != nil {
+		cat.setTablesError(err)
+		return err
+	}
+	for id, tbl := range tableMap {
+		prev, ok := prevTableMap[id]
+		if !ok {
+			if err := cat.source(tbl.Source).listener.attachTrigger(tbl); err != nil {
+				log.Warnf("Error adding trigger to table %v: %v", id, err)
+			}
+		} else if isTableChanged(prev, tbl) {
+			cat.purgeEtags(tbl)
+		} else {
+			tableMap[id] = prev
+		}
+	}
+	for id, prev := range prevTableMap {
+		if _, ok := tableMap[id]; !ok {
+			if err := cat.source(prev.Source).listener.detachTrigger(prev); err != nil {
+				log.Warnf("Error removing trigger from table %v: %v", id, err)
+			}
+			cat.purgeEtags(prev)
+		}
+	}
+	cat.setTables(tableMap)
+	return nil
+}
+
+// isTableChanged tests whether the definition of a table has changed
+func isTableChanged(prev *api.Table, tbl *api.Table) bool {
+	cmp := *prev
+	cmp.Extent = tbl.Extent
+	cmp.TimeExtent = tbl.TimeExtent
+	return !reflect.DeepEqual(&cmp, tbl)
+}
+
+// purgeEtags removes the cached etags of a table,
+// which may be cached under the table name if it is in the public schema
+func (cat *catalogDB) purgeEtags(tbl *api.Table) {
+	names := []string{tbl.ID}
+	if tbl.Schema == "public" {
+		names = append(names, api.SourceID(tbl.Source, tbl.Table))
+	}
+	for _, name := range names {
+		_, err := cat.cache.RemoveCollectionEtags(name)
+		if err != nil {
+			log.Warnf("Error removing etags of %v from cache: %v", name, err)
+		}
+	}
 }
 
 func tablesSorted(tableMap map[string]*api.Table) []*api.Table {
@@ -529,25 +810,39 @@ func tablesSorted(tableMap map[string]*api.Table) []*api.Table {
 	return lsort
 }
 
-func (cat *catalogDB) readTables(db *pgxpool.Pool) map[string]*api.Table {
+// readTables reads the tables of all sources
+func (cat *catalogDB) readTables() (map[string]*api.Table, error) {
+	tables := make(map[string]*api.Table)
+	for _, src := range cat.sources {
+		if err := src.readTables(tables); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// readTables reads the tables of a source, with ids prefixed by the source name
+func (src *dataSource) readTables(tables map[string]*api.Table) error {
 	log.Debugf("Load table catalog:\n%v", sqlTables)
-	rows, err := db.Query(context.Background(), sqlTables)
+	rows, err := src.dbconn.Query(context.Background(), sqlTables)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	tables := make(map[string]*api.Table)
+	defer rows.Close()
 	for rows.Next() {
-		tbl := scanTable(rows)
-		if isIncluded(tbl, cat.tableIncludes, cat.tableExcludes) {
+		tbl, err := scanTable(rows)
+		if err != nil {
+			return err
+		}
+		if isIncluded(tbl, src.tableIncludes, src.tableExcludes) {
+			tbl.Source = src.name
+			tbl.ID = api.SourceID(src.name, tbl.ID)
+			tbl.TimeColumns = timeColumns(tbl, conf.Current().Database.TimeColumns)
 			tables[tbl.ID] = tbl
 		}
 	}
 	// Check for errors from iterating over rows.
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
-	}
-	rows.Close()
-	return tables
+	return rows.Err()
 }
 
 func isIncluded(tbl *api.Table, tableIncludes map[string]string, tableExcludes map[string]string) bool {
@@ -568,26 +863,56 @@ func isMatchSchemaTable(tbl *api.Table, list map[string]string) bool {
 	if _, ok := list[schemaLow]; ok {
 		return true
 	}
-	idLow := strings.ToLower(tbl.ID)
+	idLow := strings.ToLower(tbl.DbID())
 	if _, ok := list[idLow]; ok {
 		return true
 	}
 	return false
 }
 
-func scanTable(rows pgx.Rows) *api.Table {
+// timeColumns determines the time columns of a table.
+// They are set in the configuration, or else the first date or timestamp column is used
+func timeColumns(tbl *api.Table, confTimeCols []conf.TableTimeColumns) []string {
+	idLow := strings.ToLower(tbl.ID)
+	for _, tc := range confTimeCols {
+		if strings.ToLower(tc.Table) != idLow {
+			continue
+		}
+		var cols []string
+		for _, col := range tc.Columns {
+			if _, ok := tbl.DbTypes[col]; !ok {
+				log.Warnf("Time column %v not found in table %v", col, tbl.ID)
+				return nil
+			}
+			cols = append(cols, col)
+		}
+		if len(cols) > 2 {
+			log.Warnf("Too many time columns for table %v: %v", tbl.ID, cols)
+			return nil
+		}
+		return cols
+	}
+	for _, col := range tbl.Columns {
+		if tbl.DbTypes[col].Type.IsTemporal() {
+			return []string{col}
+		}
+	}
+	return nil
+}
+
+func scanTable(rows pgx.Rows) (*api.Table, error) {
 	var (
 		id, schema, table, description, geometryCol string
 		srid                                        int
 		geometryType, idColumn                      string
-		idColHasDefault                             bool
+		idColHasDefault, hasRowVersions             bool
 		props                                       pgtype.TextArray
 	)
 
 	err := rows.Scan(&id, &schema, &table, &description, &geometryCol,
-		&srid, &geometryType, &idColumn, &idColHasDefault, &props)
+		&srid, &geometryType, &idColumn, &idColHasDefault, &props, &hasRowVersions)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	// Use https://godoc.org/github.com/jackc/pgtype#TextArray
@@ -647,17 +972,18 @@ func scanTable(rows pgx.Rows) *api.Table {
 		JSONTypes:       jsontypes,
 		ColDesc:         colDesc,
 		IDColHasDefault: idColHasDefault,
-	}
+		HasRowVersions:  hasRowVersions,
+	}, nil
 }
 
 //=================================================
 
 //nolint:unused
-func readFeatures(ctx context.Context, db *pgxpool.Pool, sql string, tableName string, idColIndex int, propCols []string, cache Cacher) ([]*api.GeojsonFeatureData, error) {
+func readFeatures(ctx context.Context, db dbQuerier, sql string, tableName string, idColIndex int, propCols []string, cache Cacher) ([]*api.GeojsonFeatureData, error) {
 	return readFeaturesWithArgs(ctx, db, sql, nil, tableName, idColIndex, propCols, cache)
 }
 
-func readFeaturesWithArgs(ctx context.Context, db *pgxpool.Pool, sql string, args []interface{}, tableName string, idColIndex int, propCols []string, cache Cacher) ([]*api.GeojsonFeatureData, error) {
+func readFeaturesWithArgs(ctx context.Context, db dbQuerier, sql string, args []interface{}, tableName string, idColIndex int, propCols []string, cache Cacher) ([]*api.GeojsonFeatureData, error) {
 	start := time.Now()
 	rows, err := db.Query(ctx, sql, args...)
 	if err != nil {
@@ -669,29 +995,73 @@ func readFeaturesWithArgs(ctx context.Context, db *pgxpool.Pool, sql string, arg
 	if err != nil {
 		return data, err
 	}
+	recordQuery(queryFeatures, start)
 	log.Debugf(fmtQueryStats, len(data), time.Since(start))
 	return data, nil
 }
 
+// readFeaturesMatched counts (or estimates) the number of rows returned by a query
+func readFeaturesMatched(ctx context.Context, db dbQuerier, sql string, args []interface{}, isEstimate bool) (int, error) {
+	if isEstimate {
+		sql = sqlCountEstimate(sql)
+	} else {
+		sql = sqlCount(sql)
+	}
+	log.Debug("Features matched query: " + sql)
+
+	if !isEstimate {
+		var count int64
+		err := db.QueryRow(ctx, sql, args...).Scan(&count)
+		if err != nil {
+			log.Warnf("Error running 'Features matched' (query: '%v'): %v", sql, err)
+			return 0, err
+		}
+		return int(count), nil
+	}
+
+	var planJSON string
+	err := db.QueryRow(ctx, sql, args...).Scan(&planJSON)
+	if err != nil {
+		log.Warnf("Error running 'Features matched' (query: '%v'): %v", sql, err)
+		return 0, err
+	}
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		}
+	}
+	if err := json.Unmarshal([]byte(planJSON), &plans); err != nil || len(plans) == 0 {
+		return 0, fmt.Errorf("unable to read query plan: %v", err)
+	}
+	return int(plans[0].Plan.Rows), nil
+}
+
 func scanFeatures(ctx context.Context, rows pgx.Rows, tableName string, idColIndex int, propCols []string, cache Cacher) ([]*api.GeojsonFeatureData, error) {
+	_, span := tracing.Start(ctx, spanScanFeatures)
+	defer span.End()
+
 	// init features array to empty (not nil)
 	var features []*api.GeojsonFeatureData = []*api.GeojsonFeatureData{}
 	for rows.Next() {
 		feature, err := scanFeature(rows, tableName, idColIndex, propCols, cache)
 		if err != nil {
+			tracing.SetError(span, err)
 			return nil, err
 		}
 		features = append(features, feature)
 	}
+	span.SetAttributes(attribute.Int("features", len(features)))
 	// context check done outside rows loop,
 	// because a long-running function might not produce any rows before timeout
 	if err := ctx.Err(); err != nil {
 		//log.Debugf("Context error scanning Features: %v", err)
+		tracing.SetError(span, err)
 		return features, err
 	}
 	// Check for errors from scanning rows.
 	if err := rows.Err(); err != nil {
 		log.Warnf("Error scanning rows for Features: %v", err)
+		tracing.SetError(span, err)
 		// TODO: return nil here ?
 		return features, err
 	}
@@ -732,6 +1102,8 @@ func scanFeature(rows pgx.Rows, tableName string, idColIndex int, propNames []st
 				return nil, err
 			}
 			out = api.MakeGeojsonFeature(tableName, id, g, props, weakEtagStr, httpDateString)
+			//--- keep the GeoJSON text for output, since the geometry object has only X and Y
+			out.GeomJSON = json.RawMessage(vals[0].(string))
 
 		} else {
 			out = api.MakeGeojsonFeature(tableName, id, vals[0].(geojson.Geometry), props, weakEtagStr, httpDateString)
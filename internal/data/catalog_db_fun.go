@@ -20,7 +20,6 @@ import (
 	"time"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
-	"github.com/CrunchyData/pg_featureserv/internal/conf"
 	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -33,42 +32,94 @@ const FunctionIDColumnName = "id"
 const SchemaPostGISFTW = "postgisftw"
 
 func (cat *catalogDB) Functions() ([]*api.Function, error) {
-	cat.refreshFunctions(true)
-	return cat.functions, nil
+	if err := cat.refreshFunctions(true); err != nil {
+		return nil, err
+	}
+	functions, _ := cat.functionSnapshot()
+	return functions, nil
 }
 
 func (cat *catalogDB) FunctionByName(name string) (*api.Function, error) {
-	cat.refreshFunctions(false)
-	fn, ok := cat.functionMap[name]
+	if err := cat.refreshFunctions(false); err != nil {
+		return nil, err
+	}
+	_, functionMap := cat.functionSnapshot()
+	fn, ok := functionMap[name]
 	if !ok {
 		return nil, nil
 	}
 	return fn, nil
 }
 
-func (cat *catalogDB) refreshFunctions(force bool) {
+// refreshFunctions reads the functions if they are not loaded yet, or if forced.
+// If they cannot be read the current functions are kept.
+// An error is returned only if no functions are loaded
+func (cat *catalogDB) refreshFunctions(force bool) error {
 	// TODO: refresh on timed basis?
-	if force || !isFunctionsLoaded {
-		cat.loadFunctions()
+	cat.tablesMutex.RLock()
+	isLoaded := isFunctionsLoaded
+	cat.tablesMutex.RUnlock()
+	if force || !isLoaded {
+		err := cat.loadFunctions()
+		if err != nil && isLoaded {
+			log.Warnf("Error reloading function catalog, keeping the current functions: %v", err)
+			return nil
+		}
+		return err
 	}
-	isFunctionsLoaded = true
+	return nil
 }
 
-func (cat *catalogDB) loadFunctions() {
-	cat.functions, cat.functionMap = readFunctionDefs(cat.dbconn, conf.Configuration.Database.FunctionIncludes)
+// functionSnapshot returns the current functions, in database order and by id.
+// They are not modified by reloads, which replace them
+func (cat *catalogDB) functionSnapshot() ([]*api.Function, map[string]*api.Function) {
+	cat.tablesMutex.RLock()
+	defer cat.tablesMutex.RUnlock()
+	return cat.functions, cat.functionMap
 }
 
-func readFunctionDefs(db *pgxpool.Pool, funSchemas []string) ([]*api.Function, map[string]*api.Function) {
+// loadFunctions reads the functions of all sources, with ids prefixed by the source name
+func (cat *catalogDB) loadFunctions() error {
+	var functions []*api.Function
+	functionMap := make(map[string]*api.Function)
+	for _, src := range cat.sources {
+		srcFunctions, _, err := readFunctionDefs(src.dbconn, src.functionIncludes)
+		if err != nil {
+			cat.tablesMutex.Lock()
+			cat.functionsErr = err
+			cat.tablesMutex.Unlock()
+			return err
+		}
+		for _, fn := range srcFunctions {
+			fn.Source = src.name
+			fn.ID = api.SourceID(src.name, fn.ID)
+			functions = append(functions, fn)
+			functionMap[fn.ID] = fn
+		}
+	}
+	cat.tablesMutex.Lock()
+	defer cat.tablesMutex.Unlock()
+	cat.functions, cat.functionMap = functions, functionMap
+	cat.functionsErr = nil
+	isFunctionsLoaded = true
+	return nil
+}
+
+func readFunctionDefs(db *pgxpool.Pool, funSchemas []string) ([]*api.Function, map[string]*api.Function, error) {
 	sql := sqlFunctions(funSchemas)
 	log.Debugf("Load function catalog:\n%v", sql)
 	rows, err := db.Query(context.Background(), sql)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
+	defer rows.Close()
 	var functions []*api.Function
 	functionMap := make(map[string]*api.Function)
 	for rows.Next() {
-		fn := scanFunctionDef(rows)
+		fn, err := scanFunctionDef(rows)
+		if err != nil {
+			return nil, nil, err
+		}
 		// TODO: for now only show geometry functions
 		//if fn.IsGeometryFunction() {
 		functions = append(functions, fn)
@@ -77,13 +128,12 @@ func readFunctionDefs(db *pgxpool.Pool, funSchemas []string) ([]*api.Function, m
 	}
 	// Check for errors from iterating over rows.
 	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
-	rows.Close()
-	return functions, functionMap
+	return functions, functionMap, nil
 }
 
-func scanFunctionDef(rows pgx.Rows) *api.Function {
+func scanFunctionDef(rows pgx.Rows) (*api.Function, error) {
 	var (
 		id, schema, name, description                              string
 		inNamesTA, inTypesTA, inDefaultsTA, outNamesTA, outTypesTA pgtype.TextArray
@@ -92,7 +142,7 @@ func scanFunctionDef(rows pgx.Rows) *api.Function {
 	err := rows.Scan(&id, &schema, &name, &description,
 		&inNamesTA, &inTypesTA, &inDefaultsTA, &outNamesTA, &outTypesTA)
 	if err != nil {
-		log.Fatalf("Error reading function catalog: %v", err)
+		return nil, fmt.Errorf("Error reading function catalog: %v", err)
 	}
 
 	inNames := toArray(inNamesTA)
@@ -135,7 +185,7 @@ func scanFunctionDef(rows pgx.Rows) *api.Function {
 		GeometryColumn: geomCol,
 	}
 	//fmt.Printf("DEBUG: Function definitions: %v\n", funDef)
-	return &funDef
+	return &funDef, nil
 }
 
 func addTypes(typeMap map[string]api.PGType, names []string, types []string) {
@@ -198,10 +248,41 @@ func (cat *catalogDB) FunctionFeatures(ctx context.Context, name string, args ma
 	sql, argValues := sqlGeomFunction(fn, args, propCols, param)
 	log.Debugf("Function features query: %v", sql)
 	log.Debugf("Function %v Args: %v", name, argValues)
-	features, err := readFeaturesWithArgs(ctx, cat.dbconn, sql, argValues, name, idColIndex, propCols, cat.cache)
+	features, err := readFeaturesWithArgs(ctx, cat.source(fn.Source).reader(ctx), sql, argValues, name, idColIndex, propCols, cat.cache)
 	return features, err
 }
 
+func (cat *catalogDB) FunctionFeaturesIterator(ctx context.Context, name string, args map[string]string, param *QueryParam) (FeatureIterator, error) {
+	fn, err := cat.FunctionByName(name)
+	if err != nil || fn == nil {
+		return nil, err
+	}
+	errArg := checkArgsValid(fn, args)
+	if errArg != nil {
+		log.Debug("ERROR: " + errArg.Error())
+		return nil, errArg
+	}
+	propCols := removeNames(param.Columns, fn.GeometryColumn, "")
+	idColIndex := indexOfName(propCols, FunctionIDColumnName)
+	sql, argValues := sqlGeomFunction(fn, args, propCols, param)
+	log.Debugf("Function features query: %v", sql)
+	log.Debugf("Function %v Args: %v", name, argValues)
+	return readFeaturesIteratorWithArgs(ctx, cat.source(fn.Source).reader(ctx), sql, argValues, name, idColIndex, propCols, cat.cache)
+}
+
+func (cat *catalogDB) FunctionFeaturesMatched(ctx context.Context, name string, args map[string]string, param *QueryParam, isEstimate bool) (int, error) {
+	fn, err := cat.FunctionByName(name)
+	if err != nil || fn == nil {
+		return 0, err
+	}
+	errArg := checkArgsValid(fn, args)
+	if errArg != nil {
+		return 0, errArg
+	}
+	sql, argValues := sqlGeomFunctionMatched(fn, args, param)
+	return readFeaturesMatched(ctx, cat.source(fn.Source).reader(ctx), sql, argValues, isEstimate)
+}
+
 func (cat *catalogDB) FunctionData(ctx context.Context, name string, args map[string]string, param *QueryParam) ([]map[string]interface{}, error) {
 	fn, err := cat.FunctionByName(name)
 	if err != nil || fn == nil {
@@ -215,7 +296,7 @@ func (cat *catalogDB) FunctionData(ctx context.Context, name string, args map[st
 	sql, argValues := sqlFunction(fn, args, propCols, param)
 	log.Debugf("Function data query: %v", sql)
 	log.Debugf("Function %v Args: %v", name, argValues)
-	data, err := readDataWithArgs(ctx, cat.dbconn, propCols, sql, argValues)
+	data, err := readDataWithArgs(ctx, cat.source(fn.Source).reader(ctx), propCols, sql, argValues)
 	return data, err
 }
 
@@ -241,15 +322,16 @@ func removeNames(names []string, ex1 string, ex2 string) []string {
 	return newNames
 }
 
-func readDataWithArgs(ctx context.Context, db *pgxpool.Pool, propCols []string, sql string, args []interface{}) ([]map[string]interface{}, error) {
+func readDataWithArgs(ctx context.Context, db dbQuerier, propCols []string, sql string, args []interface{}) ([]map[string]interface{}, error) {
 	start := time.Now()
-	rows, err := db.Query(context.Background(), sql, args...)
+	rows, err := db.Query(ctx, sql, args...)
 	if err != nil {
 		log.Warnf("Error running 'Data' (query: '%v'): %v", sql, err)
 		return nil, err
 	}
 	defer rows.Close()
 	data := scanData(ctx, rows, propCols)
+	recordQuery(queryData, start)
 	log.Debugf(fmtQueryStats, len(data), time.Since(start))
 	return data, nil
 }
@@ -290,10 +372,12 @@ func scanDataRow(rows pgx.Rows, hasID bool, propNames []string) map[string]inter
 }
 
 // Creates a fully qualified function id.
-// adds default postgisftw schema if name arg has no schema
+// adds default postgisftw schema if name arg has no schema,
+// keeping the data source prefix
 func FunctionQualifiedId(name string) string {
-	if strings.Contains(name, ".") {
+	source, id := api.SplitSourceID(name)
+	if strings.Contains(id, ".") {
 		return name
 	}
-	return SchemaPostGISFTW + "." + name
+	return api.SourceID(source, SchemaPostGISFTW+"."+id)
 }
@@ -37,6 +37,11 @@ type CatalogMock struct {
 	tableData    map[string][]*featureMock
 	FunctionDefs []*api.Function
 	cache        Cacher
+	// RoleTables lists the tables visible to session roles.
+	// Roles which are not listed see all tables
+	RoleTables map[string][]string
+	// Unhealthy sets the failure messages of components reported by Health
+	Unhealthy map[string]string
 }
 
 var instance CatalogMock
@@ -94,6 +99,7 @@ func newCatalogMock() CatalogMock {
 		Extent:       api.Extent{Minx: -120, Miny: 40, Maxx: -74, Maxy: 60},
 		Srid:         4326,
 		GeometryType: "Point",
+		IDColumn:     "id",
 		Columns:      propNames,
 		DbTypes:      types,
 		JSONTypes:    jtypes,
@@ -193,16 +199,59 @@ func newCatalogMock() CatalogMock {
 	return catMock
 }
 
+// AddSourceTable adds a copy of a mock table, with its features, as a table of a named source
+func (cat *CatalogMock) AddSourceTable(source string, name string) *api.Table {
+	orig, _ := cat.TableByName(name)
+	if orig == nil {
+		return nil
+	}
+	tbl := *orig
+	tbl.ID = api.SourceID(source, name)
+	tbl.Source = source
+	cat.TableDefs = append(cat.TableDefs, &tbl)
+
+	var features []*featureMock
+	for _, feat := range cat.tableData[name] {
+		copied := *feat
+		copied.Props = make(map[string]interface{})
+		for k, v := range feat.Props {
+			copied.Props[k] = v
+		}
+		features = append(features, &copied)
+	}
+	cat.tableData[tbl.ID] = features
+	return &tbl
+}
+
 // -------------------------------------------------
 
 func (cat *CatalogMock) Initialize(includeList []string, excludeList []string) {
 	// this is a no-op
 }
 
+func (cat *CatalogMock) Reload(includeList []string, excludeList []string) {
+	// this is a no-op
+}
+
 func (cat *CatalogMock) Close() {
 	// this is a no-op
 }
 
+func (cat *CatalogMock) APIKeys(table string, column string) ([]string, error) {
+	return nil, nil
+}
+
+func (cat *CatalogMock) Health(ctx context.Context) map[string]*api.HealthComponent {
+	components := map[string]*api.HealthComponent{
+		api.HealthCatalog: api.HealthOK(fmt.Sprintf("%v tables", len(cat.TableDefs))),
+		api.HealthCache:   api.HealthOK(cat.cache.Type()),
+	}
+	for name, msg := range cat.Unhealthy {
+		components[name] = api.HealthFail(msg)
+	}
+	return components
+}
+
 func (cat *CatalogMock) GetCache() Cacher {
 	return cat.cache
 }
@@ -211,6 +260,40 @@ func (cat *CatalogMock) Tables() ([]*api.Table, error) {
 	return cat.TableDefs, nil
 }
 
+func (cat *CatalogMock) TablesForSession(ctx context.Context) ([]*api.Table, error) {
+	var tables []*api.Table
+	for _, tbl := range cat.TableDefs {
+		if cat.isTableVisible(ctx, tbl.ID) {
+			tables = append(tables, tbl)
+		}
+	}
+	return tables, nil
+}
+
+func (cat *CatalogMock) TableForSession(ctx context.Context, name string) (*api.Table, error) {
+	if !cat.isTableVisible(ctx, name) {
+		return nil, nil
+	}
+	return cat.TableByName(name)
+}
+
+func (cat *CatalogMock) isTableVisible(ctx context.Context, name string) bool {
+	session := SessionFromContext(ctx)
+	if session == nil {
+		return true
+	}
+	names, ok := cat.RoleTables[session.Role]
+	if !ok {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (cat *CatalogMock) TableReload(name string) {
 	// no-op for mock data
 }
@@ -232,6 +315,7 @@ func (cat *CatalogMock) TableFeatures(ctx context.Context, name string, param *Q
 		return nil, nil
 	}
 	featFilt := doFilter(features, param.Filter)
+	featFilt = doCursor(featFilt, param.KeysetColumns, param.Cursor)
 	featuresLim := doLimit(featFilt, param.Limit, param.Offset)
 
 	var propNames []string
@@ -245,6 +329,53 @@ func (cat *CatalogMock) TableFeatures(ctx context.Context, name string, param *Q
 	return featureClones, nil
 }
 
+func (cat *CatalogMock) TableFeaturesIterator(ctx context.Context, name string, param *QueryParam) (FeatureIterator, error) {
+	features, err := cat.TableFeatures(ctx, name, param)
+	if features == nil || err != nil {
+		return nil, err
+	}
+	return newSliceFeatureIterator(features), nil
+}
+
+func (cat *CatalogMock) TableFeaturesMatched(ctx context.Context, name string, param *QueryParam, isEstimate bool) (int, error) {
+	features, ok := cat.tableData[name]
+	if !ok {
+		return 0, nil
+	}
+	return len(doFilter(features, param.Filter)), nil
+}
+
+// TableTile returns an empty tile, with an etag computed from the features located in the tile
+func (cat *CatalogMock) TableTile(ctx context.Context, name string, tile *api.Tile, param *QueryParam) (*api.TileData, error) {
+	features, ok := cat.tableData[name]
+	if !ok {
+		return nil, nil
+	}
+	return &api.TileData{Data: []byte{}, Etag: tileEtag(features, tile, param)}, nil
+}
+
+func (cat *CatalogMock) TableTileEtag(ctx context.Context, name string, tile *api.Tile, param *QueryParam) (string, error) {
+	features, ok := cat.tableData[name]
+	if !ok {
+		return "", nil
+	}
+	return tileEtag(features, tile, param), nil
+}
+
+// tileEtag computes a hash of the etags of the features located in a tile
+func tileEtag(features []*featureMock, tile *api.Tile, param *QueryParam) string {
+	bounds := tile.Bounds()
+	sum := fnv.New32a()
+	for _, feat := range doFilter(features, param.Filter) {
+		center := feat.Geom.Geometry().Bound().Center()
+		x, y := api.LonLatToWebMercator(center[0], center[1])
+		if x >= bounds.Minx && x < bounds.Maxx && y >= bounds.Miny && y < bounds.Maxy {
+			sum.Write([]byte(feat.WeakEtag.Etag))
+		}
+	}
+	return fmt.Sprintf("%x", sum.Sum32())
+}
+
 func (cat *CatalogMock) TableFeature(ctx context.Context, name string, id string, param *QueryParam) (*api.GeojsonFeatureData, error) {
 	features, ok := cat.tableData[name]
 	if !ok {
@@ -469,6 +600,16 @@ func (cat *CatalogMock) FunctionFeatures(ctx context.Context, name string, args
 	return nil, nil
 }
 
+func (cat *CatalogMock) FunctionFeaturesIterator(ctx context.Context, name string, args map[string]string, param *QueryParam) (FeatureIterator, error) {
+	// TODO:
+	return nil, nil
+}
+
+func (cat *CatalogMock) FunctionFeaturesMatched(ctx context.Context, name string, args map[string]string, param *QueryParam, isEstimate bool) (int, error) {
+	// TODO:
+	return 0, nil
+}
+
 func (cat *CatalogMock) FunctionData(ctx context.Context, name string, args map[string]string, param *QueryParam) ([]map[string]interface{}, error) {
 	// TODO:
 	return nil, nil
@@ -0,0 +1,356 @@
+package data
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Request sessions: queries run in a transaction with the role and claims of the request user,
+// so that grants and row-level security policies apply per user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+// SettingClaims is the database setting holding the JSON claims of the request user
+const SettingClaims = "request.jwt.claims"
+
+// sqlSetSession sets the role and claims for the rest of the transaction
+const sqlSetSession = "SELECT set_config('role', $1, true), set_config('" + SettingClaims + "', $2, true)"
+
+// SQLSTATE of insufficient_privilege errors
+const pgCodeInsufficientPrivilege = "42501"
+
+// time after which the tables visible to a role are read again, to pick up changed grants
+const roleTablesTTL = 60 * time.Second
+
+// Session holds the database role and the claims of the user of a request
+type Session struct {
+	// Role is the role queries run as. The connection role is used if blank
+	Role string
+	// Claims is a JSON object of the user claims, or blank
+	Claims string
+}
+
+type sessionKey struct{}
+
+// WithSession returns a context for a request session
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the request session, or nil if there is none
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// IsPermissionError tests whether an error is a database privilege error,
+// raised by a missing grant or a row-level security policy
+func IsPermissionError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgCodeInsufficientPrivilege
+}
+
+// dbQuerier runs request queries
+type dbQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// Query runs a query on the primary request pool of the source
+func (src *dataSource) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return poolQuerier{src.requestPool}.Query(ctx, sql, args...)
+}
+
+// QueryRow runs a query returning a row on the primary request pool of the source
+func (src *dataSource) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return poolQuerier{src.requestPool}.QueryRow(ctx, sql, args...)
+}
+
+// poolQuerier runs request queries on a pool
+type poolQuerier struct {
+	pool *pgxpool.Pool
+}
+
+// Query runs a query on a connection of the pool, in a transaction if the request has a session
+// or a statement timeout. The transaction ends and the connection is released when the rows are closed
+func (q poolQuerier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	conn, tx, err := q.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows pgx.Rows
+	if tx != nil {
+		rows, err = tx.Query(ctx, sql, args...)
+	} else {
+		rows, err = conn.Query(ctx, sql, args...)
+	}
+	if err != nil {
+		endRequest(conn, tx, err)
+		return nil, err
+	}
+	return &requestRows{Rows: rows, conn: conn, tx: tx}, nil
+}
+
+// QueryRow runs a query returning a row on a connection of the pool, in a transaction if the request
+// has a session or a statement timeout. The transaction ends and the connection is released when the row is scanned
+func (q poolQuerier) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	conn, tx, err := q.begin(ctx)
+	if err != nil {
+		return errRow{err}
+	}
+	if tx != nil {
+		return &requestRow{row: tx.QueryRow(ctx, sql, args...), conn: conn, tx: tx}
+	}
+	return &requestRow{row: conn.QueryRow(ctx, sql, args...), conn: conn}
+}
+
+// begin acquires a connection for a request query, and begins a transaction
+// setting the session and the statement timeout of the table of the query, if they are set.
+// Otherwise the query runs with the default statement timeout of the connection, without a transaction
+func (q poolQuerier) begin(ctx context.Context) (*requestConn, pgx.Tx, error) {
+	conn, err := acquireRequestConn(ctx, q.pool)
+	if err != nil {
+		return nil, nil, err
+	}
+	session := SessionFromContext(ctx)
+	timeout := statementTimeoutFromContext(ctx)
+	if session == nil && timeout == 0 {
+		return conn, nil, nil
+	}
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		conn.release()
+		return nil, nil, err
+	}
+	if session != nil {
+		err = setSession(ctx, tx, session)
+	}
+	if err == nil && timeout > 0 {
+		err = setStatementTimeout(ctx, tx, timeout)
+	}
+	if err != nil {
+		endRequest(conn, tx, err)
+		return nil, nil, err
+	}
+	return conn, tx, nil
+}
+
+// setSession sets the role and claims of a session for the rest of the transaction
+func setSession(ctx context.Context, tx pgx.Tx, session *Session) error {
+	claims := session.Claims
+	if claims == "" {
+		claims = "{}"
+	}
+	role := session.Role
+	if role == "" {
+		// "none" resets the role to the connection role
+		role = "none"
+	}
+	if _, err := tx.Exec(ctx, sqlSetSession, role, claims); err != nil {
+		log.Warnf("Error setting session role '%v': %v", session.Role, err)
+		return err
+	}
+	return nil
+}
+
+// endSession commits a session transaction, or rolls it back if the statement failed.
+// A context which is not cancelled is used, so the connection is returned to the pool in a clean state
+func endSession(tx pgx.Tx, err error) error {
+	if err != nil && err != pgx.ErrNoRows {
+		_ = tx.Rollback(context.Background())
+		return nil
+	}
+	errCommit := tx.Commit(context.Background())
+	if errCommit == pgx.ErrTxClosed {
+		return nil
+	}
+	return errCommit
+}
+
+// endRequest ends the transaction of a request query, if any, and releases its connection
+func endRequest(conn *requestConn, tx pgx.Tx, err error) error {
+	var errEnd error
+	if tx != nil {
+		errEnd = endSession(tx, err)
+	}
+	conn.release()
+	return errEnd
+}
+
+// requestRows ends the transaction and releases the connection of a query when the rows are closed
+type requestRows struct {
+	pgx.Rows
+	conn *requestConn
+	tx   pgx.Tx
+	// isClosed is set once the rows are closed, since Close may be called more than once
+	isClosed bool
+}
+
+func (rows *requestRows) Close() {
+	rows.Rows.Close()
+	if rows.isClosed {
+		return
+	}
+	rows.isClosed = true
+	if err := endRequest(rows.conn, rows.tx, rows.Rows.Err()); err != nil {
+		log.Warnf("Error committing session transaction: %v", err)
+	}
+}
+
+// Next closes the rows after the last row, as pgx does, so that the connection is released
+func (rows *requestRows) Next() bool {
+	if rows.Rows.Next() {
+		return true
+	}
+	rows.Close()
+	return false
+}
+
+// requestRow ends the transaction and releases the connection of a query when the row is scanned
+type requestRow struct {
+	row  pgx.Row
+	conn *requestConn
+	tx   pgx.Tx
+}
+
+func (row *requestRow) Scan(dest ...interface{}) error {
+	err := row.row.Scan(dest...)
+	if errEnd := endRequest(row.conn, row.tx, err); errEnd != nil {
+		return errEnd
+	}
+	return err
+}
+
+// errRow is a row for a query which could not be run
+type errRow struct {
+	err error
+}
+
+func (row errRow) Scan(dest ...interface{}) error {
+	return row.err
+}
+
+// roleTables holds whether a role can select the catalog tables, by table id
+type roleTables struct {
+	isVisible map[string]bool
+	loadTime  time.Time
+}
+
+func (cat *catalogDB) TablesForSession(ctx context.Context) ([]*api.Table, error) {
+	tables, err := cat.Tables()
+	session := SessionFromContext(ctx)
+	if err != nil || session == nil || session.Role == "" {
+		return tables, err
+	}
+	isVisible := cat.roleTables(session.Role, tables)
+	var visible []*api.Table
+	for _, tbl := range tables {
+		if isVisible[tbl.ID] {
+			visible = append(visible, tbl)
+		}
+	}
+	return visible, nil
+}
+
+func (cat *catalogDB) TableForSession(ctx context.Context, name string) (*api.Table, error) {
+	tbl, err := cat.TableByName(name)
+	session := SessionFromContext(ctx)
+	if err != nil || session == nil || session.Role == "" {
+		return tbl, err
+	}
+	if !cat.roleTables(session.Role, []*api.Table{tbl})[tbl.ID] {
+		log.Debugf("Table %v is not visible to role %v", tbl.ID, session.Role)
+		return nil, nil
+	}
+	return tbl, nil
+}
+
+// roleTables returns whether a role can select tables.
+// The result is cached by role, and read again if it is expired or a table has not been checked yet
+func (cat *catalogDB) roleTables(role string, tables []*api.Table) map[string]bool {
+	cat.roleTablesMutex.Lock()
+	defer cat.roleTablesMutex.Unlock()
+
+	entry, ok := cat.roleTablesCache[role]
+	if ok && time.Since(entry.loadTime) < roleTablesTTL && entry.hasTables(tables) {
+		return entry.isVisible
+	}
+	// check all catalog tables, so that listings and single tables share the entry
+	catalogTables, _ := cat.tableSnapshot()
+	entry = &roleTables{
+		isVisible: cat.readRoleTables(role, catalogTables),
+		loadTime:  time.Now(),
+	}
+	if cat.roleTablesCache == nil {
+		cat.roleTablesCache = make(map[string]*roleTables)
+	}
+	cat.roleTablesCache[role] = entry
+	return entry.isVisible
+}
+
+func (entry *roleTables) hasTables(tables []*api.Table) bool {
+	for _, tbl := range tables {
+		if _, ok := entry.isVisible[tbl.ID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// readRoleTables queries which tables a role can select, in the database of each source.
+// No table is visible if the query fails (for instance if the role does not exist)
+func (cat *catalogDB) readRoleTables(role string, tables []*api.Table) map[string]bool {
+	isVisible := make(map[string]bool, len(tables))
+	for _, src := range cat.sources {
+		var ids []string
+		for _, tbl := range tables {
+			if tbl.Source == src.name {
+				ids = append(ids, tbl.DbID())
+				isVisible[tbl.ID] = false
+			}
+		}
+		if len(ids) > 0 {
+			src.readRoleTables(role, ids, isVisible)
+		}
+	}
+	return isVisible
+}
+
+func (src *dataSource) readRoleTables(role string, ids []string, isVisible map[string]bool) {
+	rows, err := src.dbconn.Query(context.Background(), sqlRoleTables, role, ids)
+	if err != nil {
+		log.Warnf("Error reading tables of role %v: %v", role, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var isSelect bool
+		if err := rows.Scan(&id, &isSelect); err != nil {
+			log.Warnf("Error reading tables of role %v: %v", role, err)
+			return
+		}
+		isVisible[api.SourceID(src.name, id)] = isSelect
+	}
+	if err := rows.Err(); err != nil {
+		log.Warnf("Error reading tables of role %v: %v", role, err)
+	}
+}
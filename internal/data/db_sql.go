@@ -25,6 +25,7 @@ import (
 	"strings"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/jackc/pgx/v4"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -47,7 +48,8 @@ const sqlTables = `SELECT
 		AND sa.attnum > 0
 		AND NOT sa.attisdropped
 		AND st.typname NOT IN ('geometry', 'geography')
-	) AS props
+	) AS props,
+	c.relkind IN ('r', 'p') AS has_row_versions
 FROM pg_class c
 JOIN pg_namespace n ON (c.relnamespace = n.oid)
 JOIN pg_attribute a ON (a.attrelid = c.oid)
@@ -65,6 +67,19 @@ AND has_table_privilege(c.oid, 'select')
 AND postgis_typmod_srid(a.atttypmod) > 0
 ORDER BY id
 `
+const sqlFmtAPIKeys = "SELECT %s::text FROM %s WHERE %s IS NOT NULL"
+
+func sqlAPIKeys(table string, column string) string {
+	tableID := pgx.Identifier(strings.Split(table, ".")).Sanitize()
+	colID := pgx.Identifier{column}.Sanitize()
+	return fmt.Sprintf(sqlFmtAPIKeys, colID, tableID, colID)
+}
+
+// sqlRoleTables tests which of the tables ($2 ids) a role ($1) can select
+const sqlRoleTables = `SELECT t.id, has_table_privilege($1, t.id, 'select')
+FROM unnest($2::text[]) AS t(id)
+`
+
 const sqlFunctionsTemplate = `WITH
 proargs AS (
 	SELECT p.oid,
@@ -161,6 +176,61 @@ END;
 $$ LANGUAGE plpgsql;
 `
 
+// sqlNotifyDDLFunction notifies the changes of the definition of tables, views and functions,
+// except for the objects of the pg_featureserv schema
+const sqlNotifyDDLFunction = `CREATE OR REPLACE FUNCTION %[1]s.notify_ddl() RETURNS EVENT_TRIGGER AS $$
+DECLARE
+		obj record;
+BEGIN
+		IF (TG_EVENT = 'sql_drop') THEN
+			FOR obj IN SELECT object_type, schema_name, object_identity FROM pg_event_trigger_dropped_objects() LOOP
+				IF (obj.object_type IN ('table', 'view', 'materialized view', 'foreign table', 'function')
+						AND obj.schema_name IS DISTINCT FROM '%[1]s') THEN
+					PERFORM pg_notify('ddl_update', json_build_object(
+						'action', TG_TAG,
+						'object_type', obj.object_type,
+						'schema', obj.schema_name,
+						'identity', obj.object_identity)::text);
+				END IF;
+			END LOOP;
+		ELSE
+			FOR obj IN SELECT object_type, schema_name, object_identity FROM pg_event_trigger_ddl_commands() LOOP
+				IF (obj.object_type IN ('table', 'table column', 'view', 'materialized view', 'foreign table', 'function')
+						AND obj.schema_name IS DISTINCT FROM '%[1]s') THEN
+					PERFORM pg_notify('ddl_update', json_build_object(
+						'action', TG_TAG,
+						'object_type', obj.object_type,
+						'schema', obj.schema_name,
+						'identity', obj.object_identity)::text);
+				END IF;
+			END LOOP;
+		END IF;
+END;
+
+$$ LANGUAGE plpgsql;
+`
+
+// event triggers are global to the database, so they are named after the pg_featureserv schema
+const sqlFmtDDLEventTriggers = `
+DROP EVENT TRIGGER IF EXISTS %[1]s_ddl_command_end;
+DROP EVENT TRIGGER IF EXISTS %[1]s_sql_drop;
+CREATE EVENT TRIGGER %[1]s_ddl_command_end ON ddl_command_end
+	WHEN TAG IN ('CREATE TABLE', 'CREATE TABLE AS', 'SELECT INTO', 'ALTER TABLE',
+		'CREATE VIEW', 'ALTER VIEW', 'CREATE MATERIALIZED VIEW', 'ALTER MATERIALIZED VIEW',
+		'CREATE FOREIGN TABLE', 'ALTER FOREIGN TABLE', 'CREATE FUNCTION', 'ALTER FUNCTION', 'COMMENT')
+	EXECUTE PROCEDURE %[1]s.notify_ddl();
+CREATE EVENT TRIGGER %[1]s_sql_drop ON sql_drop
+	WHEN TAG IN ('DROP TABLE', 'DROP VIEW', 'DROP MATERIALIZED VIEW', 'DROP FOREIGN TABLE', 'DROP FUNCTION')
+	EXECUTE PROCEDURE %[1]s.notify_ddl();
+`
+
+// sqlFmtHasNotifyTrigger checks whether a relation is a table, and has a trigger notifying its changes
+const sqlFmtHasNotifyTrigger = `SELECT c.relkind IN ('r', 'p'), EXISTS (SELECT 1 FROM pg_trigger
+	WHERE tgrelid = c.oid AND tgfoid = '%s.notify_event'::regproc)
+FROM pg_class c WHERE c.oid = $1::regclass`
+
+const sqlRelationExists = "SELECT to_regclass($1) IS NOT NULL"
+
 func sqlFunctions(funSchemas []string) string {
 	inSchemas := quotedList(funSchemas)
 	return strings.Replace(sqlFunctionsTemplate, "#SCHEMAS#", inSchemas, 1)
@@ -188,8 +258,17 @@ func sqlExtentExact(tbl *api.Table) string {
 	return fmt.Sprintf(sqlFmtExtentExact, tbl.GeometryColumn, tbl.Srid, tbl.Schema, tbl.Table)
 }
 
-// xmin is used as weak eTag value
-const sqlFmtFeatures = "SELECT %v, xmin AS eTag, %v FROM \"%s\".\"%s\" %v %v %v %s;"
+const sqlFmtTimeExtent = `SELECT to_json(min(%v)) #>> '{}', to_json(max(%v)) #>> '{}' FROM "%s"."%s";`
+
+// sqlTimeExtent creates a query for the first and last times of a table, in ISO 8601 format
+func sqlTimeExtent(tbl *api.Table) string {
+	startCol := strconv.Quote(tbl.TimeColumns[0])
+	endCol := strconv.Quote(tbl.TimeColumns[len(tbl.TimeColumns)-1])
+	return fmt.Sprintf(sqlFmtTimeExtent, startCol, endCol, tbl.Schema, tbl.Table)
+}
+
+// the row version is used as weak eTag value
+const sqlFmtFeatures = "SELECT %v, %v AS eTag, %v FROM \"%s\".\"%s\" %v %v %v %s;"
 
 func sqlFeatures(tbl *api.Table, param *QueryParam) (string, []interface{}) {
 	geomCol := sqlGeomCol(tbl.GeometryColumn, tbl.Srid, param)
@@ -197,15 +276,135 @@ func sqlFeatures(tbl *api.Table, param *QueryParam) (string, []interface{}) {
 	propCols := sqlColListFromColumnMap(param.Columns, tbl.DbTypes)
 	bboxFilter := sqlBBoxFilter(tbl.GeometryColumn, tbl.Srid, param.Bbox, param.BboxCrs)
 	attrFilter, attrVals := sqlAttrFilter(param.Filter)
-	cqlFilter := sqlCqlFilter(param.FilterSql)
-	sqlWhere := sqlWhere(bboxFilter, attrFilter, cqlFilter)
+	cqlFilter := sqlAnd(sqlCqlFilter(param.FilterSql), sqlDateTimeFilter(tbl.TimeColumns, param.DateTime))
+	keysetFilter, keysetVals := sqlKeysetFilter(param.KeysetColumns, tbl.DbTypes, param.KeysetIsDesc, param.Cursor, len(attrVals))
+	sqlWhere := sqlWhere(bboxFilter, attrFilter, sqlAnd(cqlFilter, keysetFilter))
 	sqlGroupBy := sqlGroupBy(param.GroupBy)
 	sqlOrderBy := sqlOrderBy(param.SortBy)
+	if len(param.KeysetColumns) > 0 {
+		sqlOrderBy = sqlKeysetOrderBy(param.KeysetColumns, param.KeysetIsDesc)
+	}
 	sqlLimitOffset := sqlLimitOffset(param.Limit, param.Offset)
-	sql := fmt.Sprintf(sqlFmtFeatures, geomCol, propCols, tbl.Schema, tbl.Table, sqlWhere, sqlGroupBy, sqlOrderBy, sqlLimitOffset)
+	attrVals = append(attrVals, keysetVals...)
+	sql := fmt.Sprintf(sqlFmtFeatures, geomCol, sqlRowVersion(tbl), propCols, tbl.Schema, tbl.Table, sqlWhere, sqlGroupBy, sqlOrderBy, sqlLimitOffset)
 	return sql, attrVals
 }
 
+const sqlFmtFeaturesMatched = "SELECT 1 FROM \"%s\".\"%s\" %v %v"
+
+// sqlFeaturesMatched creates a query returning a row for each feature matched by the query filters.
+// Limit and offset are not applied
+func sqlFeaturesMatched(tbl *api.Table, param *QueryParam) (string, []interface{}) {
+	bboxFilter := sqlBBoxFilter(tbl.GeometryColumn, tbl.Srid, param.Bbox, param.BboxCrs)
+	attrFilter, attrVals := sqlAttrFilter(param.Filter)
+	cqlFilter := sqlAnd(sqlCqlFilter(param.FilterSql), sqlDateTimeFilter(tbl.TimeColumns, param.DateTime))
+	sqlWhere := sqlWhere(bboxFilter, attrFilter, cqlFilter)
+	sqlGroupBy := sqlGroupBy(param.GroupBy)
+	sql := fmt.Sprintf(sqlFmtFeaturesMatched, tbl.Schema, tbl.Table, sqlWhere, sqlGroupBy)
+	return sql, attrVals
+}
+
+const sqlFmtCount = "SELECT count(*) FROM ( %v ) AS matched;"
+
+// sqlCount wraps a query to count the rows it returns
+func sqlCount(sql string) string {
+	return fmt.Sprintf(sqlFmtCount, sql)
+}
+
+const sqlFmtCountEstimate = "EXPLAIN (FORMAT JSON) %v;"
+
+// sqlCountEstimate wraps a query to obtain the planner estimate of the rows it returns
+func sqlCountEstimate(sql string) string {
+	return fmt.Sprintf(sqlFmtCountEstimate, sql)
+}
+
+// The tile features are read once, to build the tile and to compute its etag:
+// a hash of the feature versions (xmin) for tables, or else a hash of the tile data,
+// since views and foreign tables have no row versions
+const sqlFmtTile = `WITH mvtgeom AS (
+	SELECT ST_AsMVTGeom( ST_Transform( ST_Force2D( "%v"::geometry ), 3857 ), ST_TileEnvelope( %d, %d, %d ), %d, %d, true ) AS _geom %v %v
+	FROM "%s"."%s"
+	WHERE "%v" && ST_Transform( ST_Expand( ST_TileEnvelope( %d, %d, %d ), %v ), %d ) %v
+),
+tile AS (
+	SELECT coalesce( ST_AsMVT( q, %v, %d, '_geom' %v ), ''::bytea ) AS mvt FROM ( SELECT _geom %v FROM mvtgeom WHERE _geom IS NOT NULL ) AS q
+)
+SELECT mvt, %v AS etag FROM tile;`
+
+const sqlTileVersionCol = ", xmin::text AS _etag"
+const sqlTileEtagVersions = "( SELECT md5( coalesce( string_agg( _etag, ',' ORDER BY _etag ), '' ) ) FROM mvtgeom )"
+const sqlTileEtagData = "md5( mvt )"
+
+func sqlTile(tbl *api.Table, tile *api.Tile, param *QueryParam) (string, []interface{}) {
+	propCols := ""
+	propNames := ""
+	idColName := ""
+	if len(param.Columns) > 0 {
+		propCols = "," + sqlColListFromColumnMap(param.Columns, tbl.DbTypes)
+		var names []string
+		for _, col := range param.Columns {
+			names = append(names, strconv.Quote(col))
+			// MVT feature ids must be integers
+			if col == tbl.IDColumn && isIntegerType(tbl.DbTypes[col].Type) {
+				idColName = ", " + sqlLiteral(col)
+			}
+		}
+		propNames = "," + strings.Join(names, ",")
+	}
+	versionCol := ""
+	etag := sqlTileEtagData
+	if tbl.HasRowVersions {
+		versionCol = sqlTileVersionCol
+		etag = sqlTileEtagVersions
+	}
+	sqlWhere, attrVals := sqlTileWhere(tbl, param)
+	sql := fmt.Sprintf(sqlFmtTile,
+		tbl.GeometryColumn, tile.Zoom, tile.X, tile.Y, api.TileExtent, api.TileBuffer, versionCol, propCols,
+		tbl.Schema, tbl.Table,
+		tbl.GeometryColumn, tile.Zoom, tile.X, tile.Y, tile.BufferSize(), tbl.Srid, sqlWhere,
+		sqlLiteral(tbl.ID), api.TileExtent, idColName, propNames,
+		etag)
+	return sql, attrVals
+}
+
+// The etag of a table tile is computed from the feature versions only,
+// so that it can be checked without building the tile
+const sqlFmtTileEtag = `SELECT md5( coalesce( string_agg( xmin::text, ',' ORDER BY xmin::text ), '' ) )
+	FROM "%s"."%s"
+	WHERE "%v" && ST_Transform( ST_Expand( ST_TileEnvelope( %d, %d, %d ), %v ), %d ) %v;`
+
+func sqlTileEtag(tbl *api.Table, tile *api.Tile, param *QueryParam) (string, []interface{}) {
+	sqlWhere, attrVals := sqlTileWhere(tbl, param)
+	sql := fmt.Sprintf(sqlFmtTileEtag,
+		tbl.Schema, tbl.Table,
+		tbl.GeometryColumn, tile.Zoom, tile.X, tile.Y, tile.BufferSize(), tbl.Srid, sqlWhere)
+	return sql, attrVals
+}
+
+// sqlTileWhere creates the filter conditions of a tile query, following the tile envelope condition
+func sqlTileWhere(tbl *api.Table, param *QueryParam) (string, []interface{}) {
+	attrFilter, attrVals := sqlAttrFilter(param.Filter)
+	cqlFilter := sqlAnd(sqlCqlFilter(param.FilterSql), sqlDateTimeFilter(tbl.TimeColumns, param.DateTime))
+	sqlWhere := ""
+	if cond := sqlAnd(attrFilter, cqlFilter); cond != "" {
+		sqlWhere = "AND " + cond
+	}
+	return sqlWhere, attrVals
+}
+
+func isIntegerType(dbType api.PGType) bool {
+	switch dbType {
+	case api.PGTypeInt, api.PGTypeInt4, api.PGTypeInt8, api.PGTypeBigInt:
+		return true
+	}
+	return false
+}
+
+// sqlLiteral quotes a string as an SQL literal
+func sqlLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 // sqlColList creates a comma-separated column list, or blank if no columns
 // If addLeadingComma is true, a leading comma is added, for use when the target SQL has columns defined before
 func sqlColListFromColumnMap(names []string, dbtypes map[string]api.Column) string {
@@ -251,14 +450,26 @@ func sqlColExpr(name string, dbtype api.PGType) string {
 	return name
 }
 
-// xmin is used as weak eTag value
-const sqlFmtFeature = "SELECT %v, xmin AS eTag, %v FROM \"%s\".\"%s\" WHERE \"%v\" = $1 LIMIT 1"
+// the row version is used as weak eTag value
+const sqlFmtFeature = "SELECT %v, %v AS eTag, %v FROM \"%s\".\"%s\" WHERE \"%v\" = $1 LIMIT 1"
+
+// sqlFmtRowHash is the version of the rows of views and foreign tables, which have no xmin
+const sqlFmtRowHash = `md5( ROW( "%s"."%s".* )::text )`
+
+// sqlRowVersion is the expression of the version of a row: xmin for tables,
+// or else a hash of the row values
+func sqlRowVersion(tbl *api.Table) string {
+	if tbl.HasRowVersions {
+		return "xmin"
+	}
+	return fmt.Sprintf(sqlFmtRowHash, tbl.Schema, tbl.Table)
+}
 
 func sqlFeature(tbl *api.Table, param *QueryParam) string {
 	geomCol := sqlGeomCol(tbl.GeometryColumn, tbl.Srid, param)
 
 	propCols := sqlColListFromColumnMap(param.Columns, tbl.DbTypes)
-	sql := fmt.Sprintf(sqlFmtFeature, geomCol, propCols, tbl.Schema, tbl.Table, tbl.IDColumn)
+	sql := fmt.Sprintf(sqlFmtFeature, geomCol, sqlRowVersion(tbl), propCols, tbl.Schema, tbl.Table, tbl.IDColumn)
 	return sql
 }
 
@@ -270,6 +481,38 @@ func sqlCqlFilter(sql string) string {
 	return "(" + sql + ")"
 }
 
+// sqlDateTimeFilter creates the condition selecting features whose time intersects a datetime interval.
+// With start and end columns, a NULL value is an open bound.
+// The timestamps are validated when the request is parsed
+func sqlDateTimeFilter(timeCols []string, dt *api.DateTime) string {
+	if dt == nil || len(timeCols) == 0 {
+		return ""
+	}
+	startCol := strconv.Quote(timeCols[0])
+	endCol := strconv.Quote(timeCols[len(timeCols)-1])
+	isInterval := len(timeCols) > 1
+	var conds []string
+	if dt.End != "" {
+		op := "<="
+		if dt.EndExclusive {
+			op = "<"
+		}
+		cond := fmt.Sprintf("%v %v %v::timestamptz", startCol, op, sqlLiteral(dt.End))
+		if isInterval {
+			cond = fmt.Sprintf("(%v OR %v IS NULL)", cond, startCol)
+		}
+		conds = append(conds, cond)
+	}
+	if dt.Start != "" {
+		cond := fmt.Sprintf("%v >= %v::timestamptz", endCol, sqlLiteral(dt.Start))
+		if isInterval {
+			cond = fmt.Sprintf("(%v OR %v IS NULL)", cond, endCol)
+		}
+		conds = append(conds, cond)
+	}
+	return strings.Join(conds, " AND ")
+}
+
 func sqlWhere(cond1 string, cond2 string, cond3 string) string {
 	var condList []string
 	if len(cond1) > 0 {
@@ -288,6 +531,93 @@ func sqlWhere(cond1 string, cond2 string, cond3 string) string {
 	return where
 }
 
+// sqlAnd combines two conditions, either of which may be blank
+func sqlAnd(cond1 string, cond2 string) string {
+	if len(cond1) == 0 {
+		return cond2
+	}
+	if len(cond2) == 0 {
+		return cond1
+	}
+	return cond1 + " AND " + cond2
+}
+
+// sqlKeysetFilter creates the condition selecting features after the cursor in keyset order.
+// The cursor values are provided as text arguments, numbered after argOffset existing ones,
+// and are cast to the type of temporal columns.
+// NULLs are after all values in ascending order, and before them in descending order (as ordered by sqlKeysetOrderBy).
+// The last keyset column is the ID column, which is not NULL
+func sqlKeysetFilter(keyCols []string, colTypes map[string]api.Column, isDesc bool, cursor []*string, argOffset int) (string, []interface{}) {
+	if len(keyCols) == 0 || len(cursor) != len(keyCols) {
+		return "", nil
+	}
+	cols := make([]string, len(keyCols))
+	args := make([]string, len(keyCols))
+	var vals []interface{}
+	for i, col := range keyCols {
+		cols[i] = fmt.Sprintf("\"%v\"", col)
+		if cursor[i] == nil {
+			continue
+		}
+		vals = append(vals, *cursor[i])
+		args[i] = fmt.Sprintf("$%v", argOffset+len(vals))
+		if colType := colTypes[col].Type; colType.IsTemporal() {
+			args[i] = fmt.Sprintf("%v::%v", args[i], colType)
+		}
+	}
+	op := ">"
+	if isDesc {
+		op = "<"
+	}
+	// a feature is after the cursor if its value of a column is after the cursor value,
+	// or is equal to it and its values of the next columns are after the cursor
+	sql := ""
+	for i := len(keyCols) - 1; i >= 0; i-- {
+		isNullable := i < len(keyCols)-1
+		var after, equal string
+		switch {
+		case cursor[i] == nil && isDesc:
+			after = fmt.Sprintf("%v IS NOT NULL", cols[i])
+			equal = fmt.Sprintf("%v IS NULL", cols[i])
+		case cursor[i] == nil:
+			equal = fmt.Sprintf("%v IS NULL", cols[i])
+		case isNullable && !isDesc:
+			after = fmt.Sprintf("%v %v %v OR %v IS NULL", cols[i], op, args[i], cols[i])
+			equal = fmt.Sprintf("%v = %v", cols[i], args[i])
+		default:
+			after = fmt.Sprintf("%v %v %v", cols[i], op, args[i])
+			equal = fmt.Sprintf("%v = %v", cols[i], args[i])
+		}
+		switch {
+		case sql == "":
+			sql = after
+		case after == "":
+			sql = fmt.Sprintf("%v AND %v", equal, sql)
+		default:
+			sql = fmt.Sprintf("%v OR (%v AND %v)", after, equal, sql)
+		}
+	}
+	if sql == "" {
+		// no feature is after a NULL ID
+		sql = "FALSE"
+	}
+	return "(" + sql + ")", vals
+}
+
+// sqlKeysetOrderBy orders features by all keyset columns, in the same direction.
+// NULLs are ordered as for a single ascending order, reversed if descending
+func sqlKeysetOrderBy(keyCols []string, isDesc bool) string {
+	dir := " ASC NULLS LAST"
+	if isDesc {
+		dir = " DESC NULLS FIRST"
+	}
+	var cols []string
+	for _, col := range keyCols {
+		cols = append(cols, fmt.Sprintf("\"%v\"%v", col, dir))
+	}
+	return "ORDER BY " + strings.Join(cols, ",")
+}
+
 func sqlAttrFilter(filterConds []*PropertyFilter) (string, []interface{}) {
 	var vals []interface{}
 	var exprItems []string
@@ -307,6 +637,9 @@ func sqlBBoxFilter(geomCol string, srcSRID int, bbox *api.Extent, bboxSRID int)
 	if bbox == nil {
 		return ""
 	}
+	if bbox.HasZ {
+		return sqlBBox3DFilter(geomCol, srcSRID, bbox, bboxSRID)
+	}
 	if srcSRID == bboxSRID {
 		return fmt.Sprintf(sqlFmtBBoxGeoFilter, geomCol,
 			bbox.Minx, bbox.Miny, bbox.Maxx, bbox.Maxy, bboxSRID)
@@ -317,13 +650,35 @@ func sqlBBoxFilter(geomCol string, srcSRID int, bbox *api.Extent, bboxSRID int)
 		srcSRID)
 }
 
+// The XY extent is tested exactly, and the Z extent using the n-D bounding box of the geometry
+const sqlFmtBBox3DFilter = ` ST_Intersects("%v", %v) AND "%v" &&& %v `
+
+func sqlBBox3DFilter(geomCol string, srcSRID int, bbox *api.Extent, bboxSRID int) string {
+	envelope := fmt.Sprintf("ST_MakeEnvelope(%v, %v, %v, %v, %v)",
+		bbox.Minx, bbox.Miny, bbox.Maxx, bbox.Maxy, bboxSRID)
+	box := fmt.Sprintf("ST_SetSRID(ST_3DMakeBox(ST_MakePoint(%v, %v, %v), ST_MakePoint(%v, %v, %v))::geometry, %v)",
+		bbox.Minx, bbox.Miny, bbox.Minz, bbox.Maxx, bbox.Maxy, bbox.Maxz, bboxSRID)
+	if srcSRID != bboxSRID {
+		//-- transform bbox to src CRS so spatial index is used
+		envelope = fmt.Sprintf("ST_Transform( %v, %v)", envelope, srcSRID)
+		box = fmt.Sprintf("ST_Transform( %v, %v)", box, srcSRID)
+	}
+	return fmt.Sprintf(sqlFmtBBox3DFilter, geomCol, envelope, geomCol, box)
+}
+
 const sqlFmtGeomCol = `ST_AsGeoJSON( %v %v ) AS _geojson`
 
 func sqlGeomCol(geomCol string, sourceSRID int, param *QueryParam) string {
 	geomColSafe := strconv.Quote(geomCol)
+	if param.Force2D {
+		geomColSafe = fmt.Sprintf("ST_Force2D( %v::geometry )", geomColSafe)
+	}
 	geomExpr := applyTransform(param.TransformFuns, geomColSafe)
 	simplifiedGeom := simplifyWithTolerance(geomExpr, param.MaxAllowableOffset)
 	geomOutExpr := transformToOutCrs(simplifiedGeom, sourceSRID, param.Crs)
+	if param.CrsLatLon {
+		geomOutExpr = fmt.Sprintf("ST_FlipCoordinates( (%v)::geometry )", geomOutExpr)
+	}
 	sql := fmt.Sprintf(sqlFmtGeomCol, geomOutExpr, sqlPrecisionArg(param.Precision))
 	return sql
 }
@@ -418,6 +773,19 @@ func sqlGeomFunction(fn *api.Function, args map[string]string, propCols []string
 	return sql, argVals
 }
 
+const sqlFmtGeomFunctionMatched = "SELECT 1 FROM \"%s\".\"%s\"( %v ) %v"
+
+// sqlGeomFunctionMatched creates a query returning a row for each feature produced by a function
+// and matched by the query filters. Limit and offset are not applied
+func sqlGeomFunctionMatched(fn *api.Function, args map[string]string, param *QueryParam) (string, []interface{}) {
+	sqlArgs, argVals := sqlFunctionArgs(fn, args)
+	bboxFilter := sqlBBoxFilter(fn.GeometryColumn, SRID_4326, param.Bbox, param.BboxCrs)
+	cqlFilter := sqlCqlFilter(param.FilterSql)
+	sqlWhere := sqlWhere(bboxFilter, cqlFilter, "")
+	sql := fmt.Sprintf(sqlFmtGeomFunctionMatched, fn.Schema, fn.Name, sqlArgs, sqlWhere)
+	return sql, argVals
+}
+
 const sqlFmtFunction = "SELECT %v FROM \"%s\".\"%s\"( %v ) %v %v %s;"
 
 func sqlFunction(fn *api.Function, args map[string]string, propCols []string, param *QueryParam) (string, []interface{}) {
@@ -0,0 +1,174 @@
+package data
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Statement timeouts and cancellation: request queries are bounded in the database
+// by statement_timeout, and are cancelled in the database when the request is cancelled,
+// so that they do not keep running after the request has ended
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+// sqlSetStatementTimeout sets the statement timeout (in milliseconds) for the rest of the transaction
+const sqlSetStatementTimeout = "SELECT set_config('statement_timeout', $1, true)"
+
+// maximum time for sending a cancel request
+const cancelTimeout = 5 * time.Second
+
+// setTimeoutParams sets the idle_in_transaction_session_timeout of the connections of a pool,
+// unless it is set in the connection string
+func setTimeoutParams(config *pgx.ConnConfig) {
+	setRuntimeParam(config, "idle_in_transaction_session_timeout", conf.Current().Database.IdleInTransactionTimeoutSec)
+}
+
+// setRequestTimeoutParams sets the default statement_timeout of the connections of a pool of request queries,
+// unless it is set in the connection string.
+// It is not set for the pool of the catalog and listener queries, which are not limited
+func setRequestTimeoutParams(config *pgx.ConnConfig) {
+	setRuntimeParam(config, "statement_timeout", int(defaultStatementTimeout()/time.Second))
+}
+
+// defaultStatementTimeout is the statement timeout of the request queries,
+// for tables without their own timeout
+func defaultStatementTimeout() time.Duration {
+	timeoutSec := conf.Current().Database.StatementTimeoutSec
+	if timeoutSec <= 0 {
+		timeoutSec = conf.Current().Server.WriteTimeoutSec
+	}
+	return time.Duration(timeoutSec) * time.Second
+}
+
+func setRuntimeParam(config *pgx.ConnConfig, name string, timeoutSec int) {
+	if _, ok := config.RuntimeParams[name]; ok || timeoutSec <= 0 {
+		return
+	}
+	if config.RuntimeParams == nil {
+		config.RuntimeParams = make(map[string]string)
+	}
+	config.RuntimeParams[name] = strconv.Itoa(timeoutSec * 1000)
+}
+
+type statementTimeoutKey struct{}
+
+// withTableTimeout returns a context for the queries of a table,
+// holding the statement timeout set for the table, if any
+func withTableTimeout(ctx context.Context, tbl *api.Table) context.Context {
+	timeout := tableStatementTimeout(tbl, conf.Current().Database.StatementTimeouts)
+	if timeout <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, statementTimeoutKey{}, timeout)
+}
+
+// WithStatementTimeout returns a context for request queries running longer than the default statement timeout,
+// such as the queries of streamed responses. The statement timeout set for a table takes precedence
+func WithStatementTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, timeout)
+}
+
+// statementTimeoutFromContext returns the statement timeout of the table of a query, or zero for the default
+// timeout of the connections
+func statementTimeoutFromContext(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	return timeout
+}
+
+// tableStatementTimeout is the statement timeout set for a table, or zero if there is none
+func tableStatementTimeout(tbl *api.Table, confTimeouts []conf.TableStatementTimeout) time.Duration {
+	idLow := strings.ToLower(tbl.ID)
+	for _, st := range confTimeouts {
+		if strings.ToLower(st.Table) == idLow {
+			return time.Duration(st.TimeoutSec) * time.Second
+		}
+	}
+	return 0
+}
+
+// setStatementTimeout sets the statement timeout of a query transaction
+func setStatementTimeout(ctx context.Context, tx pgx.Tx, timeout time.Duration) error {
+	ms := strconv.FormatInt(int64(timeout/time.Millisecond), 10)
+	_, err := tx.Exec(ctx, sqlSetStatementTimeout, ms)
+	return err
+}
+
+// requestConn is a pool connection running the queries of a request.
+// The running statement is cancelled in the database if the request is cancelled
+type requestConn struct {
+	*pgxpool.Conn
+	mutex     sync.Mutex
+	isWatched bool
+	done      chan struct{}
+}
+
+func acquireRequestConn(ctx context.Context, pool *pgxpool.Pool) (*requestConn, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rc := &requestConn{Conn: conn}
+	if ctx.Done() != nil {
+		rc.isWatched = true
+		rc.done = make(chan struct{})
+		go rc.watch(ctx)
+	}
+	return rc, nil
+}
+
+// watch cancels the running statement when the context is cancelled.
+// Cancelling the context only closes the client side of the connection,
+// so the cancellation is sent to the database with a cancel request,
+// the protocol equivalent of pg_cancel_backend, which does not need a pool connection
+func (rc *requestConn) watch(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-rc.done:
+		return
+	}
+	// the connection is not released while the cancel request is sent,
+	// so that it can only cancel the statements of this request
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if !rc.isWatched {
+		return
+	}
+	pgConn := rc.Conn.Conn().PgConn()
+	log.Debugf("Cancelling query of backend %v: %v", pgConn.PID(), ctx.Err())
+	cancelCtx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	if err := pgConn.CancelRequest(cancelCtx); err != nil {
+		log.Warnf("Error cancelling query of backend %v: %v", pgConn.PID(), err)
+	}
+}
+
+// release stops watching the request context, and returns the connection to the pool
+func (rc *requestConn) release() {
+	rc.mutex.Lock()
+	if rc.isWatched {
+		rc.isWatched = false
+		close(rc.done)
+	}
+	rc.mutex.Unlock()
+	rc.Conn.Release()
+}
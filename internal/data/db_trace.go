@@ -0,0 +1,82 @@
+package data
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// names of the spans of the data layer
+const (
+	spanScanFeatures = "scan features"
+	spanQuery        = "db.query"
+)
+
+/*
+queryTracer records a span for each query run by pgx.
+pgx reports the SQL and duration of completed queries to its logger,
+with the context of the query, so the tracer wraps the logger.
+Entries are passed on to the logger at the level it was set to
+*/
+type queryTracer struct {
+	logger   pgx.Logger
+	logLevel pgx.LogLevel
+}
+
+// traceQueries wraps the logger of a connection configuration with a query tracer.
+// Queries are reported at the Info level, so the connection log level is raised to it
+func traceQueries(config *pgx.ConnConfig) {
+	config.Logger = &queryTracer{logger: config.Logger, logLevel: config.LogLevel}
+	if config.LogLevel < pgx.LogLevelInfo {
+		config.LogLevel = pgx.LogLevelInfo
+	}
+}
+
+func (t *queryTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if sql, ok := data["sql"].(string); ok {
+		end := time.Now()
+		start := end
+		if duration, ok := data["time"].(time.Duration); ok {
+			start = end.Add(-duration)
+		}
+		_, span := tracing.Start(ctx, spanQuery,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithTimestamp(start),
+			trace.WithAttributes(
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.operation", msg),
+				attribute.String("db.statement", sql)))
+		if rows, ok := data["rowCount"].(int); ok {
+			span.SetAttributes(attribute.Int("db.rows", rows))
+		}
+		if level <= pgx.LogLevelError {
+			if err, ok := data["err"].(error); ok {
+				tracing.SetError(span, err)
+			} else {
+				tracing.SetError(span, errors.New(msg+" failed"))
+			}
+		}
+		span.End(trace.WithTimestamp(end))
+	}
+	if t.logger != nil && level <= t.logLevel {
+		t.logger.Log(ctx, level, msg, data)
+	}
+}
@@ -0,0 +1,143 @@
+package data
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rowsFeatureIterator reads features one row at a time from a query result.
+// The database connection is held until the iterator is closed.
+type rowsFeatureIterator struct {
+	ctx        context.Context
+	rows       pgx.Rows
+	tableName  string
+	idColIndex int
+	propCols   []string
+	cache      Cacher
+	feature    *api.GeojsonFeatureData
+	err        error
+	count      int
+	start      time.Time
+	span       trace.Span
+}
+
+func readFeaturesIteratorWithArgs(ctx context.Context, db dbQuerier, sql string, args []interface{}, tableName string, idColIndex int, propCols []string, cache Cacher) (FeatureIterator, error) {
+	start := time.Now()
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		log.Warnf("Error running 'Features' (query: '%v'): %v", sql, err)
+		return nil, err
+	}
+	iter := &rowsFeatureIterator{
+		ctx:        ctx,
+		rows:       rows,
+		tableName:  tableName,
+		idColIndex: idColIndex,
+		propCols:   propCols,
+		cache:      cache,
+		start:      start,
+	}
+	// features are scanned as the response is written
+	_, iter.span = tracing.Start(ctx, spanScanFeatures)
+	return iter, nil
+}
+
+func (iter *rowsFeatureIterator) Next() bool {
+	if iter.err != nil {
+		return false
+	}
+	// the context is checked for every row,
+	// so that a cancelled request stops reading as soon as possible
+	if err := iter.ctx.Err(); err != nil {
+		iter.err = err
+		return false
+	}
+	if !iter.rows.Next() {
+		// context check done after rows loop as well,
+		// because a long-running function might not produce any rows before timeout
+		if err := iter.ctx.Err(); err != nil {
+			iter.err = err
+			return false
+		}
+		if err := iter.rows.Err(); err != nil {
+			log.Warnf("Error scanning rows for Features: %v", err)
+			iter.err = err
+		}
+		return false
+	}
+	feature, err := scanFeature(iter.rows, iter.tableName, iter.idColIndex, iter.propCols, iter.cache)
+	if err != nil {
+		iter.err = err
+		return false
+	}
+	iter.feature = feature
+	iter.count++
+	return true
+}
+
+func (iter *rowsFeatureIterator) Feature() *api.GeojsonFeatureData {
+	return iter.feature
+}
+
+func (iter *rowsFeatureIterator) Err() error {
+	return iter.err
+}
+
+func (iter *rowsFeatureIterator) Close() {
+	iter.rows.Close()
+	iter.span.SetAttributes(attribute.Int("features", iter.count))
+	tracing.SetError(iter.span, iter.err)
+	iter.span.End()
+	recordQuery(queryFeatures, iter.start)
+	log.Debugf(fmtQueryStats, iter.count, time.Since(iter.start))
+}
+
+// sliceFeatureIterator iterates over features already held in memory
+type sliceFeatureIterator struct {
+	features []*api.GeojsonFeatureData
+	index    int
+}
+
+func newSliceFeatureIterator(features []*api.GeojsonFeatureData) *sliceFeatureIterator {
+	return &sliceFeatureIterator{features: features, index: -1}
+}
+
+func (iter *sliceFeatureIterator) Next() bool {
+	if iter.index+1 >= len(iter.features) {
+		return false
+	}
+	iter.index++
+	return true
+}
+
+func (iter *sliceFeatureIterator) Feature() *api.GeojsonFeatureData {
+	return iter.features[iter.index]
+}
+
+func (iter *sliceFeatureIterator) Err() error {
+	return nil
+}
+
+func (iter *sliceFeatureIterator) Close() {
+	// nothing to release
+}
@@ -97,6 +97,26 @@ func doFilter(features []*featureMock, filter []*PropertyFilter) []*featureMock
 	return result
 }
 
+// doCursor keeps the features after the cursor.
+// Only keyset paging on the feature id is supported
+func doCursor(features []*featureMock, keyCols []string, cursor []*string) []*featureMock {
+	if len(keyCols) != 1 || len(cursor) != 1 || cursor[0] == nil {
+		return features
+	}
+	after, err := strconv.Atoi(*cursor[0])
+	if err != nil {
+		return features
+	}
+	var result []*featureMock
+	for _, feat := range features {
+		id, _ := strconv.Atoi(feat.ID)
+		if id > after {
+			result = append(result, feat)
+		}
+	}
+	return result
+}
+
 func doLimit(features []*featureMock, limit int, offset int) []*featureMock {
 	start := 0
 	end := len(features)
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
 	"github.com/jackc/pgconn"
@@ -32,17 +34,40 @@ import (
 // TODO: make the schema name configurable
 const tempDBSchema = "pgfeatureserv"
 
+// delay before listening again after a connection error
+const listenRetryDelay = 5 * time.Second
+
+// notification channels
+const (
+	channelTableUpdate = "table_update"
+	channelDDLUpdate   = "ddl_update"
+)
+
+const sqlFmtDropTrigger = `
+	DROP TRIGGER IF EXISTS "%[1]s_notify_event" ON %[2]s;
+	`
+
+const sqlFmtCreateTrigger = `
+	CREATE TRIGGER "%[1]s_notify_event"
+	AFTER INSERT OR UPDATE OR DELETE ON %[2]s
+	FOR EACH ROW EXECUTE PROCEDURE %[3]s.notify_event();
+	`
+
 // A listenerDB is associated to a catalogDB, and manages the operations required for listening
 // the events occuring on the database. This includes creating the trigger function in the base,
 // applying the trigger function to the tables included in pg_featureserv, and listening to
 // events on those tables
 type listenerDB struct {
+	source        string             // name of the data source
 	dbconn        *pgxpool.Pool      // connection to database
 	tableIncludes map[string]string  // list of included tables
 	tableExcludes map[string]string  // list of excluded tables
 	cache         Cacher             // cache of the catalog
 	stopListen    context.CancelFunc // channel used to stop the listen goroutine
 	notifications map[string]eventNotification
+	isListening   int32 // set while the listen goroutine runs (accessed atomically)
+	// called after a change of the definition of a table, view or function
+	onSchemaChange func(ddlNotification)
 }
 
 // An eventNotification is a notification sent by the database after a INSERT, UPDATE or DELETE
@@ -59,15 +84,31 @@ type eventNotification struct {
 	RawData  string
 }
 
+// A ddlNotification is a notification sent by the database after a change of the definition
+// of a table, view or function. It is populated using the return value of the event trigger
+// function named `sqlNotifyDDLFunction` defined in db_sql.go
+type ddlNotification struct {
+	Action     string `json:"action"`      // command triggering the event (CREATE TABLE, ALTER TABLE, DROP VIEW...)
+	ObjectType string `json:"object_type"` // type of the changed object (table, view, function...)
+	Schema     string `json:"schema"`      // schema of the changed object
+	Identity   string `json:"identity"`    // identity of the changed object
+}
+
+// toString for ddlNotification
+func (e ddlNotification) String() string {
+	return fmt.Sprintf("ddlNotification[action: '%v', %v: %v]", e.Action, e.ObjectType, e.Identity)
+}
+
 // toString for eventNotification
 func (e eventNotification) String() string {
 	return fmt.Sprintf("eventNotification[Id: %v, table: '%v.%v', action: '%v', xmin: %v/%v, data: %v]", e.Id, e.Schema, e.Table, e.Action, e.Old_xmin, e.New_xmin, e.RawData)
 }
 
 // creates new db listener
-func newListenerDB(conn *pgxpool.Pool, cache Cacher) *listenerDB {
+func newListenerDB(source string, conn *pgxpool.Pool, cache Cacher) *listenerDB {
 
 	listener := &listenerDB{
+		source: source,
 		dbconn: conn,
 		cache:  cache,
 	}
@@ -79,6 +120,7 @@ func newListenerDB(conn *pgxpool.Pool, cache Cacher) *listenerDB {
 //   - add temporary DB schema
 //   - add trigger function temp schema
 //   - add trigger functions to included tables
+//   - add event triggers notifying DDL changes
 //   - start listening to database operations
 func (listener *listenerDB) Initialize(tableIncludes map[string]string, tableExcludes map[string]string) {
 	listener.tableIncludes = tableIncludes
@@ -89,15 +131,27 @@ func (listener *listenerDB) Initialize(tableIncludes map[string]string, tableExc
 	ctxGoroutine, stopListen := context.WithCancel(ctx)
 	listener.stopListen = stopListen
 
-	listener.addTemporaryDBSchema()
-	listener.addTriggerFunctionToDB()
-	listener.addTriggerToTables()
+	// the listener must be set up at startup
+	if err := listener.addTemporaryDBSchema(); err != nil {
+		log.Fatal(err)
+	}
+	if err := listener.addTriggerFunctionToDB(); err != nil {
+		log.Fatal(err)
+	}
+	if err := listener.addTriggerToTables(); err != nil {
+		log.Fatal(err)
+	}
+	if err := listener.addDDLTriggersToDB(); err != nil {
+		log.Fatal(err)
+	}
 	go listener.listen(ctxGoroutine)
 }
 
 // Listen for INSERT or UPDATE or DELETE using triggers
 // pgxPool can't listen, code snippet from https://github.com/jackc/pgx/issues/1121
 func (listener *listenerDB) listen(ctx context.Context) {
+	atomic.StoreInt32(&listener.isListening, 1)
+	defer atomic.StoreInt32(&listener.isListening, 0)
 	for {
 		listener.listenOneNotification(ctx)
 		select {
@@ -111,39 +165,44 @@ func (listener *listenerDB) listen(ctx context.Context) {
 func (listener *listenerDB) listenOneNotification(ctx context.Context) {
 	listenConn, err := listener.dbconn.Acquire(ctx)
 	if err != nil {
-		if pgconn.Timeout(err) {
-			return
-		}
-		log.Fatal(err)
+		listener.waitAfterError(ctx, err)
+		return
 	}
 	defer listenConn.Release()
 
-	_, err = listenConn.Exec(ctx, "LISTEN table_update")
+	_, err = listenConn.Exec(ctx, "LISTEN "+channelTableUpdate+"; LISTEN "+channelDDLUpdate)
 	if err != nil {
-		if pgconn.Timeout(err) {
-			return
-		}
-		log.Fatal(err)
+		listener.waitAfterError(ctx, err)
+		return
 	}
+	atomic.StoreInt32(&listener.isListening, 1)
 
 	notification, err := listenConn.Conn().WaitForNotification(ctx)
 	if err != nil {
-		if pgconn.Timeout(err) {
-			return
-		}
-		log.Fatal(err)
+		listener.waitAfterError(ctx, err)
+		return
+	}
+
+	if notification.Channel == channelDDLUpdate {
+		listener.handleDDLNotification(notification.Payload)
+		return
 	}
 
 	var notificationData eventNotification
 
 	errUnMarsh := json.Unmarshal([]byte(notification.Payload), &notificationData)
 	if errUnMarsh != nil {
-		log.Fatal(errUnMarsh)
+		log.Warnf("Listener received invalid notification '%v': %v", notification.Payload, errUnMarsh)
+		return
 	}
 
 	// split raw data:
 	re := regexp.MustCompile(`^([0-9]+):([0-9]+):(.*)$`)
 	rawArray := re.FindStringSubmatch(notificationData.RawData)
+	if rawArray == nil {
+		log.Warnf("Listener received invalid notification data '%v'", notificationData.RawData)
+		return
+	}
 	pgCountStr := rawArray[1]
 	pgCurrStr := rawArray[2]
 	data := rawArray[3]
@@ -172,12 +231,14 @@ func (listener *listenerDB) handleCompleteNotification(md5 string) {
 	delete(listener.notifications, md5)
 
 	log.Debugf("Listener received complete notification (md5:%v): %v, cache: %v", notificationData.Md5, notificationData, listener.cache)
+	listenerNotifications.WithLabelValues(notificationData.Action).Inc()
 
 	var data map[string]interface{} // data contained in the row
 
 	errUnMarsh := json.Unmarshal([]byte(notificationData.RawData), &data)
 	if errUnMarsh != nil {
-		log.Fatal(errUnMarsh)
+		log.Warnf("Listener received invalid notification data (md5:%v): %v", md5, errUnMarsh)
+		return
 	}
 
 	if notificationData.Action == "DELETE" || notificationData.Action == "UPDATE" {
@@ -188,7 +249,7 @@ func (listener *listenerDB) handleCompleteNotification(md5 string) {
 		}
 	}
 	if notificationData.Action == "INSERT" || notificationData.Action == "UPDATE" {
-		collection := notificationData.Id
+		collection := api.SourceID(listener.source, notificationData.Id)
 		// ==== retrieve tabe data
 		table, errCat := CatDBInstance().TableByName(collection)
 		if errCat != nil {
@@ -232,108 +293,179 @@ func (listener *listenerDB) handleCompleteNotification(md5 string) {
 	}
 }
 
+func (listener *listenerDB) handleDDLNotification(payload string) {
+	var notificationData ddlNotification
+	errUnMarsh := json.Unmarshal([]byte(payload), &notificationData)
+	if errUnMarsh != nil {
+		log.Warnf("Listener received invalid DDL notification '%v': %v", payload, errUnMarsh)
+		return
+	}
+	log.Debugf("Listener received DDL notification: %v", notificationData)
+	listenerNotifications.WithLabelValues(notificationData.Action).Inc()
+
+	if listener.onSchemaChange != nil {
+		listener.onSchemaChange(notificationData)
+	}
+}
+
+// waitAfterError logs an error of the listen connection, and waits before listening again,
+// so that the server keeps running while the database is unavailable
+func (listener *listenerDB) waitAfterError(ctx context.Context, err error) {
+	if ctx.Err() != nil || pgconn.Timeout(err) {
+		return
+	}
+	atomic.StoreInt32(&listener.isListening, 0)
+	log.Warnf("Listener error, listening again in %v: %v", listenRetryDelay, err)
+	select {
+	case <-ctx.Done():
+	case <-time.After(listenRetryDelay):
+	}
+}
+
+// IsListening tests whether the listen goroutine is running
+func (listener *listenerDB) IsListening() bool {
+	return atomic.LoadInt32(&listener.isListening) == 1
+}
+
 func (listener *listenerDB) Close() {
 	if listener.stopListen != nil {
 		listener.stopListen()
 	}
-	listener.dropTriggers()
-	listener.dropTemporaryDBSchema()
+	if err := listener.dropTriggers(); err != nil {
+		log.Warnf("Error dropping triggers: %v", err)
+	}
+	if err := listener.dropTemporaryDBSchema(); err != nil {
+		log.Warnf("Error dropping schema %v: %v", tempDBSchema, err)
+	}
 }
 
-func (listener *listenerDB) addTemporaryDBSchema() {
+func (listener *listenerDB) addTemporaryDBSchema() error {
 	sqlStatement := "CREATE SCHEMA IF NOT EXISTS %s"
 	_, errExec := listener.dbconn.Exec(context.Background(), fmt.Sprintf(sqlStatement, tempDBSchema))
-	if errExec != nil {
-		log.Fatal(errExec)
-	}
+	return errExec
 }
 
-func (listener *listenerDB) dropTemporaryDBSchema() {
+func (listener *listenerDB) dropTemporaryDBSchema() error {
 	sqlStatement := "DROP SCHEMA IF EXISTS %s CASCADE"
 	_, errExec := listener.dbconn.Exec(context.Background(), fmt.Sprintf(sqlStatement, tempDBSchema))
-	if errExec != nil {
-		log.Fatal(errExec)
-	}
+	return errExec
 }
 
-func (listener *listenerDB) addTriggerFunctionToDB() {
+func (listener *listenerDB) addTriggerFunctionToDB() error {
 	_, errExec := listener.dbconn.Exec(context.Background(), fmt.Sprintf(sqlNotifyFunction, tempDBSchema))
+	return errExec
+}
+
+// addDDLTriggersToDB adds the event triggers notifying changes of tables, views and functions.
+// Event triggers can only be created by a superuser, so the catalog is not reloaded otherwise
+func (listener *listenerDB) addDDLTriggersToDB() error {
+	_, errExec := listener.dbconn.Exec(context.Background(), fmt.Sprintf(sqlNotifyDDLFunction, tempDBSchema))
 	if errExec != nil {
-		log.Fatal(errExec)
+		return errExec
 	}
+	_, errExec = listener.dbconn.Exec(context.Background(), fmt.Sprintf(sqlFmtDDLEventTriggers, tempDBSchema))
+	if errExec != nil {
+		log.Warnf("Cannot create event triggers, the catalog will not be reloaded after schema changes: %v", errExec)
+	}
+	return nil
 }
 
-func (listener *listenerDB) addTriggerToTables() {
+func (listener *listenerDB) addTriggerToTables() error {
 	log.Debugf("Add trigger to tables:\n%v", sqlTables)
-	rows, err := listener.dbconn.Query(context.Background(), sqlTables)
+	tables, err := listener.readIncludedTables()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	for rows.Next() {
-		tbl := scanTable(rows)
-		if isIncluded(tbl, listener.tableIncludes, listener.tableExcludes) {
-			listener.addTriggerToTable(tbl)
+	for _, tbl := range tables {
+		if err := listener.addTriggerToTable(tbl); err != nil {
+			return err
 		}
 	}
-	// Check for errors from iterating over rows.
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
-	}
-	rows.Close()
+	return nil
 }
 
-func (listener *listenerDB) addTriggerToTable(tbl *api.Table) {
-	dropTriggerBytes := []byte(`
-	DROP TRIGGER IF EXISTS "%[1]s_notify_event" ON %[2]s;
-	`)
-	triggerBytes := []byte(`
-	CREATE TRIGGER "%[1]s_notify_event"
-	AFTER INSERT OR UPDATE OR DELETE ON %[2]s
-	FOR EACH ROW EXECUTE PROCEDURE %[3]s.notify_event();
-	`)
-
+func (listener *listenerDB) addTriggerToTable(tbl *api.Table) error {
 	triggerName := tbl.Schema + "_" + tbl.Table
-	dropTriggerStatement := fmt.Sprintf(string(dropTriggerBytes), triggerName, tbl.ID)
-	triggerStatement := fmt.Sprintf(string(triggerBytes), triggerName, tbl.ID, tempDBSchema)
+	dropTriggerStatement := fmt.Sprintf(sqlFmtDropTrigger, triggerName, tbl.DbID())
+	triggerStatement := fmt.Sprintf(sqlFmtCreateTrigger, triggerName, tbl.DbID(), tempDBSchema)
 	_, errDrop := listener.dbconn.Exec(context.Background(), dropTriggerStatement)
 	if errDrop != nil {
-		log.Fatal(errDrop)
+		return errDrop
 	}
 	_, err := listener.dbconn.Exec(context.Background(), triggerStatement)
+	return err
+}
+
+func (listener *listenerDB) dropTriggers() error {
+	log.Debugf("Drop triggers:\n%v", sqlTables)
+	tables, err := listener.readIncludedTables()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	for _, tbl := range tables {
+		if err := listener.dropTrigger(tbl); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (listener *listenerDB) dropTriggers() {
-	log.Debugf("Drop triggers:\n%v", sqlTables)
+func (listener *listenerDB) dropTrigger(tbl *api.Table) error {
+	triggerName := tbl.Schema + "_" + tbl.Table
+	dropTriggerStatement := fmt.Sprintf(sqlFmtDropTrigger, triggerName, tbl.DbID())
+
+	_, errDrop := listener.dbconn.Exec(context.Background(), dropTriggerStatement)
+	return errDrop
+}
+
+// readIncludedTables reads the tables of the source which are included in the catalog
+func (listener *listenerDB) readIncludedTables() ([]*api.Table, error) {
 	rows, err := listener.dbconn.Query(context.Background(), sqlTables)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	defer rows.Close()
+	var tables []*api.Table
 	for rows.Next() {
-		tbl := scanTable(rows)
+		tbl, err := scanTable(rows)
+		if err != nil {
+			return nil, err
+		}
 		if isIncluded(tbl, listener.tableIncludes, listener.tableExcludes) {
-			listener.dropTrigger(tbl)
+			tables = append(tables, tbl)
 		}
 	}
 	// Check for errors from iterating over rows.
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
-	}
-	rows.Close()
+	return tables, rows.Err()
 }
 
-func (listener *listenerDB) dropTrigger(tbl *api.Table) {
-	dropTriggerBytes := []byte(`
-	DROP TRIGGER IF EXISTS "%[1]s_notify_event" ON %[2]s;
-	`)
-
+// attachTrigger adds the trigger function to a table added to the catalog while running.
+// Views have no trigger, and a renamed table keeps its trigger
+func (listener *listenerDB) attachTrigger(tbl *api.Table) error {
+	var isTable, hasTrigger bool
+	sql := fmt.Sprintf(sqlFmtHasNotifyTrigger, tempDBSchema)
+	err := listener.dbconn.QueryRow(context.Background(), sql, tbl.DbID()).Scan(&isTable, &hasTrigger)
+	if err != nil {
+		return err
+	}
+	if !isTable || hasTrigger {
+		return nil
+	}
 	triggerName := tbl.Schema + "_" + tbl.Table
-	dropTriggerStatement := fmt.Sprintf(string(dropTriggerBytes), triggerName, tbl.ID)
+	triggerStatement := fmt.Sprintf(sqlFmtCreateTrigger, triggerName, tbl.DbID(), tempDBSchema)
+	_, err = listener.dbconn.Exec(context.Background(), triggerStatement)
+	return err
+}
 
-	_, errDrop := listener.dbconn.Exec(context.Background(), dropTriggerStatement)
-	if errDrop != nil {
-		log.Fatal(errDrop)
+// detachTrigger removes the trigger function from a table removed from the catalog while running.
+// The triggers of dropped tables are dropped with them
+func (listener *listenerDB) detachTrigger(tbl *api.Table) error {
+	var exists bool
+	err := listener.dbconn.QueryRow(context.Background(), sqlRelationExists, tbl.DbID()).Scan(&exists)
+	if err != nil || !exists {
+		return err
 	}
+	triggerName := tbl.Schema + "_" + tbl.Table
+	_, err = listener.dbconn.Exec(context.Background(), fmt.Sprintf(sqlFmtDropTrigger, triggerName, tbl.DbID()))
+	return err
 }
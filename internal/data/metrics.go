@@ -0,0 +1,202 @@
+package data
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// MetricsNamespace is the prefix of the metric names
+const MetricsNamespace = "pg_featureserv"
+
+// names of the queries timed by queryDuration
+const (
+	queryFeatures = "features"
+	queryData     = "data"
+	queryTile     = "tile"
+)
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: MetricsNamespace,
+	Name:      "db_query_duration_seconds",
+	Help:      "Duration of database queries in seconds, including reading the results",
+}, []string{"query"})
+
+var cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: MetricsNamespace,
+	Name:      "cache_hits_total",
+	Help:      "Number of etag cache lookups finding the etag, by cache type",
+}, []string{"cache"})
+
+var cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: MetricsNamespace,
+	Name:      "cache_misses_total",
+	Help:      "Number of etag cache lookups not finding the etag, by cache type",
+}, []string{"cache"})
+
+var readQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: MetricsNamespace,
+	Name:      "db_read_queries_total",
+	Help:      "Number of read request queries, by server (primary or replica)",
+}, []string{"server"})
+
+var listenerNotifications = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: MetricsNamespace,
+	Name:      "listener_notifications_total",
+	Help:      "Number of table change notifications received, by action",
+}, []string{"action"})
+
+func recordQuery(query string, start time.Time) {
+	queryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+func recordCacheLookup(cache Cacher, isFound bool) {
+	if isFound {
+		cacheHits.WithLabelValues(cache.Type()).Inc()
+	} else {
+		cacheMisses.WithLabelValues(cache.Type()).Inc()
+	}
+}
+
+//=================================================
+
+// a sample is a value of a function metric, with its label value
+type sample struct {
+	labelValue string
+	value      float64
+}
+
+// a funcMetric is a metric with one label, read by a function when the metrics are collected
+type funcMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	samples   func() []sample
+}
+
+func newFuncMetric(name string, help string, label string, valueType prometheus.ValueType, samples func() []sample) funcMetric {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(MetricsNamespace, "", name), help, []string{label}, nil)
+	return funcMetric{desc: desc, valueType: valueType, samples: samples}
+}
+
+// funcCollector collects function metrics
+type funcCollector []funcMetric
+
+func (c funcCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c {
+		ch <- m.desc
+	}
+}
+
+func (c funcCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c {
+		for _, s := range m.samples() {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, s.value, s.labelValue)
+		}
+	}
+}
+
+// the collectors of the catalog, by name
+var (
+	collectorsMutex sync.Mutex
+	collectors      = make(map[string]prometheus.Collector)
+)
+
+// registerCollector registers a collector of the catalog,
+// replacing the collector registered with the same name by a previous catalog
+func registerCollector(name string, c prometheus.Collector) {
+	collectorsMutex.Lock()
+	defer collectorsMutex.Unlock()
+	if prev, ok := collectors[name]; ok {
+		prometheus.Unregister(prev)
+	}
+	prometheus.MustRegister(c)
+	collectors[name] = c
+}
+
+// registerCacheMetrics reports the number of entries of the etag cache
+func registerCacheMetrics(cache Cacher) {
+	registerCollector("cache", funcCollector{
+		newFuncMetric("cache_entries", "Number of entries in the etag cache", "cache", prometheus.GaugeValue,
+			func() []sample {
+				return []sample{{labelValue: cache.Type(), value: float64(cache.Size())}}
+			}),
+	})
+}
+
+// registerPoolMetrics reports the statistics of the request connection pools, by source.
+// The source of the default database is blank
+func registerPoolMetrics(sources []*dataSource) {
+	metric := func(name string, help string, valueType prometheus.ValueType, value func(stat *pgxpool.Stat) float64) funcMetric {
+		return newFuncMetric(name, help, "source", valueType, func() []sample {
+			var samples []sample
+			for _, src := range sources {
+				samples = append(samples, sample{labelValue: src.name, value: value(src.requestPool.Stat())})
+			}
+			return samples
+		})
+	}
+	registerCollector("pool", funcCollector{
+		metric("db_pool_acquired_conns", "Number of connections in use", prometheus.GaugeValue,
+			func(stat *pgxpool.Stat) float64 { return float64(stat.AcquiredConns()) }),
+		metric("db_pool_idle_conns", "Number of idle connections", prometheus.GaugeValue,
+			func(stat *pgxpool.Stat) float64 { return float64(stat.IdleConns()) }),
+		metric("db_pool_total_conns", "Number of open connections", prometheus.GaugeValue,
+			func(stat *pgxpool.Stat) float64 { return float64(stat.TotalConns()) }),
+		metric("db_pool_max_conns", "Maximum number of connections", prometheus.GaugeValue,
+			func(stat *pgxpool.Stat) float64 { return float64(stat.MaxConns()) }),
+		metric("db_pool_acquires_total", "Number of connections acquired from the pool", prometheus.CounterValue,
+			func(stat *pgxpool.Stat) float64 { return float64(stat.AcquireCount()) }),
+		metric("db_pool_empty_acquires_total", "Number of acquires that waited for a connection", prometheus.CounterValue,
+			func(stat *pgxpool.Stat) float64 { return float64(stat.EmptyAcquireCount()) }),
+		metric("db_pool_acquire_duration_seconds_total", "Total time spent acquiring connections, in seconds", prometheus.CounterValue,
+			func(stat *pgxpool.Stat) float64 { return stat.AcquireDuration().Seconds() }),
+	})
+}
+
+// registerReplicaMetrics reports the state of the read replicas
+func registerReplicaMetrics(sources []*dataSource) {
+	metric := func(name string, help string, value func(rep *replica) float64) funcMetric {
+		return newFuncMetric(name, help, "replica", prometheus.GaugeValue, func() []sample {
+			var samples []sample
+			for _, src := range sources {
+				for _, rep := range src.replicas {
+					samples = append(samples, sample{labelValue: rep.name, value: value(rep)})
+				}
+			}
+			return samples
+		})
+	}
+	registerCollector("replica", funcCollector{
+		metric("db_replica_available", "Whether a read replica is available (1) or not (0)",
+			func(rep *replica) float64 {
+				rep.mutex.RLock()
+				defer rep.mutex.RUnlock()
+				if rep.isAvailable {
+					return 1
+				}
+				return 0
+			}),
+		metric("db_replica_lag_seconds", "Replication lag of a read replica in seconds, at the last check",
+			func(rep *replica) float64 {
+				rep.mutex.RLock()
+				defer rep.mutex.RUnlock()
+				return rep.lag
+			}),
+	})
+}
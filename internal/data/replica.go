@@ -0,0 +1,245 @@
+package data
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Read replicas: the queries of read requests run on a replica of the source database,
+// unless it lags too far behind the primary, or has not yet replayed the last write of the client.
+// Writes, the catalog queries and the listener use the primary
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+// interval between the checks of the replication state of the replicas
+const replicaCheckInterval = 5 * time.Second
+
+// sqlReplicaStatus reads whether a server is a replica, the WAL position it has replayed,
+// and its replication lag in seconds (zero if it has replayed all the WAL it received)
+const sqlReplicaStatus = `SELECT pg_is_in_recovery(),
+	COALESCE(pg_last_wal_replay_lsn()::text, ''),
+	CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END::float8`
+
+// sqlWriteLSN reads the WAL position of the primary after a write
+const sqlWriteLSN = "SELECT pg_current_wal_insert_lsn()::text"
+
+// names of the servers counted by readQueries
+const (
+	serverPrimary = "primary"
+	serverReplica = "replica"
+)
+
+// a replica is a read replica of a source database, with its last known replication state
+type replica struct {
+	name string
+	// dbconn is a pool of request queries
+	dbconn *pgxpool.Pool
+	mutex  sync.RWMutex
+	// isAvailable is set if the replica answered the last check as a server in recovery
+	isAvailable bool
+	// lag is the replication lag in seconds
+	lag float64
+	// replayLSN is the WAL position replayed by the replica
+	replayLSN uint64
+}
+
+// Consistency holds the read-your-writes token of a request.
+// The token of a read request is the position of the last write of the client,
+// which a replica must have replayed to serve the request.
+// Writes set the token to their own position
+type Consistency struct {
+	Token string
+}
+
+type consistencyKey struct{}
+
+// WithConsistency returns a context holding the consistency token of a request
+func WithConsistency(ctx context.Context, consistency *Consistency) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, consistency)
+}
+
+// ConsistencyFromContext returns the consistency token of a request, or nil if there is none
+func ConsistencyFromContext(ctx context.Context) *Consistency {
+	consistency, _ := ctx.Value(consistencyKey{}).(*Consistency)
+	return consistency
+}
+
+// connectReplicas connects to the replicas of a source, and starts checking their replication state
+func (src *dataSource) connectReplicas(dbconns []string, maxConns int) {
+	for i, dbconn := range dbconns {
+		name := src.healthName(fmt.Sprintf("replica%d", i+1))
+		src.replicas = append(src.replicas, &replica{
+			name:   name,
+			dbconn: dbConnect(name, requestDbConfig(dbconn, maxConns)),
+		})
+	}
+	if len(src.replicas) == 0 {
+		return
+	}
+	src.checkReplicas()
+	src.stopReplicaChecks = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(replicaCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				src.checkReplicas()
+			case <-src.stopReplicaChecks:
+				return
+			}
+		}
+	}()
+}
+
+func (src *dataSource) closeReplicas() {
+	if src.stopReplicaChecks != nil {
+		close(src.stopReplicaChecks)
+	}
+	for _, rep := range src.replicas {
+		rep.dbconn.Close()
+	}
+}
+
+func (src *dataSource) checkReplicas() {
+	for _, rep := range src.replicas {
+		rep.check()
+	}
+}
+
+// check reads the replication state of the replica.
+// The replica is not used if the check fails
+func (rep *replica) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaCheckInterval)
+	defer cancel()
+	var isInRecovery bool
+	var replayLSN string
+	var lag float64
+	err := rep.dbconn.QueryRow(ctx, sqlReplicaStatus).Scan(&isInRecovery, &replayLSN, &lag)
+	if err == nil && !isInRecovery {
+		err = fmt.Errorf("server is not in recovery")
+	}
+	var lsn uint64
+	if err == nil {
+		lsn, err = parseLSN(replayLSN)
+	}
+
+	rep.mutex.Lock()
+	defer rep.mutex.Unlock()
+	if err != nil {
+		if rep.isAvailable {
+			log.Warnf("Replica %v is not available: %v", rep.name, err)
+		}
+		rep.isAvailable = false
+		return
+	}
+	if !rep.isAvailable {
+		log.Infof("Replica %v is available (lag %.1fs)", rep.name, lag)
+	}
+	rep.isAvailable = true
+	rep.lag = lag
+	rep.replayLSN = lsn
+}
+
+// isUsable tests whether the replica is available, lags less than the maximum lag,
+// and has replayed the WAL up to a position
+func (rep *replica) isUsable(maxLag float64, minLSN uint64) bool {
+	rep.mutex.RLock()
+	defer rep.mutex.RUnlock()
+	return rep.isAvailable && rep.lag <= maxLag && rep.replayLSN >= minLSN
+}
+
+// reader returns the querier of the queries of read requests:
+// a usable replica if there is one, or else the primary
+func (src *dataSource) reader(ctx context.Context) dbQuerier {
+	if len(src.replicas) == 0 {
+		return src
+	}
+	maxLag := float64(conf.Current().Database.ReplicaMaxLagSec)
+	minLSN := src.consistencyLSN(ctx)
+	// replicas are used in turn
+	start := int(atomic.AddUint32(&src.replicaNext, 1))
+	for i := range src.replicas {
+		rep := src.replicas[(start+i)%len(src.replicas)]
+		if rep.isUsable(maxLag, minLSN) {
+			readQueries.WithLabelValues(serverReplica).Inc()
+			return poolQuerier{rep.dbconn}
+		}
+	}
+	readQueries.WithLabelValues(serverPrimary).Inc()
+	return src
+}
+
+// consistencyLSN is the WAL position of the last write of the client to the source,
+// or zero if the request has no token for the source
+func (src *dataSource) consistencyLSN(ctx context.Context) uint64 {
+	consistency := ConsistencyFromContext(ctx)
+	if consistency == nil || consistency.Token == "" {
+		return 0
+	}
+	source, token := api.SplitSourceID(consistency.Token)
+	if source != src.name {
+		return 0
+	}
+	lsn, err := parseLSN(token)
+	if err != nil {
+		log.Debugf("Invalid consistency token '%v': %v", consistency.Token, err)
+		return 0
+	}
+	return lsn
+}
+
+// recordWrite sets the consistency token of a write request to the WAL position of the primary,
+// so that the next reads of the client only use replicas which have replayed the write
+func (src *dataSource) recordWrite(ctx context.Context) {
+	consistency := ConsistencyFromContext(ctx)
+	if len(src.replicas) == 0 || consistency == nil {
+		return
+	}
+	var lsn string
+	err := src.requestPool.QueryRow(ctx, sqlWriteLSN).Scan(&lsn)
+	if err != nil {
+		log.Warnf("Error reading WAL position after write: %v", err)
+		return
+	}
+	consistency.Token = api.SourceID(src.name, lsn)
+}
+
+// parseLSN parses a WAL position in the Postgres text format (e.g. 16/B374D848)
+func parseLSN(lsn string) (uint64, error) {
+	parts := strings.Split(lsn, "/")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid WAL position '%v'", lsn)
+	}
+	hi, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WAL position '%v'", lsn)
+	}
+	lo, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WAL position '%v'", lsn)
+	}
+	return hi<<32 | lo, nil
+}
@@ -0,0 +1,180 @@
+package data
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/log/logrusadapter"
+	"github.com/jackc/pgx/v4/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+// maximum number of connections of the pool of the catalog and listener queries of a source
+const catalogPoolMaxConns = 4
+
+// A dataSource is a database published in the catalog,
+// with its own connection pools, tables, functions and listener.
+// The ids of the tables and functions of named sources are prefixed by the source name
+type dataSource struct {
+	name string
+	// dbconn is the pool of the catalog, listener and health queries
+	dbconn *pgxpool.Pool
+	// requestPool is the pool of the request queries, with the default statement timeout
+	requestPool      *pgxpool.Pool
+	tableIncludes    map[string]string
+	tableExcludes    map[string]string
+	functionIncludes []string
+	listener         *listenerDB
+	// read replicas, used in turn by read requests
+	replicas          []*replica
+	replicaNext       uint32
+	stopReplicaChecks chan struct{}
+}
+
+// newDataSources connects to the databases of the catalog:
+// the default database, if set, and the configured sources
+func newDataSources(cache Cacher) []*dataSource {
+	confDb := conf.Current().Database
+	var sources []*dataSource
+	// the default database is required if there is no other source
+	if confDb.DbConnection != "" || len(confDb.Sources) == 0 {
+		src := newDataSource("", confDb.DbConnection, confDb.DbPoolMaxConns, cache)
+		src.functionIncludes = confDb.FunctionIncludes
+		src.connectReplicas(confDb.ReplicaConnections, confDb.DbPoolMaxConns)
+		sources = append(sources, src)
+	}
+	names := make(map[string]bool)
+	for _, confSrc := range confDb.Sources {
+		if confSrc.Name == "" || strings.Contains(confSrc.Name, api.SourceSeparator) {
+			log.Fatalf("Invalid data source name '%v': it must be set, without '%v'", confSrc.Name, api.SourceSeparator)
+		}
+		if names[confSrc.Name] {
+			log.Fatalf("Duplicate data source name '%v'", confSrc.Name)
+		}
+		names[confSrc.Name] = true
+
+		maxConns := confSrc.DbPoolMaxConns
+		if maxConns <= 0 {
+			maxConns = confDb.DbPoolMaxConns
+		}
+		src := newDataSource(confSrc.Name, confSrc.DbConnection, maxConns, cache)
+		src.tableIncludes = nameMap(confSrc.TableIncludes)
+		src.tableExcludes = nameMap(confSrc.TableExcludes)
+		src.functionIncludes = confSrc.FunctionIncludes
+		if src.functionIncludes == nil {
+			src.functionIncludes = confDb.FunctionIncludes
+		}
+		src.connectReplicas(confSrc.ReplicaConnections, maxConns)
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+func newDataSource(name string, dbconn string, maxConns int, cache Cacher) *dataSource {
+	conn := dbConnect(name, dbConfig(dbconn, catalogPoolMaxConns))
+	return &dataSource{
+		name:        name,
+		dbconn:      conn,
+		requestPool: dbPool(requestDbConfig(dbconn, maxConns)),
+		listener:    newListenerDB(name, conn, cache),
+	}
+}
+
+func dbConnect(name string, dbconfig *pgxpool.Config) *pgxpool.Pool {
+	db := dbPool(dbconfig)
+	dbName := dbconfig.ConnConfig.Config.Database
+	dbUser := dbconfig.ConnConfig.Config.User
+	dbHost := dbconfig.ConnConfig.Config.Host
+	if name == "" {
+		log.Infof("Connected as %s to %s @ %s", dbUser, dbName, dbHost)
+	} else {
+		log.Infof("Connected source %s as %s to %s @ %s", name, dbUser, dbName, dbHost)
+	}
+	return db
+}
+
+func dbPool(dbconfig *pgxpool.Config) *pgxpool.Pool {
+	db, err := pgxpool.ConnectConfig(context.Background(), dbconfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+// requestDbConfig is the configuration of a pool of request queries,
+// which sets the default statement timeout of the connections
+func requestDbConfig(dbconn string, maxConns int) *pgxpool.Config {
+	dbconfig := dbConfig(dbconn, maxConns)
+	setRequestTimeoutParams(dbconfig.ConnConfig)
+	return dbconfig
+}
+
+func dbConfig(dbconn string, maxConns int) *pgxpool.Config {
+	// disallow blank config for safety
+	if dbconn == "" {
+		log.Fatal("Blank DbConnection is disallowed for security reasons")
+	}
+
+	dbconfig, err := pgxpool.ParseConfig(dbconn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Read and parse connection lifetime
+	dbPoolMaxLifeTime, errt := time.ParseDuration(conf.Current().Database.DbPoolMaxConnLifeTime)
+	if errt != nil {
+		log.Fatal(errt)
+	}
+	dbconfig.MaxConnLifetime = dbPoolMaxLifeTime
+
+	// Read and parse max connections
+	if maxConns > 0 {
+		dbconfig.MaxConns = int32(maxConns)
+	}
+
+	// Read current log level and use one less-fine level
+	dbconfig.ConnConfig.Logger = logrusadapter.NewLogger(log.New())
+	levelString, _ := (log.GetLevel() - 1).MarshalText()
+	pgxLevel, _ := pgx.LogLevelFromString(string(levelString))
+	dbconfig.ConnConfig.LogLevel = pgxLevel
+	setTimeoutParams(dbconfig.ConnConfig)
+	if conf.Current().Tracing.IsEnabled() {
+		traceQueries(dbconfig.ConnConfig)
+	}
+
+	return dbconfig
+}
+
+// source returns the data source with a name
+func (cat *catalogDB) source(name string) *dataSource {
+	for _, src := range cat.sources {
+		if src.name == name {
+			return src
+		}
+	}
+	return nil
+}
+
+// healthName is the name of a health component of the source
+func (src *dataSource) healthName(component string) string {
+	if src.name == "" {
+		return component
+	}
+	return component + "." + src.name
+}
@@ -0,0 +1,236 @@
+package fgb
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+func TestWriterHeader(t *testing.T) {
+	data := writeTestPoints(t, 3)
+	util.Equals(t, magicBytes, data[:8], "magic bytes")
+
+	header := data[8:]
+	root := fbRoot(header)
+	util.Equals(t, "test", fbFieldString(header, root, 0), "name")
+	util.Equals(t, uint8(geomPoint), fbFieldUint8(header, root, 2), "geometry type")
+	util.Equals(t, uint64(3), binary.LittleEndian.Uint64(header[fbFieldPos(header, root, 8):]), "features count")
+	util.Equals(t, uint16(IndexNodeSize), binary.LittleEndian.Uint16(header[fbFieldPos(header, root, 9):]), "index node size")
+	util.Equals(t, []float64{0, 0, 2, 2}, fbFieldFloat64s(header, root, 1), "envelope")
+
+	columns := fbFieldTables(header, root, 7)
+	util.Equals(t, 3, len(columns), "# columns")
+	util.Equals(t, "id", fbFieldString(header, columns[0], 0), "column name")
+	util.Equals(t, uint8(colInt), fbFieldUint8(header, columns[0], 1), "column type")
+	util.Equals(t, "name", fbFieldString(header, columns[1], 0), "column name")
+	util.Equals(t, uint8(colString), fbFieldUint8(header, columns[1], 1), "column type")
+	util.Equals(t, uint8(colDouble), fbFieldUint8(header, columns[2], 1), "column type")
+
+	crs := fbDeref(header, fbFieldPos(header, root, 10))
+	util.Equals(t, "EPSG", fbFieldString(header, crs, 0), "crs org")
+	util.Equals(t, uint32(4326), binary.LittleEndian.Uint32(header[fbFieldPos(header, crs, 1):]), "crs code")
+}
+
+func TestWriterIndex(t *testing.T) {
+	num := 40
+	data := writeTestPoints(t, num)
+	headerSize := 4 + int(binary.LittleEndian.Uint32(data[8:]))
+	numNodes := levelBounds(num, IndexNodeSize)[0][1]
+	// 40 leaves, 3 nodes and the root
+	util.Equals(t, num+3+1, numNodes, "# index nodes")
+
+	index := data[8+headerSize : 8+headerSize+numNodes*nodeItemSize]
+	features := data[8+headerSize+numNodes*nodeItemSize:]
+
+	root := readNode(index, 0)
+	util.Equals(t, orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{39, 39}}, root.bound, "root bound")
+
+	// every leaf references a feature located in its bound
+	for i := numNodes - num; i < numNodes; i++ {
+		leaf := readNode(index, i)
+		feature := features[leaf.offset:]
+		table := fbRoot(feature)
+		geom := fbDeref(feature, fbFieldPos(feature, table, 0))
+		xy := fbFieldFloat64s(feature, geom, 1)
+		util.Equals(t, leaf.bound.Min, orb.Point{xy[0], xy[1]}, "leaf bound")
+	}
+
+	// the children of a node are in its bound
+	for i := 0; i < numNodes-num; i++ {
+		node := readNode(index, i)
+		for j := int(node.offset); j < int(node.offset)+IndexNodeSize && j < numNodes; j++ {
+			child := readNode(index, j)
+			util.Assert(t, node.bound.Contains(child.bound.Min) && node.bound.Contains(child.bound.Max),
+				"node %v does not contain node %v", i, j)
+		}
+	}
+}
+
+func TestWriterProperties(t *testing.T) {
+	w := NewWriter("test", "", 4326, "Point", testColumns(), "id")
+	g := geojson.NewGeometry(orb.Point{1, 2})
+	err := w.Add(&api.GeojsonFeatureData{ID: "7", Geom: g, Props: map[string]interface{}{"name": "a", "value": nil}})
+	util.Assert(t, err == nil, "%v", err)
+	var buf bytes.Buffer
+	_, err = w.WriteTo(&buf)
+	util.Assert(t, err == nil, "%v", err)
+
+	data := buf.Bytes()
+	headerSize := 4 + int(binary.LittleEndian.Uint32(data[8:]))
+	feature := data[8+headerSize+2*nodeItemSize:]
+	props := fbFieldBytes(feature, fbRoot(feature), 1)
+	// id: column 0, int32 7 - name: column 1, string "a" - value is null
+	expected := []byte{0, 0, 7, 0, 0, 0, 1, 0, 1, 0, 0, 0, 'a'}
+	util.Equals(t, expected, props, "properties")
+}
+
+func TestWriterPolygon(t *testing.T) {
+	poly := orb.Polygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{1, 1}, {2, 1}, {2, 2}, {1, 1}},
+	}
+	table := encodeGeometry(orb.MultiPolygon{poly, poly})
+	buf := finishSizePrefixed(table)
+	geom := fbRoot(buf)
+	util.Equals(t, uint8(geomMultiPolygon), fbFieldUint8(buf, geom, 6), "type")
+	parts := fbFieldTables(buf, geom, 7)
+	util.Equals(t, 2, len(parts), "# parts")
+	util.Equals(t, uint8(geomPolygon), fbFieldUint8(buf, parts[0], 6), "part type")
+	util.Equals(t, 18, len(fbFieldFloat64s(buf, parts[0], 1)), "# coordinates")
+
+	endsPos := fbDeref(buf, fbFieldPos(buf, parts[1], 0))
+	util.Equals(t, uint32(2), binary.LittleEndian.Uint32(buf[endsPos:]), "# ends")
+	util.Equals(t, uint32(5), binary.LittleEndian.Uint32(buf[endsPos+4:]), "end of ring 1")
+	util.Equals(t, uint32(9), binary.LittleEndian.Uint32(buf[endsPos+8:]), "end of ring 2")
+}
+
+func TestWriterEmpty(t *testing.T) {
+	w := NewWriter("test", "", 4326, "Point", testColumns(), "id")
+	var buf bytes.Buffer
+	_, err := w.WriteTo(&buf)
+	util.Assert(t, err == nil, "%v", err)
+
+	header := buf.Bytes()[8:]
+	root := fbRoot(header)
+	util.Equals(t, uint16(0), binary.LittleEndian.Uint16(header[fbFieldPos(header, root, 9):]), "index node size")
+	util.Equals(t, 0, fbFieldPos(header, root, 1), "no envelope")
+	headerSize := 4 + int(binary.LittleEndian.Uint32(header))
+	util.Equals(t, 8+headerSize, buf.Len(), "no index and no features")
+}
+
+//========================================
+
+func testColumns() []Column {
+	return []Column{
+		{Name: "id", Type: api.PGTypeInt4},
+		{Name: "name", Type: api.PGTypeText},
+		{Name: "value", Type: api.PGTypeFloat8},
+	}
+}
+
+// writeTestPoints writes points on a diagonal
+func writeTestPoints(t *testing.T, num int) []byte {
+	w := NewWriter("test", "Test", 4326, "Point", testColumns(), "id")
+	for i := 0; i < num; i++ {
+		g := geojson.NewGeometry(orb.Point{float64(i), float64(i)})
+		props := map[string]interface{}{"name": "point", "value": float64(i) / 2}
+		err := w.Add(&api.GeojsonFeatureData{ID: "1", Geom: g, Props: props})
+		util.Assert(t, err == nil, "%v", err)
+	}
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	util.Assert(t, err == nil, "%v", err)
+	util.Equals(t, int64(buf.Len()), n, "bytes written")
+	return buf.Bytes()
+}
+
+func readNode(index []byte, i int) nodeItem {
+	pos := i * nodeItemSize
+	f := func(k int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(index[pos+8*k:]))
+	}
+	return nodeItem{
+		bound:  orb.Bound{Min: orb.Point{f(0), f(1)}, Max: orb.Point{f(2), f(3)}},
+		offset: binary.LittleEndian.Uint64(index[pos+32:]),
+	}
+}
+
+// minimal flatbuffers reader, for size-prefixed buffers
+
+func fbRoot(buf []byte) int {
+	return 4 + int(binary.LittleEndian.Uint32(buf[4:]))
+}
+
+func fbDeref(buf []byte, pos int) int {
+	return pos + int(binary.LittleEndian.Uint32(buf[pos:]))
+}
+
+// fbField returns the position of a table field, or 0 if it is absent
+func fbFieldPos(buf []byte, table int, slot int) int {
+	vtable := table - int(int32(binary.LittleEndian.Uint32(buf[table:])))
+	vtableSize := int(binary.LittleEndian.Uint16(buf[vtable:]))
+	if 4+2*slot >= vtableSize {
+		return 0
+	}
+	offset := int(binary.LittleEndian.Uint16(buf[vtable+4+2*slot:]))
+	if offset == 0 {
+		return 0
+	}
+	return table + offset
+}
+
+func fbFieldUint8(buf []byte, table int, slot int) uint8 {
+	return buf[fbFieldPos(buf, table, slot)]
+}
+
+func fbFieldBytes(buf []byte, table int, slot int) []byte {
+	pos := fbDeref(buf, fbFieldPos(buf, table, slot))
+	n := int(binary.LittleEndian.Uint32(buf[pos:]))
+	return buf[pos+4 : pos+4+n]
+}
+
+func fbFieldString(buf []byte, table int, slot int) string {
+	return string(fbFieldBytes(buf, table, slot))
+}
+
+func fbFieldFloat64s(buf []byte, table int, slot int) []float64 {
+	pos := fbDeref(buf, fbFieldPos(buf, table, slot))
+	n := int(binary.LittleEndian.Uint32(buf[pos:]))
+	if (pos+4)%8 != 0 {
+		panic("vector of doubles is not aligned")
+	}
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[pos+4+8*i:]))
+	}
+	return values
+}
+
+func fbFieldTables(buf []byte, table int, slot int) []int {
+	pos := fbDeref(buf, fbFieldPos(buf, table, slot))
+	n := int(binary.LittleEndian.Uint32(buf[pos:]))
+	tables := make([]int, n)
+	for i := range tables {
+		tables[i] = fbDeref(buf, pos+4+4*i)
+	}
+	return tables
+}
@@ -0,0 +1,233 @@
+package fgb
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Minimal FlatBuffers encoder, limited to what FlatGeobuf needs.
+// Unlike the reference builder, objects are written front to back:
+// a table is written before the objects it references,
+// which is valid since offsets to referenced objects only have to be positive.
+// Alignment is relative to the start of the size prefix,
+// which is how size-prefixed buffers are verified.
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+type fbObject interface {
+	// write appends the object to the buffer, and returns its position
+	write(b *fbBuilder) int
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+// finishSizePrefixed encodes a root table as a size-prefixed buffer
+func finishSizePrefixed(root *fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 8, 256)}
+	pos := root.write(b)
+	binary.LittleEndian.PutUint32(b.buf[4:], uint32(pos-4))
+	binary.LittleEndian.PutUint32(b.buf[0:], uint32(len(b.buf)-4))
+	return b.buf
+}
+
+// padBefore pads the buffer so that it is aligned after n more bytes are written
+func (b *fbBuilder) padBefore(n int, align int) {
+	for (len(b.buf)+n)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) appendUint32(v uint32) {
+	b.buf = appendUint32(b.buf, v)
+}
+
+// setOffset sets the offset stored at pos to reference the object at target
+func (b *fbBuilder) setOffset(pos int, target int) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+//========================================
+
+type fbString string
+
+func (s fbString) write(b *fbBuilder) int {
+	b.padBefore(0, 4)
+	pos := len(b.buf)
+	b.appendUint32(uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+// fbVector is a vector of scalars, already encoded
+type fbVector struct {
+	elemSize int
+	length   int
+	data     []byte
+}
+
+func newFloat64Vector(values []float64) *fbVector {
+	data := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		data = appendUint64(data, math.Float64bits(v))
+	}
+	return &fbVector{elemSize: 8, length: len(values), data: data}
+}
+
+func newUint32Vector(values []uint32) *fbVector {
+	data := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		data = appendUint32(data, v)
+	}
+	return &fbVector{elemSize: 4, length: len(values), data: data}
+}
+
+func newByteVector(values []byte) *fbVector {
+	return &fbVector{elemSize: 1, length: len(values), data: values}
+}
+
+func (v *fbVector) write(b *fbBuilder) int {
+	align := 4
+	if v.elemSize > align {
+		align = v.elemSize
+	}
+	// the elements following the length must be aligned
+	b.padBefore(4, align)
+	pos := len(b.buf)
+	b.appendUint32(uint32(v.length))
+	b.buf = append(b.buf, v.data...)
+	return pos
+}
+
+// fbTableVector is a vector of tables
+type fbTableVector []*fbTable
+
+func (v fbTableVector) write(b *fbBuilder) int {
+	b.padBefore(0, 4)
+	pos := len(b.buf)
+	b.appendUint32(uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, t := range v {
+		slot := pos + 4 + 4*i
+		b.setOffset(slot, t.write(b))
+	}
+	return pos
+}
+
+//========================================
+
+type fbField struct {
+	slot   int
+	size   int
+	scalar []byte
+	ref    fbObject
+}
+
+// fbTable is a table, with fields identified by their slot in the schema
+type fbTable struct {
+	fields []fbField
+}
+
+func (t *fbTable) addScalar(slot int, data []byte) {
+	t.fields = append(t.fields, fbField{slot: slot, size: len(data), scalar: data})
+}
+
+func (t *fbTable) addUint8(slot int, v uint8) {
+	t.addScalar(slot, []byte{v})
+}
+
+func (t *fbTable) addUint16(slot int, v uint16) {
+	t.addScalar(slot, appendUint16(nil, v))
+}
+
+func (t *fbTable) addInt32(slot int, v int32) {
+	t.addScalar(slot, appendUint32(nil, uint32(v)))
+}
+
+func (t *fbTable) addUint64(slot int, v uint64) {
+	t.addScalar(slot, appendUint64(nil, v))
+}
+
+func (t *fbTable) addRef(slot int, obj fbObject) {
+	t.fields = append(t.fields, fbField{slot: slot, size: 4, ref: obj})
+}
+
+func (t *fbTable) write(b *fbBuilder) int {
+	// larger fields first, so they are aligned without padding
+	fields := make([]fbField, len(t.fields))
+	copy(fields, t.fields)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].size > fields[j].size })
+
+	numSlots := 0
+	offsets := make([]int, len(fields))
+	// the table starts with the offset to its vtable
+	size := 4
+	for i, f := range fields {
+		for size%f.size != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += f.size
+		if f.slot+1 > numSlots {
+			numSlots = f.slot + 1
+		}
+	}
+
+	//--- vtable
+	vtable := make([]byte, 4+2*numSlots)
+	binary.LittleEndian.PutUint16(vtable[0:], uint16(len(vtable)))
+	binary.LittleEndian.PutUint16(vtable[2:], uint16(size))
+	for i, f := range fields {
+		binary.LittleEndian.PutUint16(vtable[4+2*f.slot:], uint16(offsets[i]))
+	}
+	b.padBefore(0, 2)
+	vtablePos := len(b.buf)
+	b.buf = append(b.buf, vtable...)
+
+	//--- table
+	b.padBefore(0, 8)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vtablePos)))
+	for i, f := range fields {
+		if f.ref == nil {
+			copy(b.buf[pos+offsets[i]:], f.scalar)
+		}
+	}
+	for i, f := range fields {
+		if f.ref != nil {
+			slot := pos + offsets[i]
+			b.setOffset(slot, f.ref.write(b))
+		}
+	}
+	return pos
+}
+
+//========================================
+// little-endian encoding helpers
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v)), uint32(v>>32))
+}
@@ -0,0 +1,160 @@
+package fgb
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Packed Hilbert R-tree, as used for the FlatGeobuf spatial index.
+// The tree is stored level by level, from the root to the leaves.
+// A leaf references the byte offset of a feature in the features section,
+// and a parent node the index of its first child node.
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+)
+
+const (
+	nodeItemSize = 40
+	hilbertMax   = (1 << 16) - 1
+)
+
+type nodeItem struct {
+	bound  orb.Bound
+	offset uint64
+}
+
+func (node *nodeItem) appendTo(buf []byte) []byte {
+	buf = appendUint64(buf, math.Float64bits(node.bound.Min[0]))
+	buf = appendUint64(buf, math.Float64bits(node.bound.Min[1]))
+	buf = appendUint64(buf, math.Float64bits(node.bound.Max[0]))
+	buf = appendUint64(buf, math.Float64bits(node.bound.Max[1]))
+	return appendUint64(buf, node.offset)
+}
+
+// levelBounds returns the node index range of each tree level, starting with the leaves
+func levelBounds(numItems int, nodeSize int) [][2]int {
+	levelNumNodes := []int{numItems}
+	n := numItems
+	numNodes := n
+	for {
+		n = (n + nodeSize - 1) / nodeSize
+		numNodes += n
+		levelNumNodes = append(levelNumNodes, n)
+		if n == 1 {
+			break
+		}
+	}
+	bounds := make([][2]int, len(levelNumNodes))
+	n = numNodes
+	for i, size := range levelNumNodes {
+		bounds[i] = [2]int{n - size, n}
+		n -= size
+	}
+	return bounds
+}
+
+// encodeIndex encodes the packed R-tree for the given items, which are in Hilbert order
+func encodeIndex(leaves []nodeItem, nodeSize int) []byte {
+	levels := levelBounds(len(leaves), nodeSize)
+	numNodes := levels[0][1]
+	nodes := make([]nodeItem, numNodes)
+	copy(nodes[levels[0][0]:], leaves)
+
+	for i := 0; i < len(levels)-1; i++ {
+		parent := levels[i+1][0]
+		for pos := levels[i][0]; pos < levels[i][1]; {
+			node := nodeItem{bound: nodes[pos].bound, offset: uint64(pos)}
+			for j := 0; j < nodeSize && pos < levels[i][1]; j++ {
+				node.bound = node.bound.Union(nodes[pos].bound)
+				pos++
+			}
+			nodes[parent] = node
+			parent++
+		}
+	}
+
+	buf := make([]byte, 0, numNodes*nodeItemSize)
+	for i := range nodes {
+		buf = nodes[i].appendTo(buf)
+	}
+	return buf
+}
+
+// sortByHilbert sorts items by the Hilbert value of their center in the given extent
+func sortByHilbert(items []*feature, extent orb.Bound) {
+	width := extent.Max[0] - extent.Min[0]
+	height := extent.Max[1] - extent.Min[1]
+	values := make(map[*feature]uint32, len(items))
+	for _, item := range items {
+		var x, y uint32
+		center := item.bound.Center()
+		if width > 0 {
+			x = uint32(math.Floor(hilbertMax * (center[0] - extent.Min[0]) / width))
+		}
+		if height > 0 {
+			y = uint32(math.Floor(hilbertMax * (center[1] - extent.Min[1]) / height))
+		}
+		values[item] = hilbert(x, y)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return values[items[i]] > values[items[j]] })
+}
+
+// hilbert computes the position of (x, y) on a Hilbert curve of order 16.
+// From https://github.com/rawrunprotected/hilbert_curves (public domain)
+func hilbert(x uint32, y uint32) uint32 {
+	a := x ^ y
+	b := 0xFFFF ^ a
+	c := 0xFFFF ^ (x | y)
+	d := x & (y ^ 0xFFFF)
+
+	A := a | (b >> 1)
+	B := (a >> 1) ^ a
+	C := ((c >> 1) ^ (b & (d >> 1))) ^ c
+	D := ((a & (c >> 1)) ^ (d >> 1)) ^ d
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 2)) ^ (b & (b >> 2))
+	B = (a & (b >> 2)) ^ (b & ((a ^ b) >> 2))
+	C ^= (a & (c >> 2)) ^ (b & (d >> 2))
+	D ^= (b & (c >> 2)) ^ ((a ^ b) & (d >> 2))
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 4)) ^ (b & (b >> 4))
+	B = (a & (b >> 4)) ^ (b & ((a ^ b) >> 4))
+	C ^= (a & (c >> 4)) ^ (b & (d >> 4))
+	D ^= (b & (c >> 4)) ^ ((a ^ b) & (d >> 4))
+
+	a, b, c, d = A, B, C, D
+	C ^= (a & (c >> 8)) ^ (b & (d >> 8))
+	D ^= (b & (c >> 8)) ^ ((a ^ b) & (d >> 8))
+
+	a = C ^ (C >> 1)
+	b = D ^ (D >> 1)
+
+	i0 := x ^ y
+	i1 := b | (0xFFFF ^ (i0 | a))
+
+	i0 = (i0 | (i0 << 8)) & 0x00FF00FF
+	i0 = (i0 | (i0 << 4)) & 0x0F0F0F0F
+	i0 = (i0 | (i0 << 2)) & 0x33333333
+	i0 = (i0 | (i0 << 1)) & 0x55555555
+
+	i1 = (i1 | (i1 << 8)) & 0x00FF00FF
+	i1 = (i1 | (i1 << 4)) & 0x0F0F0F0F
+	i1 = (i1 | (i1 << 2)) & 0x33333333
+	i1 = (i1 | (i1 << 1)) & 0x55555555
+
+	return (i1 << 1) | i0
+}
@@ -0,0 +1,426 @@
+package fgb
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Encoding of features in the FlatGeobuf format (https://flatgeobuf.org),
+// including the packed Hilbert R-tree spatial index.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/paulmach/orb"
+)
+
+// FlatGeobuf geometry types
+const (
+	geomUnknown         = 0
+	geomPoint           = 1
+	geomLineString      = 2
+	geomPolygon         = 3
+	geomMultiPoint      = 4
+	geomMultiLineString = 5
+	geomMultiPolygon    = 6
+	geomCollection      = 7
+)
+
+// FlatGeobuf column types
+const (
+	colBool     = 2
+	colInt      = 5
+	colLong     = 7
+	colFloat    = 9
+	colDouble   = 10
+	colString   = 11
+	colJSON     = 12
+	colDateTime = 13
+)
+
+// IndexNodeSize is the number of children of the spatial index nodes
+const IndexNodeSize = 16
+
+var magicBytes = []byte{0x66, 0x67, 0x62, 0x03, 0x66, 0x67, 0x62, 0x01}
+
+// Column describes a feature property
+type Column struct {
+	Name string
+	Type api.PGType
+}
+
+// Writer encodes features as a FlatGeobuf dataset.
+// Features are held in memory until the dataset is written,
+// since the spatial index and header are written before them
+type Writer struct {
+	name     string
+	title    string
+	srid     int
+	geomType uint8
+	columns  []Column
+	idColumn string
+	features []*feature
+}
+
+type feature struct {
+	bound    orb.Bound
+	hasBound bool
+	data     []byte
+}
+
+// NewWriter creates a writer for features with the given geometry type and properties.
+// The value of the idColumn property is the feature id
+func NewWriter(name string, title string, srid int, geometryType string, columns []Column, idColumn string) *Writer {
+	return &Writer{
+		name:     name,
+		title:    title,
+		srid:     srid,
+		geomType: geometryTypeCode(geometryType),
+		columns:  columns,
+		idColumn: idColumn,
+	}
+}
+
+// Add encodes a feature
+func (w *Writer) Add(feat *api.GeojsonFeatureData) error {
+	item := feature{}
+	table := &fbTable{}
+	if feat.Geom != nil && feat.Geom.Geometry() != nil {
+		geom := feat.Geom.Geometry()
+		table.addRef(0, encodeGeometry(geom))
+		item.bound = geom.Bound()
+		item.hasBound = true
+	}
+	props, err := w.encodeProperties(feat)
+	if err != nil {
+		return err
+	}
+	if len(props) > 0 {
+		table.addRef(1, newByteVector(props))
+	}
+	item.data = finishSizePrefixed(table)
+	w.features = append(w.features, &item)
+	return nil
+}
+
+// WriteTo writes the dataset: header, spatial index and features
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	// the index needs a bounding box for every feature
+	isIndexed := len(w.features) > 0
+	hasExtent := false
+	var extent orb.Bound
+	for _, item := range w.features {
+		if !item.hasBound {
+			isIndexed = false
+			continue
+		}
+		if hasExtent {
+			extent = extent.Union(item.bound)
+		} else {
+			extent = item.bound
+			hasExtent = true
+		}
+	}
+
+	var index []byte
+	if isIndexed {
+		sortByHilbert(w.features, extent)
+		leaves := make([]nodeItem, len(w.features))
+		var offset uint64
+		for i, item := range w.features {
+			leaves[i] = nodeItem{bound: item.bound, offset: offset}
+			offset += uint64(len(item.data))
+		}
+		index = encodeIndex(leaves, IndexNodeSize)
+	}
+
+	var written int64
+	chunks := [][]byte{magicBytes, w.encodeHeader(extent, hasExtent, isIndexed), index}
+	for _, item := range w.features {
+		chunks = append(chunks, item.data)
+	}
+	for _, chunk := range chunks {
+		n, err := out.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (w *Writer) encodeHeader(extent orb.Bound, hasExtent bool, isIndexed bool) []byte {
+	header := &fbTable{}
+	header.addRef(0, fbString(w.name))
+	if hasExtent {
+		header.addRef(1, newFloat64Vector([]float64{extent.Min[0], extent.Min[1], extent.Max[0], extent.Max[1]}))
+	}
+	header.addUint8(2, w.geomType)
+	if len(w.columns) > 0 {
+		var columns fbTableVector
+		for _, col := range w.columns {
+			column := &fbTable{}
+			column.addRef(0, fbString(col.Name))
+			column.addUint8(1, columnTypeCode(col.Type))
+			columns = append(columns, column)
+		}
+		header.addRef(7, columns)
+	}
+	header.addUint64(8, uint64(len(w.features)))
+	var nodeSize uint16
+	if isIndexed {
+		nodeSize = IndexNodeSize
+	}
+	header.addUint16(9, nodeSize)
+	if w.srid > 0 {
+		crs := &fbTable{}
+		crs.addRef(0, fbString("EPSG"))
+		crs.addInt32(1, int32(w.srid))
+		header.addRef(10, crs)
+	}
+	if w.title != "" {
+		header.addRef(11, fbString(w.title))
+	}
+	return finishSizePrefixed(header)
+}
+
+//========================================
+// Geometry
+
+func geometryTypeCode(geomType string) uint8 {
+	switch geomType {
+	case "Point":
+		return geomPoint
+	case "LineString":
+		return geomLineString
+	case "Polygon":
+		return geomPolygon
+	case "MultiPoint":
+		return geomMultiPoint
+	case "MultiLineString":
+		return geomMultiLineString
+	case "MultiPolygon":
+		return geomMultiPolygon
+	case "GeometryCollection":
+		return geomCollection
+	}
+	return geomUnknown
+}
+
+// encodeGeometry encodes a geometry. The type is always set,
+// so that features can be read when the header geometry type is unknown
+func encodeGeometry(geom orb.Geometry) *fbTable {
+	table := &fbTable{}
+	var typ uint8
+	var rings [][]orb.Point
+	var parts fbTableVector
+	switch g := geom.(type) {
+	case orb.Point:
+		typ = geomPoint
+		rings = [][]orb.Point{{g}}
+	case orb.MultiPoint:
+		typ = geomMultiPoint
+		rings = [][]orb.Point{g}
+	case orb.LineString:
+		typ = geomLineString
+		rings = [][]orb.Point{g}
+	case orb.Ring:
+		typ = geomLineString
+		rings = [][]orb.Point{g}
+	case orb.MultiLineString:
+		typ = geomMultiLineString
+		for _, line := range g {
+			rings = append(rings, line)
+		}
+	case orb.Polygon:
+		typ = geomPolygon
+		for _, ring := range g {
+			rings = append(rings, ring)
+		}
+	case orb.MultiPolygon:
+		typ = geomMultiPolygon
+		for _, poly := range g {
+			parts = append(parts, encodeGeometry(poly))
+		}
+	case orb.Collection:
+		typ = geomCollection
+		for _, part := range g {
+			parts = append(parts, encodeGeometry(part))
+		}
+	case orb.Bound:
+		return encodeGeometry(g.ToPolygon())
+	}
+
+	var xy []float64
+	var ends []uint32
+	for _, ring := range rings {
+		for _, p := range ring {
+			xy = append(xy, p[0], p[1])
+		}
+		ends = append(ends, uint32(len(xy)/2))
+	}
+	// ends are only needed for several parts
+	if len(ends) > 1 {
+		table.addRef(0, newUint32Vector(ends))
+	}
+	if len(xy) > 0 {
+		table.addRef(1, newFloat64Vector(xy))
+	}
+	table.addUint8(6, typ)
+	if len(parts) > 0 {
+		table.addRef(7, parts)
+	}
+	return table
+}
+
+//========================================
+// Properties
+
+func columnTypeCode(dbType api.PGType) uint8 {
+	switch dbType {
+	case api.PGTypeBool:
+		return colBool
+	case api.PGTypeInt, api.PGTypeInt4:
+		return colInt
+	case api.PGTypeInt8, api.PGTypeBigInt:
+		return colLong
+	case api.PGTypeFloat4:
+		return colFloat
+	case api.PGTypeFloat8, api.PGTypeNumeric:
+		return colDouble
+	case api.PGTypeDate, api.PGTypeTimeStamp, api.PGTypeTimeStampTZ:
+		return colDateTime
+	case api.PGTypeJSON:
+		return colJSON
+	}
+	// arrays are encoded as JSON
+	if len(dbType) > 1 && dbType[0] == '_' {
+		return colJSON
+	}
+	return colString
+}
+
+// encodeProperties encodes the non-null property values, each prefixed by its column index
+func (w *Writer) encodeProperties(feat *api.GeojsonFeatureData) ([]byte, error) {
+	var buf []byte
+	for i, col := range w.columns {
+		var val interface{}
+		if col.Name == w.idColumn && feat.ID != "" {
+			val = feat.ID
+		} else {
+			val = feat.Props[col.Name]
+		}
+		if val == nil {
+			continue
+		}
+		var err error
+		buf = appendUint16(buf, uint16(i))
+		buf, err = appendValue(buf, columnTypeCode(col.Type), val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for property %v: %v", col.Name, err)
+		}
+	}
+	return buf, nil
+}
+
+func appendValue(buf []byte, colType uint8, val interface{}) ([]byte, error) {
+	switch colType {
+	case colBool:
+		b, err := toBool(val)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case colInt:
+		n, err := toInt64(val)
+		return appendUint32(buf, uint32(int32(n))), err
+	case colLong:
+		n, err := toInt64(val)
+		return appendUint64(buf, uint64(n)), err
+	case colFloat:
+		f, err := toFloat64(val)
+		return appendUint32(buf, math.Float32bits(float32(f))), err
+	case colDouble:
+		f, err := toFloat64(val)
+		return appendUint64(buf, math.Float64bits(f)), err
+	case colDateTime:
+		if t, ok := val.(time.Time); ok {
+			return appendString(buf, t.Format(time.RFC3339)), nil
+		}
+		return appendString(buf, fmt.Sprint(val)), nil
+	case colJSON:
+		if raw, ok := val.(json.RawMessage); ok {
+			return appendString(buf, string(raw)), nil
+		}
+		text, err := json.Marshal(val)
+		return appendString(buf, string(text)), err
+	}
+	return appendString(buf, fmt.Sprint(val)), nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+func toBool(val interface{}) (bool, error) {
+	switch v := val.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, fmt.Errorf("not a boolean: %v", val)
+}
+
+func toInt64(val interface{}) (int64, error) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float32:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("not an integer: %v", val)
+}
+
+func toFloat64(val interface{}) (float64, error) {
+	switch v := val.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case int, int16, int32, int64:
+		n, _ := toInt64(v)
+		return float64(n), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("not a number: %v", val)
+}
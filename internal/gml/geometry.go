@@ -0,0 +1,109 @@
+package gml
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/paulmach/orb"
+)
+
+// encodeGeometry encodes a geometry with the GML 3.2 Simple Features elements.
+// Every geometry element needs a gml:id, which is derived from the given id.
+// The srsName is only set on the outer geometry
+func encodeGeometry(e *encoder, geom orb.Geometry, id string, srs string) {
+	attrs := fmt.Sprintf(` gml:id="%s"`, escape(id))
+	if srs != "" {
+		attrs += fmt.Sprintf(` srsName="%s"`, srs)
+	}
+	switch g := geom.(type) {
+	case orb.Point:
+		e.printf("<gml:Point%s><gml:pos>", attrs)
+		encodePoints(e, []orb.Point{g})
+		e.printf("</gml:pos></gml:Point>")
+	case orb.LineString:
+		encodeLineString(e, g, attrs)
+	case orb.Ring:
+		encodeLineString(e, orb.LineString(g), attrs)
+	case orb.Polygon:
+		e.printf("<gml:Polygon%s>", attrs)
+		for i, ring := range g {
+			tag := "gml:interior"
+			if i == 0 {
+				tag = "gml:exterior"
+			}
+			e.printf("<%s><gml:LinearRing><gml:posList>", tag)
+			encodePoints(e, ring)
+			e.printf("</gml:posList></gml:LinearRing></%s>", tag)
+		}
+		e.printf("</gml:Polygon>")
+	case orb.Bound:
+		encodeGeometry(e, g.ToPolygon(), id, srs)
+	case orb.MultiPoint:
+		e.printf("<gml:MultiPoint%s>", attrs)
+		for i, p := range g {
+			e.printf("<gml:pointMember>")
+			encodeGeometry(e, p, memberID(id, i), "")
+			e.printf("</gml:pointMember>")
+		}
+		e.printf("</gml:MultiPoint>")
+	case orb.MultiLineString:
+		e.printf("<gml:MultiCurve%s>", attrs)
+		for i, line := range g {
+			e.printf("<gml:curveMember>")
+			encodeGeometry(e, line, memberID(id, i), "")
+			e.printf("</gml:curveMember>")
+		}
+		e.printf("</gml:MultiCurve>")
+	case orb.MultiPolygon:
+		e.printf("<gml:MultiSurface%s>", attrs)
+		for i, poly := range g {
+			e.printf("<gml:surfaceMember>")
+			encodeGeometry(e, poly, memberID(id, i), "")
+			e.printf("</gml:surfaceMember>")
+		}
+		e.printf("</gml:MultiSurface>")
+	case orb.Collection:
+		e.printf("<gml:MultiGeometry%s>", attrs)
+		for i, part := range g {
+			e.printf("<gml:geometryMember>")
+			encodeGeometry(e, part, memberID(id, i), "")
+			e.printf("</gml:geometryMember>")
+		}
+		e.printf("</gml:MultiGeometry>")
+	}
+}
+
+func encodeLineString(e *encoder, line orb.LineString, attrs string) {
+	e.printf("<gml:LineString%s><gml:posList>", attrs)
+	encodePoints(e, line)
+	e.printf("</gml:posList></gml:LineString>")
+}
+
+// encodePoints encodes coordinates as a list of space-separated values
+func encodePoints(e *encoder, points []orb.Point) {
+	for i, p := range points {
+		if i > 0 {
+			e.buf.WriteByte(' ')
+		}
+		e.buf.WriteString(strconv.FormatFloat(p[0], 'f', -1, 64))
+		e.buf.WriteByte(' ')
+		e.buf.WriteString(strconv.FormatFloat(p[1], 'f', -1, 64))
+	}
+}
+
+func memberID(id string, index int) string {
+	return id + "." + strconv.Itoa(index+1)
+}
@@ -0,0 +1,251 @@
+package gml
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Encoding of features as GML 3.2, following the Simple Features Level 0 profile
+// and the feature collection schema of OGC API - Features - Part 1.
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+)
+
+// XML namespaces and schema locations
+const (
+	NamespaceGML   = "http://www.opengis.net/gml/3.2"
+	NamespaceSF    = "http://www.opengis.net/ogcapi-features-1/1.0/sf"
+	NamespaceAtom  = "http://www.w3.org/2005/Atom"
+	NamespaceXSI   = "http://www.w3.org/2001/XMLSchema-instance"
+	NamespaceXS    = "http://www.w3.org/2001/XMLSchema"
+	NamespaceGMLSF = "http://www.opengis.net/gmlsf/2.0"
+
+	SchemaLocationGML   = "http://schemas.opengis.net/gml/3.2.1/gml.xsd"
+	SchemaLocationSF    = "http://schemas.opengis.net/ogcapi/features/part1/1.0/xml/core-sf.xsd"
+	SchemaLocationGMLSF = "http://schemas.opengis.net/gmlsfProfile/2.0/gmlsfLevels.xsd"
+
+	// prefix of the application schema namespace
+	prefixApp = "app"
+	// name of the geometry property, if the table has no geometry column name
+	defaultGeometryName = "geometry"
+)
+
+// FeatureType describes the encoding of the features of a collection
+type FeatureType struct {
+	// Name is the feature element name
+	Name string
+	// Namespace is the namespace of the application schema
+	Namespace string
+	// SchemaURL is the location of the application schema
+	SchemaURL    string
+	GeometryName string
+	GeometryType string
+	// Columns are the encoded properties, in schema order
+	Columns  []string
+	Types    map[string]api.PGType
+	IDColumn string
+	// Srid is the coordinate system of the geometries
+	Srid int
+	// LatLon is set if the geometries are in latitude/longitude order (EPSG:4326)
+	LatLon bool
+}
+
+// NewFeatureType creates the feature type of a table, for the given properties
+func NewFeatureType(tbl *api.Table, columns []string, namespace string, schemaURL string) *FeatureType {
+	types := make(map[string]api.PGType, len(tbl.DbTypes))
+	for name, col := range tbl.DbTypes {
+		types[name] = col.Type
+	}
+	geomName := tbl.GeometryColumn
+	if geomName == "" {
+		geomName = defaultGeometryName
+	}
+	return &FeatureType{
+		Name:         xmlName(tbl.ID),
+		Namespace:    namespace,
+		SchemaURL:    schemaURL,
+		GeometryName: xmlName(geomName),
+		GeometryType: tbl.GeometryType,
+		Columns:      columns,
+		Types:        types,
+		IDColumn:     tbl.IDColumn,
+		Srid:         tbl.Srid,
+	}
+}
+
+// Collection holds the metadata of a feature collection response
+type Collection struct {
+	// NumberMatched is the number of features matched by the query, or -1 if unknown
+	NumberMatched int
+	TimeStamp     time.Time
+	Links         []*api.Link
+}
+
+// EncodeCollection encodes features as a feature collection document
+func (ft *FeatureType) EncodeCollection(coll *Collection, features []*api.GeojsonFeatureData) []byte {
+	e := &encoder{}
+	e.header()
+	numMatched := "unknown"
+	if coll.NumberMatched >= 0 {
+		numMatched = strconv.Itoa(coll.NumberMatched)
+	}
+	e.printf(`<sf:FeatureCollection xmlns:sf="%s" xmlns:gml="%s" xmlns:atom="%s" xmlns:xsi="%s" xmlns:%s="%s"`,
+		NamespaceSF, NamespaceGML, NamespaceAtom, NamespaceXSI, prefixApp, escape(ft.Namespace))
+	e.printf(` xsi:schemaLocation="%s %s %s %s"`, NamespaceSF, SchemaLocationSF, escape(ft.Namespace), escape(ft.SchemaURL))
+	e.printf(` numberMatched="%s" numberReturned="%d" timeStamp="%s">`,
+		numMatched, len(features), coll.TimeStamp.UTC().Format(time.RFC3339))
+	for _, link := range coll.Links {
+		e.printf(`<atom:link href="%s" rel="%s" type="%s" title="%s"/>`,
+			escape(link.Href), escape(link.Rel), escape(link.Type), escape(link.Title))
+	}
+	for i, feat := range features {
+		e.printf("<sf:featureMember>")
+		ft.encodeFeature(e, feat, i+1, "")
+		e.printf("</sf:featureMember>")
+	}
+	e.printf("</sf:FeatureCollection>\n")
+	return e.buf.Bytes()
+}
+
+// EncodeFeature encodes a feature as a standalone document
+func (ft *FeatureType) EncodeFeature(feat *api.GeojsonFeatureData) []byte {
+	e := &encoder{}
+	e.header()
+	namespaces := fmt.Sprintf(` xmlns:gml="%s" xmlns:xsi="%s" xmlns:%s="%s" xsi:schemaLocation="%s %s"`,
+		NamespaceGML, NamespaceXSI, prefixApp, escape(ft.Namespace), escape(ft.Namespace), escape(ft.SchemaURL))
+	ft.encodeFeature(e, feat, 1, namespaces)
+	e.printf("\n")
+	return e.buf.Bytes()
+}
+
+// encodeFeature encodes a feature element. The feature number is used
+// for the gml:id if the feature has no id.
+func (ft *FeatureType) encodeFeature(e *encoder, feat *api.GeojsonFeatureData, num int, namespaces string) {
+	id := feat.ID
+	if id == "" {
+		id = strconv.Itoa(num)
+	}
+	gmlID := ft.Name + "." + xmlIDValue(id)
+	e.printf(`<%s:%s gml:id="%s"%s>`, prefixApp, ft.Name, escape(gmlID), namespaces)
+	if feat.Geom != nil && feat.Geom.Geometry() != nil {
+		e.printf("<%s:%s>", prefixApp, ft.GeometryName)
+		encodeGeometry(e, feat.Geom.Geometry(), gmlID+".geom", srsName(ft.Srid, ft.LatLon))
+		e.printf("</%s:%s>", prefixApp, ft.GeometryName)
+	}
+	for _, col := range ft.Columns {
+		var val interface{}
+		if col == ft.IDColumn && feat.ID != "" {
+			val = feat.ID
+		} else {
+			val = feat.Props[col]
+		}
+		// absent properties are null
+		if val == nil {
+			continue
+		}
+		name := xmlName(col)
+		e.printf("<%s:%s>%s</%s:%s>", prefixApp, name, escape(formatValue(val, ft.Types[col])), prefixApp, name)
+	}
+	e.printf("</%s:%s>", prefixApp, ft.Name)
+}
+
+// srsName is the URI of a coordinate system.
+// CRS84 is used for WGS 84 unless coordinates are in latitude/longitude order
+func srsName(srid int, isLatLon bool) string {
+	return api.CrsURIAxis(srid, isLatLon)
+}
+
+// formatValue formats a property value as XML Schema text.
+// Arrays and objects are encoded as JSON
+func formatValue(val interface{}, dbType api.PGType) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		if dbType == api.PGTypeDate {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	case json.RawMessage:
+		return string(v)
+	}
+	kind := reflect.ValueOf(val).Kind()
+	if kind == reflect.Slice || kind == reflect.Map {
+		if text, err := json.Marshal(val); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(val)
+}
+
+// xmlName converts a name to a valid XML element name,
+// by replacing invalid characters
+func xmlName(name string) string {
+	if name == "" || !isNameStartChar([]rune(name)[0]) {
+		name = "_" + name
+	}
+	return xmlIDValue(name)
+}
+
+// xmlIDValue converts a feature id to a valid part of a gml:id,
+// by replacing invalid characters
+func xmlIDValue(id string) string {
+	return strings.Map(func(r rune) rune {
+		if isNameChar(r) {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+func isNameStartChar(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r > 0x7F
+}
+
+func isNameChar(r rune) bool {
+	return isNameStartChar(r) || r == '-' || r == '.' || (r >= '0' && r <= '9')
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s)) //nolint:errcheck
+	return b.String()
+}
+
+//========================================
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) header() {
+	e.buf.WriteString(xml.Header)
+}
+
+func (e *encoder) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&e.buf, format, args...)
+}
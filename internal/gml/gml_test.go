@@ -0,0 +1,140 @@
+package gml
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+func TestEncodeFeature(t *testing.T) {
+	ft := testFeatureType()
+	feat := &api.GeojsonFeatureData{
+		ID:    "7",
+		Geom:  geojson.NewGeometry(orb.Point{1.5, -2}),
+		Props: map[string]interface{}{"name": "a < b", "value": nil, "valid": true},
+	}
+	doc := string(ft.EncodeFeature(feat))
+	checkWellFormed(t, doc)
+
+	util.Assert(t, strings.Contains(doc, `<app:test.table gml:id="test.table.7"`), "feature element: %v", doc)
+	util.Assert(t, strings.Contains(doc, `xsi:schemaLocation="http://test/collections/test.table http://test/collections/test.table/schema.xsd"`), "schema location: %v", doc)
+	util.Assert(t, strings.Contains(doc, `<app:geom><gml:Point gml:id="test.table.7.geom" srsName="http://www.opengis.net/def/crs/OGC/1.3/CRS84"><gml:pos>1.5 -2</gml:pos></gml:Point></app:geom>`), "geometry: %v", doc)
+	util.Assert(t, strings.Contains(doc, "<app:id>7</app:id><app:name>a &lt; b</app:name><app:valid>true</app:valid></app:test.table>"), "properties: %v", doc)
+	util.Assert(t, !strings.Contains(doc, "app:value"), "null property is encoded: %v", doc)
+}
+
+func TestEncodeCollection(t *testing.T) {
+	ft := testFeatureType()
+	ft.Srid = 3857
+	features := []*api.GeojsonFeatureData{
+		{ID: "1", Geom: geojson.NewGeometry(orb.Point{1, 2}), Props: map[string]interface{}{}},
+		{ID: "2", Props: map[string]interface{}{"name": "no geometry"}},
+	}
+	coll := &Collection{
+		NumberMatched: -1,
+		TimeStamp:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Links:         []*api.Link{{Href: "http://test/items.xml?a=1&b=2", Rel: "self", Type: api.ContentTypeGMLSF0}},
+	}
+	doc := string(ft.EncodeCollection(coll, features))
+	checkWellFormed(t, doc)
+
+	util.Assert(t, strings.Contains(doc, `numberMatched="unknown" numberReturned="2" timeStamp="2024-01-02T03:04:05Z"`), "collection attributes: %v", doc)
+	util.Assert(t, strings.Contains(doc, `<atom:link href="http://test/items.xml?a=1&amp;b=2" rel="self"`), "link: %v", doc)
+	util.Equals(t, 2, strings.Count(doc, "<sf:featureMember>"), "# features")
+	util.Assert(t, strings.Contains(doc, `srsName="http://www.opengis.net/def/crs/EPSG/0/3857"`), "srsName: %v", doc)
+}
+
+func TestEncodeGeometry(t *testing.T) {
+	poly := orb.Polygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 0}},
+		{{1, 1}, {2, 1}, {2, 2}, {1, 1}},
+	}
+	e := &encoder{}
+	encodeGeometry(e, orb.MultiPolygon{poly}, "g", "srs")
+	doc := e.buf.String()
+	expected := `<gml:MultiSurface gml:id="g" srsName="srs"><gml:surfaceMember><gml:Polygon gml:id="g.1">` +
+		`<gml:exterior><gml:LinearRing><gml:posList>0 0 10 0 10 10 0 0</gml:posList></gml:LinearRing></gml:exterior>` +
+		`<gml:interior><gml:LinearRing><gml:posList>1 1 2 1 2 2 1 1</gml:posList></gml:LinearRing></gml:interior>` +
+		`</gml:Polygon></gml:surfaceMember></gml:MultiSurface>`
+	util.Equals(t, expected, doc, "multipolygon")
+
+	e = &encoder{}
+	encodeGeometry(e, orb.MultiLineString{{{0, 0}, {1, 1}}}, "g", "")
+	util.Equals(t, `<gml:MultiCurve gml:id="g"><gml:curveMember><gml:LineString gml:id="g.1"><gml:posList>0 0 1 1</gml:posList></gml:LineString></gml:curveMember></gml:MultiCurve>`,
+		e.buf.String(), "multilinestring")
+}
+
+func TestEncodeSchema(t *testing.T) {
+	doc := string(testFeatureType().EncodeSchema())
+	checkWellFormed(t, doc)
+
+	util.Assert(t, strings.Contains(doc, `targetNamespace="http://test/collections/test.table"`), "namespace: %v", doc)
+	util.Assert(t, strings.Contains(doc, `<xs:element name="test.table" type="app:test.tableType" substitutionGroup="gml:AbstractFeature"/>`), "feature element: %v", doc)
+	util.Assert(t, strings.Contains(doc, `<xs:element name="geom" type="gml:PointPropertyType" minOccurs="0"/>`), "geometry property: %v", doc)
+	util.Assert(t, strings.Contains(doc, `<xs:element name="id" type="xs:int" minOccurs="0"/>`+
+		`<xs:element name="name" type="xs:string" minOccurs="0"/>`+
+		`<xs:element name="valid" type="xs:boolean" minOccurs="0"/>`+
+		`<xs:element name="value" type="xs:double" minOccurs="0"/>`), "properties: %v", doc)
+	util.Assert(t, strings.Contains(doc, "<gmlsf:ComplianceLevel>0</gmlsf:ComplianceLevel>"), "compliance level: %v", doc)
+}
+
+func TestXMLName(t *testing.T) {
+	util.Equals(t, "public.table", xmlName("public.table"), "valid name")
+	util.Equals(t, "_1st_column", xmlName("1st column"), "invalid start")
+	util.Equals(t, "a_b_c", xmlName("a:b/c"), "invalid characters")
+	util.Equals(t, "_", xmlName(""), "empty name")
+	util.Equals(t, "1_2", xmlIDValue("1 2"), "id value")
+}
+
+//========================================
+
+func testFeatureType() *FeatureType {
+	tbl := &api.Table{
+		ID:             "test.table",
+		GeometryType:   "Point",
+		GeometryColumn: "geom",
+		IDColumn:       "id",
+		Srid:           4326,
+		Columns:        []string{"id", "name", "valid", "value"},
+		DbTypes: map[string]api.Column{
+			"id":    {Type: api.PGTypeInt4},
+			"name":  {Type: api.PGTypeText},
+			"valid": {Type: api.PGTypeBool},
+			"value": {Type: api.PGTypeFloat8},
+		},
+	}
+	return NewFeatureType(tbl, tbl.Columns, "http://test/collections/test.table", "http://test/collections/test.table/schema.xsd")
+}
+
+// checkWellFormed checks that a document is well-formed XML
+func checkWellFormed(t *testing.T, doc string) {
+	dec := xml.NewDecoder(bytes.NewReader([]byte(doc)))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return
+		}
+		util.Assert(t, err == nil, "invalid XML: %v\n%v", err, doc)
+	}
+}
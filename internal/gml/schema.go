@@ -0,0 +1,58 @@
+package gml
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+)
+
+// EncodeSchema encodes the GML application schema (XSD) of the feature type.
+// All properties are optional, since null values are not encoded
+func (ft *FeatureType) EncodeSchema() []byte {
+	e := &encoder{}
+	e.header()
+	e.printf(`<xs:schema xmlns:xs="%s" xmlns:gml="%s" xmlns:gmlsf="%s" xmlns:%s="%s" targetNamespace="%s" elementFormDefault="qualified" version="1.0">`,
+		NamespaceXS, NamespaceGML, NamespaceGMLSF, prefixApp, escape(ft.Namespace), escape(ft.Namespace))
+	e.printf(`<xs:annotation><xs:appinfo source="%s"><gmlsf:ComplianceLevel>0</gmlsf:ComplianceLevel></xs:appinfo></xs:annotation>`,
+		SchemaLocationGMLSF)
+	e.printf(`<xs:import namespace="%s" schemaLocation="%s"/>`, NamespaceGML, SchemaLocationGML)
+	e.printf(`<xs:element name="%s" type="%s:%sType" substitutionGroup="gml:AbstractFeature"/>`, ft.Name, prefixApp, ft.Name)
+	e.printf(`<xs:complexType name="%sType"><xs:complexContent><xs:extension base="gml:AbstractFeatureType"><xs:sequence>`, ft.Name)
+	e.printf(`<xs:element name="%s" type="%s" minOccurs="0"/>`, ft.GeometryName, geometryPropertyType(ft.GeometryType))
+	for _, col := range ft.Columns {
+		e.printf(`<xs:element name="%s" type="%s" minOccurs="0"/>`, xmlName(col), ft.Types[col].ToXSDType())
+	}
+	e.printf(`</xs:sequence></xs:extension></xs:complexContent></xs:complexType>`)
+	e.printf("</xs:schema>\n")
+	return e.buf.Bytes()
+}
+
+// geometryPropertyType is the GML property type of a geometry type
+func geometryPropertyType(geomType string) string {
+	switch geomType {
+	case api.GeometryTypePoint:
+		return "gml:PointPropertyType"
+	case api.GeometryTypeLineString:
+		return "gml:CurvePropertyType"
+	case api.GeometryTypePolygon:
+		return "gml:SurfacePropertyType"
+	case api.GeometryTypeMultiPoint:
+		return "gml:MultiPointPropertyType"
+	case api.GeometryTypeMultiLineString:
+		return "gml:MultiCurvePropertyType"
+	case api.GeometryTypeMultiPolygon:
+		return "gml:MultiSurfacePropertyType"
+	}
+	return "gml:GeometryPropertyType"
+}
@@ -0,0 +1,129 @@
+package limit
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// interval after which the API keys are reloaded, to pick up added or revoked keys
+const keysReloadInterval = 60 * time.Second
+
+// KeyLoader reads the list of valid API keys
+type KeyLoader func() ([]string, error)
+
+// KeyStore holds the valid API keys
+type KeyStore struct {
+	load     KeyLoader
+	mutex    sync.RWMutex
+	keys     map[string]bool
+	loadTime time.Time
+	// isLoading is set while the keys are reloaded
+	isLoading bool
+}
+
+// NewKeyStore creates a key store, loading the keys
+func NewKeyStore(load KeyLoader) (*KeyStore, error) {
+	store := &KeyStore{load: load}
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// reload loads the keys, and replaces the current keys with them.
+// The store is not locked while the keys are loaded
+func (store *KeyStore) reload() error {
+	list, err := store.load()
+	if err != nil {
+		return fmt.Errorf("unable to load API keys: %v", err)
+	}
+	keys := make(map[string]bool, len(list))
+	for _, key := range list {
+		keys[key] = true
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.keys = keys
+	store.loadTime = time.Now()
+	return nil
+}
+
+// IsValid tests whether a key is valid.
+// The keys are reloaded in the background when they are older than the reload interval,
+// so that requests do not wait for the reload
+func (store *KeyStore) IsValid(key string) bool {
+	store.mutex.RLock()
+	isValid := key != "" && store.keys[key]
+	isStale := time.Since(store.loadTime) > keysReloadInterval
+	store.mutex.RUnlock()
+	if isStale {
+		store.startReload()
+	}
+	return isValid
+}
+
+// startReload reloads the keys in the background, unless they are already being reloaded.
+// If this fails the current keys are kept until the next interval
+func (store *KeyStore) startReload() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.isLoading || time.Since(store.loadTime) <= keysReloadInterval {
+		return
+	}
+	store.isLoading = true
+	go func() {
+		err := store.reload()
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+		if err != nil {
+			log.Warn(err)
+			store.loadTime = time.Now()
+		}
+		store.isLoading = false
+	}()
+}
+
+// FileKeys is a loader of the keys in a file, one per line.
+// Blank lines and lines starting with # are ignored
+func FileKeys(path string) KeyLoader {
+	return func() ([]string, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseKeys(data), nil
+	}
+}
+
+func parseKeys(data []byte) []string {
+	var keys []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys
+}
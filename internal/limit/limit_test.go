@@ -0,0 +1,128 @@
+package limit
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	lim := NewMemoryLimiter(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		ok, _ := lim.allowAt("a", now)
+		util.Assert(t, ok, "request %d in burst should be allowed", i)
+	}
+	ok, wait := lim.allowAt("a", now)
+	util.Assert(t, !ok, "request after burst should be rejected")
+	util.Equals(t, 500*time.Millisecond, wait, "wait for next token")
+
+	// other keys have their own bucket
+	ok, _ = lim.allowAt("b", now)
+	util.Assert(t, ok, "other key should be allowed")
+
+	// tokens are refilled at the rate
+	ok, _ = lim.allowAt("a", now.Add(500*time.Millisecond))
+	util.Assert(t, ok, "request after refill should be allowed")
+	ok, _ = lim.allowAt("a", now.Add(500*time.Millisecond))
+	util.Assert(t, !ok, "request after refilled token should be rejected")
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	lim := NewMemoryLimiter(1, 1)
+	now := time.Now()
+	lim.allowAt("a", now)
+	lim.allowAt("b", now.Add(sweepInterval-time.Second))
+	util.Equals(t, 2, len(lim.buckets), "# buckets before sweep")
+	lim.allowAt("b", now.Add(sweepInterval+time.Second))
+	util.Equals(t, 1, len(lim.buckets), "# buckets after sweep")
+}
+
+func TestKeyStore(t *testing.T) {
+	keys := []string{"k1", "k2"}
+	store, err := NewKeyStore(func() ([]string, error) { return keys, nil })
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	util.Assert(t, store.IsValid("k1"), "k1 should be valid")
+	util.Assert(t, !store.IsValid("k3"), "k3 should not be valid")
+	util.Assert(t, !store.IsValid(""), "blank key should not be valid")
+
+	// keys are reloaded in the background after the interval
+	keys = []string{"k3"}
+	store.mutex.Lock()
+	store.loadTime = time.Now().Add(-2 * keysReloadInterval)
+	store.mutex.Unlock()
+	util.Assert(t, store.IsValid("k1"), "k1 should be valid until reloaded")
+	waitReload(t, store)
+	util.Assert(t, store.IsValid("k3"), "k3 should be valid after reload")
+	util.Assert(t, !store.IsValid("k1"), "k1 should be revoked after reload")
+
+	_, err = NewKeyStore(func() ([]string, error) { return nil, fmt.Errorf("no keys") })
+	util.AssertIsError(t, err, "load error")
+}
+
+// checks that keys are checked while they are reloaded
+func TestKeyStoreSlowReload(t *testing.T) {
+	release := make(chan struct{})
+	isLoaded := false
+	store, err := NewKeyStore(func() ([]string, error) {
+		if isLoaded {
+			<-release
+			return []string{"k2"}, nil
+		}
+		isLoaded = true
+		return []string{"k1"}, nil
+	})
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+
+	store.mutex.Lock()
+	store.loadTime = time.Now().Add(-2 * keysReloadInterval)
+	store.mutex.Unlock()
+	done := make(chan bool)
+	go func() {
+		done <- store.IsValid("k1") && store.IsValid("k1")
+	}()
+	select {
+	case isValid := <-done:
+		util.Assert(t, isValid, "k1 should be valid while reloading")
+	case <-time.After(time.Second):
+		t.Fatal("key check blocked by reload")
+	}
+	close(release)
+	waitReload(t, store)
+	util.Assert(t, store.IsValid("k2"), "k2 should be valid after reload")
+}
+
+// waitReload waits for the background reload of a key store to end
+func waitReload(t *testing.T, store *KeyStore) {
+	for i := 0; i < 100; i++ {
+		store.mutex.RLock()
+		isLoading := store.isLoading
+		store.mutex.RUnlock()
+		if !isLoading {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("keys not reloaded")
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("# comment\nk1\n\n  k2  \n"))
+	util.Equals(t, []string{"k1", "k2"}, keys, "keys")
+}
@@ -0,0 +1,100 @@
+package limit
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Token bucket rate limiting: each client key has a bucket of Burst tokens,
+// refilled at Rate tokens per second. A request takes a token, and is rejected if there is none
+
+import (
+	"sync"
+	"time"
+)
+
+// interval between removals of idle buckets
+const sweepInterval = 60 * time.Second
+
+// Limiter limits the rate of requests by client key
+type Limiter interface {
+	// Allow takes a token for a client key.
+	// If there is none it returns false, and the time until a token is available
+	Allow(key string) (bool, time.Duration)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryLimiter holds the buckets in memory, for a single service instance
+type MemoryLimiter struct {
+	rate      float64
+	burst     float64
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter creates a limiter allowing rate requests per second, with bursts of burst requests
+func NewMemoryLimiter(rate float64, burst int) *MemoryLimiter {
+	return &MemoryLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (lim *MemoryLimiter) Allow(key string) (bool, time.Duration) {
+	return lim.allowAt(key, time.Now())
+}
+
+func (lim *MemoryLimiter) allowAt(key string, now time.Time) (bool, time.Duration) {
+	lim.mutex.Lock()
+	defer lim.mutex.Unlock()
+	lim.sweep(now)
+
+	b, ok := lim.buckets[key]
+	if !ok {
+		b = &bucket{tokens: lim.burst, last: now}
+		lim.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * lim.rate
+	if b.tokens > lim.burst {
+		b.tokens = lim.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, secondsDuration((1 - b.tokens) / lim.rate)
+}
+
+// sweep removes the buckets which have been idle long enough to be full
+func (lim *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(lim.lastSweep) < sweepInterval {
+		return
+	}
+	lim.lastSweep = now
+	fillTime := secondsDuration(lim.burst / lim.rate)
+	for key, b := range lim.buckets {
+		if now.Sub(b.last) > fillTime {
+			delete(lim.buckets, key)
+		}
+	}
+}
+
+func secondsDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
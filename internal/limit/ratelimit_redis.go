@@ -0,0 +1,91 @@
+package limit
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	log "github.com/sirupsen/logrus"
+)
+
+const redisKeyPrefix = "pg_featureserv:ratelimit:"
+
+// redisDB is the Redis database of the buckets. It is not the database of the etag cache (1),
+// which is scanned, counted and flushed as a whole
+const redisDB = 2
+
+// scriptTakeToken updates a bucket and takes a token atomically.
+// It returns whether a token was taken, and the seconds until one is available
+var scriptTakeToken = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = (1 - tokens) / rate
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(burst / rate) + 1)
+return {allowed, tostring(wait)}
+`)
+
+// RedisLimiter holds the buckets in Redis, so that limits are shared by service instances
+type RedisLimiter struct {
+	client *redis.Client
+	rate   float64
+	burst  int
+}
+
+// NewRedisLimiter creates a limiter allowing rate requests per second, with bursts of burst requests
+func NewRedisLimiter(addr string, password string, rate float64, burst int) (*RedisLimiter, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       redisDB,
+	})
+	if _, err := client.Ping(context.Background()).Result(); err != nil {
+		return nil, fmt.Errorf("redis connection error: %s", err.Error())
+	}
+	return &RedisLimiter{client: client, rate: rate, burst: burst}, nil
+}
+
+// Allow takes a token from the bucket of a key.
+// Requests are allowed if Redis cannot be reached, so that an outage does not stop the service
+func (lim *RedisLimiter) Allow(key string) (bool, time.Duration) {
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	res, err := scriptTakeToken.Run(context.Background(), lim.client, []string{redisKeyPrefix + key},
+		lim.rate, lim.burst, strconv.FormatFloat(now, 'f', 3, 64)).Slice()
+	if err != nil || len(res) != 2 {
+		log.Warnf("Rate limit error: %v", err)
+		return true, 0
+	}
+	if allowed, _ := res[0].(int64); allowed == 1 {
+		return true, 0
+	}
+	waitStr, _ := res[1].(string)
+	wait, _ := strconv.ParseFloat(waitStr, 64)
+	return false, secondsDuration(wait)
+}
@@ -0,0 +1,130 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/auth"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	headerAuthorization   = "Authorization"
+	headerWWWAuthenticate = "WWW-Authenticate"
+	authSchemeBearer      = "Bearer"
+)
+
+// authVerifier verifies bearer tokens. Authentication is disabled if it is nil
+var authVerifier *auth.Verifier
+
+// initAuth creates the token verifier from the configured JWKS
+func initAuth(confAuth conf.Auth) {
+	authVerifier = nil
+	if !confAuth.IsEnabled() {
+		if conf.Current().Database.AllowWrite {
+			if confAuth.AllowAnonymousWrite {
+				log.Warn("Database.AllowWrite and Auth.AllowAnonymousWrite are set but no Auth JWKS is configured: write requests are not authenticated")
+			} else {
+				log.Warn("Database.AllowWrite is set but no Auth JWKS is configured: write requests are rejected unless Auth.AllowAnonymousWrite is set")
+			}
+		}
+		return
+	}
+	verifier, err := auth.NewVerifier(auth.Options{
+		JwksFile: confAuth.JwksFile,
+		JwksURL:  confAuth.JwksUrl,
+		Issuer:   confAuth.Issuer,
+		Audience: confAuth.Audience,
+	})
+	if err != nil {
+		log.Fatalf("Unable to initialize authentication: %v", err)
+	}
+	authVerifier = verifier
+	log.Info("Authentication of bearer tokens is enabled")
+}
+
+// SetAuthVerifier sets the verifier for bearer tokens (nil disables authentication)
+func SetAuthVerifier(verifier *auth.Verifier) {
+	authVerifier = verifier
+}
+
+// AuthHandler verifies the bearer token of a request and adds its claims to the request context,
+// along with the database session of the user.
+// Requests without a token are passed on unauthenticated;
+// routes which require authentication are wrapped by requireAuth
+func AuthHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if authVerifier == nil || token == "" {
+			if anonRole := conf.Current().Auth.AnonRole; anonRole != "" {
+				r = r.WithContext(data.WithSession(r.Context(), &data.Session{Role: anonRole}))
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+		claims, err := authVerifier.Verify(token)
+		if err != nil {
+			log.Debugf("Rejected token: %v", err)
+			w.Header().Set(headerWWWAuthenticate, authSchemeBearer+` error="invalid_token"`)
+			http.Error(w, fmt.Sprintf(api.ErrMsgInvalidToken, err), http.StatusUnauthorized)
+			return
+		}
+		session := userSession(claims, conf.Current().Auth.RoleClaim)
+		log.Debugf("Authenticated subject: %v (role: %v)", claims.Subject(), session.Role)
+		ctx := auth.WithClaims(r.Context(), claims)
+		ctx = data.WithSession(ctx, session)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// userSession is the database session of an authenticated user.
+// The role is read from the role claim; the connection role is used if there is none
+func userSession(claims auth.Claims, roleClaim string) *data.Session {
+	claimsJSON, _ := json.Marshal(claims)
+	return &data.Session{
+		Role:   claims.String(roleClaim),
+		Claims: string(claimsJSON),
+	}
+}
+
+// bearerToken extracts the token of an Authorization header with the Bearer scheme
+func bearerToken(r *http.Request) string {
+	val := r.Header.Get(headerAuthorization)
+	if len(val) <= len(authSchemeBearer) || !strings.EqualFold(val[:len(authSchemeBearer)+1], authSchemeBearer+" ") {
+		return ""
+	}
+	return strings.TrimSpace(val[len(authSchemeBearer)+1:])
+}
+
+// requireAuth rejects requests which are not authenticated.
+// If authentication is not enabled, requests are rejected unless anonymous writes are allowed
+func requireAuth(handler func(http.ResponseWriter, *http.Request) *appError) func(http.ResponseWriter, *http.Request) *appError {
+	return func(w http.ResponseWriter, r *http.Request) *appError {
+		if authVerifier == nil && !conf.Current().Auth.AllowAnonymousWrite {
+			return &appError{nil, api.ErrMsgWriteNotAuthenticated, http.StatusForbidden}
+		}
+		if authVerifier != nil && auth.ClaimsFromContext(r.Context()) == nil {
+			w.Header().Set(headerWWWAuthenticate, authSchemeBearer)
+			return appErrorUnauthorized(nil, api.ErrMsgUnauthorized)
+		}
+		return handler(w, r)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"net/http"
+
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+)
+
+// headerConsistencyToken carries the read-your-writes token:
+// it is returned by writes, and sent by clients with their next reads
+// so that they are not served by a replica which has not replayed the write yet
+const headerConsistencyToken = "X-Consistency-Token"
+
+// ConsistencyHandler adds the consistency token sent by the client to the request context
+func ConsistencyHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consistency := &data.Consistency{Token: r.Header.Get(headerConsistencyToken)}
+		h.ServeHTTP(w, r.WithContext(data.WithConsistency(r.Context(), consistency)))
+	})
+}
+
+// writeConsistencyToken returns the consistency token set by a write, if any
+func writeConsistencyToken(w http.ResponseWriter, r *http.Request) {
+	consistency := data.ConsistencyFromContext(r.Context())
+	if consistency != nil && consistency.Token != "" {
+		w.Header().Set(headerConsistencyToken, consistency.Token)
+	}
+}
@@ -0,0 +1,110 @@
+package db_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// a table with a nullable column, and a time column with sub-second values
+const sqlCreateCursorTable = `DROP TABLE IF EXISTS public.mock_cursor;
+	CREATE TABLE public.mock_cursor (
+		id int PRIMARY KEY,
+		geometry public.geometry(Point, 4326) NOT NULL,
+		prop_n int,
+		prop_t timestamptz NOT NULL
+	);
+	INSERT INTO public.mock_cursor
+	SELECT i, ST_SetSRID(ST_MakePoint(i, i), 4326),
+		CASE WHEN i % 3 = 0 THEN NULL ELSE i % 4 END,
+		'2020-01-01 00:00:00+00'::timestamptz + (i % 5) * interval '1.123456 second'
+	FROM generate_series(1, 10) AS i`
+
+// checks that keyset paging on a nullable sort column returns all features in order, in both directions
+func (t *DbTests) TestPagingCursorNullsDb() {
+	t.Test.Run("TestPagingCursorNullsDb", func(t *testing.T) {
+		createCursorTable(t)
+		defer dropCursorTable(t)
+
+		checkCursorPaging(t, "prop_n", "SELECT id FROM public.mock_cursor ORDER BY prop_n ASC NULLS LAST, id ASC")
+		checkCursorPaging(t, "-prop_n", "SELECT id FROM public.mock_cursor ORDER BY prop_n DESC NULLS FIRST, id DESC")
+	})
+}
+
+// checks that keyset paging on a time column returns all features in order
+func (t *DbTests) TestPagingCursorTimeDb() {
+	t.Test.Run("TestPagingCursorTimeDb", func(t *testing.T) {
+		createCursorTable(t)
+		defer dropCursorTable(t)
+
+		checkCursorPaging(t, "prop_t", "SELECT id FROM public.mock_cursor ORDER BY prop_t, id")
+		checkCursorPaging(t, "-prop_t", "SELECT id FROM public.mock_cursor ORDER BY prop_t DESC, id DESC")
+	})
+}
+
+// checkCursorPaging follows the next links of the items sorted by a column,
+// which must all use a cursor, and checks the features against the ids returned by a query
+func checkCursorPaging(t *testing.T, sortBy string, sqlExpected string) {
+	conf.Configuration.Paging.UseCursor = true
+	defer func() { conf.Configuration.Paging.UseCursor = false }()
+
+	var expected []string
+	rows, err := db.Query(context.Background(), sqlExpected)
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		expected = append(expected, id)
+	}
+	rows.Close()
+
+	var ids []string
+	path := "/collections/mock_cursor/items?limit=3&sortby=" + sortBy
+	for page := 0; path != "" && page < 10; page++ {
+		var v api.FeatureCollection
+		err := json.Unmarshal(hTest.ReadBody(hTest.DoRequest(t, path)), &v)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		for _, feat := range v.Features {
+			ids = append(ids, feat.ID)
+		}
+		path = ""
+		for _, link := range v.Links {
+			if link.Rel == api.RelNext {
+				util.Assert(t, strings.Contains(link.Href, "cursor="), "next link must contain a cursor: %v", link.Href)
+				path = strings.TrimPrefix(link.Href, hTest.UrlBase)
+			}
+		}
+	}
+	util.Equals(t, expected, ids, "feature ids sorted by "+sortBy)
+}
+
+func createCursorTable(t *testing.T) {
+	_, err := db.Exec(context.Background(), sqlCreateCursorTable)
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	cat.Reload(nil, nil)
+}
+
+func dropCursorTable(t *testing.T) {
+	_, err := db.Exec(context.Background(), "DROP TABLE IF EXISTS public.mock_cursor")
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	cat.Reload(nil, nil)
+}
@@ -0,0 +1,72 @@
+package db_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// a table with times around the day 2020-01-01, in the session time zone
+const sqlCreateDateTimeTable = `DROP TABLE IF EXISTS public.mock_datetime;
+	CREATE TABLE public.mock_datetime (
+		id int PRIMARY KEY,
+		geometry public.geometry(Point, 4326) NOT NULL,
+		prop_t timestamptz NOT NULL
+	);
+	INSERT INTO public.mock_datetime VALUES
+		(1, 'SRID=4326;POINT(1 1)', '2019-12-31 23:00:00'),
+		(2, 'SRID=4326;POINT(2 2)', '2020-01-01 00:00:00'),
+		(3, 'SRID=4326;POINT(3 3)', '2020-01-01 12:00:00'),
+		(4, 'SRID=4326;POINT(4 4)', '2020-01-01 23:59:59.5'),
+		(5, 'SRID=4326;POINT(5 5)', '2020-01-02 00:00:00')`
+
+// checks that a date matches the times of the whole day, as an instant or as the end of an interval
+func (t *DbTests) TestDateTimeDateDb() {
+	t.Test.Run("TestDateTimeDateDb", func(t *testing.T) {
+		_, err := db.Exec(context.Background(), sqlCreateDateTimeTable)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		cat.Reload(nil, nil)
+		defer func() {
+			_, err := db.Exec(context.Background(), "DROP TABLE IF EXISTS public.mock_datetime")
+			util.Assert(t, err == nil, "unexpected error: %v", err)
+			cat.Reload(nil, nil)
+		}()
+
+		checkDateTimeIds(t, "2020-01-01", []string{"2", "3", "4"})
+		checkDateTimeIds(t, "2019-12-31/2020-01-01", []string{"1", "2", "3", "4"})
+		checkDateTimeIds(t, "../2020-01-01", []string{"1", "2", "3", "4"})
+		checkDateTimeIds(t, "2020-01-01/..", []string{"2", "3", "4", "5"})
+
+		hTest.DoRequestStatus(t, "/collections/mock_datetime/items?datetime=2020-01-02/2020-01-01", http.StatusBadRequest)
+	})
+}
+
+// checkDateTimeIds checks the ids of the features matching a datetime value
+func checkDateTimeIds(t *testing.T, datetime string, expected []string) {
+	var v api.FeatureCollection
+	path := "/collections/mock_datetime/items?sortby=id&datetime=" + datetime
+	err := json.Unmarshal(hTest.ReadBody(hTest.DoRequest(t, path)), &v)
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	ids := []string{}
+	for _, feat := range v.Features {
+		ids = append(ids, feat.ID)
+	}
+	util.Equals(t, expected, ids, "feature ids for datetime "+datetime)
+}
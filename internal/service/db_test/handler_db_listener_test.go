@@ -18,6 +18,7 @@ package db_test
 */
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -26,6 +27,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/CrunchyData/pg_featureserv/internal/api"
 	"github.com/CrunchyData/pg_featureserv/internal/data"
 	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
 	"github.com/paulmach/orb"
@@ -282,3 +284,69 @@ func (t *DbTests) TestMultipleNotificationAfterCreate() {
 		util.Assert(t, sizeAfter > sizeBefore, fmt.Sprintf("cache size augmented after one insert: %d should > %d", sizeAfter, sizeBefore))
 	})
 }
+
+// checks that the catalog is reloaded after tables are created, altered or dropped
+func (t *DbTests) TestCatalogReloadAfterDDL() {
+	t.Test.Run("TestCatalogReloadAfterDDL", func(t *testing.T) {
+		_, err := db.Exec(context.Background(), `
+			DROP TABLE IF EXISTS public.mock_ddl;
+			CREATE TABLE public.mock_ddl (id serial PRIMARY KEY, prop_a text, geom geometry(Point, 4326));
+			INSERT INTO public.mock_ddl (prop_a, geom) VALUES ('a', ST_SetSRID(ST_MakePoint(1, 2), 4326));
+		`)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		// Sleep in order to wait for the catalog to reload (parallel goroutine)
+		time.Sleep(200 * time.Millisecond)
+		hTest.DoRequestStatus(t, "/collections/mock_ddl/items/1", http.StatusOK)
+
+		//--- the new table has a trigger updating the cache
+		sizeBefore := cat.GetCache().Size()
+		_, err = db.Exec(context.Background(), `INSERT INTO public.mock_ddl (prop_a, geom) VALUES ('b', ST_SetSRID(ST_MakePoint(3, 4), 4326))`)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		time.Sleep(100 * time.Millisecond)
+		util.Assert(t, cat.GetCache().Size() > sizeBefore, "cache size augmented after insert in new table")
+
+		//--- a new column is served
+		_, err = db.Exec(context.Background(), `ALTER TABLE public.mock_ddl ADD COLUMN prop_b int DEFAULT 7`)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		time.Sleep(200 * time.Millisecond)
+		rr := hTest.DoRequestStatus(t, "/collections/mock_ddl/items/1", http.StatusOK)
+		var v map[string]interface{}
+		errUnMarsh := json.Unmarshal(rr.Body.Bytes(), &v)
+		util.Assert(t, errUnMarsh == nil, "%s", errUnMarsh)
+		props := v["properties"].(map[string]interface{})
+		util.Equals(t, float64(7), props["prop_b"], "new column value")
+
+		//--- a dropped table is not served
+		_, err = db.Exec(context.Background(), `DROP TABLE public.mock_ddl`)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		time.Sleep(200 * time.Millisecond)
+		hTest.DoRequestStatus(t, "/collections/mock_ddl/items/1", http.StatusNotFound)
+	})
+}
+
+// checks that the tables are read while they are reloaded (run with -race to detect unguarded accesses)
+func (t *DbTests) TestCatalogReloadConcurrentReads() {
+	t.Test.Run("TestCatalogReloadConcurrentReads", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 5; i++ {
+				cat.Reload(nil, nil)
+			}
+		}()
+		for {
+			select {
+			case <-done:
+				hTest.DoRequestStatus(t, "/collections/mock_a/items/1", http.StatusOK)
+				return
+			default:
+			}
+			tables, err := cat.Tables()
+			util.Assert(t, err == nil, "unexpected error: %v", err)
+			util.Assert(t, len(tables) > 0, "tables expected while reloading")
+			_, err = cat.TableByName("mock_a")
+			util.Assert(t, err == nil, "unexpected error: %v", err)
+			util.Assert(t, cat.Health(context.Background())[api.HealthCatalog].Status == api.HealthStatusOK, "catalog health while reloading")
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package db_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	"github.com/CrunchyData/pg_featureserv/internal/service"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// checks that reads fall back to the primary when no replica is available
+func (t *DbTests) TestReplicaFallbackDb() {
+	t.Test.Run("TestReplicaFallbackDb", func(t *testing.T) {
+		params := data.QueryParam{Limit: 100000, Offset: 0, Crs: 4326}
+		features, err := cat.TableFeatures(context.Background(), "mock_b", &params)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Equals(t, 100, len(features), "# features")
+
+		// a token the replica has not replayed also falls back to the primary
+		ctx := data.WithConsistency(context.Background(), &data.Consistency{Token: "FFFFFFFF/FFFFFFFF"})
+		features, err = cat.TableFeatures(ctx, "mock_b", &params)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Equals(t, 100, len(features), "# features with consistency token")
+	})
+}
+
+// checks that writes return a consistency token, and that reads accept it
+func (t *DbTests) TestReplicaConsistencyTokenDb() {
+	t.Test.Run("TestReplicaConsistencyTokenDb", func(t *testing.T) {
+		handler := service.ConsistencyHandler(hTest.Router)
+
+		req, _ := http.NewRequest("DELETE", hTest.BasePath+"/collections/mock_b/items/1", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		util.Equals(t, http.StatusNoContent, rr.Code, "delete status")
+		token := rr.Header().Get("X-Consistency-Token")
+		util.Assert(t, strings.Contains(token, "/"), "WAL position expected as consistency token, got '%v'", token)
+
+		req, _ = http.NewRequest("GET", hTest.BasePath+"/collections/mock_b/items/2", nil)
+		req.Header.Set("X-Consistency-Token", token)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		util.Equals(t, http.StatusOK, rr.Code, "read with consistency token")
+		util.Equals(t, "", rr.Header().Get("X-Consistency-Token"), "no consistency token for reads")
+	})
+}
@@ -0,0 +1,82 @@
+package db_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+const sessionTestRole = "pgfs_test_user"
+
+// checks that queries of a request session run with its role, and that row-level security applies to its claims
+func (t *DbTests) TestSessionRowLevelSecurityDb() {
+	t.Test.Run("TestSessionRowLevelSecurityDb", func(t *testing.T) {
+		_, err := db.Exec(context.Background(), `
+			DO $$ BEGIN
+				IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = '`+sessionTestRole+`') THEN
+					CREATE ROLE `+sessionTestRole+` NOLOGIN;
+				END IF;
+			END $$;
+			GRANT `+sessionTestRole+` TO CURRENT_USER;
+			GRANT SELECT ON public.mock_b TO `+sessionTestRole+`;
+			ALTER TABLE public.mock_b ENABLE ROW LEVEL SECURITY;
+			CREATE POLICY mock_b_max ON public.mock_b FOR SELECT TO `+sessionTestRole+`
+				USING (prop_b <= (current_setting('`+data.SettingClaims+`', true)::json->>'max_b')::int);
+		`)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+
+		params := data.QueryParam{Limit: 100000, Offset: 0, Crs: 4326}
+		features, err := cat.TableFeatures(context.Background(), "mock_b", &params)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Equals(t, 100, len(features), "# features without session")
+
+		ctx := data.WithSession(context.Background(), &data.Session{Role: sessionTestRole, Claims: `{"max_b":10}`})
+		features, err = cat.TableFeatures(ctx, "mock_b", &params)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Equals(t, 10, len(features), "# features visible to session")
+
+		_, err = cat.TableFeatures(ctx, "mock_a", &params)
+		util.Assert(t, data.IsPermissionError(err), "permission error expected, got: %v", err)
+	})
+}
+
+// checks that the tables of a request session are those its role can select
+func (t *DbTests) TestSessionTableVisibilityDb() {
+	t.Test.Run("TestSessionTableVisibilityDb", func(t *testing.T) {
+		_, err := db.Exec(context.Background(), `
+			GRANT SELECT ON public.mock_b TO `+sessionTestRole+`;
+			REVOKE SELECT ON public.mock_a FROM `+sessionTestRole+`;
+		`)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+
+		ctx := data.WithSession(context.Background(), &data.Session{Role: sessionTestRole})
+		tables, err := cat.TablesForSession(ctx)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		isVisible := make(map[string]bool)
+		for _, tbl := range tables {
+			isVisible[tbl.ID] = true
+		}
+		util.Assert(t, isVisible["public.mock_b"], "mock_b should be visible")
+		util.Assert(t, !isVisible["public.mock_a"], "mock_a should not be visible")
+
+		tbl, err := cat.TableForSession(ctx, "mock_a")
+		util.Assert(t, err == nil && tbl == nil, "mock_a should not be found")
+		tbl, err = cat.TableForSession(ctx, "mock_b")
+		util.Assert(t, err == nil && tbl != nil, "mock_b should be found")
+	})
+}
@@ -0,0 +1,109 @@
+package db_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// the second source publishes a single table of the test database
+const (
+	sourceName       = "second"
+	sourceTable      = "public.mock_source"
+	sourceCollection = sourceName + api.SourceSeparator + sourceTable
+)
+
+const sqlCreateSourceTable = `
+	DROP TABLE IF EXISTS public.mock_source;
+	CREATE TABLE public.mock_source (id serial PRIMARY KEY, prop_a text, geom geometry(Point, 4326));
+	INSERT INTO public.mock_source (prop_a, geom) VALUES
+		('a', ST_SetSRID(ST_MakePoint(1, 2), 4326)),
+		('b', ST_SetSRID(ST_MakePoint(3, 4), 4326));
+`
+
+// checks that the tables of a named source are listed, read and written by their source id,
+// and that the listener of the source updates the cache of its collections
+func (t *DbTests) TestSourceDb() {
+	t.Test.Run("TestSourceDb", func(t *testing.T) {
+		//--- only the included table of the source is listed
+		rr := hTest.DoRequest(t, "/collections")
+		var colls api.CollectionsInfo
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &colls)
+		util.Assert(t, errUnMarsh == nil, "%v", errUnMarsh)
+		names := make(map[string]bool)
+		for _, coll := range colls.Collections {
+			names[coll.Name] = true
+		}
+		util.Assert(t, names[sourceCollection], "source collection must be listed")
+		util.Assert(t, !names[sourceName+api.SourceSeparator+"public.mock_a"], "table not included in the source must not be listed")
+
+		tbl, err := cat.TableByName(sourceCollection)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Equals(t, sourceName, tbl.Source, "table source")
+		util.Equals(t, sourceTable, tbl.DbID(), "table DbID")
+
+		//--- features are read by the source id, with or without the public schema
+		path := "/collections/" + sourceCollection
+		checkItem(t, sourceCollection, 1)
+		hTest.DoRequestStatus(t, "/collections/"+sourceName+api.SourceSeparator+"mock_source/items/2", http.StatusOK)
+		hTest.DoRequestStatus(t, "/collections/other"+api.SourceSeparator+sourceTable+"/items/1", http.StatusNotFound)
+
+		//--- writes go to the table of the source, and are notified to its listener
+		header := make(http.Header)
+		header.Add("Content-Type", api.ContentTypeGeoJSON)
+		jsonStr := `{
+			"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [5, 6]},
+			"properties": {"prop_a": "POST"}
+		}`
+		rr = hTest.DoPostRequest(t, path+"/items", []byte(jsonStr), header)
+		loc := rr.Header().Get("Location")
+		util.Assert(t, strings.Contains(loc, path+"/items/3"), "Header location must contain the source collection id, got '%v'", loc)
+		checkItem(t, sourceCollection, 3)
+
+		// Sleep in order to wait for the cache to update (parallel goroutine)
+		time.Sleep(100 * time.Millisecond)
+		removed, err := cat.GetCache().RemoveCollectionEtags(sourceCollection)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Assert(t, removed > 0, "cache entries of the source collection expected after insert")
+
+		jsonStr = `{
+			"type": "Feature",
+			"id": "3",
+			"geometry": {"type": "Point", "coordinates": [7, 8]},
+			"properties": {"prop_a": "PUT"}
+		}`
+		hTest.DoRequestMethodStatus(t, "PUT", path+"/items/3", []byte(jsonStr), header, http.StatusNoContent)
+		var propA string
+		err = db.QueryRow(context.Background(), "SELECT prop_a FROM public.mock_source WHERE id = 3").Scan(&propA)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Equals(t, "PUT", propA, "replaced property")
+
+		time.Sleep(100 * time.Millisecond)
+		removed, err = cat.GetCache().RemoveCollectionEtags(sourceCollection)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Assert(t, removed > 0, "cache entries of the source collection expected after update")
+
+		hTest.DoDeleteRequestStatus(t, path+"/items/3", http.StatusNoContent)
+		hTest.DoRequestStatus(t, path+"/items/3", http.StatusNotFound)
+	})
+}
@@ -167,6 +167,31 @@ func (t *DbTests) TestGetCrs() {
 	})
 }
 
+// EPSG:4326 coordinates are in latitude/longitude order
+func (t *DbTests) TestGetCrsLatLon() {
+	t.Test.Run("TestGetCrsLatLon", func(t *testing.T) {
+		var lonLat, latLon api.FeatureCollection
+		rr := hTest.DoRequest(t, "/collections/mock_a/items?limit=1&crs=4326")
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &lonLat)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		rr = hTest.DoRequest(t, "/collections/mock_a/items?limit=1&crs=EPSG:4326")
+		errUnMarsh = json.Unmarshal(hTest.ReadBody(rr), &latLon)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		util.Equals(t, "<"+api.CrsURIEPSG4326+">", rr.Header().Get(api.HeaderContentCrs), "Content-Crs")
+
+		pt := lonLat.Features[0].Geom.Geometry().(orb.Point)
+		util.Equals(t, orb.Point{pt.Y(), pt.X()}, latLon.Features[0].Geom.Geometry().(orb.Point), "feature 1 coordinates")
+
+		//-- bbox in latitude/longitude order
+		bbox := fmt.Sprintf("%v,%v,%v,%v", pt.Y()-0.1, pt.X()-0.1, pt.Y()+0.1, pt.X()+0.1)
+		rr = hTest.DoRequest(t, "/collections/mock_a/items?limit=1&bbox-crs=EPSG:4326&bbox="+bbox)
+		var inBbox api.FeatureCollection
+		errUnMarsh = json.Unmarshal(hTest.ReadBody(rr), &inBbox)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		util.Equals(t, 1, len(inBbox.Features), "# features in bbox")
+	})
+}
+
 func (t *DbTests) TestGetWrongCrs() {
 	t.Test.Run("TestGetWrongCrs", func(t *testing.T) {
 		hTest.DoRequestStatus(t, "/collections/mock_a/items?limit=2&crs=3", http.StatusBadRequest)
@@ -0,0 +1,75 @@
+package db_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// views have no row versions (xmin)
+const sqlCreateTileView = `CREATE OR REPLACE VIEW public.mock_view AS SELECT * FROM public.mock_a`
+
+// checks the etag of the tiles of a table, computed from the feature versions
+func (t *DbTests) TestTileTableDb() {
+	t.Test.Run("TestTileTableDb", func(t *testing.T) {
+		path := "/collections/public.mock_a/tiles/WebMercatorQuad/0/0/0"
+		resp := hTest.DoRequest(t, path)
+		util.Equals(t, api.ContentTypeMVT, resp.Header().Get("Content-Type"), "Content-Type")
+		util.Assert(t, len(hTest.ReadBody(resp)) > 0, "tile must not be empty")
+		etag := resp.Header().Get("Etag")
+		util.Assert(t, etag != "", "Etag header missing")
+
+		header := make(http.Header)
+		header.Add("If-None-Match", "\""+etag+"\"")
+		hTest.DoRequestMethodStatus(t, "GET", path, nil, header, http.StatusNotModified)
+
+		// the etag changes when a feature of the tile changes
+		_, err := db.Exec(context.Background(), "UPDATE public.mock_a SET prop_a = 'changed' WHERE id = 1")
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		hTest.DoRequestMethodStatus(t, "GET", path, nil, header, http.StatusOK)
+	})
+}
+
+// checks that the tiles of a view are served, with an etag computed from the tile data
+func (t *DbTests) TestTileViewDb() {
+	t.Test.Run("TestTileViewDb", func(t *testing.T) {
+		_, err := db.Exec(context.Background(), sqlCreateTileView)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		cat.Reload(nil, nil)
+		defer func() {
+			_, err := db.Exec(context.Background(), "DROP VIEW IF EXISTS public.mock_view")
+			util.Assert(t, err == nil, "unexpected error: %v", err)
+			cat.Reload(nil, nil)
+		}()
+
+		path := "/collections/public.mock_view/tiles/WebMercatorQuad/0/0/0"
+		resp := hTest.DoRequest(t, path)
+		util.Equals(t, api.ContentTypeMVT, resp.Header().Get("Content-Type"), "Content-Type")
+		util.Assert(t, len(hTest.ReadBody(resp)) > 0, "tile must not be empty")
+		etag := resp.Header().Get("Etag")
+		util.Assert(t, etag != "", "Etag header missing")
+
+		header := make(http.Header)
+		header.Add("If-None-Match", "\""+etag+"\"")
+		hTest.DoRequestMethodStatus(t, "GET", path, nil, header, http.StatusNotModified)
+
+		// the features of a view are served too
+		hTest.DoRequest(t, "/collections/public.mock_view/items")
+	})
+}
@@ -0,0 +1,115 @@
+package db_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+	"github.com/jackc/pgconn"
+)
+
+// SQLSTATE of query_canceled errors, raised by statement timeouts and cancel requests
+const pgCodeQueryCanceled = "57014"
+
+// default statement timeout of the request queries of the tests,
+// set before the connection pools are created
+const testStatementTimeoutSec = 2
+
+// a view taking half a second for each of the 9 rows of mock_a
+const sqlCreateSlowView = `CREATE OR REPLACE VIEW public.mock_slow AS
+	SELECT * FROM public.mock_a WHERE pg_sleep(0.5)::text = ''`
+
+const sqlSlowQueries = `SELECT count(*) FROM pg_stat_activity
+	WHERE state = 'active' AND query LIKE '%mock_slow%' AND pid <> pg_backend_pid()`
+
+// checks that the statement timeout set for a collection stops its queries in the database
+func (t *DbTests) TestStatementTimeoutDb() {
+	t.Test.Run("TestStatementTimeoutDb", func(t *testing.T) {
+		createSlowView(t)
+		defer dropSlowView(t)
+		conf.Configuration.Database.StatementTimeouts = []conf.TableStatementTimeout{{Table: "public.mock_slow", TimeoutSec: 1}}
+		defer func() { conf.Configuration.Database.StatementTimeouts = nil }()
+
+		params := data.QueryParam{Limit: 100000, Offset: 0, Crs: 4326}
+		start := time.Now()
+		_, err := cat.TableFeatures(context.Background(), "mock_slow", &params)
+		util.Assert(t, isQueryCanceled(err), "query_canceled error expected, got: %v", err)
+		util.Assert(t, time.Since(start) < 4*time.Second, "query should stop after the timeout, took %v", time.Since(start))
+	})
+}
+
+// checks that the default statement timeout stops the request queries,
+// but not the catalog queries, such as the extent of a table
+func (t *DbTests) TestDefaultStatementTimeoutDb() {
+	t.Test.Run("TestDefaultStatementTimeoutDb", func(t *testing.T) {
+		createSlowView(t)
+		defer dropSlowView(t)
+
+		params := data.QueryParam{Limit: 100000, Offset: 0, Crs: 4326}
+		start := time.Now()
+		_, err := cat.TableFeatures(context.Background(), "mock_slow", &params)
+		util.Assert(t, isQueryCanceled(err), "query_canceled error expected, got: %v", err)
+		util.Assert(t, time.Since(start) < 4*time.Second, "query should stop after the timeout, took %v", time.Since(start))
+
+		// the exact extent of the view is read by a catalog query, running longer than the timeout
+		cat.TableReload("mock_slow")
+		tbl, err := cat.TableByName("mock_slow")
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Assert(t, tbl.Extent.Maxx > tbl.Extent.Minx, "extent expected, got %v", tbl.Extent)
+	})
+}
+
+// checks that cancelling a request cancels its query in the database
+func (t *DbTests) TestQueryCancelDb() {
+	t.Test.Run("TestQueryCancelDb", func(t *testing.T) {
+		createSlowView(t)
+		defer dropSlowView(t)
+
+		params := data.QueryParam{Limit: 100000, Offset: 0, Crs: 4326}
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		_, err := cat.TableFeatures(ctx, "mock_slow", &params)
+		util.Assert(t, err != nil, "error expected for a cancelled request")
+
+		// the cancel request is handled asynchronously by the database
+		time.Sleep(200 * time.Millisecond)
+		var count int
+		err = db.QueryRow(context.Background(), sqlSlowQueries).Scan(&count)
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		util.Equals(t, 0, count, "# queries still running")
+	})
+}
+
+func createSlowView(t *testing.T) {
+	_, err := db.Exec(context.Background(), sqlCreateSlowView)
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	cat.Reload(nil, nil)
+}
+
+func dropSlowView(t *testing.T) {
+	_, err := db.Exec(context.Background(), "DROP VIEW IF EXISTS public.mock_slow")
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	cat.Reload(nil, nil)
+}
+
+func isQueryCanceled(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgCodeQueryCanceled
+}
@@ -17,6 +17,7 @@ package db_test
 */
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -48,9 +49,24 @@ type DbTests struct {
 func TestMain(m *testing.M) {
 	conf.InitConfig("", false) // getting default configuration
 	conf.Configuration.Database.AllowWrite = true
+	conf.Configuration.Auth.AllowAnonymousWrite = true
+	conf.Configuration.Database.StatementTimeoutSec = testStatementTimeoutSec
 
 	log.Debug("init : Db/Service")
 	db = util.CreateTestDb()
+	// the test database is also set as its own read replica: it is not in recovery,
+	// so reads fall back to it, and writes return consistency tokens
+	conf.Configuration.Database.ReplicaConnections = []string{conf.Configuration.Database.DbConnection}
+	// the test database is also published as a named source, restricted to one table
+	_, err := db.Exec(context.Background(), sqlCreateSourceTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf.Configuration.Database.Sources = []conf.DataSource{{
+		Name:          sourceName,
+		DbConnection:  conf.Configuration.Database.DbConnection,
+		TableIncludes: []string{sourceTable},
+	}}
 
 	cat = data.CatDBInstance()
 	service.SetCatalogInstance(cat)
@@ -80,6 +96,7 @@ func TestRunnerHandlerDb(t *testing.T) {
 		test.TestGetAllForAnyGeometryTable()
 		test.TestGetFormatHandlingSuffix()
 		test.TestGetCrs()
+		test.TestGetCrsLatLon()
 		test.TestGetWrongCrs()
 		afterEachRun()
 	})
@@ -150,6 +167,9 @@ func TestRunnerHandlerDb(t *testing.T) {
 		test.TestCacheSizeDecreaseAfterDelete()
 		test.TestCacheModifiedAfterUpdate()
 		test.TestMultipleNotificationAfterCreate()
+		test.TestCatalogReloadAfterDDL()
+		test.TestCatalogReloadConcurrentReads()
+		test.TestSourceDb()
 		afterEachRun()
 	})
 	t.Run("HEADER-IF-NON-MATCH", func(t *testing.T) {
@@ -179,6 +199,54 @@ func TestRunnerHandlerDb(t *testing.T) {
 		afterEachRun()
 	})
 
+	t.Run("SESSION", func(t *testing.T) {
+		beforeEachRun()
+		test := DbTests{Test: t}
+		test.TestSessionRowLevelSecurityDb()
+		test.TestSessionTableVisibilityDb()
+		afterEachRun()
+	})
+
+	t.Run("REPLICA", func(t *testing.T) {
+		beforeEachRun()
+		test := DbTests{Test: t}
+		test.TestReplicaFallbackDb()
+		test.TestReplicaConsistencyTokenDb()
+		afterEachRun()
+	})
+
+	t.Run("TILES", func(t *testing.T) {
+		beforeEachRun()
+		test := DbTests{Test: t}
+		test.TestTileTableDb()
+		test.TestTileViewDb()
+		afterEachRun()
+	})
+
+	t.Run("TIMEOUT", func(t *testing.T) {
+		beforeEachRun()
+		test := DbTests{Test: t}
+		test.TestStatementTimeoutDb()
+		test.TestDefaultStatementTimeoutDb()
+		test.TestQueryCancelDb()
+		afterEachRun()
+	})
+
+	t.Run("CURSOR", func(t *testing.T) {
+		beforeEachRun()
+		test := DbTests{Test: t}
+		test.TestPagingCursorNullsDb()
+		test.TestPagingCursorTimeDb()
+		afterEachRun()
+	})
+
+	t.Run("DATETIME", func(t *testing.T) {
+		beforeEachRun()
+		test := DbTests{Test: t}
+		test.TestDateTimeDateDb()
+		afterEachRun()
+	})
+
 	t.Run("SPECIAL_SCHEMA_TABLE_COLUMN", func(t *testing.T) {
 		beforeEachRun()
 		test := DbTests{Test: t}
@@ -200,6 +268,10 @@ func beforeRun() {
 func afterRun() {
 	log.Debug("afterRun")
 	cat.Close()
+	_, err := db.Exec(context.Background(), "DROP TABLE IF EXISTS "+sourceTable)
+	if err != nil {
+		log.Warn(err)
+	}
 	// close Db
 	util.CloseTestDb(db)
 }
@@ -23,9 +23,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -45,6 +47,8 @@ const (
 	routeVarFunctionID   = "funid"
 	routeVarStrongEtag   = "etag"
 	routeOptionalFormat  = "{fmt:(?:\\.[a-zA-Z]+)?}"
+
+	routeNameStreamPrefix = "stream:"
 )
 
 func InitRouter(basePath string) *mux.Router {
@@ -67,28 +71,59 @@ func InitRouter(basePath string) *mux.Router {
 
 	addRoute(router, "/conformance"+routeOptionalFormat, handleConformance)
 
+	addRoute(router, routeHealthLive, handleHealthLive)
+	addRoute(router, routeHealthReady, handleHealthReady)
+
 	addRoute(router, "/collections"+routeOptionalFormat, handleCollections)
 
 	addRoute(router, "/collections/{cid}"+routeOptionalFormat, handleCollection)
 
-	addRoute(router, "/collections/{cid}/items"+routeOptionalFormat, handleCollectionItems)
+	addStreamRoute(router, "/collections/{cid}/items"+routeOptionalFormat, handleCollectionItems)
+
+	addStreamFilterRoute(router, "/collections/{cid}/items"+routeOptionalFormat, handleCollectionItemsFilter)
+
+	addRoute(router, "/collections/{cid}/schema.xsd", handleCollectionSchemaGML)
 
-	if conf.Configuration.Database.AllowWrite {
-		addRouteWithMethod(router, "/collections/{cid}/items", handleCreateCollectionItem, "POST")
-		addRouteWithMethod(router, "/collections/{cid}/items/{fid}", handleDeleteCollectionItem, "DELETE")
-		addRouteWithMethod(router, "/collections/{cid}/items/{fid}"+routeOptionalFormat, handleItem, "PATCH")
-		addRouteWithMethod(router, "/collections/{cid}/items/{fid}"+routeOptionalFormat, handleItem, "PUT")
+	addRoute(router, "/collections/{cid}/queryables", handleCollectionQueryables)
+
+	addRoute(router, "/collections/{cid}/sortables", handleCollectionSortables)
+
+	if conf.Current().Database.AllowWrite {
+		addRouteWithMethod(router, "/collections/{cid}/items", requireAuth(handleCreateCollectionItem), "POST")
+		addRouteWithMethod(router, "/collections/{cid}/items/{fid}", requireAuth(handleDeleteCollectionItem), "DELETE")
+		addRouteWithMethod(router, "/collections/{cid}/items/{fid}"+routeOptionalFormat, requireAuth(handleItem), "PATCH")
+		addRouteWithMethod(router, "/collections/{cid}/items/{fid}"+routeOptionalFormat, requireAuth(handleItem), "PUT")
 
 		addRoute(router, "/collections/{cid}/schema"+routeOptionalFormat, handleCollectionSchemas)
 	}
 
 	addRoute(router, "/collections/{cid}/items/{fid}"+routeOptionalFormat, handleItem)
 
+	addRoute(router, "/collections/{cid}/tiles"+routeOptionalFormat, handleCollectionTilesets)
+
+	addRoute(router, "/collections/{cid}/tiles/{tms}"+routeOptionalFormat, handleCollectionTileset)
+
+	addRoute(router, "/collections/{cid}/tiles/{tms}/{z:[0-9]+}/{x:[0-9]+}/{y:[0-9]+}"+routeOptionalTileFormat, handleCollectionTile)
+
+	addRoute(router, "/tileMatrixSets"+routeOptionalFormat, handleTileMatrixSets)
+
+	addRoute(router, "/tileMatrixSets/{tms}"+routeOptionalFormat, handleTileMatrixSet)
+
 	addRoute(router, "/functions"+routeOptionalFormat, handleFunctions)
 
 	addRoute(router, "/functions/{funid}", handleFunction)
 
-	addRoute(router, "/functions/{funid}/items", handleFunctionItems)
+	addStreamRoute(router, "/functions/{funid}/items"+routeOptionalFormat, handleFunctionItems)
+
+	addStreamFilterRoute(router, "/functions/{funid}/items"+routeOptionalFormat, handleFunctionItemsFilter)
+
+	addRoute(router, "/functions/{funid}/queryables", handleFunctionQueryables)
+
+	addRoute(router, "/functions/{funid}/sortables", handleFunctionSortables)
+
+	if conf.Current().Server.EnableMetrics {
+		addRoute(router, routeMetrics, handleMetrics)
+	}
 
 	return router
 }
@@ -101,6 +136,24 @@ func addRouteWithMethod(router *mux.Router, path string, handler func(http.Respo
 	router.Handle(path, appHandler(handler)).Methods(method)
 }
 
+// addStreamRoute adds a GET route whose JSON responses are streamed.
+// The route is named so that the handler chain can recognize it
+func addStreamRoute(router *mux.Router, path string, handler func(http.ResponseWriter, *http.Request) *appError) {
+	router.Handle(path, appHandler(handler)).Methods("GET").Name(routeNameStreamPrefix + path)
+}
+
+// isStreamRequest tests whether a request is served by a streamed route
+func isStreamRequest(router *mux.Router, r *http.Request) bool {
+	var match mux.RouteMatch
+	if !router.Match(r, &match) || match.Route == nil {
+		return false
+	}
+	if !strings.HasPrefix(match.Route.GetName(), routeNameStreamPrefix) {
+		return false
+	}
+	return api.RequestedFormat(r) == api.FormatJSON
+}
+
 //nolint:unused
 func handleRootJSON(w http.ResponseWriter, r *http.Request) *appError {
 	return doRoot(w, r, api.FormatJSON)
@@ -116,7 +169,7 @@ func doRoot(w http.ResponseWriter, r *http.Request, format string) *appError {
 	urlBase := serveURLBase(r)
 
 	// --- create content
-	content := api.NewRootInfo(&conf.Configuration)
+	content := api.NewRootInfo(conf.Current())
 
 	switch format {
 	case api.FormatHTML:
@@ -199,7 +252,7 @@ func handleCollections(w http.ResponseWriter, r *http.Request) *appError {
 	format := api.RequestedFormat(r)
 	urlBase := serveURLBase(r)
 
-	colls, err := catalogInstance.Tables()
+	colls, err := catalogInstance.TablesForSession(r.Context())
 	if err != nil {
 		return appErrorInternal(err, api.ErrMsgLoadCollections)
 	}
@@ -265,6 +318,14 @@ func linksCollection(name string, urlBase string, isSummary bool) []*api.Link {
 		Type:  api.ContentTypeGeoJSON,
 		Title: api.TitleFeaturesGeoJSON})
 
+	links = append(links, &api.Link{
+		Href:  urlPath(urlBase, api.PathCollectionTilesets(name)),
+		Rel:   api.RelTilesetsVector,
+		Type:  api.ContentTypeJSON,
+		Title: api.TitleTilesets})
+
+	links = append(links, linksPropertiesSchemas(urlBase, path)...)
+
 	return links
 }
 
@@ -276,7 +337,7 @@ func handleCollection(w http.ResponseWriter, r *http.Request) *appError {
 	// it may be an issue if the schema name is provided here
 	name := getRequestVarStrip(routeVarCollectionID, format, r)
 
-	tbl, err1 := catalogInstance.TableByName(name)
+	tbl, err1 := catalogInstance.TableForSession(r.Context(), name)
 	if err1 != nil {
 		return appErrorInternal(err1, api.ErrMsgCollectionAccess, name)
 	}
@@ -315,7 +376,7 @@ func handleCollectionSchemas(w http.ResponseWriter, r *http.Request) *appError {
 
 	//--- extract request parameters
 	name := getRequestVar(routeVarCollectionID, r)
-	tbl, err1 := catalogInstance.TableByName(name)
+	tbl, err1 := catalogInstance.TableForSession(r.Context(), name)
 	if err1 != nil {
 		return appErrorInternal(err1, api.ErrMsgCollectionAccess, name)
 	}
@@ -364,7 +425,7 @@ func handleCreateCollectionItem(w http.ResponseWriter, r *http.Request) *appErro
 	}
 
 	//--- check feature availability
-	tbl, err1 := catalogInstance.TableByName(name)
+	tbl, err1 := catalogInstance.TableForSession(r.Context(), name)
 	if err1 != nil {
 		return appErrorInternal(err1, api.ErrMsgCollectionAccess, name)
 	}
@@ -391,7 +452,10 @@ func handleCreateCollectionItem(w http.ResponseWriter, r *http.Request) *appErro
 	}
 
 	//--- get crs header
-	crs := r.Header.Get("Content-Crs")
+	crs, errCrs := requestContentCrs(r)
+	if errCrs != nil {
+		return errCrs
+	}
 
 	newId, err2 := catalogInstance.AddTableFeature(r.Context(), name, bodyContent, crs)
 	if err2 != nil {
@@ -402,6 +466,7 @@ func handleCreateCollectionItem(w http.ResponseWriter, r *http.Request) *appErro
 	}
 
 	w.Header().Set("Location", fmt.Sprintf("%scollections/%s/items/%d", urlBase, name, newId))
+	writeConsistencyToken(w, r)
 	w.WriteHeader(http.StatusCreated)
 	return nil
 }
@@ -426,7 +491,7 @@ func handleDeleteCollectionItem(w http.ResponseWriter, r *http.Request) *appErro
 	}
 
 	//--- check collection availability
-	tbl, err1 := catalogInstance.TableByName(name)
+	tbl, err1 := catalogInstance.TableForSession(r.Context(), name)
 	if err1 != nil {
 		return appErrorInternal(err1, api.ErrMsgCollectionAccess, name)
 	}
@@ -438,6 +503,7 @@ func handleDeleteCollectionItem(w http.ResponseWriter, r *http.Request) *appErro
 	if err2 != nil {
 		return appErrorNotFound(err2, api.ErrMsgFeatureNotFound, fid)
 	}
+	writeConsistencyToken(w, r)
 	w.WriteHeader(http.StatusNoContent)
 	return nil
 
@@ -446,7 +512,6 @@ func handleDeleteCollectionItem(w http.ResponseWriter, r *http.Request) *appErro
 func handleCollectionItems(w http.ResponseWriter, r *http.Request) *appError {
 	// "/collections/{id}/items"
 	format := api.RequestedFormat(r)
-	urlBase := serveURLBase(r)
 	query := api.URLQuery(r.URL)
 
 	//--- extract request parameters
@@ -456,22 +521,36 @@ func handleCollectionItems(w http.ResponseWriter, r *http.Request) *appError {
 		return appErrorBadRequest(err, err.Error())
 	}
 
-	tbl, err1 := catalogInstance.TableByName(name)
+	tbl, err1 := catalogInstance.TableForSession(r.Context(), name)
 	if err1 != nil {
 		return appErrorInternal(err1, api.ErrMsgCollectionAccess, name)
 	}
 	if tbl == nil {
 		return appErrorNotFound(err1, api.ErrMsgCollectionNotFound, name)
 	}
-	param, errQuery := createQueryParams(&reqParam, tbl.Columns, tbl.Srid)
+	param, errQuery := createQueryParams(r.Context(), &reqParam, tbl.Columns, tbl.Srid)
 	param.Filter = parseFilter(reqParam.Values, tbl.DbTypes)
 	if errQuery == nil {
+		if err := setDateTimeParam(param, &reqParam, tbl.TimeColumns); err != nil {
+			return appErrorBadRequest(err, err.Error())
+		}
+		if err := setKeysetParams(param, &reqParam, tbl.IDColumn); err != nil {
+			return appErrorBadRequest(err, err.Error())
+		}
 		ctx := r.Context()
+		page := newItemsPage(r, api.PathCollectionItems(name), param, tbl.IDColumn)
+		setContentCrs(w, format, param.Crs, param.CrsLatLon)
 		switch format {
 		case api.FormatJSON:
-			return writeItemsJSON(ctx, w, name, param, urlBase)
+			return writeItemsJSON(ctx, w, name, param, page)
 		case api.FormatHTML:
-			return writeItemsHTML(w, tbl, name, query, urlBase)
+			return writeItemsHTML(w, tbl, name, query, page)
+		case api.FormatFlatGeobuf:
+			return writeItemsFlatGeobuf(ctx, w, tbl, param)
+		case api.FormatCSV:
+			return writeItemsCSV(ctx, w, tbl, param)
+		case api.FormatXML:
+			return writeItemsGML(ctx, w, tbl, param, page)
 		default:
 			return appErrorNotAcceptable(nil, api.ErrMsgNotSupportedFormat, format)
 		}
@@ -607,8 +686,9 @@ func getUpdateItemSchema(ctx context.Context, table *api.Table) (openapi3.Schema
 	return featureInfoSchema, nil
 }
 
-func writeItemsHTML(w http.ResponseWriter, tbl *api.Table, name string, query string, urlBase string) *appError {
+func writeItemsHTML(w http.ResponseWriter, tbl *api.Table, name string, query string, page *itemsPage) *appError {
 
+	urlBase := page.urlBase
 	pathItems := api.PathCollectionItems(name)
 	// --- encoding
 	context := ui.NewPageData()
@@ -621,41 +701,263 @@ func writeItemsHTML(w http.ResponseWriter, tbl *api.Table, name string, query st
 	context.Title = tbl.Title
 	context.IDColumn = tbl.IDColumn
 	context.ShowFeatureLink = true
+	page.setPageURLs(context)
 
 	// features are not needed for items page (page queries for them)
 	return writeHTML(w, nil, context, ui.PageItems())
 }
 
-func writeItemsJSON(ctx context.Context, w http.ResponseWriter, name string, param *data.QueryParam, urlBase string) *appError {
+func writeItemsJSON(ctx context.Context, w http.ResponseWriter, name string, param *data.QueryParam, page *itemsPage) *appError {
+	//--- count matched features
+	if isMatched, isEstimate := numberMatchedMode(); isMatched {
+		numMatched, err := catalogInstance.TableFeaturesMatched(ctx, name, param, isEstimate)
+		if err != nil {
+			return appErrorItemsRead(err, name, param.Crs)
+		}
+		page.numMatched = numMatched
+		page.isEstimate = isEstimate
+	}
+
 	//--- query features data
-	features, err := catalogInstance.TableFeatures(ctx, name, param)
+	iter, err := catalogInstance.TableFeaturesIterator(ctx, name, param)
 	if err != nil {
-		if strings.Contains(err.Error(), fmt.Sprintf("SRID (%v)", param.Crs)) {
-			return appErrorBadRequest(err, api.ErrMsgWrongCrs, strconv.Itoa(param.Crs))
-		}
-		return appErrorInternal(err, api.ErrMsgDataReadError, name)
+		return appErrorItemsRead(err, name, param.Crs)
 	}
-	if features == nil {
+	if iter == nil {
 		return appErrorNotFound(err, api.ErrMsgCollectionNotFound, name)
 	}
+	defer iter.Close()
 
-	//--- assemble resonse
-	content := api.NewFeatureCollectionInfo(features)
-	content.Links = linksItems(name, urlBase)
+	// read ahead the first feature, so query errors
+	// are still reported with an error status
+	hasFeature := iter.Next()
+	if err := iter.Err(); err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
 
-	return writeJSON(w, api.ContentTypeGeoJSON, content)
+	//--- stream response
+	content := api.NewFeatureCollectionInfo(nil)
+	content.Links = linksItems(page.path, page.urlBase)
+
+	return writeFeaturesStream(w, iter, hasFeature, content, page)
 }
 
-func linksItems(name string, urlBase string) []*api.Link {
-	path := api.PathCollectionItems(name)
+// setContentCrs sets the header for the CRS of the feature geometries in a response
+func setContentCrs(w http.ResponseWriter, format string, crs int, isLatLon bool) {
+	if format == api.FormatHTML {
+		return
+	}
+	w.Header().Set(api.HeaderContentCrs, "<"+api.CrsURIAxis(crs, isLatLon)+">")
+}
 
+// requestContentCrs is the SRID of the feature geometries in a request body, or blank if not specified
+func requestContentCrs(r *http.Request) (string, *appError) {
+	hdr := r.Header.Get(api.HeaderContentCrs)
+	if hdr == "" {
+		return "", nil
+	}
+	srid, err := api.ParseCrs(hdr)
+	if err != nil {
+		return "", appErrorBadRequest(err, api.ErrMsgWrongCrs, hdr)
+	}
+	//-- GeoJSON bodies are in longitude/latitude order
+	if api.IsCrsLatLon(hdr) {
+		return "", appErrorBadRequest(nil, api.ErrMsgCrsLatLon, api.HeaderContentCrs, hdr)
+	}
+	return strconv.Itoa(srid), nil
+}
+
+// appErrorItemsRead maps an error reading features to an error response
+func appErrorItemsRead(err error, name string, crs int) *appError {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &appError{err, api.ErrMsgRequestTimeout, http.StatusServiceUnavailable}
+	}
+	if strings.Contains(err.Error(), fmt.Sprintf("SRID (%v)", crs)) {
+		return appErrorBadRequest(err, api.ErrMsgWrongCrs, strconv.Itoa(crs))
+	}
+	return appErrorInternal(err, api.ErrMsgDataReadError, name)
+}
+
+func linksItems(path string, urlBase string) []*api.Link {
 	var links []*api.Link
 	links = append(links, linkSelf(urlBase, path, api.TitleDocument))
 	links = append(links, linkAlt(urlBase, path, api.TitleDocument))
+	links = append(links, &api.Link{
+		Href:  urlPathFormat(urlBase, path, api.FormatFlatGeobuf),
+		Rel:   api.RelAlt,
+		Type:  api.ContentTypeFlatGeobuf,
+		Title: api.TitleDocument + api.TitleAsFlatGeobuf})
 
 	return links
 }
 
+// itemsPage describes the page of features requested by an items query.
+// It provides the links to the next and previous pages
+type itemsPage struct {
+	urlBase string
+	path    string
+	query   url.Values
+	offset  int
+	limit   int
+	// numMatched is the number of features matched by the query, or -1 if unknown
+	numMatched int
+	// isEstimate is set if numMatched is estimated by the query planner
+	isEstimate bool
+	// keysetColumns are set if the next page is requested with a cursor
+	keysetColumns []string
+	idColumn      string
+	isCursor      bool
+}
+
+func newItemsPage(r *http.Request, path string, param *data.QueryParam, idColumn string) *itemsPage {
+	return &itemsPage{
+		urlBase:       serveURLBase(r),
+		path:          path,
+		query:         r.URL.Query(),
+		offset:        param.Offset,
+		limit:         param.Limit,
+		numMatched:    -1,
+		keysetColumns: param.KeysetColumns,
+		idColumn:      idColumn,
+		isCursor:      param.Cursor != nil,
+	}
+}
+
+// numberMatchedMode returns whether numberMatched is provided for items,
+// and whether it is estimated
+func numberMatchedMode() (bool, bool) {
+	switch strings.ToLower(conf.Current().Paging.NumberMatched) {
+	case conf.NumberMatchedExact:
+		return true, false
+	case conf.NumberMatchedEstimate:
+		return true, true
+	}
+	return false, false
+}
+
+// hasNext tests whether there are features after the page.
+// If the number of features matched is unknown or estimated,
+// a full page is assumed to be followed by another one
+func (page *itemsPage) hasNext(numReturned int) bool {
+	if page.limit <= 0 {
+		return false
+	}
+	// the position of a page requested with a cursor is unknown
+	if page.numMatched >= 0 && !page.isEstimate && !page.isCursor {
+		return page.offset+numReturned < page.numMatched
+	}
+	return numReturned >= page.limit
+}
+
+func (page *itemsPage) hasPrev() bool {
+	return page.offset > 0 && page.limit > 0
+}
+
+func (page *itemsPage) prevOffset() int {
+	offset := page.offset - page.limit
+	if offset < 0 {
+		offset = 0
+	}
+	return offset
+}
+
+// urlOffset provides the URL of the page at the given offset,
+// preserving all other query parameters
+func (page *itemsPage) urlOffset(format string, offset int) string {
+	query := page.queryWithout(api.ParamOffset)
+	if offset > 0 {
+		query.Set(api.ParamOffset, strconv.Itoa(offset))
+	}
+	return page.urlQuery(format, query)
+}
+
+// urlCursor provides the URL of the page starting after the cursor,
+// preserving all other query parameters
+func (page *itemsPage) urlCursor(format string, cursor *api.Cursor) string {
+	query := page.queryWithout(api.ParamOffset, api.ParamCursor)
+	query.Set(api.ParamCursor, cursor.ToEncodedString())
+	return page.urlQuery(format, query)
+}
+
+// queryWithout copies the request query parameters, except for the given names
+func (page *itemsPage) queryWithout(names ...string) url.Values {
+	query := url.Values{}
+	for key, vals := range page.query {
+		if isNameIn(strings.ToLower(key), names) {
+			continue
+		}
+		query[key] = vals
+	}
+	return query
+}
+
+func isNameIn(name string, names []string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (page *itemsPage) urlQuery(format string, query url.Values) string {
+	if format == "" {
+		href := urlPath(page.urlBase, page.path)
+		if len(query) > 0 {
+			href = href + "?" + query.Encode()
+		}
+		return href
+	}
+	return urlPathFormatQuery(page.urlBase, page.path, format, query.Encode())
+}
+
+// nextURL provides the URL of the next page, following the last feature of the page.
+// A cursor is used if keyset paging is active and the keyset values of the feature are known,
+// otherwise the offset is used
+func (page *itemsPage) nextURL(format string, numReturned int, last *api.GeojsonFeatureData) string {
+	if len(page.keysetColumns) > 0 && page.offset == 0 && last != nil {
+		cursor := api.MakeCursor(page.keysetColumns, page.idColumn, last)
+		if cursor != nil {
+			return page.urlCursor(format, cursor)
+		}
+	}
+	return page.urlOffset(format, page.offset+numReturned)
+}
+
+// links provides the paging links for a page containing numReturned features,
+// the last of which is provided
+func (page *itemsPage) links(numReturned int, last *api.GeojsonFeatureData) []*api.Link {
+	var links []*api.Link
+	if page.hasNext(numReturned) {
+		links = append(links, &api.Link{
+			Href:  page.nextURL("", numReturned, last),
+			Rel:   api.RelNext,
+			Type:  api.ContentTypeGeoJSON,
+			Title: api.TitleNextPage})
+	}
+	// a page requested with a cursor can not provide the previous one
+	if page.hasPrev() && !page.isCursor {
+		links = append(links, &api.Link{
+			Href:  page.urlOffset("", page.prevOffset()),
+			Rel:   api.RelPrev,
+			Type:  api.ContentTypeGeoJSON,
+			Title: api.TitlePrevPage})
+	}
+	return links
+}
+
+// setPageURLs sets the paging URLs of an HTML items page.
+// The features are loaded by the page, so the next page URL is always provided
+func (page *itemsPage) setPageURLs(context *ui.PageData) {
+	context.Limit = page.limit
+	if page.limit > 0 {
+		context.URLNext = page.urlOffset(api.FormatHTML, page.offset+page.limit)
+	}
+	if page.hasPrev() {
+		context.URLPrev = page.urlOffset(api.FormatHTML, page.prevOffset())
+	}
+}
+
 func handleItem(w http.ResponseWriter, r *http.Request) *appError {
 
 	// Parameters
@@ -672,7 +974,7 @@ func handleItem(w http.ResponseWriter, r *http.Request) *appError {
 	}
 
 	// Getting collection
-	tbl, err1 := catalogInstance.TableByName(tableName)
+	tbl, err1 := catalogInstance.TableForSession(r.Context(), tableName)
 	if err1 != nil {
 		return appErrorInternal(err1, api.ErrMsgCollectionAccess, tableName)
 	}
@@ -745,10 +1047,11 @@ func handleItem(w http.ResponseWriter, r *http.Request) *appError {
 	switch r.Method {
 	case http.MethodGet:
 		// GET
-		param, errQuery := createQueryParams(&reqParam, tbl.Columns, tbl.Srid)
+		param, errQuery := createQueryParams(r.Context(), &reqParam, tbl.Columns, tbl.Srid)
 		if errQuery != nil {
 			return appErrorBadRequest(errQuery, api.ErrMsgInvalidQuery)
 		}
+		setContentCrs(w, format, param.Crs, param.CrsLatLon)
 		switch format {
 		case api.FormatJSON:
 			return writeItemJSON(r.Context(), w, tableName, fid, param, urlBase, reqParam.Crs)
@@ -756,6 +1059,9 @@ func handleItem(w http.ResponseWriter, r *http.Request) *appError {
 		case api.FormatHTML:
 			return writeItemHTML(w, tbl, tableName, fid, query, urlBase)
 
+		case api.FormatXML:
+			return writeItemGML(r.Context(), w, tbl, fid, param, urlBase, reqParam.Crs)
+
 		default:
 			return appErrorNotAcceptable(nil, api.ErrMsgNotSupportedFormat, format)
 		}
@@ -782,7 +1088,10 @@ func handleItem(w http.ResponseWriter, r *http.Request) *appError {
 		}
 
 		// retrieve crs
-		crs := r.Header.Get("Content-Crs")
+		crs, errCrs := requestContentCrs(r)
+		if errCrs != nil {
+			return errCrs
+		}
 
 		// perform replace in database
 		err2 := catalogInstance.ReplaceTableFeature(r.Context(), tableName, fid, body, crs)
@@ -793,6 +1102,7 @@ func handleItem(w http.ResponseWriter, r *http.Request) *appError {
 			return appErrorInternal(err2, api.ErrMsgReplaceFeature, tableName)
 		}
 
+		writeConsistencyToken(w, r)
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 
@@ -821,7 +1131,10 @@ func handleItem(w http.ResponseWriter, r *http.Request) *appError {
 		}
 
 		// retrieve crs
-		crs := r.Header.Get("Content-Crs")
+		crs, errCrs := requestContentCrs(r)
+		if errCrs != nil {
+			return errCrs
+		}
 
 		// perform update in database
 		errUpdate := catalogInstance.PartialUpdateTableFeature(r.Context(), tableName, fid, body, crs)
@@ -831,6 +1144,7 @@ func handleItem(w http.ResponseWriter, r *http.Request) *appError {
 			}
 			return appErrorInternal(errUpdate, api.ErrMsgPartialUpdateFeature, tableName)
 		}
+		writeConsistencyToken(w, r)
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 
@@ -1011,6 +1325,8 @@ func linksFunction(id string, urlBase string, isSummary bool, isGeomFun bool) []
 		Rel:   "items",
 		Type:  conType,
 		Title: dataTitle})
+
+	links = append(links, linksPropertiesSchemas(urlBase, path)...)
 	return links
 }
 
@@ -1055,7 +1371,6 @@ func handleFunction(w http.ResponseWriter, r *http.Request) *appError {
 
 func handleFunctionItems(w http.ResponseWriter, r *http.Request) *appError {
 	format := api.RequestedFormat(r)
-	urlBase := serveURLBase(r)
 
 	//--- extract request parameters
 	name := data.FunctionQualifiedId(getRequestVarStrip(routeVarFunctionID, format, r))
@@ -1069,31 +1384,46 @@ func handleFunctionItems(w http.ResponseWriter, r *http.Request) *appError {
 	if fn == nil && err == nil {
 		return appErrorNotFound(err, api.ErrMsgFunctionNotFound, name)
 	}
-	param, err := createQueryParams(&reqParam, fn.OutNames, data.SRID_4326)
+	param, err := createQueryParams(r.Context(), &reqParam, fn.OutNames, data.SRID_4326)
 	if err != nil {
 		return appErrorBadRequest(err, err.Error())
 	}
+	if reqParam.Cursor != nil {
+		return appErrorBadRequest(nil, api.ErrMsgInvalidCursor, "keyset paging not available")
+	}
 	fnArgs := restrict(reqParam.Values, fn.InNames)
 	// log.Debugf("Function request args: %v ", fnArgs)
 
 	ctx := r.Context()
+	page := newItemsPage(r, api.PathFunctionItems(name), param, "")
+	if fn.IsGeometryFunction() {
+		setContentCrs(w, format, param.Crs, param.CrsLatLon)
+	}
 	switch format {
 	case api.FormatJSON:
 		if fn.IsGeometryFunction() {
-			return writeFunItemsGeoJSON(ctx, w, name, fnArgs, param, urlBase)
+			return writeFunItemsGeoJSON(ctx, w, name, fnArgs, param, page)
 		}
 		return writeFunItemsJSON(ctx, w, name, fnArgs, param)
 	case api.FormatHTML:
-		return writeFunItemsHTML(w, name, query, urlBase)
+		return writeFunItemsHTML(w, name, query, page)
 	case api.FormatText:
 		return writeFunItemsText(ctx, w, api.ContentTypeText, name, fnArgs, param)
 	case api.FormatSVG:
 		return writeFunItemsText(ctx, w, api.ContentTypeSVG, name, fnArgs, param)
+	case api.FormatFlatGeobuf:
+		if fn.IsGeometryFunction() {
+			return writeFunItemsFlatGeobuf(ctx, w, fn, fnArgs, param)
+		}
+		return appErrorNotAcceptable(nil, api.ErrMsgNotSupportedFormat, format)
+	case api.FormatCSV:
+		return writeFunItemsCSV(ctx, w, fn, fnArgs, param)
 	}
 	return nil
 }
 
-func writeFunItemsHTML(w http.ResponseWriter, name string, query string, urlBase string) *appError {
+func writeFunItemsHTML(w http.ResponseWriter, name string, query string, page *itemsPage) *appError {
+	urlBase := page.urlBase
 	fn, err1 := catalogInstance.FunctionByName(name)
 	if err1 != nil {
 		return appErrorInternal(err1, api.ErrMsgFunctionAccess, name)
@@ -1113,26 +1443,45 @@ func writeFunItemsHTML(w http.ResponseWriter, name string, query string, urlBase
 	context.Title = fn.ID
 	context.Function = fn
 	context.IDColumn = data.FunctionIDColumnName
+	page.setPageURLs(context)
 
 	// features are not needed for items page (page queries for them)
 	return writeHTML(w, nil, context, ui.PageFunctionItems())
 }
 
-func writeFunItemsGeoJSON(ctx context.Context, w http.ResponseWriter, name string, args map[string]string, param *data.QueryParam, urlBase string) *appError {
+func writeFunItemsGeoJSON(ctx context.Context, w http.ResponseWriter, name string, args map[string]string, param *data.QueryParam, page *itemsPage) *appError {
+	//--- count matched features
+	if isMatched, isEstimate := numberMatchedMode(); isMatched {
+		numMatched, err := catalogInstance.FunctionFeaturesMatched(ctx, name, args, param, isEstimate)
+		if err != nil {
+			return appErrorItemsRead(err, name, param.Crs)
+		}
+		page.numMatched = numMatched
+		page.isEstimate = isEstimate
+	}
+
 	//--- query features data
-	features, err := catalogInstance.FunctionFeatures(ctx, name, args, param)
+	iter, err := catalogInstance.FunctionFeaturesIterator(ctx, name, args, param)
 	if err != nil {
-		return appErrorInternal(err, api.ErrMsgDataReadError, name)
+		return appErrorItemsRead(err, name, param.Crs)
 	}
-	if features == nil {
+	if iter == nil {
 		return appErrorNotFound(err, api.ErrMsgNoDataRead, name)
 	}
+	defer iter.Close()
+
+	// read ahead the first feature, so query errors
+	// are still reported with an error status
+	hasFeature := iter.Next()
+	if err := iter.Err(); err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
 
-	//--- assemble resonse
-	content := api.NewFeatureCollectionInfo(features)
-	content.Links = linksItems(name, urlBase)
+	//--- stream response
+	content := api.NewFeatureCollectionInfo(nil)
+	content.Links = linksItems(page.path, page.urlBase)
 
-	return writeJSON(w, api.ContentTypeGeoJSON, content)
+	return writeFeaturesStream(w, iter, hasFeature, content, page)
 }
 
 func writeFunItemsJSON(ctx context.Context, w http.ResponseWriter, name string, args map[string]string, param *data.QueryParam) *appError {
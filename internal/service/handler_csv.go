@@ -0,0 +1,193 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkt"
+)
+
+// names of the CSV geometry columns
+const (
+	csvColumnWKT = "WKT"
+	csvColumnX   = "x"
+	csvColumnY   = "y"
+)
+
+func writeItemsCSV(ctx context.Context, w http.ResponseWriter, tbl *api.Table, param *data.QueryParam) *appError {
+	name := tbl.ID
+	iter, err := catalogInstance.TableFeaturesIterator(ctx, name, param)
+	if err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
+	if iter == nil {
+		return appErrorNotFound(err, api.ErrMsgCollectionNotFound, name)
+	}
+	isPoint := itemsGeometryType(tbl.GeometryType, param) == "Point"
+	return writeFeaturesCSV(w, iter, param.Columns, tbl.IDColumn, isPoint, name, param)
+}
+
+func writeFunItemsCSV(ctx context.Context, w http.ResponseWriter, fn *api.Function, args map[string]string, param *data.QueryParam) *appError {
+	name := fn.ID
+	if fn.IsGeometryFunction() {
+		var columns []string
+		for _, col := range param.Columns {
+			if col != fn.GeometryColumn {
+				columns = append(columns, col)
+			}
+		}
+		iter, err := catalogInstance.FunctionFeaturesIterator(ctx, name, args, param)
+		if err != nil {
+			return appErrorItemsRead(err, name, param.Crs)
+		}
+		if iter == nil {
+			return appErrorNotFound(err, api.ErrMsgNoDataRead, name)
+		}
+		return writeFeaturesCSV(w, iter, columns, data.FunctionIDColumnName, false, name, param)
+	}
+
+	//--- query data
+	rows, err := catalogInstance.FunctionData(ctx, name, args, param)
+	if err != nil {
+		return appErrorInternal(err, api.ErrMsgFunctionAccess, name)
+	}
+	if rows == nil {
+		return appErrorNotFound(err, api.ErrMsgNoDataRead, name)
+	}
+	cw := newCSVWriter(w)
+	cw.Write(param.Columns) //nolint:errcheck
+	record := make([]string, len(param.Columns))
+	for _, row := range rows {
+		for i, col := range param.Columns {
+			record[i] = csvValue(row[col])
+		}
+		cw.Write(record) //nolint:errcheck
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return appErrorInternal(err, api.ErrMsgDataWriteError, name)
+	}
+	return nil
+}
+
+// writeFeaturesCSV writes features as CSV, with a header row.
+// The geometry is written as WKT, or as x and y columns for points
+func writeFeaturesCSV(w http.ResponseWriter, iter data.FeatureIterator, columns []string, idColumn string, isPoint bool, name string, param *data.QueryParam) *appError {
+	defer iter.Close()
+
+	// read ahead the first feature, so query errors
+	// are still reported with an error status
+	hasFeature := iter.Next()
+	if err := iter.Err(); err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
+
+	span := startWriteSpan(w)
+	defer span.End()
+
+	header := append([]string{}, columns...)
+	if isPoint {
+		header = append(header, csvColumnX, csvColumnY)
+	} else {
+		header = append(header, csvColumnWKT)
+	}
+	cw := newCSVWriter(w)
+	cw.Write(header) //nolint:errcheck
+
+	record := make([]string, len(header))
+	for ; hasFeature; hasFeature = iter.Next() {
+		feat := iter.Feature()
+		for i, col := range columns {
+			if col == idColumn && feat.ID != "" {
+				record[i] = feat.ID
+			} else {
+				record[i] = csvValue(feat.Props[col])
+			}
+		}
+		var geom orb.Geometry
+		if feat.Geom != nil {
+			geom = feat.Geom.Geometry()
+		}
+		n := len(columns)
+		if isPoint {
+			record[n], record[n+1] = "", ""
+			if pt, ok := geom.(orb.Point); ok {
+				record[n] = strconv.FormatFloat(pt[0], 'f', -1, 64)
+				record[n+1] = strconv.FormatFloat(pt[1], 'f', -1, 64)
+			}
+		} else {
+			record[n] = ""
+			if geom != nil {
+				record[n] = wkt.MarshalString(geom)
+			}
+		}
+		cw.Write(record) //nolint:errcheck
+	}
+	cw.Flush()
+	// the status has already been sent,
+	// so an error can only be reported by aborting the response
+	if err := iter.Err(); err != nil {
+		abortStream(err)
+	}
+	if err := cw.Error(); err != nil {
+		return appErrorInternal(err, api.ErrMsgDataWriteError, name)
+	}
+	return nil
+}
+
+// newCSVWriter starts a CSV response, with RFC 4180 line endings
+func newCSVWriter(w http.ResponseWriter) *csv.Writer {
+	w.Header().Set("Content-Type", api.ContentTypeCSV)
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	return cw
+}
+
+// csvValue formats a property value as text.
+// Arrays and objects are written as JSON
+func csvValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case json.RawMessage:
+		return string(v)
+	}
+	kind := reflect.ValueOf(val).Kind()
+	if kind == reflect.Slice || kind == reflect.Map {
+		if text, err := json.Marshal(val); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(val)
+}
@@ -0,0 +1,98 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	"github.com/CrunchyData/pg_featureserv/internal/fgb"
+)
+
+func writeItemsFlatGeobuf(ctx context.Context, w http.ResponseWriter, tbl *api.Table, param *data.QueryParam) *appError {
+	name := tbl.ID
+	columns := make([]fgb.Column, 0, len(param.Columns))
+	for _, col := range param.Columns {
+		columns = append(columns, fgb.Column{Name: col, Type: tbl.DbTypes[col].Type})
+	}
+	writer := fgb.NewWriter(name, tbl.Title, param.Crs, itemsGeometryType(tbl.GeometryType, param), columns, tbl.IDColumn)
+
+	iter, err := catalogInstance.TableFeaturesIterator(ctx, name, param)
+	if err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
+	if iter == nil {
+		return appErrorNotFound(err, api.ErrMsgCollectionNotFound, name)
+	}
+	return writeFlatGeobuf(w, iter, writer, name, param)
+}
+
+func writeFunItemsFlatGeobuf(ctx context.Context, w http.ResponseWriter, fn *api.Function, args map[string]string, param *data.QueryParam) *appError {
+	name := fn.ID
+	types := make(map[string]api.PGType, len(fn.OutNames))
+	for i, col := range fn.OutNames {
+		types[col] = api.PGType(fn.OutDbTypes[i])
+	}
+	columns := make([]fgb.Column, 0, len(param.Columns))
+	for _, col := range param.Columns {
+		if col != fn.GeometryColumn {
+			columns = append(columns, fgb.Column{Name: col, Type: types[col]})
+		}
+	}
+	writer := fgb.NewWriter(name, "", param.Crs, "", columns, data.FunctionIDColumnName)
+
+	iter, err := catalogInstance.FunctionFeaturesIterator(ctx, name, args, param)
+	if err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
+	if iter == nil {
+		return appErrorNotFound(err, api.ErrMsgNoDataRead, name)
+	}
+	return writeFlatGeobuf(w, iter, writer, name, param)
+}
+
+// writeFlatGeobuf reads all features before writing the response,
+// since the FlatGeobuf header and spatial index precede the features
+func writeFlatGeobuf(w http.ResponseWriter, iter data.FeatureIterator, writer *fgb.Writer, name string, param *data.QueryParam) *appError {
+	defer iter.Close()
+	for iter.Next() {
+		if err := writer.Add(iter.Feature()); err != nil {
+			return appErrorInternal(err, api.ErrMsgEncoding)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
+
+	span := startWriteSpan(w)
+	defer span.End()
+
+	w.Header().Set("Content-Type", api.ContentTypeFlatGeobuf)
+	w.WriteHeader(http.StatusOK)
+	if _, err := writer.WriteTo(w); err != nil {
+		return appErrorInternal(err, api.ErrMsgDataWriteError, name)
+	}
+	return nil
+}
+
+// itemsGeometryType is the geometry type of the output features,
+// which is unknown when geometries are transformed
+func itemsGeometryType(geomType string, param *data.QueryParam) string {
+	if len(param.TransformFuns) > 0 {
+		return ""
+	}
+	return geomType
+}
@@ -0,0 +1,83 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/gorilla/mux"
+)
+
+// filter request bodies are recognized by their content type
+var routeFilterContentType = "^(" + regexp.QuoteMeta(api.ContentTypeCQL2JSON) + "|" + regexp.QuoteMeta(api.ContentTypeCQL2Text) + ")"
+
+// maximum size of a filter in a request body.
+// The filter is kept in the query of the response links, so it can not be arbitrarily large
+const maxFilterBodySize = 256 * 1024
+
+// addStreamFilterRoute adds a POST route for queries with a filter in the request body.
+// The route is named so that its responses are streamed, as for the GET route
+func addStreamFilterRoute(router *mux.Router, path string, handler func(http.ResponseWriter, *http.Request) *appError) {
+	router.Handle(path, appHandler(handler)).
+		Methods("POST").
+		HeadersRegexp("Content-Type", routeFilterContentType).
+		Name(routeNameStreamPrefix + "POST:" + path)
+}
+
+func handleCollectionItemsFilter(w http.ResponseWriter, r *http.Request) *appError {
+	// "/collections/{id}/items" with filter in body
+	if err := setFilterFromBody(w, r); err != nil {
+		return err
+	}
+	return handleCollectionItems(w, r)
+}
+
+func handleFunctionItemsFilter(w http.ResponseWriter, r *http.Request) *appError {
+	// "/functions/{id}/items" with filter in body
+	if err := setFilterFromBody(w, r); err != nil {
+		return err
+	}
+	return handleFunctionItems(w, r)
+}
+
+// setFilterFromBody sets the filter and filter-lang query parameters from the request body.
+// This allows long filter expressions, and keeps them in the response links
+func setFilterFromBody(w http.ResponseWriter, r *http.Request) *appError {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxFilterBodySize))
+	if err != nil {
+		// the body is read up to the maximum size if it is too large
+		if len(body) >= maxFilterBodySize {
+			return &appError{err, fmt.Sprintf(api.ErrMsgFilterTooLarge, maxFilterBodySize), http.StatusRequestEntityTooLarge}
+		}
+		return appErrorInternal(err, api.ErrMsgInvalidQuery)
+	}
+	filter := strings.TrimSpace(string(body))
+	if len(filter) == 0 {
+		return appErrorBadRequest(nil, api.ErrMsgInvalidParameterValue, api.ParamFilter, "")
+	}
+	lang := api.FilterLangCQL2Text
+	if strings.HasPrefix(r.Header.Get("Content-Type"), api.ContentTypeCQL2JSON) {
+		lang = api.FilterLangCQL2JSON
+	}
+	query := r.URL.Query()
+	query.Set(api.ParamFilter, filter)
+	query.Set(api.ParamFilterLang, lang)
+	r.URL.RawQuery = query.Encode()
+	return nil
+}
@@ -0,0 +1,148 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	"github.com/CrunchyData/pg_featureserv/internal/gml"
+)
+
+func handleCollectionSchemaGML(w http.ResponseWriter, r *http.Request) *appError {
+	// "/collections/{id}/schema.xsd"
+	name := getRequestVar(routeVarCollectionID, r)
+	tbl, err := catalogInstance.TableForSession(r.Context(), name)
+	if err != nil {
+		return appErrorInternal(err, api.ErrMsgCollectionAccess, name)
+	}
+	if tbl == nil {
+		return appErrorNotFound(err, api.ErrMsgCollectionNotFound, name)
+	}
+	ft := gmlFeatureType(serveURLBase(r), tbl, tbl.Columns)
+	writeResponse(w, api.ContentTypeXSD, ft.EncodeSchema())
+	return nil
+}
+
+func writeItemsGML(ctx context.Context, w http.ResponseWriter, tbl *api.Table, param *data.QueryParam, page *itemsPage) *appError {
+	name := tbl.ID
+	//--- count matched features
+	if isMatched, isEstimate := numberMatchedMode(); isMatched {
+		numMatched, err := catalogInstance.TableFeaturesMatched(ctx, name, param, isEstimate)
+		if err != nil {
+			return appErrorItemsRead(err, name, param.Crs)
+		}
+		page.numMatched = numMatched
+		page.isEstimate = isEstimate
+	}
+
+	//--- query features data
+	iter, err := catalogInstance.TableFeaturesIterator(ctx, name, param)
+	if err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
+	if iter == nil {
+		return appErrorNotFound(err, api.ErrMsgCollectionNotFound, name)
+	}
+	defer iter.Close()
+	// the number of features is written before them
+	var features []*api.GeojsonFeatureData
+	for iter.Next() {
+		features = append(features, iter.Feature())
+	}
+	if err := iter.Err(); err != nil {
+		return appErrorItemsRead(err, name, param.Crs)
+	}
+
+	ft := gmlFeatureType(page.urlBase, tbl, param.Columns)
+	ft.Srid = param.Crs
+	ft.LatLon = param.CrsLatLon
+	coll := &gml.Collection{
+		NumberMatched: page.numMatched,
+		TimeStamp:     time.Now(),
+		Links:         linksItemsGML(page, features),
+	}
+	writeResponse(w, api.ContentTypeGMLSF0, ft.EncodeCollection(coll, features))
+	return nil
+}
+
+func writeItemGML(ctx context.Context, w http.ResponseWriter, tbl *api.Table, fid string, param *data.QueryParam, urlBase string, crs int) *appError {
+	name := tbl.ID
+	//--- query data for request
+	feature, err := catalogInstance.TableFeature(ctx, name, fid, param)
+	if err != nil {
+		if strings.Contains(err.Error(), fmt.Sprintf("SRID (%v)", crs)) {
+			return appErrorBadRequest(err, api.ErrMsgWrongCrs, strconv.Itoa(crs))
+		}
+		return appErrorInternal(err, api.ErrMsgDataReadError, name)
+	}
+	if feature == nil {
+		return appErrorNotFound(nil, api.ErrMsgFeatureNotFound, fid)
+	}
+
+	ft := gmlFeatureType(urlBase, tbl, param.Columns)
+	ft.Srid = param.Crs
+	ft.LatLon = param.CrsLatLon
+
+	strongEtag := api.MakeStrongEtag(feature.WeakEtag.Collection, feature.WeakEtag.FeatureId, feature.WeakEtag.Etag,
+		feature.WeakEtag.LastModified, crs, api.FormatXML)
+	w.Header().Set("Etag", strongEtag.ToEncodedString())
+	w.Header().Set("Last-Modified", strongEtag.WeakEtagData.LastModified)
+
+	writeResponse(w, api.ContentTypeGMLSF0, ft.EncodeFeature(feature))
+	return nil
+}
+
+// gmlFeatureType describes the GML encoding of the features of a table.
+// The application schema namespace is the collection URL
+func gmlFeatureType(urlBase string, tbl *api.Table, columns []string) *gml.FeatureType {
+	namespace := urlPath(urlBase, api.PathCollection(tbl.ID))
+	schemaURL := urlPathFormat(urlBase, api.PathCollectionSchema(tbl.ID), api.FormatXSD)
+	return gml.NewFeatureType(tbl, columns, namespace, schemaURL)
+}
+
+func linksItemsGML(page *itemsPage, features []*api.GeojsonFeatureData) []*api.Link {
+	links := []*api.Link{{
+		Href:  page.urlQuery(api.FormatXML, page.query),
+		Rel:   api.RelSelf,
+		Type:  api.ContentTypeGMLSF0,
+		Title: api.TitleDocument},
+	}
+	numReturned := len(features)
+	if page.hasNext(numReturned) {
+		var last *api.GeojsonFeatureData
+		if numReturned > 0 {
+			last = features[numReturned-1]
+		}
+		links = append(links, &api.Link{
+			Href:  page.nextURL(api.FormatXML, numReturned, last),
+			Rel:   api.RelNext,
+			Type:  api.ContentTypeGMLSF0,
+			Title: api.TitleNextPage})
+	}
+	if page.hasPrev() && !page.isCursor {
+		links = append(links, &api.Link{
+			Href:  page.urlOffset(api.FormatXML, page.prevOffset()),
+			Rel:   api.RelPrev,
+			Type:  api.ContentTypeGMLSF0,
+			Title: api.TitlePrevPage})
+	}
+	return links
+}
@@ -0,0 +1,83 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+)
+
+const (
+	routeHealth      = "/health"
+	routeHealthLive  = routeHealth + "/live"
+	routeHealthReady = routeHealth + "/ready"
+)
+
+// maximum time for the readiness checks
+const healthTimeout = 5 * time.Second
+
+// handleHealthLive reports that the service is running and able to serve requests
+func handleHealthLive(w http.ResponseWriter, r *http.Request) *appError {
+	return writeHealth(w, api.NewHealth(nil))
+}
+
+// handleHealthReady reports whether the components the service depends on are available
+func handleHealthReady(w http.ResponseWriter, r *http.Request) *appError {
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+	return writeHealth(w, api.NewHealth(catalogInstance.Health(ctx)))
+}
+
+// writeHealth writes a health status, with status 503 if the service is not healthy
+func writeHealth(w http.ResponseWriter, health *api.Health) *appError {
+	encodedContent, err := json.Marshal(health)
+	if err != nil {
+		return appErrorInternal(err, api.ErrMsgEncoding)
+	}
+	status := http.StatusOK
+	if !health.IsOK() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", api.ContentTypeJSON)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, err = w.Write(encodedContent)
+	if err != nil {
+		return appErrorInternal(err, api.ErrMsgDataWriteError, "")
+	}
+	return nil
+}
+
+// healthRoute returns the health check route of a request (routeHealthLive or routeHealthReady),
+// or blank if the request is not a health check
+func healthRoute(r *http.Request) string {
+	basePath := "/" + strings.Trim(conf.Current().Server.BasePath, "/")
+	if basePath == "/" {
+		basePath = ""
+	}
+	if !strings.HasPrefix(r.URL.Path, basePath) {
+		return ""
+	}
+	switch route := r.URL.Path[len(basePath):]; route {
+	case routeHealthLive, routeHealthReady:
+		return route
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"net/http"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+)
+
+func handleCollectionQueryables(w http.ResponseWriter, r *http.Request) *appError {
+	// "/collections/{id}/queryables"
+	return writeCollectionPropertiesSchema(w, r, api.TagQueryables)
+}
+
+func handleCollectionSortables(w http.ResponseWriter, r *http.Request) *appError {
+	// "/collections/{id}/sortables"
+	return writeCollectionPropertiesSchema(w, r, api.TagSortables)
+}
+
+func handleFunctionQueryables(w http.ResponseWriter, r *http.Request) *appError {
+	// "/functions/{id}/queryables"
+	return writeFunctionPropertiesSchema(w, r, api.TagQueryables)
+}
+
+func handleFunctionSortables(w http.ResponseWriter, r *http.Request) *appError {
+	// "/functions/{id}/sortables"
+	return writeFunctionPropertiesSchema(w, r, api.TagSortables)
+}
+
+func writeCollectionPropertiesSchema(w http.ResponseWriter, r *http.Request, tag string) *appError {
+	name := getRequestVar(routeVarCollectionID, r)
+	tbl, err := catalogInstance.TableForSession(r.Context(), name)
+	if err != nil {
+		return appErrorInternal(err, api.ErrMsgCollectionAccess, name)
+	}
+	if tbl == nil {
+		return appErrorNotFound(err, api.ErrMsgCollectionNotFound, name)
+	}
+	id := urlPath(serveURLBase(r), api.PathCollection(name)+"/"+tag)
+	if tag == api.TagSortables {
+		return writeJSON(w, api.ContentTypeSchemaJSON, tbl.TableSortables(id))
+	}
+	return writeJSON(w, api.ContentTypeSchemaJSON, tbl.TableQueryables(id))
+}
+
+func writeFunctionPropertiesSchema(w http.ResponseWriter, r *http.Request, tag string) *appError {
+	shortName := getRequestVar(routeVarFunctionID, r)
+	name := data.FunctionQualifiedId(shortName)
+	fn, err := catalogInstance.FunctionByName(name)
+	if err != nil {
+		return appErrorInternal(err, api.ErrMsgFunctionAccess, name)
+	}
+	if fn == nil {
+		return appErrorNotFound(err, api.ErrMsgFunctionNotFound, name)
+	}
+	id := urlPath(serveURLBase(r), api.PathFunction(shortName)+"/"+tag)
+	if tag == api.TagSortables {
+		return writeJSON(w, api.ContentTypeSchemaJSON, fn.FunctionSortables(id))
+	}
+	return writeJSON(w, api.ContentTypeSchemaJSON, fn.FunctionQueryables(id))
+}
+
+// linksPropertiesSchemas provides the links to the queryables and sortables of a collection or function
+func linksPropertiesSchemas(urlBase string, path string) []*api.Link {
+	return []*api.Link{
+		{
+			Href:  urlPath(urlBase, path+"/"+api.TagQueryables),
+			Rel:   api.RelQueryables,
+			Type:  api.ContentTypeSchemaJSON,
+			Title: api.TitleQueryables,
+		},
+		{
+			Href:  urlPath(urlBase, path+"/"+api.TagSortables),
+			Rel:   api.RelSortables,
+			Type:  api.ContentTypeSchemaJSON,
+			Title: api.TitleSortables,
+		},
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/go-http-utils/headers"
+)
+
+const (
+	routeVarTileMatrixSetID = "tms"
+	routeVarTileMatrix      = "z"
+	routeVarTileCol         = "x"
+	routeVarTileRow         = "y"
+	routeOptionalTileFormat = "{fmt:(?:\\.(?:mvt|pbf))?}"
+
+	// tiles are always in Web Mercator
+	tileSrid   = 3857
+	tileFormat = "mvt"
+)
+
+func handleTileMatrixSets(w http.ResponseWriter, r *http.Request) *appError {
+	format := api.RequestedFormat(r)
+	urlBase := serveURLBase(r)
+
+	tms := api.NewWebMercatorQuad()
+	ref := tms.NewTileMatrixSetRef()
+	ref.Links = []*api.Link{linkTilingScheme(urlBase, tms.ID)}
+	content := api.TileMatrixSets{TileMatrixSets: []*api.TileMatrixSetRef{ref}}
+
+	switch format {
+	case api.FormatJSON:
+		return writeJSON(w, api.ContentTypeJSON, content)
+	default:
+		return appErrorNotAcceptable(nil, api.ErrMsgNotSupportedFormat, format)
+	}
+}
+
+func handleTileMatrixSet(w http.ResponseWriter, r *http.Request) *appError {
+	format := api.RequestedFormat(r)
+	urlBase := serveURLBase(r)
+
+	tmsID := getRequestVarStrip(routeVarTileMatrixSetID, format, r)
+	if tmsID != api.TileMatrixSetWebMercatorQuad {
+		return appErrorNotFound(nil, api.ErrMsgTileMatrixSetNotFound, tmsID)
+	}
+	content := api.NewWebMercatorQuad()
+	content.Links = []*api.Link{linkSelf(urlBase, api.PathTileMatrixSet(tmsID), api.TitleTilingScheme)}
+
+	switch format {
+	case api.FormatJSON:
+		return writeJSON(w, api.ContentTypeJSON, content)
+	default:
+		return appErrorNotAcceptable(nil, api.ErrMsgNotSupportedFormat, format)
+	}
+}
+
+func handleCollectionTilesets(w http.ResponseWriter, r *http.Request) *appError {
+	format := api.RequestedFormat(r)
+	urlBase := serveURLBase(r)
+
+	name := getRequestVar(routeVarCollectionID, r)
+	tbl, err := catalogInstance.TableForSession(r.Context(), name)
+	if err != nil {
+		return appErrorInternal(err, api.ErrMsgCollectionAccess, name)
+	}
+	if tbl == nil {
+		return appErrorNotFound(nil, api.ErrMsgCollectionNotFound, name)
+	}
+
+	tileset := api.NewTileset(tbl)
+	tileset.Links = linksTileset(name, api.TileMatrixSetWebMercatorQuad, urlBase)
+	content := api.Tilesets{
+		Tilesets: []*api.Tileset{tileset},
+		Links:    []*api.Link{linkSelf(urlBase, api.PathCollectionTilesets(name), api.TitleTilesets)},
+	}
+
+	switch format {
+	case api.FormatJSON:
+		return writeJSON(w, api.ContentTypeJSON, content)
+	default:
+		return appErrorNotAcceptable(nil, api.ErrMsgNotSupportedFormat, format)
+	}
+}
+
+func handleCollectionTileset(w http.ResponseWriter, r *http.Request) *appError {
+	format := api.RequestedFormat(r)
+	urlBase := serveURLBase(r)
+
+	name := getRequestVar(routeVarCollectionID, r)
+	tmsID := getRequestVarStrip(routeVarTileMatrixSetID, format, r)
+	if tmsID != api.TileMatrixSetWebMercatorQuad {
+		return appErrorNotFound(nil, api.ErrMsgTileMatrixSetNotFound, tmsID)
+	}
+	tbl, err := catalogInstance.TableForSession(r.Context(), name)
+	if err != nil {
+		return appErrorInternal(err, api.ErrMsgCollectionAccess, name)
+	}
+	if tbl == nil {
+		return appErrorNotFound(nil, api.ErrMsgCollectionNotFound, name)
+	}
+
+	content := api.NewTileset(tbl)
+	content.Links = linksTileset(name, tmsID, urlBase)
+
+	switch format {
+	case api.FormatJSON:
+		return writeJSON(w, api.ContentTypeJSON, content)
+	default:
+		return appErrorNotAcceptable(nil, api.ErrMsgNotSupportedFormat, format)
+	}
+}
+
+func linksTileset(name string, tmsID string, urlBase string) []*api.Link {
+	var links []*api.Link
+	links = append(links, linkSelf(urlBase, api.PathCollectionTileset(name, tmsID), api.TitleTilesets))
+	links = append(links, linkTilingScheme(urlBase, tmsID))
+	links = append(links, &api.Link{
+		Href:      urlPath(urlBase, api.PathCollectionTileTemplate(name, tmsID)),
+		Rel:       api.RelItem,
+		Type:      api.ContentTypeMVT,
+		Title:     api.TitleTileMVT,
+		Templated: true})
+	return links
+}
+
+func linkTilingScheme(urlBase string, tmsID string) *api.Link {
+	return &api.Link{
+		Href:  urlPath(urlBase, api.PathTileMatrixSet(tmsID)),
+		Rel:   api.RelTilingScheme,
+		Type:  api.ContentTypeJSON,
+		Title: api.TitleTilingScheme}
+}
+
+func handleCollectionTile(w http.ResponseWriter, r *http.Request) *appError {
+	// "/collections/{id}/tiles/{tms}/{z}/{x}/{y}"
+	name := getRequestVar(routeVarCollectionID, r)
+	tmsID := getRequestVar(routeVarTileMatrixSetID, r)
+	if tmsID != api.TileMatrixSetWebMercatorQuad {
+		return appErrorNotFound(nil, api.ErrMsgTileMatrixSetNotFound, tmsID)
+	}
+	tile, err := api.ParseTile(getRequestVar(routeVarTileMatrix, r),
+		getRequestVar(routeVarTileCol, r), getRequestVar(routeVarTileRow, r))
+	if err != nil {
+		return appErrorBadRequest(err, api.ErrMsgInvalidTile, err.Error())
+	}
+	reqParam, err := parseRequestParams(r)
+	if err != nil {
+		return appErrorBadRequest(err, err.Error())
+	}
+
+	tbl, err1 := catalogInstance.TableForSession(r.Context(), name)
+	if err1 != nil {
+		return appErrorInternal(err1, api.ErrMsgCollectionAccess, name)
+	}
+	if tbl == nil {
+		return appErrorNotFound(err1, api.ErrMsgCollectionNotFound, name)
+	}
+	param, errQuery := createQueryParams(r.Context(), &reqParam, tbl.Columns, tbl.Srid)
+	if errQuery != nil {
+		return appErrorBadRequest(errQuery, api.ErrMsgInvalidQuery)
+	}
+	param.Filter = parseFilter(reqParam.Values, tbl.DbTypes)
+	if err := setDateTimeParam(param, &reqParam, tbl.TimeColumns); err != nil {
+		return appErrorBadRequest(err, err.Error())
+	}
+
+	// the etag of a table tile is computed from the versions of its features,
+	// so that the tile is only built if the client does not have it already
+	tileID := strings.Join([]string{api.TagTiles, tmsID, tile.String()}, "/")
+	etag, err2 := catalogInstance.TableTileEtag(r.Context(), name, tile, param)
+	if err2 != nil {
+		return appErrorItemsRead(err2, name, param.Crs)
+	}
+	if etag != "" {
+		isNotModified, errEtag := writeTileEtag(w, r, name, tileID, etag)
+		if errEtag != nil || isNotModified {
+			return errEtag
+		}
+	}
+
+	//--- query data for request
+	tileData, err3 := catalogInstance.TableTile(r.Context(), name, tile, param)
+	if err3 != nil {
+		return appErrorItemsRead(err3, name, param.Crs)
+	}
+	if tileData == nil {
+		return appErrorNotFound(nil, api.ErrMsgCollectionNotFound, name)
+	}
+	isNotModified, errEtag := writeTileEtag(w, r, name, tileID, tileData.Etag)
+	if errEtag != nil || isNotModified {
+		return errEtag
+	}
+	return writeResponse(w, api.ContentTypeMVT, tileData.Data)
+}
+
+// writeTileEtag sets the Etag and Last-Modified headers of a tile,
+// and answers Not Modified if the client has the current version of the tile.
+// The date at which an etag has been seen first is kept in the cache as last modification date
+func writeTileEtag(w http.ResponseWriter, r *http.Request, name string, tileID string, etag string) (bool, *appError) {
+	weakEtag := api.MakeWeakEtag(name, tileID, etag, "")
+	cached, err := catalogInstance.GetCache().GetWeakEtag(weakEtag)
+	if err != nil {
+		return false, appErrorInternal(err, api.ErrMsgDataReadError, name)
+	}
+	if cached != nil {
+		weakEtag.LastModified = cached.LastModified
+	} else {
+		weakEtag.LastModified = api.GetCurrentHttpDate()
+		_, _ = catalogInstance.GetCache().AddWeakEtag(weakEtag.CacheKey(), weakEtag)
+	}
+	strongEtag := api.MakeStrongEtag(name, tileID, weakEtag.Etag, weakEtag.LastModified, tileSrid, tileFormat)
+	encodedStrongEtag := strongEtag.ToEncodedString()
+	w.Header().Set("Etag", encodedStrongEtag)
+	w.Header().Set("Last-Modified", weakEtag.LastModified)
+
+	if isNotModified(r, encodedStrongEtag, weakEtag.String(), weakEtag.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return true, nil
+	}
+	return false, nil
+}
+
+// isNotModified evaluates the If-None-Match and If-Modified-Since headers of a request.
+// If-Modified-Since is ignored if If-None-Match is present (RFC 7232 section 3.3)
+func isNotModified(r *http.Request, strongEtag string, weakEtag string, lastModified string) bool {
+	if ifNoneMatch := r.Header.Get(headers.IfNoneMatch); ifNoneMatch != "" {
+		return isEtagMatched(ifNoneMatch, strongEtag, weakEtag)
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get(headers.IfModifiedSince))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(ifModifiedSince)
+}
+
+// isEtagMatched tests whether an If-None-Match header value contains the current etag of a resource
+func isEtagMatched(ifNoneMatch string, strongEtag string, weakEtag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, etag := range strings.Split(ifNoneMatch, ",") {
+		etag = strings.TrimSpace(etag)
+		if etag == "*" || etag == weakEtag || strings.Trim(etag, "\"") == strongEtag {
+			return true
+		}
+	}
+	return false
+}
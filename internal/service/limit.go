@@ -0,0 +1,247 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/limit"
+	log "github.com/sirupsen/logrus"
+	"github.com/theckman/httpforwarded"
+)
+
+const (
+	headerAPIKey     = "X-API-Key"
+	paramAPIKey      = "api_key"
+	headerRetryAfter = "Retry-After"
+)
+
+// apiKeyStore holds the valid API keys. Keys are not required if it is nil
+var apiKeyStore *limit.KeyStore
+
+// rateLimiter limits the rate of requests. Requests are not limited if it is nil
+var rateLimiter limit.Limiter
+
+// trustedProxies are the networks of the proxies whose forwarding headers provide the client IP
+var trustedProxies []*net.IPNet
+
+// initLimits creates the API key store and the rate limiter from configuration.
+// API keys can be read from the database, so this is done once the catalog is set
+func initLimits() {
+	confKeys := conf.Current().ApiKeys
+	apiKeyStore = nil
+	if confKeys.IsEnabled() {
+		loader := limit.FileKeys(confKeys.File)
+		if confKeys.File == "" {
+			loader = func() ([]string, error) {
+				return catalogInstance.APIKeys(confKeys.Table, confKeys.Column)
+			}
+		}
+		store, err := limit.NewKeyStore(loader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiKeyStore = store
+		log.Info("API keys are required")
+	}
+
+	confLimit := conf.Current().RateLimit
+	if err := SetTrustedProxies(confLimit.TrustedProxies); err != nil {
+		log.Fatalf("Invalid RateLimit.TrustedProxies: %v", err)
+	}
+	rateLimiter = nil
+	if confLimit.IsEnabled() {
+		burst := confLimit.Burst
+		if burst < 1 {
+			burst = int(math.Ceil(confLimit.RequestsPerSec))
+		}
+		switch confLimit.Backend {
+		case conf.RateLimitBackendRedis:
+			confRedis := conf.Current().Cache.Redis
+			lim, err := limit.NewRedisLimiter(confRedis.Url, confRedis.Password, confLimit.RequestsPerSec, burst)
+			if err != nil {
+				log.Fatalf("Error in rate limit init: %v", err)
+			}
+			rateLimiter = lim
+		case conf.RateLimitBackendMemory:
+			rateLimiter = limit.NewMemoryLimiter(confLimit.RequestsPerSec, burst)
+		default:
+			log.Fatalf("Invalid rate limit backend: Memory and Redis are supported. %v defined", confLimit.Backend)
+		}
+		log.Infof("Rate limit: %v requests/sec, burst %v (%v backend)", confLimit.RequestsPerSec, burst, confLimit.Backend)
+	}
+}
+
+// SetAPIKeyStore sets the valid API keys (nil disables API keys)
+func SetAPIKeyStore(store *limit.KeyStore) {
+	apiKeyStore = store
+}
+
+// SetRateLimiter sets the rate limiter (nil disables rate limiting)
+func SetRateLimiter(lim limit.Limiter) {
+	rateLimiter = lim
+}
+
+// SetTrustedProxies sets the proxies whose forwarding headers provide the client IP,
+// as IP addresses or CIDR networks
+func SetTrustedProxies(proxies []string) error {
+	var nets []*net.IPNet
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid IP address %q", proxy)
+			}
+			bits := 8 * len(ip)
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return err
+		}
+		nets = append(nets, ipNet)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+// LimitHandler checks the API key of a request, if keys are required,
+// and limits the rate of requests by API key, or by client IP for requests without a valid key
+func LimitHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// orchestrator probes have no API key.
+		// Readiness checks query the database, so they are still rate limited
+		route := healthRoute(r)
+		if route == routeHealthLive {
+			h.ServeHTTP(w, r)
+			return
+		}
+		key := requestAPIKey(r)
+		isKeyValid := false
+		if apiKeyStore != nil && route != routeHealthReady {
+			if key == "" {
+				http.Error(w, api.ErrMsgMissingAPIKey, http.StatusUnauthorized)
+				return
+			}
+			if !apiKeyStore.IsValid(key) {
+				http.Error(w, api.ErrMsgInvalidAPIKey, http.StatusForbidden)
+				return
+			}
+			isKeyValid = true
+		}
+		if rateLimiter != nil {
+			// unverified keys are not used, as clients could vary them to avoid limits
+			client := "ip:" + clientIP(r)
+			if isKeyValid {
+				client = "key:" + key
+			}
+			if ok, wait := rateLimiter.Allow(client); !ok {
+				retrySec := int(math.Ceil(wait.Seconds()))
+				if retrySec < 1 {
+					retrySec = 1
+				}
+				log.Debugf("Rate limit exceeded for %v", client)
+				w.Header().Set(headerRetryAfter, strconv.Itoa(retrySec))
+				http.Error(w, api.ErrMsgRateLimited, http.StatusTooManyRequests)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// requestAPIKey reads the API key from the X-API-Key header or the api_key query parameter.
+// The query parameter is removed, so that it is not taken as a property filter or copied into links
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(headerAPIKey); key != "" {
+		return key
+	}
+	query := r.URL.Query()
+	key := query.Get(paramAPIKey)
+	if _, ok := query[paramAPIKey]; ok {
+		query.Del(paramAPIKey)
+		r.URL.RawQuery = query.Encode()
+	}
+	return key
+}
+
+// clientIP is the IP address of the client of a request.
+// For requests from a trusted proxy, it is the last address forwarded by the proxies
+// (in the Forwarded or X-Forwarded-For headers) which is not a trusted proxy
+func clientIP(r *http.Request) string {
+	ip := hostIP(r.RemoteAddr)
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+	hops := forwardedFor(r)
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = hops[i]
+		if !isTrustedProxy(ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// forwardedFor returns the addresses of the clients and proxies a request was forwarded for, in order
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	if values, ok := r.Header[http.CanonicalHeaderKey("Forwarded")]; ok {
+		if params, err := httpforwarded.Parse(values); err == nil {
+			for _, hop := range params["for"] {
+				hops = append(hops, hostIP(hop))
+			}
+		}
+		return hops
+	}
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, hostIP(strings.TrimSpace(hop)))
+		}
+	}
+	return hops
+}
+
+// hostIP removes the port of an address, if any, and the brackets of an IPv6 address
+func hostIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
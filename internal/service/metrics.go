@@ -0,0 +1,142 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const routeMetrics = "/metrics"
+
+// routeUnknown labels the metrics of requests not matching any route
+const routeUnknown = "unknown"
+
+var httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: data.MetricsNamespace,
+	Name:      "http_requests_total",
+	Help:      "Number of HTTP requests, by route, method and status",
+}, []string{"route", "method", "status"})
+
+var httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: data.MetricsNamespace,
+	Name:      "http_request_duration_seconds",
+	Help:      "Duration of HTTP requests in seconds, by route and method",
+}, []string{"route", "method"})
+
+// metricsHandler serves the metrics of the default registry, in the format accepted by the client
+var metricsHandler = promhttp.Handler()
+
+// statusWriter records the status of a response.
+// It holds the request context, for the spans of writing the response
+type statusWriter struct {
+	http.ResponseWriter
+	ctx    context.Context
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes through flushes of streamed responses
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// routeTemplate is the path template of the route matched by a request,
+// used to bound the number of metric series
+func routeTemplate(r *http.Request) string {
+	if cur := mux.CurrentRoute(r); cur != nil {
+		return pathTemplate(cur)
+	}
+	return routeUnknown
+}
+
+// pathTemplate is the path template of a route, or "unknown" if it has none
+func pathTemplate(route *mux.Route) string {
+	if tpl, err := route.GetPathTemplate(); err == nil {
+		return tpl
+	}
+	return routeUnknown
+}
+
+// MetricsHandler counts the requests and their duration by route and status.
+// It wraps the whole handler chain, so that requests rejected
+// before reaching the router (by authentication, limits or timeouts) are counted.
+// The route is the one the router matches, or "unknown" if there is none
+func MetricsHandler(router *mux.Router, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := routeUnknown
+		var match mux.RouteMatch
+		if router.Match(r, &match) && match.Route != nil {
+			route = pathTemplate(match.Route)
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		// the request is counted even if the handler panics
+		// (as it does with http.ErrAbortHandler to abort a streamed response).
+		// The panic is passed on to the server
+		defer func() {
+			p := recover()
+			status := sw.statusCode()
+			if p != nil && sw.status == 0 {
+				status = http.StatusInternalServerError
+			}
+			recordRequest(r, route, status, time.Since(start))
+			if p != nil {
+				panic(p)
+			}
+		}()
+		h.ServeHTTP(sw, r)
+	})
+}
+
+// recordRequest counts a request and its duration
+func recordRequest(r *http.Request, route string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(route, r.Method).Observe(duration.Seconds())
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) *appError {
+	metricsHandler.ServeHTTP(w, r)
+	return nil
+}
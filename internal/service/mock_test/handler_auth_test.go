@@ -0,0 +1,150 @@
+package mock_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/auth"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/service"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// checks that write requests require a valid bearer token when authentication is enabled
+func (t *MockTests) TestAuthWriteRequests() {
+	t.Test.Run("TestAuthWriteRequests", func(t *testing.T) {
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		service.SetAuthVerifier(makeAuthVerifier(t, &key.PublicKey))
+		defer service.SetAuthVerifier(nil)
+		handler := service.AuthHandler(hTest.Router)
+
+		// reads do not require a token
+		rr := doAuthRequest(handler, "GET", "/collections/mock_b/items/1", "")
+		util.Equals(t, http.StatusOK, rr.Code, "read without token")
+
+		rr = doAuthRequest(handler, "DELETE", "/collections/mock_b/items/1", "")
+		util.Equals(t, http.StatusUnauthorized, rr.Code, "write without token")
+		util.Equals(t, "Bearer", rr.Header().Get("WWW-Authenticate"), "WWW-Authenticate header")
+
+		rr = doAuthRequest(handler, "DELETE", "/collections/mock_b/items/1", "not.a.token")
+		util.Equals(t, http.StatusUnauthorized, rr.Code, "write with invalid token")
+		util.Assert(t, strings.Contains(rr.Header().Get("WWW-Authenticate"), "invalid_token"), "invalid_token error expected")
+
+		expired := signToken(key, map[string]interface{}{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()})
+		rr = doAuthRequest(handler, "DELETE", "/collections/mock_b/items/1", expired)
+		util.Equals(t, http.StatusUnauthorized, rr.Code, "write with expired token")
+
+		valid := signToken(key, map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+		rr = doAuthRequest(handler, "DELETE", "/collections/mock_b/items/1", valid)
+		util.Equals(t, http.StatusNoContent, rr.Code, "write with valid token")
+	})
+}
+
+// checks that write requests are rejected without authentication, unless anonymous writes are allowed
+func (t *MockTests) TestAuthAnonymousWrite() {
+	t.Test.Run("TestAuthAnonymousWrite", func(t *testing.T) {
+		conf.Configuration.Auth.AllowAnonymousWrite = false
+		defer func() { conf.Configuration.Auth.AllowAnonymousWrite = true }()
+		handler := service.AuthHandler(hTest.Router)
+
+		rr := doAuthRequest(handler, "GET", "/collections/mock_a/items/1", "")
+		util.Equals(t, http.StatusOK, rr.Code, "read without authentication")
+		rr = doAuthRequest(handler, "DELETE", "/collections/mock_b/items/2", "")
+		util.Equals(t, http.StatusForbidden, rr.Code, "write without authentication")
+
+		conf.Configuration.Auth.AllowAnonymousWrite = true
+		rr = doAuthRequest(handler, "DELETE", "/collections/mock_b/items/2", "")
+		util.Equals(t, http.StatusNoContent, rr.Code, "anonymous write allowed")
+	})
+}
+
+// checks that collections not visible to the role of the request session are not listed or found
+func (t *MockTests) TestSessionRoleVisibility() {
+	t.Test.Run("TestSessionRoleVisibility", func(t *testing.T) {
+		catalogMock.RoleTables = map[string][]string{"reader": {"mock_a"}}
+		conf.Configuration.Auth.AnonRole = "reader"
+		defer func() {
+			catalogMock.RoleTables = nil
+			conf.Configuration.Auth.AnonRole = ""
+		}()
+		handler := service.AuthHandler(hTest.Router)
+
+		rr := doAuthRequest(handler, "GET", "/collections", "")
+		util.Equals(t, http.StatusOK, rr.Code, "collections status")
+		var v api.CollectionsInfo
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, "%v", errUnMarsh)
+		util.Equals(t, 1, len(v.Collections), "# collections")
+		util.Equals(t, "mock_a", v.Collections[0].Name, "visible collection")
+
+		rr = doAuthRequest(handler, "GET", "/collections/mock_a/items", "")
+		util.Equals(t, http.StatusOK, rr.Code, "visible collection items")
+		rr = doAuthRequest(handler, "GET", "/collections/mock_b", "")
+		util.Equals(t, http.StatusNotFound, rr.Code, "hidden collection")
+		rr = doAuthRequest(handler, "GET", "/collections/mock_b/items", "")
+		util.Equals(t, http.StatusNotFound, rr.Code, "hidden collection items")
+	})
+}
+
+func doAuthRequest(handler http.Handler, method string, url string, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, hTest.BasePath+url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func makeAuthVerifier(t *testing.T, key *rsa.PublicKey) *auth.Verifier {
+	jwks := map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA", "kid": "test",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, _ := json.Marshal(jwks)
+	file, err := ioutil.TempFile("", "jwks*.json")
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	defer os.Remove(file.Name())
+	_, _ = file.Write(data)
+	file.Close()
+
+	verifier, err := auth.NewVerifier(auth.Options{JwksFile: file.Name()})
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	return verifier
+}
+
+func signToken(key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -19,15 +19,19 @@ package mock_test
 */
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"testing"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
 	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+	"github.com/getkin/kin-openapi/openapi3"
 )
 
 func (t *MockTests) TestRoot() {
@@ -181,6 +185,115 @@ func (t *MockTests) TestFeatureFormats() {
 	})
 }
 
+func (t *MockTests) TestCollectionItemsFlatGeobuf() {
+	t.Test.Run("TestCollectionItemsFlatGeobuf", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/collections/mock_a/items.fgb?limit=5")
+		util.Equals(t, api.ContentTypeFlatGeobuf, resp.Header().Get("Content-Type"), "Content-Type")
+		body := hTest.ReadBody(resp)
+		util.Equals(t, []byte("fgb\x03fgb"), body[:7], "FlatGeobuf magic bytes")
+
+		// From header Accept
+		var header = make(http.Header)
+		header.Add("Accept", api.ContentTypeFlatGeobuf)
+		resp2 := hTest.DoRequestMethodStatus(t, "GET", "/collections/mock_a/items?limit=5", nil, header, http.StatusOK)
+		util.Equals(t, body, hTest.ReadBody(resp2), "FlatGeobuf from Accept header")
+
+		// a function without geometry has no FlatGeobuf output
+		hTest.DoRequestStatus(t, "/functions/fun_a/items.fgb", http.StatusNotAcceptable)
+
+		// advertised in the items links
+		resp3 := hTest.DoRequest(t, "/collections/mock_a/items")
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(resp3), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		checkLink(t, v.Links[2], api.RelAlt, api.ContentTypeFlatGeobuf, hTest.UrlBase+"/collections/mock_a/items.fgb")
+	})
+}
+
+// checks the FlatGeobuf format of items is described in the OpenAPI document
+func (t *MockTests) TestApiFlatGeobuf() {
+	t.Test.Run("TestApiFlatGeobuf", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/api")
+		var v openapi3.T
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(resp), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		for _, path := range []string{"/collections/{collectionId}/items", "/functions/{functionId}/items"} {
+			item := v.Paths.Find(path)
+			util.Assert(t, item != nil, "%v path exists", path)
+			content := item.Get.Responses.Get(http.StatusOK).Value.Content
+			util.Assert(t, content.Get(api.ContentTypeFlatGeobuf) != nil, "%v FlatGeobuf response content", path)
+
+			itemFormat := v.Paths.Find(path + ".{format}")
+			util.Assert(t, itemFormat != nil, "%v format path exists", path)
+			param := itemFormat.Get.Parameters.GetByInAndName("path", "format")
+			util.Assert(t, param != nil, "%v format parameter exists", path)
+			util.Assert(t, isEnumValue(param.Schema.Value.Enum, api.FormatFlatGeobuf), "%v fgb format value", path)
+		}
+	})
+}
+
+func isEnumValue(enum []interface{}, value string) bool {
+	for _, v := range enum {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *MockTests) TestCollectionItemsCSV() {
+	t.Test.Run("TestCollectionItemsCSV", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/collections/mock_a/items.csv?limit=3")
+		util.Equals(t, api.ContentTypeCSV, resp.Header().Get("Content-Type"), "Content-Type")
+		body := hTest.ReadBody(resp)
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		util.Assert(t, err == nil, fmt.Sprintf("%v", err))
+		util.Equals(t, 4, len(records), "# records")
+		util.Equals(t, []string{"prop_a", "prop_b", "prop_c", "prop_d", "x", "y"}, records[0], "header")
+		util.Equals(t, []string{"propA", "1", "propC", "1", "-120", "40"}, records[1], "first record")
+		util.Assert(t, bytes.HasSuffix(body, []byte("\r\n")), "CRLF line endings")
+
+		// From header Accept, with selected properties
+		var header = make(http.Header)
+		header.Add("Accept", api.ContentTypeCSV)
+		resp2 := hTest.DoRequestMethodStatus(t, "GET", "/collections/mock_a/items?limit=3&properties=prop_b", nil, header, http.StatusOK)
+		records, err = csv.NewReader(resp2.Body).ReadAll()
+		util.Assert(t, err == nil, fmt.Sprintf("%v", err))
+		util.Equals(t, []string{"prop_b", "x", "y"}, records[0], "header")
+	})
+}
+
+func (t *MockTests) TestCollectionItemsGML() {
+	t.Test.Run("TestCollectionItemsGML", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/collections/mock_a/items.xml?limit=2")
+		util.Equals(t, api.ContentTypeGMLSF0, resp.Header().Get("Content-Type"), "Content-Type")
+		body := string(hTest.ReadBody(resp))
+		util.Assert(t, strings.Contains(body, `<sf:FeatureCollection`), "feature collection")
+		util.Equals(t, 2, strings.Count(body, "<sf:featureMember>"), "# features")
+		util.Assert(t, strings.Contains(body, `numberReturned="2"`), "numberReturned")
+		util.Assert(t, strings.Contains(body, `<atom:link href="http://test/collections/mock_a/items.xml?limit=2&amp;offset=2" rel="next"`), "next link: %v", body)
+		util.Assert(t, strings.Contains(body, `<app:mock_a gml:id="mock_a.1"><app:geometry><gml:Point`), "feature: %v", body)
+
+		// From header Accept
+		var header = make(http.Header)
+		header.Add("Accept", api.ContentTypeGML+"; version=3.2")
+		resp2 := hTest.DoRequestMethodStatus(t, "GET", "/collections/mock_a/items/1", nil, header, http.StatusOK)
+		util.Equals(t, api.ContentTypeGMLSF0, resp2.Header().Get("Content-Type"), "Content-Type")
+		body = string(hTest.ReadBody(resp2))
+		util.Assert(t, strings.Contains(body, `<app:mock_a gml:id="mock_a.1" xmlns:gml="http://www.opengis.net/gml/3.2"`), "feature: %v", body)
+		util.Assert(t, strings.Contains(body, `<app:prop_a>propA</app:prop_a>`), "property: %v", body)
+
+		// application schema
+		resp3 := hTest.DoRequest(t, "/collections/mock_a/schema.xsd")
+		util.Equals(t, api.ContentTypeXSD, resp3.Header().Get("Content-Type"), "Content-Type")
+		body = string(hTest.ReadBody(resp3))
+		util.Assert(t, strings.Contains(body, `<xs:element name="prop_b" type="xs:int" minOccurs="0"/>`), "schema: %v", body)
+
+		hTest.DoRequestStatus(t, "/collections/missing/schema.xsd", http.StatusNotFound)
+	})
+}
+
 func (t *MockTests) TestCollectionsResponse() {
 	t.Test.Run("TestCollectionsResponse", func(t *testing.T) {
 		path := "/collections"
@@ -246,6 +359,24 @@ func (t *MockTests) TestCollectionItemsResponse() {
 	})
 }
 
+func (t *MockTests) TestCollectionItemsStreamed() {
+	t.Test.Run("TestCollectionItemsStreamed", func(t *testing.T) {
+		path := "/collections/mock_c/items?limit=250"
+		resp := hTest.DoRequest(t, path)
+		body, _ := ioutil.ReadAll(resp.Body)
+
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(body, &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		util.Equals(t, api.GeoJSONFeatureCollection, v.Type, "type")
+		util.Equals(t, 250, len(v.Features), "# features")
+		util.Equals(t, uint(250), v.NumberReturned, "numberReturned")
+		util.Assert(t, v.TimeStamp != "", "timeStamp must be present")
+		util.Assert(t, resp.Flushed, "response must be flushed while writing features")
+	})
+}
+
 // check if item is available and is not empty
 func (t *MockTests) TestCollectionItem() {
 	t.Test.Run("TestCollectionItem", func(t *testing.T) {
@@ -0,0 +1,86 @@
+package mock_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/limit"
+	"github.com/CrunchyData/pg_featureserv/internal/service"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// checks the liveness and readiness endpoints, with a status per component
+func (t *MockTests) TestHealth() {
+	t.Test.Run("TestHealth", func(t *testing.T) {
+		rr := hTest.DoRequestStatus(t, "/health/live", http.StatusOK)
+		health := readHealth(t, rr.Body.Bytes())
+		util.Equals(t, api.HealthStatusOK, health.Status, "live status")
+
+		rr = hTest.DoRequestStatus(t, "/health/ready", http.StatusOK)
+		util.Equals(t, "no-store", rr.Header().Get("Cache-Control"), "Cache-Control header")
+		health = readHealth(t, rr.Body.Bytes())
+		util.Equals(t, api.HealthStatusOK, health.Status, "ready status")
+		util.Equals(t, api.HealthStatusOK, health.Components[api.HealthCatalog].Status, "catalog status")
+
+		catalogMock.Unhealthy = map[string]string{api.HealthDatabase: "connection refused"}
+		defer func() { catalogMock.Unhealthy = nil }()
+		rr = hTest.DoRequestStatus(t, "/health/ready", http.StatusServiceUnavailable)
+		health = readHealth(t, rr.Body.Bytes())
+		util.Equals(t, api.HealthStatusFail, health.Status, "not ready status")
+		util.Equals(t, "connection refused", health.Components[api.HealthDatabase].Message, "database message")
+
+		// the liveness of the service does not depend on its components
+		hTest.DoRequestStatus(t, "/health/live", http.StatusOK)
+	})
+}
+
+// checks that health checks do not require an API key, and that readiness checks are rate limited
+func (t *MockTests) TestHealthWithoutAPIKey() {
+	t.Test.Run("TestHealthWithoutAPIKey", func(t *testing.T) {
+		conf.Configuration.Server.BasePath = hTest.BasePath
+		defer func() { conf.Configuration.Server.BasePath = "" }()
+		store, _ := limit.NewKeyStore(func() ([]string, error) { return []string{"secret"}, nil })
+		service.SetAPIKeyStore(store)
+		defer service.SetAPIKeyStore(nil)
+		service.SetRateLimiter(limit.NewMemoryLimiter(0.5, 1))
+		defer service.SetRateLimiter(nil)
+		handler := service.LimitHandler(hTest.Router)
+
+		rr := doLimitRequest(handler, "/health/live", "", "1.2.3.4:1000")
+		util.Equals(t, http.StatusOK, rr.Code, "live without key")
+		rr = doLimitRequest(handler, "/health/ready", "", "1.2.3.4:1000")
+		util.Equals(t, http.StatusOK, rr.Code, "ready without key")
+		rr = doLimitRequest(handler, "/health/ready", "", "1.2.3.4:1000")
+		util.Equals(t, http.StatusTooManyRequests, rr.Code, "ready over limit")
+		rr = doLimitRequest(handler, "/health/live", "", "1.2.3.4:1000")
+		util.Equals(t, http.StatusOK, rr.Code, "live over limit")
+		rr = doLimitRequest(handler, "/collections", "", "5.6.7.8:1000")
+		util.Equals(t, http.StatusUnauthorized, rr.Code, "collections without key")
+		// only the health routes are exempt
+		rr = doLimitRequest(handler, "/collections/health/live", "", "5.6.7.8:1000")
+		util.Equals(t, http.StatusUnauthorized, rr.Code, "path ending as a health route without key")
+	})
+}
+
+func readHealth(t *testing.T, body []byte) api.Health {
+	var health api.Health
+	errUnMarsh := json.Unmarshal(body, &health)
+	util.Assert(t, errUnMarsh == nil, "%s", errUnMarsh)
+	return health
+}
@@ -45,6 +45,18 @@ func (t *MockTests) TestHTMLItems() {
 		hTest.DoRequest(t, "/collections/mock_a/items.html")
 	})
 }
+func (t *MockTests) TestHTMLItemsPaging() {
+	t.Test.Run("TestHTMLItemsPaging", func(t *testing.T) {
+		rr := hTest.DoRequest(t, "/collections/mock_b/items.html?limit=10&offset=20")
+		body := rr.Body.String()
+		if !strings.Contains(body, "http://test/collections/mock_b/items.html?limit=10&amp;offset=30") {
+			t.Errorf("Items page should contain a link to the next page")
+		}
+		if !strings.Contains(body, "http://test/collections/mock_b/items.html?limit=10&amp;offset=10") {
+			t.Errorf("Items page should contain a link to the previous page")
+		}
+	})
+}
 func (t *MockTests) TestHTMLItem() {
 	t.Test.Run("TestHTMLItem", func(t *testing.T) {
 		hTest.DoRequest(t, "/collections/mock_a/items/1.html")
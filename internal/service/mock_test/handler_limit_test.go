@@ -0,0 +1,111 @@
+package mock_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/limit"
+	"github.com/CrunchyData/pg_featureserv/internal/service"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// checks that requests require a valid API key, in the header or the query parameter
+func (t *MockTests) TestAPIKeys() {
+	t.Test.Run("TestAPIKeys", func(t *testing.T) {
+		store, _ := limit.NewKeyStore(func() ([]string, error) { return []string{"secret"}, nil })
+		service.SetAPIKeyStore(store)
+		defer service.SetAPIKeyStore(nil)
+		handler := service.LimitHandler(hTest.Router)
+
+		rr := doLimitRequest(handler, "/collections/mock_a/items", "", "1.2.3.4:1000")
+		util.Equals(t, http.StatusUnauthorized, rr.Code, "request without key")
+		rr = doLimitRequest(handler, "/collections/mock_a/items", "wrong", "1.2.3.4:1000")
+		util.Equals(t, http.StatusForbidden, rr.Code, "request with wrong key")
+		rr = doLimitRequest(handler, "/collections/mock_a/items", "secret", "1.2.3.4:1000")
+		util.Equals(t, http.StatusOK, rr.Code, "request with key header")
+		// the query parameter is not taken as a property filter
+		rr = doLimitRequest(handler, "/collections/mock_a/items?api_key=secret", "", "1.2.3.4:1000")
+		util.Equals(t, http.StatusOK, rr.Code, "request with key parameter")
+	})
+}
+
+// checks that requests over the rate limit of a client are rejected with a Retry-After header
+func (t *MockTests) TestRateLimit() {
+	t.Test.Run("TestRateLimit", func(t *testing.T) {
+		service.SetRateLimiter(limit.NewMemoryLimiter(0.5, 2))
+		defer service.SetRateLimiter(nil)
+		handler := service.LimitHandler(hTest.Router)
+
+		for i := 0; i < 2; i++ {
+			rr := doLimitRequest(handler, "/collections", "", "1.2.3.4:1000")
+			util.Equals(t, http.StatusOK, rr.Code, "request in burst")
+		}
+		rr := doLimitRequest(handler, "/collections", "", "1.2.3.4:2000")
+		util.Equals(t, http.StatusTooManyRequests, rr.Code, "request over limit")
+		util.Equals(t, "2", rr.Header().Get("Retry-After"), "Retry-After header")
+
+		rr = doLimitRequest(handler, "/collections", "", "5.6.7.8:1000")
+		util.Equals(t, http.StatusOK, rr.Code, "request of other client")
+	})
+}
+
+// checks that the client IP is read from the forwarding headers of trusted proxies only
+func (t *MockTests) TestRateLimitTrustedProxies() {
+	t.Test.Run("TestRateLimitTrustedProxies", func(t *testing.T) {
+		err := service.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		defer service.SetTrustedProxies(nil) //nolint:errcheck
+		err = service.SetTrustedProxies([]string{"10.0.0.0/8", "proxy"})
+		util.AssertIsError(t, err, "invalid proxy address")
+		service.SetRateLimiter(limit.NewMemoryLimiter(0.5, 1))
+		defer service.SetRateLimiter(nil)
+		handler := service.LimitHandler(hTest.Router)
+
+		rr := doForwardedRequest(handler, "10.1.1.1:1000", "X-Forwarded-For", "1.2.3.4, 192.168.1.1")
+		util.Equals(t, http.StatusOK, rr.Code, "first request of client")
+		rr = doForwardedRequest(handler, "10.2.2.2:1000", "Forwarded", `for=1.2.3.4;proto=https, for="192.168.1.1:8080"`)
+		util.Equals(t, http.StatusTooManyRequests, rr.Code, "same client through other proxies")
+		rr = doForwardedRequest(handler, "10.1.1.1:1000", "X-Forwarded-For", "5.6.7.8")
+		util.Equals(t, http.StatusOK, rr.Code, "other client")
+		// a client cannot set its address by sending the header itself
+		rr = doForwardedRequest(handler, "1.2.3.4:1000", "X-Forwarded-For", "9.9.9.9")
+		util.Equals(t, http.StatusTooManyRequests, rr.Code, "forwarded address of untrusted client")
+		// only the addresses added by trusted proxies are used
+		rr = doForwardedRequest(handler, "10.1.1.1:1000", "X-Forwarded-For", "9.9.9.9, 1.2.3.4")
+		util.Equals(t, http.StatusTooManyRequests, rr.Code, "address set by the client")
+	})
+}
+
+func doForwardedRequest(handler http.Handler, remoteAddr string, header string, value string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", hTest.BasePath+"/collections", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set(header, value)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func doLimitRequest(handler http.Handler, url string, key string, remoteAddr string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", hTest.BasePath+url, nil)
+	req.RemoteAddr = remoteAddr
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
@@ -0,0 +1,140 @@
+package mock_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/limit"
+	"github.com/CrunchyData/pg_featureserv/internal/service"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+	"github.com/prometheus/common/expfmt"
+)
+
+// checks that /metrics is served only if enabled, and reports the requests by route and status
+func (t *MockTests) TestMetrics() {
+	t.Test.Run("TestMetrics", func(t *testing.T) {
+		hTest.DoRequestStatus(t, "/metrics", http.StatusNotFound)
+
+		conf.Configuration.Server.EnableMetrics = true
+		defer func() { conf.Configuration.Server.EnableMetrics = false }()
+		handler := newMetricsHandler()
+
+		doMetricsRequest(t, handler, "/collections/mock_a/items", http.StatusOK)
+		doMetricsRequest(t, handler, "/collections/missing/items", http.StatusNotFound)
+		doMetricsRequest(t, handler, "/nothing/here", http.StatusNotFound)
+
+		rr := doMetricsRequest(t, handler, "/metrics", http.StatusOK)
+		util.Equals(t, string(expfmt.FmtText), rr.Header().Get("Content-Type"), "content type")
+		body := rr.Body.String()
+		for _, line := range []string{
+			`pg_featureserv_http_requests_total{method="GET",route="/pg_featureserv/collections/{cid}/items{fmt:(?:\\.[a-zA-Z]+)?}",status="200"}`,
+			`pg_featureserv_http_requests_total{method="GET",route="/pg_featureserv/collections/{cid}/items{fmt:(?:\\.[a-zA-Z]+)?}",status="404"}`,
+			`pg_featureserv_http_requests_total{method="GET",route="unknown",status="404"}`,
+			"# TYPE pg_featureserv_http_request_duration_seconds histogram",
+		} {
+			util.Assert(t, strings.Contains(body, line), "metrics should contain %v", line)
+		}
+	})
+}
+
+// checks that a request aborted by a panic of its handler is counted
+func (t *MockTests) TestMetricsAbortedRequest() {
+	t.Test.Run("TestMetricsAbortedRequest", func(t *testing.T) {
+		conf.Configuration.Server.EnableMetrics = true
+		defer func() { conf.Configuration.Server.EnableMetrics = false }()
+		handler := newMetricsHandler()
+		series := `pg_featureserv_http_requests_total{method="GET",route="/pg_featureserv/collections/{cid}/items{fmt:(?:\\.[a-zA-Z]+)?}",status="200"}`
+		countBefore := metricValue(doMetricsRequest(t, handler, "/metrics", http.StatusOK).Body.String(), series)
+
+		// the streamed response is aborted when writing the features fails
+		req := httptest.NewRequest("GET", "http://test/pg_featureserv/collections/mock_c/items?limit=1000", nil)
+		w := &failingWriter{ResponseWriter: httptest.NewRecorder(), failAfter: 4096}
+		func() {
+			defer func() {
+				p := recover()
+				util.Equals(t, http.ErrAbortHandler, p, "handler panic")
+			}()
+			handler.ServeHTTP(w, req)
+		}()
+
+		countAfter := metricValue(doMetricsRequest(t, handler, "/metrics", http.StatusOK).Body.String(), series)
+		util.Equals(t, countBefore+1, countAfter, "aborted request count")
+	})
+}
+
+// checks that requests rejected before reaching the router are counted
+func (t *MockTests) TestMetricsRejectedRequest() {
+	t.Test.Run("TestMetricsRejectedRequest", func(t *testing.T) {
+		conf.Configuration.Server.EnableMetrics = true
+		defer func() { conf.Configuration.Server.EnableMetrics = false }()
+		store, _ := limit.NewKeyStore(func() ([]string, error) { return []string{"secret"}, nil })
+		service.SetAPIKeyStore(store)
+		defer service.SetAPIKeyStore(nil)
+		router := service.InitRouter("/pg_featureserv")
+		handler := service.MetricsHandler(router, service.LimitHandler(router))
+		series := `pg_featureserv_http_requests_total{method="GET",route="/pg_featureserv/collections/{cid}/items{fmt:(?:\\.[a-zA-Z]+)?}",status="401"}`
+		countBefore := metricValue(doLimitRequest(handler, "/metrics", "secret", "1.2.3.4:1000").Body.String(), series)
+
+		rr := doLimitRequest(handler, "/collections/mock_a/items", "", "1.2.3.4:1000")
+		util.Equals(t, http.StatusUnauthorized, rr.Code, "request without key")
+
+		countAfter := metricValue(doLimitRequest(handler, "/metrics", "secret", "1.2.3.4:1000").Body.String(), series)
+		util.Equals(t, countBefore+1, countAfter, "rejected request count")
+	})
+}
+
+// failingWriter fails the writes of a response after a number of bytes
+type failingWriter struct {
+	http.ResponseWriter
+	failAfter int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.failAfter {
+		return 0, errors.New("connection closed")
+	}
+	w.failAfter -= len(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// newMetricsHandler counts the requests to the router
+func newMetricsHandler() http.Handler {
+	router := service.InitRouter("/pg_featureserv")
+	return service.MetricsHandler(router, router)
+}
+
+// doMetricsRequest requests a path from a handler and checks the response status
+func doMetricsRequest(t *testing.T, handler http.Handler, path string, status int) *httptest.ResponseRecorder {
+	rr := doLimitRequest(handler, path, "", "1.2.3.4:1000")
+	util.Equals(t, status, rr.Code, "status of "+path)
+	return rr
+}
+
+// metricValue reads the value of a metric series, zero if it is not reported
+func metricValue(body string, series string) float64 {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			v, _ := strconv.ParseFloat(strings.TrimPrefix(line, series+" "), 64)
+			return v
+		}
+	}
+	return 0
+}
@@ -17,10 +17,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"testing"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
 	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
 )
 
@@ -72,6 +76,158 @@ func (t *MockTests) TestFilterBDNone() {
 	})
 }
 
+func (t *MockTests) TestFilterLang() {
+	t.Test.Run("TestFilterLang", func(t *testing.T) {
+		filter := url.QueryEscape(`{"op":"=","args":[{"property":"prop_b"},1]}`)
+		hTest.DoRequest(t, "/collections/mock_a/items?filter-lang=cql2-json&filter="+filter)
+		hTest.DoRequest(t, "/collections/mock_a/items?filter-lang=cql2-text&filter=prop_b=1")
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?filter-lang=cql2-json&filter=prop_b=1", http.StatusBadRequest)
+		concat := url.QueryEscape(`{"op":"=","args":[{"property":"prop_b"},{"op":"||","args":["a","b"]}]}`)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?filter-lang=cql2-json&filter="+concat, http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?filter-lang=xml&filter=prop_b=1", http.StatusBadRequest)
+	})
+}
+
+func (t *MockTests) TestFilterPostBody() {
+	t.Test.Run("TestFilterPostBody", func(t *testing.T) {
+		filter := `{"op":"=","args":[{"property":"prop_b"},1]}`
+		header := make(http.Header)
+		header.Add("Content-Type", api.ContentTypeCQL2JSON)
+		rr := hTest.DoRequestMethodStatus(t, "POST", "/collections/mock_a/items?limit=2", []byte(filter), header, http.StatusOK)
+
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		util.Equals(t, 2, len(v.Features), "# features")
+
+		//-- paging links carry the filter from the body
+		next := v.Links[3]
+		util.Equals(t, api.RelNext, next.Rel, "next link")
+		util.Assert(t, strings.Contains(next.Href, "filter="+url.QueryEscape(filter)+"&filter-lang=cql2-json"), "next link filter: %v", next.Href)
+
+		header.Set("Content-Type", api.ContentTypeCQL2Text)
+		hTest.DoRequestMethodStatus(t, "POST", "/collections/mock_a/items", []byte("prop_b = 1"), header, http.StatusOK)
+		hTest.DoRequestMethodStatus(t, "POST", "/collections/mock_a/items", []byte("prop_b = "), header, http.StatusBadRequest)
+		hTest.DoRequestMethodStatus(t, "POST", "/collections/mock_a/items", []byte(""), header, http.StatusBadRequest)
+
+		//-- filter body too large
+		large := "prop_b = 1" + strings.Repeat(" OR prop_b = 1", 20000)
+		hTest.DoRequestMethodStatus(t, "POST", "/collections/mock_a/items", []byte(large), header, http.StatusRequestEntityTooLarge)
+	})
+}
+
+func (t *MockTests) TestDateTime() {
+	t.Test.Run("TestDateTime", func(t *testing.T) {
+		//-- mock collections have no time columns
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?datetime=2020-01-01T00:00:00Z", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?datetime=2020-01-01/..", http.StatusBadRequest)
+
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?datetime=yesterday", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?datetime=../..", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?datetime=2020-01-02/2020-01-01", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?datetime=2020-01-01/2020-01-02/2020-01-03", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?datetime=2020-01-01'", http.StatusBadRequest)
+
+		//-- no temporal extent without time columns
+		rr := hTest.DoRequest(t, "/collections/mock_a")
+		var v api.CollectionInfo
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		util.Assert(t, v.Extent.Temporal == nil, "unexpected temporal extent")
+	})
+}
+
+func (t *MockTests) TestTemporalExtent() {
+	t.Test.Run("TestTemporalExtent", func(t *testing.T) {
+		tbl, _ := catalogMock.TableByName("mock_a")
+		tbl.TimeColumns = []string{"prop_a"}
+		defer func() {
+			tbl.TimeColumns = nil
+			tbl.TimeExtent = api.DateTime{}
+		}()
+
+		//-- the temporal extent is omitted until it is known
+		rr := hTest.DoRequest(t, "/collections")
+		var colls api.CollectionsInfo
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &colls)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		util.Assert(t, colls.Collections[0].Extent.Temporal == nil, "unexpected temporal extent")
+
+		tbl.TimeExtent = api.DateTime{Start: "2020-01-01T00:00:00Z", End: "2020-12-31T00:00:00Z"}
+		rr = hTest.DoRequest(t, "/collections/mock_a")
+		var v api.CollectionInfo
+		errUnMarsh = json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		util.Assert(t, v.Extent.Temporal != nil, "temporal extent expected")
+		util.Equals(t, "2020-01-01T00:00:00Z", *v.Extent.Temporal.Interval[0][0], "temporal extent start")
+		util.Equals(t, "2020-12-31T00:00:00Z", *v.Extent.Temporal.Interval[0][1], "temporal extent end")
+	})
+}
+
+func (t *MockTests) TestCrs() {
+	t.Test.Run("TestCrs", func(t *testing.T) {
+		rr := hTest.DoRequest(t, "/collections/mock_a/items")
+		util.Equals(t, "<"+api.CrsURICRS84+">", rr.Header().Get(api.HeaderContentCrs), "default Content-Crs")
+
+		rr = hTest.DoRequest(t, "/collections/mock_a/items?crs="+url.QueryEscape("http://www.opengis.net/def/crs/EPSG/0/3857"))
+		util.Equals(t, "<http://www.opengis.net/def/crs/EPSG/0/3857>", rr.Header().Get(api.HeaderContentCrs), "crs URI")
+
+		rr = hTest.DoRequest(t, "/collections/mock_a/items?crs=EPSG:2154&bbox-crs=2154&bbox=1,2,3,4")
+		util.Equals(t, "<http://www.opengis.net/def/crs/EPSG/0/2154>", rr.Header().Get(api.HeaderContentCrs), "crs EPSG code")
+
+		rr = hTest.DoRequest(t, "/collections/mock_a/items/1?crs="+url.QueryEscape(api.CrsURICRS84))
+		util.Equals(t, "<"+api.CrsURICRS84+">", rr.Header().Get(api.HeaderContentCrs), "feature Content-Crs")
+
+		header := make(http.Header)
+		header.Add(api.HeaderAcceptCrs, "<http://www.opengis.net/def/crs/EPSG/0/3857>")
+		rr = hTest.DoRequestMethodStatus(t, "GET", "/collections/mock_a/items", nil, header, http.StatusOK)
+		util.Equals(t, "<http://www.opengis.net/def/crs/EPSG/0/3857>", rr.Header().Get(api.HeaderContentCrs), "Accept-Crs")
+
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?crs=EPSG:foo", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?bbox-crs=http://example.com/crs&bbox=1,2,3,4", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?crs=-1", http.StatusBadRequest)
+
+		//-- collection metadata lists the supported and storage CRS
+		rr = hTest.DoRequest(t, "/collections/mock_a")
+		var v api.CollectionInfo
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		util.Equals(t, []string{api.CrsURICRS84, api.CrsURIEPSG4326}, v.Crs, "collection crs")
+		util.Equals(t, api.CrsURICRS84, v.StorageCrs, "collection storageCrs")
+	})
+}
+
+// EPSG:4326 is in latitude/longitude order, unlike CRS84 and the 4326 SRID
+func (t *MockTests) TestCrsLatLon() {
+	t.Test.Run("TestCrsLatLon", func(t *testing.T) {
+		rr := hTest.DoRequest(t, "/collections/mock_a/items?crs=EPSG:4326")
+		util.Equals(t, "<"+api.CrsURIEPSG4326+">", rr.Header().Get(api.HeaderContentCrs), "crs EPSG:4326")
+
+		rr = hTest.DoRequest(t, "/collections/mock_a/items/1?crs="+url.QueryEscape(api.CrsURIEPSG4326))
+		util.Equals(t, "<"+api.CrsURIEPSG4326+">", rr.Header().Get(api.HeaderContentCrs), "feature crs EPSG URI")
+
+		rr = hTest.DoRequest(t, "/collections/mock_a/items?crs=4326")
+		util.Equals(t, "<"+api.CrsURICRS84+">", rr.Header().Get(api.HeaderContentCrs), "crs SRID")
+
+		header := make(http.Header)
+		header.Add(api.HeaderAcceptCrs, "<"+api.CrsURIEPSG4326+">")
+		rr = hTest.DoRequestMethodStatus(t, "GET", "/collections/mock_a/items", nil, header, http.StatusOK)
+		util.Equals(t, "<"+api.CrsURIEPSG4326+">", rr.Header().Get(api.HeaderContentCrs), "Accept-Crs EPSG:4326")
+
+		rr = hTest.DoRequest(t, "/collections/mock_a/items.xml?crs=EPSG:4326")
+		util.Assert(t, strings.Contains(string(hTest.ReadBody(rr)), `srsName="`+api.CrsURIEPSG4326+`"`), "GML srsName")
+
+		//-- filters and request bodies in latitude/longitude order are rejected
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?filter-crs=EPSG:4326&filter="+url.QueryEscape("INTERSECTS(geom, POINT(1 2))"), http.StatusBadRequest)
+		header = make(http.Header)
+		header.Add("Content-Type", api.ContentTypeGeoJSON)
+		header.Add(api.HeaderContentCrs, "<"+api.CrsURIEPSG4326+">")
+		rr = hTest.DoRequestMethodStatus(t, "POST", "/collections/mock_a/items",
+			[]byte(data.MakeJSONWithPointForSimple("mock_a", 0, 12, 34)), header, http.StatusBadRequest)
+		util.Assert(t, strings.Contains(rr.Body.String(), fmt.Sprintf(api.ErrMsgCrsLatLon, api.HeaderContentCrs, "<"+api.CrsURIEPSG4326+">")), "body CRS error: %v", rr.Body.String())
+	})
+}
+
 func (t *MockTests) TestSortBy() {
 	t.Test.Run("TestSortBy", func(t *testing.T) {
 		rr := hTest.DoRequest(t, "/collections/mock_a/items?sortby=prop_b")
@@ -176,6 +332,143 @@ func (t *MockTests) TestOffsetInvalid() {
 	})
 }
 
+func (t *MockTests) TestPagingLinks() {
+	t.Test.Run("TestPagingLinks", func(t *testing.T) {
+		path := "/collections/mock_b/items"
+		rr := hTest.DoRequest(t, path+"?limit=10&offset=20&properties=prop_a")
+
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		// no count is done by default, so a full page is followed by a next page
+		util.Assert(t, v.NumberMatched == nil, "numberMatched is not provided")
+		util.Equals(t, 5, len(v.Links), "# links")
+		checkLink(t, v.Links[3], api.RelNext, api.ContentTypeGeoJSON, hTest.UrlBase+path+"?limit=10&offset=30&properties=prop_a")
+		checkLink(t, v.Links[4], api.RelPrev, api.ContentTypeGeoJSON, hTest.UrlBase+path+"?limit=10&offset=10&properties=prop_a")
+	})
+}
+
+func (t *MockTests) TestPagingLinksFirstPage() {
+	t.Test.Run("TestPagingLinksFirstPage", func(t *testing.T) {
+		path := "/collections/mock_b/items"
+		rr := hTest.DoRequest(t, path+"?limit=10&offset=5")
+
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		util.Equals(t, 5, len(v.Links), "# links")
+		checkLink(t, v.Links[3], api.RelNext, api.ContentTypeGeoJSON, hTest.UrlBase+path+"?limit=10&offset=15")
+		// the previous page starts at the first feature
+		checkLink(t, v.Links[4], api.RelPrev, api.ContentTypeGeoJSON, hTest.UrlBase+path+"?limit=10")
+	})
+}
+
+func (t *MockTests) TestPagingNumberMatched() {
+	t.Test.Run("TestPagingNumberMatched", func(t *testing.T) {
+		conf.Configuration.Paging.NumberMatched = conf.NumberMatchedExact
+		defer func() { conf.Configuration.Paging.NumberMatched = conf.NumberMatchedNone }()
+
+		path := "/collections/mock_b/items"
+		rr := hTest.DoRequest(t, path+"?limit=10&offset=90")
+
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		util.Assert(t, v.NumberMatched != nil, "numberMatched is provided")
+		util.Equals(t, uint(100), *v.NumberMatched, "numberMatched")
+		util.Equals(t, uint(10), v.NumberReturned, "numberReturned")
+		// last page has no next link
+		util.Equals(t, 4, len(v.Links), "# links")
+		checkLink(t, v.Links[3], api.RelPrev, api.ContentTypeGeoJSON, hTest.UrlBase+path+"?limit=10&offset=80")
+	})
+}
+
+func (t *MockTests) TestPagingNumberMatchedNone() {
+	t.Test.Run("TestPagingNumberMatchedNone", func(t *testing.T) {
+		conf.Configuration.Paging.NumberMatched = conf.NumberMatchedExact
+		defer func() { conf.Configuration.Paging.NumberMatched = conf.NumberMatchedNone }()
+
+		path := "/collections/mock_b/items"
+		rr := hTest.DoRequest(t, path+"?limit=10&prop_a=none")
+
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		// an exact count of zero is provided
+		util.Assert(t, v.NumberMatched != nil, "numberMatched is provided")
+		util.Equals(t, uint(0), *v.NumberMatched, "numberMatched")
+		util.Equals(t, uint(0), v.NumberReturned, "numberReturned")
+	})
+}
+
+func (t *MockTests) TestPagingNumberMatchedEstimate() {
+	t.Test.Run("TestPagingNumberMatchedEstimate", func(t *testing.T) {
+		conf.Configuration.Paging.NumberMatched = conf.NumberMatchedEstimate
+		defer func() { conf.Configuration.Paging.NumberMatched = conf.NumberMatchedNone }()
+
+		path := "/collections/mock_b/items"
+		rr := hTest.DoRequest(t, path+"?limit=10&offset=90")
+
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		util.Assert(t, v.NumberMatched != nil, "numberMatched is provided")
+		// the estimate does not decide the next link, so a full page is followed by a next page
+		util.Equals(t, 5, len(v.Links), "# links")
+		checkLink(t, v.Links[3], api.RelNext, api.ContentTypeGeoJSON, hTest.UrlBase+path+"?limit=10&offset=100")
+	})
+}
+
+func (t *MockTests) TestPagingCursor() {
+	t.Test.Run("TestPagingCursor", func(t *testing.T) {
+		conf.Configuration.Paging.UseCursor = true
+		defer func() { conf.Configuration.Paging.UseCursor = false }()
+
+		rr := hTest.DoRequest(t, "/collections/mock_c/items?limit=10")
+
+		var v api.FeatureCollection
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(rr), &v)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		// first page has only a next link, using a cursor
+		util.Equals(t, 4, len(v.Links), "# links")
+		util.Equals(t, api.RelNext, v.Links[3].Rel, "Link rel")
+		util.Assert(t, strings.Contains(v.Links[3].Href, "cursor="), "next link must contain a cursor")
+		util.Assert(t, !strings.Contains(v.Links[3].Href, "offset="), "next link must not contain an offset")
+
+		// follow the next link
+		nextPath := strings.TrimPrefix(v.Links[3].Href, hTest.UrlBase)
+		rr = hTest.DoRequest(t, nextPath)
+
+		var vNext api.FeatureCollection
+		errUnMarsh = json.Unmarshal(hTest.ReadBody(rr), &vNext)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+
+		util.Equals(t, 10, len(vNext.Features), "# features")
+		util.Equals(t, "11", vNext.Features[0].ID, "feature 11 id")
+		util.Equals(t, "20", vNext.Features[9].ID, "feature 20 id")
+	})
+}
+
+func (t *MockTests) TestPagingCursorInvalid() {
+	t.Test.Run("TestPagingCursorInvalid", func(t *testing.T) {
+		hTest.DoRequestStatus(t, "/collections/mock_c/items?cursor=x", http.StatusBadRequest)
+
+		// cursor for another sort order
+		id := "10"
+		cursor := api.Cursor{Columns: []string{"id"}, Values: []*string{&id}}
+		hTest.DoRequestStatus(t, "/collections/mock_c/items?sortby=prop_a&cursor="+cursor.ToEncodedString(), http.StatusBadRequest)
+
+		// no ID column for keyset paging
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?cursor="+cursor.ToEncodedString(), http.StatusBadRequest)
+	})
+}
+
 func (t *MockTests) TestTransformValid() {
 	t.Test.Run("TestTransformValid", func(t *testing.T) {
 		hTest.DoRequest(t, "/collections/mock_a/items?transform=centroid")
@@ -197,6 +490,7 @@ func (t *MockTests) TestTransformInvalid() {
 func (t *MockTests) TestBBox() {
 	t.Test.Run("TestBBox", func(t *testing.T) {
 		hTest.DoRequest(t, "/collections/mock_a/items?bbox=1,2,3,4")
+		hTest.DoRequest(t, "/collections/mock_a/items?bbox=1,2,0,3,4,100")
 		// TODO: add some tests
 	})
 }
@@ -204,6 +498,16 @@ func (t *MockTests) TestBBox() {
 func (t *MockTests) TestBBoxInvalid() {
 	t.Test.Run("TestBBoxInvalid", func(t *testing.T) {
 		hTest.DoRequestStatus(t, "/collections/mock_a/items?bbox=1,2,3,x", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?bbox=1,2,3,4,5", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?bbox=1,2,0,3,4,x", http.StatusBadRequest)
+	})
+}
+
+func (t *MockTests) TestForce2D() {
+	t.Test.Run("TestForce2D", func(t *testing.T) {
+		hTest.DoRequest(t, "/collections/mock_a/items?force2d=true")
+		hTest.DoRequest(t, "/collections/mock_a/items/1?force2d=false")
+		hTest.DoRequestStatus(t, "/collections/mock_a/items?force2d=maybe", http.StatusBadRequest)
 	})
 }
 
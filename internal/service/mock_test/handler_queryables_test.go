@@ -0,0 +1,90 @@
+package mock_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+func (t *MockTests) TestCollectionQueryables() {
+	t.Test.Run("TestCollectionQueryables", func(t *testing.T) {
+		path := "/collections/mock_a/queryables"
+		resp := hTest.DoRequest(t, path)
+		util.Equals(t, api.ContentTypeSchemaJSON, resp.Header().Get("Content-Type"), "Content-Type")
+
+		var v api.PropertiesSchema
+		err := json.Unmarshal(hTest.ReadBody(resp), &v)
+		util.Assert(t, err == nil, "%v", err)
+		util.Equals(t, api.JSONSchemaDialect, v.Schema, "$schema")
+		util.Equals(t, hTest.UrlBase+path, v.ID, "$id")
+		util.Equals(t, "object", v.Type, "type")
+		util.Equals(t, 4, len(v.Properties), "# queryables")
+		util.Equals(t, api.QueryableSchema{Title: "Property A", Type: "string"}, *v.Properties["prop_a"], "prop_a")
+		util.Equals(t, api.QueryableSchema{Title: "Property B", Type: "integer"}, *v.Properties["prop_b"], "prop_b")
+
+		hTest.DoRequestStatus(t, "/collections/missing/queryables", http.StatusNotFound)
+
+		// advertised in the collection links
+		resp2 := hTest.DoRequest(t, "/collections/mock_a")
+		var coll api.CollectionInfo
+		err = json.Unmarshal(hTest.ReadBody(resp2), &coll)
+		util.Assert(t, err == nil, "%v", err)
+		n := len(coll.Links)
+		checkLink(t, coll.Links[n-2], api.RelQueryables, api.ContentTypeSchemaJSON, hTest.UrlBase+path)
+		checkLink(t, coll.Links[n-1], api.RelSortables, api.ContentTypeSchemaJSON, hTest.UrlBase+"/collections/mock_a/sortables")
+	})
+}
+
+func (t *MockTests) TestCollectionSortables() {
+	t.Test.Run("TestCollectionSortables", func(t *testing.T) {
+		path := "/collections/mock_a/sortables"
+		resp := hTest.DoRequest(t, path)
+		util.Equals(t, api.ContentTypeSchemaJSON, resp.Header().Get("Content-Type"), "Content-Type")
+
+		var v api.PropertiesSchema
+		err := json.Unmarshal(hTest.ReadBody(resp), &v)
+		util.Assert(t, err == nil, "%v", err)
+		util.Equals(t, hTest.UrlBase+path, v.ID, "$id")
+		util.Equals(t, 4, len(v.Properties), "# sortables")
+	})
+}
+
+func (t *MockTests) TestFunctionQueryables() {
+	t.Test.Run("TestFunctionQueryables", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/functions/fun_b/queryables")
+		var v api.PropertiesSchema
+		err := json.Unmarshal(hTest.ReadBody(resp), &v)
+		util.Assert(t, err == nil, "%v", err)
+		util.Equals(t, 3, len(v.Properties), "# queryables")
+		util.Equals(t, "https://geojson.org/schema/Geometry.json", v.Properties["out_geom"].Ref, "geometry $ref")
+		util.Equals(t, "integer", v.Properties["out_id"].Type, "out_id")
+		util.Equals(t, "string", v.Properties["out_param1"].Type, "out_param1")
+
+		// the geometry is not sortable
+		resp2 := hTest.DoRequest(t, "/functions/fun_b/sortables")
+		var sortables api.PropertiesSchema
+		err = json.Unmarshal(hTest.ReadBody(resp2), &sortables)
+		util.Assert(t, err == nil, "%v", err)
+		util.Equals(t, 2, len(sortables.Properties), "# sortables")
+		_, isGeom := sortables.Properties["out_geom"]
+		util.Assert(t, !isGeom, "geometry must not be sortable")
+
+		hTest.DoRequestStatus(t, "/functions/missing/queryables", http.StatusNotFound)
+	})
+}
@@ -0,0 +1,110 @@
+package mock_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// checks the ids of the tables and functions of the default and named sources
+func (t *MockTests) TestSourceID() {
+	t.Test.Run("TestSourceID", func(t *testing.T) {
+		util.Equals(t, "public.tbl", api.SourceID("", "public.tbl"), "default source id")
+		util.Equals(t, "src:public.tbl", api.SourceID("src", "public.tbl"), "named source id")
+
+		source, id := api.SplitSourceID("public.tbl")
+		util.Equals(t, "", source, "default source")
+		util.Equals(t, "public.tbl", id, "default source table id")
+		source, id = api.SplitSourceID("src:public.tbl")
+		util.Equals(t, "src", source, "named source")
+		util.Equals(t, "public.tbl", id, "named source table id")
+		// only the first separator splits the source name
+		source, id = api.SplitSourceID("src:public.a:b")
+		util.Equals(t, "src", source, "named source")
+		util.Equals(t, "public.a:b", id, "named source table id")
+
+		tbl := api.Table{ID: "public.tbl"}
+		util.Equals(t, "public.tbl", tbl.DbID(), "default source DbID")
+		tbl = api.Table{ID: "src:public.tbl", Source: "src"}
+		util.Equals(t, "public.tbl", tbl.DbID(), "named source DbID")
+		// a table of the default source with a separator in its name keeps it
+		tbl = api.Table{ID: "public.a:b"}
+		util.Equals(t, "public.a:b", tbl.DbID(), "default source DbID with separator")
+	})
+}
+
+// checks that the collections of a named source are listed, read and written by their source id
+func (t *MockTests) TestSourceCollection() {
+	t.Test.Run("TestSourceCollection", func(t *testing.T) {
+		tbl := catalogMock.AddSourceTable("src", "mock_a")
+		util.Equals(t, "src:mock_a", tbl.ID, "source table id")
+		util.Equals(t, "mock_a", tbl.DbID(), "source table DbID")
+
+		resp := hTest.DoRequest(t, "/collections")
+		body, _ := ioutil.ReadAll(resp.Body)
+		var colls api.CollectionsInfo
+		errUnMarsh := json.Unmarshal(body, &colls)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		isListed := false
+		for _, coll := range colls.Collections {
+			if coll.Name == tbl.ID {
+				checkCollection(t, coll, tbl.ID, tbl.Title)
+				isListed = true
+			}
+		}
+		util.Assert(t, isListed, "source collection must be listed")
+
+		path := "/collections/src:mock_a"
+		resp = hTest.DoRequest(t, path)
+		body, _ = ioutil.ReadAll(resp.Body)
+		var coll api.CollectionInfo
+		errUnMarsh = json.Unmarshal(body, &coll)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		checkCollection(t, &coll, tbl.ID, tbl.Title)
+
+		resp = hTest.DoRequest(t, path+"/items/1")
+		body, _ = ioutil.ReadAll(resp.Body)
+		var feature api.GeojsonFeatureData
+		errUnMarsh = json.Unmarshal(body, &feature)
+		util.Assert(t, errUnMarsh == nil, fmt.Sprintf("%v", errUnMarsh))
+		util.Equals(t, "1", feature.ID, "feature id")
+
+		// writes go to the features of the source table
+		sizeBefore := catalogMock.TableSize("mock_a")
+		header := make(http.Header)
+		header.Add("Content-Type", api.ContentTypeGeoJSON)
+		jsonStr := data.MakeJSONWithPointForSimple(tbl.ID, 0, 12, 34)
+		rr := hTest.DoPostRequest(t, path+"/items", []byte(jsonStr), header)
+		loc := rr.Header().Get("Location")
+		newID := catalogMock.TableSize(tbl.ID)
+		util.Assert(t, strings.Contains(loc, fmt.Sprintf("%v/items/%d", path, newID)), "Header location must contain the source collection id")
+		hTest.DoRequest(t, fmt.Sprintf("%v/items/%d", path, newID))
+		util.Equals(t, sizeBefore, catalogMock.TableSize("mock_a"), "default source table size")
+
+		hTest.DoDeleteRequestStatus(t, path+"/items/1", http.StatusNoContent)
+		hTest.DoRequestStatus(t, path+"/items/1", http.StatusNotFound)
+		hTest.DoRequest(t, "/collections/mock_a/items/1")
+
+		hTest.DoRequestStatus(t, "/collections/other:mock_a", http.StatusNotFound)
+	})
+}
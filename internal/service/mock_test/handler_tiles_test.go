@@ -0,0 +1,148 @@
+package mock_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/api"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+func (t *MockTests) TestTile() {
+	t.Test.Run("TestTile", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/collections/mock_a/tiles/WebMercatorQuad/0/0/0")
+		util.Equals(t, api.ContentTypeMVT, resp.Header().Get("Content-Type"), "Content-Type")
+		util.Assert(t, resp.Header().Get("Last-Modified") != "", "Last-Modified header missing")
+
+		etag, err := api.DecodeStrongEtag(resp.Header().Get("Etag"))
+		util.Assert(t, err == nil, "Etag must be a strong etag")
+		util.Equals(t, "mock_a", etag.Collection, "etag collection")
+		util.Equals(t, "tiles/WebMercatorQuad/0/0/0", etag.FeatureId, "etag tile")
+		util.Equals(t, 3857, etag.Srid, "etag srid")
+		util.Equals(t, "mvt", etag.Format, "etag format")
+
+		// same tile with extension
+		resp2 := hTest.DoRequest(t, "/collections/mock_a/tiles/WebMercatorQuad/0/0/0.mvt")
+		util.Equals(t, resp.Header().Get("Etag"), resp2.Header().Get("Etag"), "Etag with extension")
+	})
+}
+
+func (t *MockTests) TestTileEtagFilter() {
+	t.Test.Run("TestTileEtagFilter", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/collections/mock_a/tiles/WebMercatorQuad/0/0/0")
+		respFilter := hTest.DoRequest(t, "/collections/mock_a/tiles/WebMercatorQuad/0/0/0?prop_b=1")
+		util.Assert(t, resp.Header().Get("Etag") != respFilter.Header().Get("Etag"),
+			"filtered tile must have a different Etag")
+	})
+}
+
+func (t *MockTests) TestTileIfNoneMatch() {
+	t.Test.Run("TestTileIfNoneMatch", func(t *testing.T) {
+		path := "/collections/mock_a/tiles/WebMercatorQuad/1/0/0"
+		resp := hTest.DoRequest(t, path)
+		etag := resp.Header().Get("Etag")
+
+		header := make(http.Header)
+		header.Add("If-None-Match", "\""+etag+"\"")
+		resp2 := hTest.DoRequestMethodStatus(t, "GET", path, nil, header, http.StatusNotModified)
+		util.Equals(t, etag, resp2.Header().Get("Etag"), "Etag on not modified")
+
+		header = make(http.Header)
+		header.Add("If-None-Match", "W/\"other\"")
+		hTest.DoRequestMethodStatus(t, "GET", path, nil, header, http.StatusOK)
+	})
+}
+
+func (t *MockTests) TestTileIfModifiedSince() {
+	t.Test.Run("TestTileIfModifiedSince", func(t *testing.T) {
+		path := "/collections/mock_a/tiles/WebMercatorQuad/1/1/0"
+		resp := hTest.DoRequest(t, path)
+		lastModified := resp.Header().Get("Last-Modified")
+
+		header := make(http.Header)
+		header.Add("If-Modified-Since", lastModified)
+		hTest.DoRequestMethodStatus(t, "GET", path, nil, header, http.StatusNotModified)
+
+		header = make(http.Header)
+		header.Add("If-Modified-Since", "Mon, 01 Jan 2001 00:00:00 GMT")
+		hTest.DoRequestMethodStatus(t, "GET", path, nil, header, http.StatusOK)
+
+		// If-Modified-Since is ignored if If-None-Match is present
+		header = make(http.Header)
+		header.Add("If-None-Match", "W/\"other\"")
+		header.Add("If-Modified-Since", lastModified)
+		hTest.DoRequestMethodStatus(t, "GET", path, nil, header, http.StatusOK)
+	})
+}
+
+func (t *MockTests) TestTileInvalid() {
+	t.Test.Run("TestTileInvalid", func(t *testing.T) {
+		hTest.DoRequestStatus(t, "/collections/mock_a/tiles/WebMercatorQuad/1/2/0", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/tiles/WebMercatorQuad/25/0/0", http.StatusBadRequest)
+		hTest.DoRequestStatus(t, "/collections/mock_a/tiles/WorldCRS84Quad/0/0/0", http.StatusNotFound)
+		hTest.DoRequestStatus(t, "/collections/missing/tiles/WebMercatorQuad/0/0/0", http.StatusNotFound)
+	})
+}
+
+func (t *MockTests) TestTileset() {
+	t.Test.Run("TestTileset", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/collections/mock_a/tiles/WebMercatorQuad")
+		var tileset api.Tileset
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(resp), &tileset)
+		util.Assert(t, errUnMarsh == nil, "%s", errUnMarsh)
+
+		util.Equals(t, "vector", tileset.DataType, "dataType")
+		util.Equals(t, 1, len(tileset.Layers), "# layers")
+		util.Equals(t, "mock_a", tileset.Layers[0].ID, "layer id")
+		util.Equals(t, 3, len(tileset.Links), "# links")
+		checkLink(t, tileset.Links[0], api.RelSelf, api.ContentTypeJSON, hTest.UrlBase+"/collections/mock_a/tiles/WebMercatorQuad")
+		checkLink(t, tileset.Links[1], api.RelTilingScheme, api.ContentTypeJSON, hTest.UrlBase+"/tileMatrixSets/WebMercatorQuad")
+		checkLink(t, tileset.Links[2], api.RelItem, api.ContentTypeMVT,
+			hTest.UrlBase+"/collections/mock_a/tiles/WebMercatorQuad/{tileMatrix}/{tileCol}/{tileRow}")
+		util.Assert(t, tileset.Links[2].Templated, "tile link must be templated")
+
+		hTest.DoRequestStatus(t, "/collections/mock_a/tiles/WorldCRS84Quad", http.StatusNotFound)
+
+		resp = hTest.DoRequest(t, "/collections/mock_a/tiles")
+		var tilesets api.Tilesets
+		errUnMarsh = json.Unmarshal(hTest.ReadBody(resp), &tilesets)
+		util.Assert(t, errUnMarsh == nil, "%s", errUnMarsh)
+		util.Equals(t, 1, len(tilesets.Tilesets), "# tilesets")
+	})
+}
+
+func (t *MockTests) TestTileMatrixSet() {
+	t.Test.Run("TestTileMatrixSet", func(t *testing.T) {
+		resp := hTest.DoRequest(t, "/tileMatrixSets/WebMercatorQuad")
+		var tms api.TileMatrixSet
+		errUnMarsh := json.Unmarshal(hTest.ReadBody(resp), &tms)
+		util.Assert(t, errUnMarsh == nil, "%s", errUnMarsh)
+
+		util.Equals(t, "WebMercatorQuad", tms.ID, "id")
+		util.Equals(t, 25, len(tms.TileMatrices), "# tile matrices")
+		util.Equals(t, 1, tms.TileMatrices[0].MatrixWidth, "matrix width at zoom 0")
+		util.Equals(t, 1024, tms.TileMatrices[10].MatrixWidth, "matrix width at zoom 10")
+
+		resp = hTest.DoRequest(t, "/tileMatrixSets")
+		var sets api.TileMatrixSets
+		errUnMarsh = json.Unmarshal(hTest.ReadBody(resp), &sets)
+		util.Assert(t, errUnMarsh == nil, "%s", errUnMarsh)
+		util.Equals(t, 1, len(sets.TileMatrixSets), "# tile matrix sets")
+
+		hTest.DoRequestStatus(t, "/tileMatrixSets/WorldCRS84Quad", http.StatusNotFound)
+	})
+}
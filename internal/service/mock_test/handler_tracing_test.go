@@ -0,0 +1,91 @@
+package mock_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+)
+
+// checks that requests are traced with spans for the handler, the CQL filter and the response,
+// continuing the trace of the traceparent header
+func (t *MockTests) TestTracing() {
+	t.Test.Run("TestTracing", func(t *testing.T) {
+		file, _ := ioutil.TempFile("", "spans*.json")
+		file.Close()
+		defer os.Remove(file.Name())
+		exporter, err := tracing.NewFileExporter(file.Name())
+		util.Assert(t, err == nil, "unexpected error: %v", err)
+		tracing.Init(exporter, tracing.Options{ServiceName: "pg_featureserv", SampleRatio: 1})
+		defer tracing.Shutdown()
+
+		header := http.Header{}
+		header.Set(tracing.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		hTest.DoRequestMethodStatus(t, "GET", "/collections/mock_a/items?filter=prop_b=1", nil, header, http.StatusOK)
+		hTest.DoRequestMethodStatus(t, "GET", "/collections/mock_a/items?filter=prop_b==", nil, header, http.StatusBadRequest)
+		tracing.Shutdown()
+
+		spans := readSpans(t, file.Name())
+		var handlerSpans []fileSpan
+		names := map[string]int{}
+		for _, span := range spans {
+			util.Equals(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID, "trace id of "+span.Name)
+			names[span.Name]++
+			if strings.HasPrefix(span.Name, "GET /pg_featureserv/collections/{cid}/items") {
+				handlerSpans = append(handlerSpans, span)
+			}
+		}
+		util.Equals(t, 2, len(handlerSpans), "handler spans")
+		util.Equals(t, "00f067aa0ba902b7", handlerSpans[0].Parent.SpanID, "handler span parent")
+		util.Equals(t, 2, names["cql.transpile"], "CQL spans")
+		util.Equals(t, 1, names["write response"], "response spans")
+		util.Assert(t, handlerSpans[0].Status.Code == "Error" || handlerSpans[1].Status.Code == "Error", "failed request has error status")
+	})
+}
+
+// fileSpan holds the fields of a span written by the file exporter
+type fileSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		SpanID string
+	}
+	Status struct {
+		Code string
+	}
+}
+
+// readSpans reads the spans of a file, one span per line
+func readSpans(t *testing.T, path string) []fileSpan {
+	data, err := ioutil.ReadFile(path)
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	var spans []fileSpan
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var span fileSpan
+		err := json.Unmarshal([]byte(line), &span)
+		util.Assert(t, err == nil, "invalid span: %v", err)
+		spans = append(spans, span)
+	}
+	return spans
+}
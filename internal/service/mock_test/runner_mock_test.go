@@ -66,6 +66,11 @@ func TestRunnerHandlerMock(t *testing.T) {
 		m.TestFeatureFormats()
 		m.TestCollectionItem()
 		m.TestCollectionItemsResponse()
+		m.TestCollectionItemsStreamed()
+		m.TestCollectionItemsFlatGeobuf()
+		m.TestApiFlatGeobuf()
+		m.TestCollectionItemsCSV()
+		m.TestCollectionItemsGML()
 		m.TestCollectionMissingItemsNotFound()
 		m.TestCollectionItemPropertiesEmpty()
 		m.TestCollectionNotFound()
@@ -89,15 +94,29 @@ func TestRunnerHandlerMock(t *testing.T) {
 		m.TestRoot()
 		m.TestBBox()
 		m.TestBBoxInvalid()
+		m.TestForce2D()
 		m.TestFilterB()
 		m.TestFilterBD()
 		m.TestFilterBDNone()
 		m.TestFilterD()
+		m.TestFilterLang()
+		m.TestFilterPostBody()
+		m.TestDateTime()
+		m.TestTemporalExtent()
+		m.TestCrs()
+		m.TestCrsLatLon()
 		m.TestLimit()
 		m.TestLimitInvalid()
 		m.TestLimitZero()
 		m.TestOffset()
 		m.TestOffsetInvalid()
+		m.TestPagingLinks()
+		m.TestPagingLinksFirstPage()
+		m.TestPagingNumberMatched()
+		m.TestPagingNumberMatchedNone()
+		m.TestPagingNumberMatchedEstimate()
+		m.TestPagingCursor()
+		m.TestPagingCursorInvalid()
 		m.TestProperties()
 		m.TestPropertiesEmpty()
 		m.TestPropertiesAll()
@@ -117,8 +136,25 @@ func TestRunnerHandlerMock(t *testing.T) {
 		m.TestHTMLFunctions()
 		m.TestHTMLItem()
 		m.TestHTMLItems()
+		m.TestHTMLItemsPaging()
 		m.TestHTMLRoot()
 	})
+	t.Run("GET - Tiles", func(t *testing.T) {
+		m := MockTests{Test: t}
+		m.TestTile()
+		m.TestTileEtagFilter()
+		m.TestTileIfNoneMatch()
+		m.TestTileIfModifiedSince()
+		m.TestTileInvalid()
+		m.TestTileset()
+		m.TestTileMatrixSet()
+	})
+	t.Run("GET - Queryables", func(t *testing.T) {
+		m := MockTests{Test: t}
+		m.TestCollectionQueryables()
+		m.TestCollectionSortables()
+		m.TestFunctionQueryables()
+	})
 	t.Run("GET - functions", func(t *testing.T) {
 		m := MockTests{Test: t}
 		m.TestFunctionJSON()
@@ -171,6 +207,50 @@ func TestRunnerHandlerMock(t *testing.T) {
 		m.TestUpdateFeaturePartialGeomFailure()
 		afterEachRun()
 	})
+	t.Run("AUTH", func(t *testing.T) {
+		beforeEachRun()
+		m := MockTests{Test: t}
+		m.TestAuthWriteRequests()
+		m.TestAuthAnonymousWrite()
+		m.TestSessionRoleVisibility()
+		afterEachRun()
+	})
+	t.Run("SOURCES", func(t *testing.T) {
+		beforeEachRun()
+		m := MockTests{Test: t}
+		m.TestSourceID()
+		m.TestSourceCollection()
+		afterEachRun()
+	})
+	t.Run("LIMITS", func(t *testing.T) {
+		beforeEachRun()
+		m := MockTests{Test: t}
+		m.TestAPIKeys()
+		m.TestRateLimit()
+		m.TestRateLimitTrustedProxies()
+		afterEachRun()
+	})
+	t.Run("METRICS", func(t *testing.T) {
+		beforeEachRun()
+		m := MockTests{Test: t}
+		m.TestMetrics()
+		m.TestMetricsAbortedRequest()
+		m.TestMetricsRejectedRequest()
+		afterEachRun()
+	})
+	t.Run("TRACING", func(t *testing.T) {
+		beforeEachRun()
+		m := MockTests{Test: t}
+		m.TestTracing()
+		afterEachRun()
+	})
+	t.Run("HEALTH", func(t *testing.T) {
+		beforeEachRun()
+		m := MockTests{Test: t}
+		m.TestHealth()
+		m.TestHealthWithoutAPIKey()
+		afterEachRun()
+	})
 
 	// nettoyage après execution des tests
 	afterRun()
@@ -204,6 +284,7 @@ func afterEachRun() {
 // ...
 func initCatMock() {
 	conf.Configuration.Database.AllowWrite = true
+	conf.Configuration.Auth.AllowAnonymousWrite = true
 	catalogMock = data.CatMockInstance()
 	service.SetCatalogInstance(catalogMock)
 
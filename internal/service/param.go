@@ -14,16 +14,21 @@ package service
 */
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
 	"github.com/CrunchyData/pg_featureserv/internal/conf"
 	"github.com/CrunchyData/pg_featureserv/internal/cql"
 	"github.com/CrunchyData/pg_featureserv/internal/data"
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type NameValMap map[string]string
@@ -31,18 +36,23 @@ type NameValMap map[string]string
 // RequestParam holds the parameters for a request
 type RequestParam struct {
 	Crs                int
+	CrsLatLon          bool
 	Limit              int
 	Offset             int
 	Bbox               *api.Extent
 	BboxCrs            int
 	Properties         []string
 	Filter             string
+	FilterLang         string
 	FilterCrs          int
 	GroupBy            []string
 	SortBy             []api.Sorting
 	Precision          int
+	Force2D            bool
 	TransformFuns      []api.TransformFunction
 	MaxAllowableOffset float64
+	Cursor             *api.Cursor
+	DateTime           *api.DateTime
 	Values             NameValMap
 }
 
@@ -51,21 +61,31 @@ func parseRequestParams(r *http.Request) (RequestParam, error) {
 	paramValues := extractSingleArgs(queryValues)
 
 	param := RequestParam{
-		Crs:       data.SRID_4326,
-		Limit:     conf.Configuration.Paging.LimitDefault,
-		Offset:    0,
-		Precision: -1,
-		BboxCrs:   data.SRID_4326,
-		Filter:    "",
-		Values:    paramValues,
-	}
-
-	// --- crs parameter
-	crs, err := parseInt(paramValues, api.ParamCrs, 0, 99999999, data.SRID_4326)
+		Crs:        data.SRID_4326,
+		Limit:      conf.Current().Paging.LimitDefault,
+		Offset:     0,
+		Precision:  -1,
+		BboxCrs:    data.SRID_4326,
+		Filter:     "",
+		FilterLang: api.FilterLangCQL2Text,
+		Values:     paramValues,
+	}
+
+	// --- crs parameter, or else Accept-Crs header
+	crs, err := parseCrs(paramValues, api.ParamCrs)
 	if err != nil {
 		return param, err
 	}
+	crsLatLon := api.IsCrsLatLon(paramValues[api.ParamCrs])
+	if _, ok := paramValues[api.ParamCrs]; !ok && r.Header.Get(api.HeaderAcceptCrs) != "" {
+		crs, err = api.ParseCrs(r.Header.Get(api.HeaderAcceptCrs))
+		if err != nil {
+			return param, err
+		}
+		crsLatLon = api.IsCrsLatLon(r.Header.Get(api.HeaderAcceptCrs))
+	}
 	param.Crs = crs
+	param.CrsLatLon = crsLatLon
 
 	// --- limit parameter
 	limit, err := parseLimit(paramValues)
@@ -81,6 +101,13 @@ func parseRequestParams(r *http.Request) (RequestParam, error) {
 	}
 	param.Offset = offset
 
+	// --- cursor parameter
+	cursor, err := parseCursor(paramValues)
+	if err != nil {
+		return param, err
+	}
+	param.Cursor = cursor
+
 	// --- bbox parameter
 	bbox, err := parseBbox(paramValues)
 	if err != nil {
@@ -89,20 +116,42 @@ func parseRequestParams(r *http.Request) (RequestParam, error) {
 	param.Bbox = bbox
 
 	// --- bbox-crs parameter
-	bboxcrs, err := parseInt(paramValues, api.ParamBboxCrs, 0, 99999999, data.SRID_4326)
+	bboxcrs, err := parseCrs(paramValues, api.ParamBboxCrs)
 	if err != nil {
 		return param, err
 	}
 	param.BboxCrs = bboxcrs
+	// --- bbox in latitude/longitude order is swapped to x/y order
+	if bbox != nil && api.IsCrsLatLon(paramValues[api.ParamBboxCrs]) {
+		bbox.Minx, bbox.Miny = bbox.Miny, bbox.Minx
+		bbox.Maxx, bbox.Maxy = bbox.Maxy, bbox.Maxx
+	}
+
+	// --- datetime parameter
+	dateTime, err := parseDateTime(paramValues)
+	if err != nil {
+		return param, err
+	}
+	param.DateTime = dateTime
 
 	// --- filter parameter
 	param.Filter = parseString(paramValues, api.ParamFilter)
 
+	// --- filter-lang parameter
+	filterLang, err := parseFilterLang(paramValues)
+	if err != nil {
+		return param, err
+	}
+	param.FilterLang = filterLang
+
 	// --- filter-crs parameter
-	filterCrs, err := parseInt(paramValues, api.ParamFilterCrs, 0, 99999999, data.SRID_4326)
+	filterCrs, err := parseCrs(paramValues, api.ParamFilterCrs)
 	if err != nil {
 		return param, err
 	}
+	if api.IsCrsLatLon(paramValues[api.ParamFilterCrs]) {
+		return param, fmt.Errorf(api.ErrMsgCrsLatLon, api.ParamFilterCrs, paramValues[api.ParamFilterCrs])
+	}
 	param.FilterCrs = filterCrs
 
 	// --- properties parameter
@@ -140,6 +189,12 @@ func parseRequestParams(r *http.Request) (RequestParam, error) {
 	}
 	param.Precision = precision
 
+	// --- force2d parameter
+	param.Force2D, err = parseBool(paramValues, api.ParamForce2D)
+	if err != nil {
+		return param, err
+	}
+
 	// --- transform parameter
 	param.TransformFuns, err = parseTransform(paramValues)
 	if err != nil {
@@ -208,24 +263,63 @@ func parseFloat(values NameValMap, key string, minVal float64, maxVal float64, d
 	return val, nil
 }
 
+// parseCrs parses a CRS parameter as an SRID, which is 4326 if not present
+func parseCrs(values NameValMap, key string) (int, error) {
+	val := values[key]
+	if len(val) < 1 {
+		return data.SRID_4326, nil
+	}
+	srid, err := api.ParseCrs(val)
+	if err != nil {
+		return 0, fmt.Errorf(api.ErrMsgInvalidParameterValue, key, val)
+	}
+	return srid, nil
+}
+
+// parseFilterLang parses the language of the filter parameter, which is CQL2-Text by default
+func parseFilterLang(values NameValMap) (string, error) {
+	val := strings.ToLower(parseString(values, api.ParamFilterLang))
+	switch val {
+	case "":
+		return api.FilterLangCQL2Text, nil
+	case api.FilterLangCQL2Text, api.FilterLangCQL2JSON:
+		return val, nil
+	}
+	return "", fmt.Errorf(api.ErrMsgInvalidParameterValue, api.ParamFilterLang, values[api.ParamFilterLang])
+}
+
 func parseLimit(values NameValMap) (int, error) {
 	val := values[api.ParamLimit]
 	if len(val) < 1 {
-		return conf.Configuration.Paging.LimitDefault, nil
+		return conf.Current().Paging.LimitDefault, nil
 	}
 	limit, err := strconv.Atoi(val)
 	if err != nil {
 		return 0, fmt.Errorf(api.ErrMsgInvalidParameterValue, api.ParamLimit, val)
 	}
-	if limit < 0 || limit > conf.Configuration.Paging.LimitMax {
-		limit = conf.Configuration.Paging.LimitMax
+	if limit < 0 || limit > conf.Current().Paging.LimitMax {
+		limit = conf.Current().Paging.LimitMax
 	}
 	return limit, nil
 }
 
+// parseCursor parses the cursor query parameter, if present, or nil if not
+func parseCursor(values NameValMap) (*api.Cursor, error) {
+	val := values[api.ParamCursor]
+	if len(val) < 1 {
+		return nil, nil
+	}
+	cursor, err := api.DecodeCursor(val)
+	if err != nil {
+		return nil, fmt.Errorf(api.ErrMsgInvalidCursor, err)
+	}
+	return cursor, nil
+}
+
 /*
 parseBbox parses the bbox query parameter, if present, or nll if not
-This has the format bbox=minLon,minLat,maxLon,maxLat.
+This has the format bbox=minLon,minLat,maxLon,maxLat
+or bbox=minLon,minLat,minZ,maxLon,maxLat,maxZ.
 */
 func parseBbox(values NameValMap) (*api.Extent, error) {
 	val := values[api.ParamBbox]
@@ -233,31 +327,95 @@ func parseBbox(values NameValMap) (*api.Extent, error) {
 		return nil, nil
 	}
 	nums := strings.Split(val, ",")
-	var isErr = false
-	if len(nums) != 4 {
+	if len(nums) != 4 && len(nums) != 6 {
 		return nil, fmt.Errorf(api.ErrMsgInvalidParameterValue, api.ParamBbox, val)
 	}
-	minLon, err := strconv.ParseFloat(nums[0], 64)
-	if err != nil {
-		isErr = true
+	coords := make([]float64, len(nums))
+	for i, num := range nums {
+		coord, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return nil, fmt.Errorf(api.ErrMsgInvalidParameterValue, api.ParamBbox, val)
+		}
+		coords[i] = coord
 	}
-	minLat, err := strconv.ParseFloat(nums[1], 64)
-	if err != nil {
-		isErr = true
+	if len(coords) == 6 {
+		bbox := api.Extent{Minx: coords[0], Miny: coords[1], Minz: coords[2],
+			Maxx: coords[3], Maxy: coords[4], Maxz: coords[5], HasZ: true}
+		return &bbox, nil
 	}
-	maxLon, err := strconv.ParseFloat(nums[2], 64)
-	if err != nil {
-		isErr = true
+	var bbox = api.Extent{Minx: coords[0], Miny: coords[1], Maxx: coords[2], Maxy: coords[3]}
+	return &bbox, nil
+}
+
+// parseBool parses a boolean parameter, which is false if not present
+func parseBool(values NameValMap, key string) (bool, error) {
+	val := values[key]
+	if len(val) < 1 {
+		return false, nil
 	}
-	maxLat, err := strconv.ParseFloat(nums[3], 64)
+	b, err := strconv.ParseBool(val)
 	if err != nil {
-		isErr = true
+		return false, fmt.Errorf(api.ErrMsgInvalidParameterValue, key, val)
 	}
-	if isErr {
-		return nil, fmt.Errorf(api.ErrMsgInvalidParameterValue, api.ParamBbox, val)
+	return b, nil
+}
+
+// datetime values are RFC 3339 timestamps or dates
+const dateLayout = "2006-01-02"
+
+var dateTimeLayouts = []string{time.RFC3339Nano, dateLayout}
+
+/*
+parseDateTime parses the datetime query parameter, if present, or nil if not.
+This is an instant, or an interval start/end where an open bound is ".." or empty.
+A date as the instant or end covers the whole day,
+so the end is the start of the next day, excluded.
+*/
+func parseDateTime(values NameValMap) (*api.DateTime, error) {
+	val := strings.TrimSpace(values[api.ParamDateTime])
+	if len(val) < 1 {
+		return nil, nil
 	}
-	var bbox = api.Extent{Minx: minLon, Miny: minLat, Maxx: maxLon, Maxy: maxLat}
-	return &bbox, nil
+	errVal := fmt.Errorf(api.ErrMsgInvalidParameterValue, api.ParamDateTime, val)
+	bounds := strings.Split(val, "/")
+	if len(bounds) > 2 {
+		return nil, errVal
+	}
+	var times []time.Time
+	for i, bound := range bounds {
+		if len(bounds) == 2 && (bound == "" || bound == "..") {
+			bounds[i] = ""
+			continue
+		}
+		t, ok := parseTime(bound)
+		if !ok {
+			return nil, errVal
+		}
+		times = append(times, t)
+	}
+	if len(times) == 0 {
+		return nil, errVal
+	}
+	dt := &api.DateTime{Start: bounds[0], End: bounds[len(bounds)-1]}
+	if day, err := time.Parse(dateLayout, dt.End); err == nil {
+		day = day.AddDate(0, 0, 1)
+		dt.End = day.Format(dateLayout)
+		dt.EndExclusive = true
+		times[len(times)-1] = day
+	}
+	if len(times) == 2 && (times[0].After(times[1]) || (dt.EndExclusive && times[0].Equal(times[1]))) {
+		return nil, errVal
+	}
+	return dt, nil
+}
+
+func parseTime(val string) (time.Time, bool) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
 }
 
 // parseProperties extracts an array of rawo property names to be included
@@ -390,27 +548,31 @@ const (
 	functionPrefixST  = "st_"
 )
 
-var transformFunctionWhitelist map[string]string
+// transformFunctionWhitelist holds the map of the transform functions, keyed by lowercase name.
+// It is replaced as a whole when the configuration is reloaded
+var transformFunctionWhitelist atomic.Value
 
 func initTransforms(funNames []string) {
-	transformFunctionWhitelist = make(map[string]string)
+	whitelist := make(map[string]string)
 	for _, name := range funNames {
 		nameLow := strings.ToLower(name)
-		transformFunctionWhitelist[nameLow] = name
+		whitelist[nameLow] = name
 	}
+	transformFunctionWhitelist.Store(whitelist)
 }
 
 // actualFunctionName converts an input function name
 // to an actual function name from the whitelist
 func actualFunctionName(name string) string {
+	whitelist, _ := transformFunctionWhitelist.Load().(map[string]string)
 	nameLow := strings.ToLower(name)
-	if actual, ok := transformFunctionWhitelist[nameLow]; ok {
+	if actual, ok := whitelist[nameLow]; ok {
 		return actual
 	}
 	if !strings.HasPrefix(nameLow, functionPrefixST) {
 		// supply ST_ prefix if not there and try again
 		stName := functionPrefixST + nameLow
-		if actual, ok := transformFunctionWhitelist[stName]; ok {
+		if actual, ok := whitelist[stName]; ok {
 			return actual
 		}
 	}
@@ -468,9 +630,10 @@ func parseFilter(paramMap map[string]string, colNameMap map[string]api.Column) [
 }
 
 // createQueryParams applies any cross-parameter logic
-func createQueryParams(param *RequestParam, colNames []string, sourceSRID int) (*data.QueryParam, error) {
+func createQueryParams(ctx context.Context, param *RequestParam, colNames []string, sourceSRID int) (*data.QueryParam, error) {
 	query := data.QueryParam{
 		Crs:                param.Crs,
+		CrsLatLon:          param.CrsLatLon,
 		Limit:              param.Limit,
 		Offset:             param.Offset,
 		Bbox:               param.Bbox,
@@ -478,6 +641,7 @@ func createQueryParams(param *RequestParam, colNames []string, sourceSRID int) (
 		GroupBy:            param.GroupBy,
 		SortBy:             param.SortBy,
 		Precision:          param.Precision,
+		Force2D:            param.Force2D,
 		TransformFuns:      param.TransformFuns,
 		MaxAllowableOffset: param.MaxAllowableOffset,
 	}
@@ -499,7 +663,15 @@ func createQueryParams(param *RequestParam, colNames []string, sourceSRID int) (
 	}
 	query.Columns = normalizePropNames(cols, colNames)
 	//-- convert filter CQL
-	sql, err := cql.TranspileToSQL(param.Filter, param.FilterCrs, sourceSRID)
+	transpile := cql.TranspileToSQL
+	if param.FilterLang == api.FilterLangCQL2JSON {
+		transpile = cql.TranspileJSONToSQL
+	}
+	_, span := tracing.Start(ctx, "cql.transpile")
+	span.SetAttributes(attribute.String("cql.filter_lang", param.FilterLang))
+	sql, err := transpile(param.Filter, param.FilterCrs, sourceSRID)
+	tracing.SetError(span, err)
+	span.End()
 	if err != nil {
 		return &query, err
 	}
@@ -507,3 +679,39 @@ func createQueryParams(param *RequestParam, colNames []string, sourceSRID int) (
 
 	return &query, nil
 }
+
+// setDateTimeParam sets the datetime query, which requires a table with time columns
+func setDateTimeParam(query *data.QueryParam, param *RequestParam, timeColumns []string) error {
+	if param.DateTime == nil {
+		return nil
+	}
+	if len(timeColumns) == 0 {
+		return fmt.Errorf(api.ErrMsgInvalidParameterValue, api.ParamDateTime, "collection has no time columns")
+	}
+	query.DateTime = param.DateTime
+	return nil
+}
+
+// setKeysetParams sets up keyset paging, if requested by a cursor or enabled by configuration.
+// Keyset paging requires an ID column, and is not possible for grouped features
+func setKeysetParams(query *data.QueryParam, param *RequestParam, idColumn string) error {
+	if param.Cursor == nil && !conf.Current().Paging.UseCursor {
+		return nil
+	}
+	keyCols := api.KeysetColumns(param.SortBy, idColumn)
+	if keyCols == nil || param.GroupBy != nil {
+		if param.Cursor != nil {
+			return fmt.Errorf(api.ErrMsgInvalidCursor, "keyset paging not available")
+		}
+		return nil
+	}
+	query.KeysetColumns = keyCols
+	query.KeysetIsDesc = len(param.SortBy) > 0 && param.SortBy[0].IsDesc
+	if param.Cursor != nil {
+		if !param.Cursor.IsForColumns(keyCols) {
+			return fmt.Errorf(api.ErrMsgInvalidCursor, "cursor does not match sort order")
+		}
+		query.Cursor = param.Cursor.Values
+	}
+	return nil
+}
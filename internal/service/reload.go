@@ -0,0 +1,83 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/cql"
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
+	"github.com/gorilla/handlers"
+	log "github.com/sirupsen/logrus"
+)
+
+// corsHandler applies the CORS allowed origins of the current configuration
+var corsHandler *reloadableCORSHandler
+
+type reloadableCORSHandler struct {
+	next    http.Handler
+	handler atomic.Value
+}
+
+func newCORSHandler(next http.Handler) *reloadableCORSHandler {
+	h := &reloadableCORSHandler{next: next}
+	h.reload()
+	return h
+}
+
+// reload sets the CORS handling from the configuration
+func (h *reloadableCORSHandler) reload() {
+	corsOpt := handlers.AllowedOrigins([]string{conf.Current().Server.CORSOrigins})
+	corsHeadersOpt := handlers.AllowedHeaders([]string{headerAuthorization, headerAPIKey, headerConsistencyToken, tracing.HeaderTraceparent})
+	corsExposedOpt := handlers.ExposedHeaders([]string{headerConsistencyToken})
+	h.handler.Store(handlers.CORS(corsOpt, corsHeadersOpt, corsExposedOpt)(h.next))
+}
+
+func (h *reloadableCORSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// ReloadConfig reads the configuration again, and applies the settings
+// which can change while the service runs, without dropping connections.
+// Changed settings which require a restart are reported
+func ReloadConfig() {
+	log.Info("Reloading configuration")
+	restart, err := conf.ReloadConfig()
+	if err != nil {
+		log.Errorf("Configuration not reloaded: %v", err)
+		return
+	}
+	if conf.Current().Server.Debug {
+		log.SetLevel(log.TraceLevel)
+	} else {
+		log.SetLevel(log.InfoLevel)
+	}
+	conf.DumpConfig()
+
+	initTransforms(conf.Current().Server.TransformFunctions)
+	cql.InitFunctions(conf.Current().Server.FilterFunctions)
+	if corsHandler != nil {
+		corsHandler.reload()
+		log.Infof("CORS Allowed Origins: %v\n", conf.Current().Server.CORSOrigins)
+	}
+	catalogInstance.Reload(conf.Current().Database.TableIncludes, conf.Current().Database.TableExcludes)
+
+	if len(restart) > 0 {
+		log.Warnf("Changed settings which require a restart: %v", strings.Join(restart, ", "))
+	}
+	log.Info("Configuration reloaded")
+}
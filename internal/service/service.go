@@ -20,11 +20,14 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
 	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/cql"
 	"github.com/CrunchyData/pg_featureserv/internal/data"
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
 	"github.com/gorilla/handlers"
 	log "github.com/sirupsen/logrus"
 )
@@ -36,60 +39,89 @@ var serverTLS *http.Server
 
 // Initialize sets the service state from configuration
 func Initialize() {
-	initTransforms(conf.Configuration.Server.TransformFunctions)
+	initTransforms(conf.Current().Server.TransformFunctions)
+	cql.InitFunctions(conf.Current().Server.FilterFunctions)
+	initAuth(conf.Current().Auth)
+	initTracing(conf.Current().Tracing)
 }
 
 func createServers() {
-	confServ := conf.Configuration.Server
+	confServ := conf.Current().Server
 
 	bindAddress := fmt.Sprintf("%v:%v", confServ.HttpHost, confServ.HttpPort)
 	bindAddressTLS := fmt.Sprintf("%v:%v", confServ.HttpHost, confServ.HttpsPort)
 	// Use HTTPS only if server certificate and private key files specified
-	isTLSEnabled = conf.Configuration.IsTLSEnabled()
+	isTLSEnabled = conf.Current().IsTLSEnabled()
 
 	log.Infof("Serving HTTP  at %s", formatBaseURL("http://", bindAddress, confServ.BasePath))
 	if isTLSEnabled {
 		log.Infof("Serving HTTPS at %s", formatBaseURL("https://", bindAddressTLS, confServ.BasePath))
 	}
-	log.Infof("CORS Allowed Origins: %v\n", conf.Configuration.Server.CORSOrigins)
+	log.Infof("CORS Allowed Origins: %v\n", conf.Current().Server.CORSOrigins)
 
 	router := InitRouter(confServ.BasePath)
 
-	// writeTimeout is slighlty longer than request timeout to allow writing error response
-	timeoutSecRequest := conf.Configuration.Server.WriteTimeoutSec
+	// writeTimeout is slighlty longer than request timeout to allow writing error response.
+	// It applies to the connection, so it must also allow the longer streamed responses
+	timeoutSecRequest := conf.Current().Server.WriteTimeoutSec
+	timeoutSecStream := streamTimeoutSec()
 	timeoutSecWrite := timeoutSecRequest + 1
+	if timeoutSecStream > timeoutSecRequest {
+		timeoutSecWrite = timeoutSecStream + 1
+	}
 
 	// ----  Handler chain  --------
-	// set CORS handling according to config
-	corsOpt := handlers.AllowedOrigins([]string{conf.Configuration.Server.CORSOrigins})
-	corsHandler := handlers.CORS(corsOpt)(router)
+	// check API keys and rate limits first, then verify bearer tokens
+	// and read the consistency token of replica reads,
+	// inside CORS handling so that rejections carry CORS headers.
+	// CORS handling is set according to config, and updated when it is reloaded
+	initLimits()
+	consistencyHandler := ConsistencyHandler(router)
+	authHandler := AuthHandler(consistencyHandler)
+	limitHandler := LimitHandler(authHandler)
+	corsHandler = newCORSHandler(limitHandler)
 	compressHandler := handlers.CompressHandler(corsHandler)
 
 	// Use a TimeoutHandler to ensure a request does not run past the WriteTimeout duration.
 	// This provides a context that allows cancellation to be propagated
-	// down to the database driver.
-	//(Unfortunately this does not propagate to the database itself.
-	// That will require another mechanism such as session config statement_timeout)
+	// down to the database driver, which cancels the running query in the database.
+	// Queries are also bounded in the database by statement_timeout
 	// If timeout expires, service returns 503 and a text message
 	timeoutHandler := http.TimeoutHandler(compressHandler,
 		time.Duration(timeoutSecRequest)*time.Second,
 		api.ErrMsgRequestTimeout)
 
+	// The TimeoutHandler buffers the entire response,
+	// so streamed responses bypass it and use a request context deadline instead,
+	// which is StreamTimeoutSec, as streams can run longer than other requests.
+	// Timeouts occurring before the response is started still return a 503
+	streamHandler := contextTimeoutHandler(compressHandler,
+		time.Duration(timeoutSecStream)*time.Second)
+	chainHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isStreamRequest(router, r) {
+			streamHandler.ServeHTTP(w, r)
+			return
+		}
+		timeoutHandler.ServeHTTP(w, r)
+	})
+	// metrics count all requests, including those rejected inside the chain
+	rootHandler := MetricsHandler(router, chainHandler)
+
 	// more "production friendly" timeouts
 	// https://blog.simon-frey.eu/go-as-in-golang-standard-net-http-config-will-break-your-production/#You_should_at_least_do_this_The_easy_path
 	server = &http.Server{
-		ReadTimeout:  time.Duration(conf.Configuration.Server.ReadTimeoutSec) * time.Second,
+		ReadTimeout:  time.Duration(conf.Current().Server.ReadTimeoutSec) * time.Second,
 		WriteTimeout: time.Duration(timeoutSecWrite) * time.Second,
 		Addr:         bindAddress,
-		Handler:      timeoutHandler,
+		Handler:      rootHandler,
 	}
 
 	if isTLSEnabled {
 		serverTLS = &http.Server{
-			ReadTimeout:  time.Duration(conf.Configuration.Server.ReadTimeoutSec) * time.Second,
+			ReadTimeout:  time.Duration(conf.Current().Server.ReadTimeoutSec) * time.Second,
 			WriteTimeout: time.Duration(timeoutSecWrite) * time.Second,
 			Addr:         bindAddressTLS,
-			Handler:      timeoutHandler,
+			Handler:      rootHandler,
 			TLSConfig: &tls.Config{
 				MinVersion: tls.VersionTLS12, // Secure TLS versions only
 			},
@@ -97,6 +129,26 @@ func createServers() {
 	}
 }
 
+// streamTimeoutSec is the maximum duration of streamed responses
+func streamTimeoutSec() int {
+	if timeoutSec := conf.Current().Server.StreamTimeoutSec; timeoutSec > 0 {
+		return timeoutSec
+	}
+	return conf.Current().Server.WriteTimeoutSec
+}
+
+// contextTimeoutHandler sets a deadline on the request context,
+// which allows cancellation to be propagated down to the database driver.
+// The request queries get the same statement timeout
+func contextTimeoutHandler(h http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		ctx = data.WithStatementTimeout(ctx, timeout)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // Set catalog instance
 func SetCatalogInstance(catalog data.Catalog) {
 	catalogInstance = catalog
@@ -109,11 +161,11 @@ func CatalogInstance() data.Catalog {
 
 // Serve starts the web service
 func Serve(catalog data.Catalog) {
-	confServ := conf.Configuration.Server
+	confServ := conf.Current().Server
 	catalogInstance = catalog
 	createServers()
 
-	log.Infof("====  Service: %s  ====\n", conf.Configuration.Metadata.Title)
+	log.Infof("====  Service: %s  ====\n", conf.Current().Metadata.Title)
 
 	// start http service
 	go func() {
@@ -135,10 +187,15 @@ func Serve(catalog data.Catalog) {
 		}()
 	}
 
-	// wait here for interrupt signal (^C)
+	// wait here for interrupt signal (^C), reloading the configuration on SIGHUP
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	<-sig
+	signal.Notify(sig, os.Interrupt, syscall.SIGHUP)
+	for s := range sig {
+		if s != syscall.SIGHUP {
+			break
+		}
+		ReloadConfig()
+	}
 
 	// Interrupt signal received:  Start shutting down
 	log.Infoln("Shutting down...")
@@ -158,12 +215,18 @@ func Serve(catalog data.Catalog) {
 
 	// abort after waiting long enough for service to shutdown gracefully
 	// this terminates long-running DB queries, which otherwise block shutdown
-	abortTimeoutSec := conf.Configuration.Server.WriteTimeoutSec + 10
+	abortTimeoutSec := conf.Current().Server.WriteTimeoutSec + 10
+	if streamTimeoutSec() > conf.Current().Server.WriteTimeoutSec {
+		abortTimeoutSec = streamTimeoutSec() + 10
+	}
 	chanCancelFatal := FatalAfter(abortTimeoutSec, "Timeout on shutdown - aborting.")
 
 	log.Debugln("Closing DB connections")
 	catalogInstance.Close()
 
+	log.Debugln("Exporting remaining spans")
+	tracing.Shutdown()
+
 	log.Infoln("Service stopped.")
 	// cancel the abort since it is not needed
 	close(chanCancelFatal)
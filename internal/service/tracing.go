@@ -0,0 +1,64 @@
+package service
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
+	log "github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// initTracing starts exporting request spans, if tracing is configured
+func initTracing(confTracing conf.Tracing) {
+	if !confTracing.IsEnabled() {
+		return
+	}
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch confTracing.Exporter {
+	case conf.TracingExporterOTLP:
+		exporter, err = tracing.NewOTLPExporter(confTracing.Endpoint)
+		log.Infof("Tracing to OTLP collector %v", confTracing.Endpoint)
+	case conf.TracingExporterFile:
+		exporter, err = tracing.NewFileExporter(confTracing.File)
+		log.Infof("Tracing to file %v", confTracing.File)
+	default:
+		log.Fatalf("Invalid tracing exporter: OTLP and File are supported. %v defined", confTracing.Exporter)
+	}
+	if err != nil {
+		log.Fatalf("Error in tracing init: %v", err)
+	}
+	tracing.Init(exporter, tracing.Options{
+		ServiceName:    confTracing.ServiceName,
+		ServiceVersion: conf.AppConfig.Version,
+		SampleRatio:    confTracing.SampleRatio,
+	})
+}
+
+// startWriteSpan starts the span of writing a response,
+// as a child of the request span held by the response writer.
+// The span is not recorded if the writer holds no request span
+func startWriteSpan(w http.ResponseWriter) trace.Span {
+	sw, ok := w.(*statusWriter)
+	if !ok || sw.ctx == nil {
+		return trace.SpanFromContext(context.Background())
+	}
+	_, span := tracing.Start(sw.ctx, "write response")
+	return span
+}
@@ -20,8 +20,10 @@ package service
 */
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -31,10 +33,14 @@ import (
 
 	"github.com/CrunchyData/pg_featureserv/internal/api"
 	"github.com/CrunchyData/pg_featureserv/internal/conf"
+	"github.com/CrunchyData/pg_featureserv/internal/data"
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
 	"github.com/CrunchyData/pg_featureserv/internal/ui"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	"github.com/theckman/httpforwarded"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -72,6 +78,17 @@ func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	handlerDone := make(chan struct{})
 	start := time.Now()
 
+	// trace the request, continuing the trace of the caller if any
+	route := routeTemplate(r)
+	ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("http.target", r.URL.RequestURI())))
+	r = r.WithContext(ctx)
+
 	// monitor context status and log anything abnormal
 	go func() {
 		select {
@@ -89,9 +106,34 @@ func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// execute the handler
+	sw := &statusWriter{ResponseWriter: w, ctx: ctx}
+	w = sw
+	// the request is traced even if the handler panics
+	// (as it does with http.ErrAbortHandler to abort a streamed response).
+	// The panic is passed on to the server
+	defer func() {
+		p := recover()
+		status := sw.statusCode()
+		if p != nil {
+			if sw.status == 0 {
+				status = http.StatusInternalServerError
+			}
+			tracing.SetError(span, fmt.Errorf("handler panic: %v", p))
+		}
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		span.End()
+		close(handlerDone)
+		if p != nil {
+			panic(p)
+		}
+	}()
 	e := fn(w, r)
 
 	if e != nil { // e is *appError, not os.Error.
+		// a query denied to the role of the request user
+		if data.IsPermissionError(e.Error) {
+			e.Code = http.StatusForbidden
+		}
 		// TODO: is this the desire behaviour?
 		// perhaps detect format and emit accordingly?
 		// log error here?
@@ -100,12 +142,13 @@ func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if e.Error == nil {
 			log.Debugf("Request processing error: %v (%v)\n", e.Message, e.Code)
 			http.Error(w, e.Message, e.Code)
+			tracing.SetError(span, errors.New(e.Message))
 		} else {
 			log.Debugf("Request processing error: %v (%v).\n\tCaused by: %v\n", e.Message, e.Code, e.Error)
 			http.Error(w, fmt.Sprintf("%s\n\tCaused by: %v", e.Message, e.Error), e.Code)
+			tracing.SetError(span, e.Error)
 		}
 	}
-	close(handlerDone)
 }
 
 // FatalAfter aborts by logging a fatal message, after a time delay.
@@ -135,6 +178,11 @@ func appErrorBadRequest(err error, format string, v ...interface{}) *appError {
 	return &appError{err, msg, http.StatusBadRequest}
 }
 
+func appErrorUnauthorized(err error, format string, v ...interface{}) *appError {
+	msg := fmt.Sprintf(format, v...)
+	return &appError{err, msg, http.StatusUnauthorized}
+}
+
 func appErrorNotFound(err error, format string, v ...interface{}) *appError {
 	msg := fmt.Sprintf(format, v...)
 	return &appError{err, msg, http.StatusNotFound}
@@ -149,7 +197,7 @@ func appErrorNotAcceptable(err error, format string, v ...interface{}) *appError
 
 func serveURLBase(r *http.Request) string {
 	// Use configuration file settings if we have them
-	configURL := conf.Configuration.Server.UrlBase
+	configURL := conf.Current().Server.UrlBase
 
 	if configURL != "" {
 		return configURL + "/"
@@ -186,7 +234,7 @@ func serveURLBase(r *http.Request) string {
 		ps = fp[0]
 	}
 
-	path := conf.Configuration.Server.BasePath
+	path := conf.Current().Server.BasePath
 	return fmt.Sprintf("%v://%v%v/", ps, ph, path)
 }
 
@@ -283,6 +331,83 @@ func writeJSON(w http.ResponseWriter, contype string, content interface{}) *appE
 	return nil
 }
 
+// writeFeaturesStream writes a GeoJSON FeatureCollection from a feature iterator.
+// Features are encoded and flushed as they are read,
+// so the response is sent with chunked transfer encoding
+// and the full collection is never held in memory.
+// hasFeature indicates whether the iterator is already positioned on a feature.
+// The paging links and counts are added once all features are written
+func writeFeaturesStream(w http.ResponseWriter, iter data.FeatureIterator, hasFeature bool, content *api.FeatureCollection, page *itemsPage) *appError {
+	span := startWriteSpan(w)
+	defer span.End()
+
+	w.Header().Set("Content-Type", api.ContentTypeGeoJSON)
+	w.WriteHeader(http.StatusOK)
+
+	flusher, canFlush := w.(http.Flusher)
+	bw := bufio.NewWriter(w)
+
+	//nolint:errcheck
+	bw.Write(content.StreamHead())
+	var count uint
+	var last *api.GeojsonFeatureData
+	for ok := hasFeature; ok; ok = iter.Next() {
+		last = iter.Feature()
+		encodedFeature, err := json.Marshal(last)
+		if err != nil {
+			abortStream(err)
+		}
+		if count > 0 {
+			//nolint:errcheck
+			bw.WriteByte(',')
+		}
+		if _, err := bw.Write(encodedFeature); err != nil {
+			abortStream(err)
+		}
+		count++
+		if canFlush && count%streamFlushFeatures == 0 {
+			if err := bw.Flush(); err != nil {
+				abortStream(err)
+			}
+			flusher.Flush()
+		}
+	}
+	// the response status has already been sent,
+	// so an error can only be reported by aborting the response
+	if err := iter.Err(); err != nil {
+		abortStream(err)
+	}
+
+	content.NumberReturned = count
+	span.SetAttributes(attribute.Int("features", int(count)))
+	if page.numMatched >= 0 {
+		numMatched := uint(page.numMatched)
+		content.NumberMatched = &numMatched
+	}
+	content.Links = append(content.Links, page.links(int(count), last)...)
+	tail, err := content.StreamTail()
+	if err != nil {
+		abortStream(err)
+	}
+	//nolint:errcheck
+	bw.Write(tail)
+	if err := bw.Flush(); err != nil {
+		return appErrorInternal(err, api.ErrMsgDataWriteError, "")
+	}
+	return nil
+}
+
+// number of features written between flushes of a streamed response
+const streamFlushFeatures = 100
+
+// abortStream aborts a streamed response after its status has been sent.
+// The connection is closed without terminating the chunked encoding,
+// so that clients do not mistake the truncated content for a complete response
+func abortStream(err error) {
+	log.Warnf("Error streaming response: %v", err)
+	panic(http.ErrAbortHandler)
+}
+
 func writeText(w http.ResponseWriter, contype string, encodedContent []byte) *appError {
 	//fmt.Println(string(encodedContent))
 	writeResponse(w, contype, encodedContent)
@@ -299,10 +424,15 @@ func writeHTML(w http.ResponseWriter, content interface{}, context interface{},
 }
 
 func writeResponse(w http.ResponseWriter, contype string, encodedContent []byte) *appError {
+	span := startWriteSpan(w)
+	defer span.End()
+	span.SetAttributes(attribute.Int("bytes", len(encodedContent)))
+
 	w.Header().Set("Content-Type", contype) //api.ContentType(format))
 	w.WriteHeader(http.StatusOK)
 	_, err := w.Write(encodedContent)
 	if err != nil {
+		tracing.SetError(span, err)
 		return appErrorInternal(err, api.ErrMsgDataWriteError, "")
 	}
 	return nil
@@ -0,0 +1,113 @@
+package tracing
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Tracing of requests with the OpenTelemetry SDK,
+// propagated with W3C trace context headers (https://www.w3.org/TR/trace-context/)
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderTraceparent is the W3C trace context header
+const HeaderTraceparent = "traceparent"
+
+// scopeName is the instrumentation scope of the spans
+const scopeName = "github.com/CrunchyData/pg_featureserv"
+
+// maximum time for exporting the pending spans at shutdown
+const shutdownTimeout = 5 * time.Second
+
+// Options are the settings of the exported spans
+type Options struct {
+	ServiceName    string
+	ServiceVersion string
+	// SampleRatio is the fraction of the traces started by the service which are exported.
+	// Traces started by a client follow the sampling decision of the client
+	SampleRatio float64
+}
+
+var (
+	mutex    sync.Mutex
+	provider *sdktrace.TracerProvider
+)
+
+var propagator = propagation.TraceContext{}
+
+// Init starts exporting spans in batches.
+// Until Init is called, spans are not recorded
+func Init(exporter sdktrace.SpanExporter, opts Options) {
+	Shutdown()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceNameKey.String(opts.ServiceName),
+			semconv.ServiceVersionKey.String(opts.ServiceVersion),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	mutex.Lock()
+	defer mutex.Unlock()
+	provider = tp
+	otel.SetTracerProvider(tp)
+}
+
+// Shutdown exports the pending spans and stops exporting.
+// Spans ended after Shutdown are dropped by the span processor
+func Shutdown() {
+	mutex.Lock()
+	tp := provider
+	provider = nil
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	mutex.Unlock()
+	if tp == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := tp.Shutdown(ctx); err != nil {
+		otel.Handle(err)
+	}
+}
+
+// Start starts a span as a child of the span in the context.
+// The tracer is read for each span, as the provider is replaced by Init and Shutdown
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(scopeName).Start(ctx, name, opts...)
+}
+
+// Extract returns a context holding the remote span of the trace context headers, if any
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// SetError records an error on a span, and sets its status to error. A nil error is ignored
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
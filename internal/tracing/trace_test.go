@@ -0,0 +1,143 @@
+package tracing_test
+
+/*
+ Copyright 2019 - 2024 Crunchy Data Solutions, Inc.
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+      http://www.apache.org/licenses/LICENSE-2.0
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/CrunchyData/pg_featureserv/internal/tracing"
+	util "github.com/CrunchyData/pg_featureserv/internal/utiltest"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestSpansDisabled(t *testing.T) {
+	tracing.Shutdown()
+	ctx, span := tracing.Start(context.Background(), "op")
+	util.Assert(t, !span.IsRecording(), "span recorded without tracing")
+	util.Assert(t, !trace.SpanContextFromContext(ctx).IsValid(), "span context without tracing")
+	tracing.SetError(span, errors.New("failed"))
+	span.End()
+}
+
+func TestSpansExported(t *testing.T) {
+	exporter := &memoryExporter{tracetest.NewInMemoryExporter()}
+	tracing.Init(exporter, tracing.Options{ServiceName: "test", SampleRatio: 1})
+
+	header := http.Header{}
+	header.Set(tracing.HeaderTraceparent, testTraceparent)
+	ctx := tracing.Extract(context.Background(), header)
+	ctx, parent := tracing.Start(ctx, "GET /items", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracing.Start(ctx, "cql.transpile")
+	tracing.SetError(child, errors.New("syntax error"))
+	child.End()
+	parent.End()
+	// a span ending after shutdown is dropped
+	_, late := tracing.Start(ctx, "late")
+	tracing.Shutdown()
+	late.End()
+	spans := exporter.GetSpans()
+
+	util.Equals(t, 2, len(spans), "number of spans")
+	byName := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	server := byName["GET /items"]
+	util.Equals(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String(), "trace id propagated")
+	util.Equals(t, "00f067aa0ba902b7", server.Parent.SpanID().String(), "remote parent")
+	util.Equals(t, trace.SpanKindServer, server.SpanKind, "span kind")
+	transpile := byName["cql.transpile"]
+	util.Equals(t, server.SpanContext.TraceID(), transpile.SpanContext.TraceID(), "child trace id")
+	util.Equals(t, server.SpanContext.SpanID(), transpile.Parent.SpanID(), "child parent")
+	util.Equals(t, codes.Error, transpile.Status.Code, "error status")
+	util.Equals(t, "syntax error", transpile.Status.Description, "error message")
+}
+
+func TestSpansNotSampled(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Init(exporter, tracing.Options{SampleRatio: 1})
+
+	header := http.Header{}
+	header.Set(tracing.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), header), "op")
+	util.Assert(t, !span.IsRecording(), "span of unsampled trace is recorded")
+	_, child := tracing.Start(ctx, "child")
+	util.Assert(t, !child.IsRecording(), "child span of unsampled trace is recorded")
+	child.End()
+	span.End()
+	util.Equals(t, 0, len(exporter.GetSpans()), "spans exported")
+	tracing.Shutdown()
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	exporter, err := tracing.NewOTLPExporter(server.URL)
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	tracing.Init(exporter, tracing.Options{ServiceName: "test", SampleRatio: 1})
+	_, span := tracing.Start(context.Background(), "op")
+	span.End()
+	tracing.Shutdown()
+
+	util.Equals(t, "/v1/traces", path, "OTLP path")
+	util.Assert(t, strings.Contains(string(body), "service.name"), "resource not exported")
+}
+
+func TestFileExporter(t *testing.T) {
+	file, _ := ioutil.TempFile("", "spans*.json")
+	file.Close()
+	defer os.Remove(file.Name())
+
+	exporter, err := tracing.NewFileExporter(file.Name())
+	util.Assert(t, err == nil, "unexpected error: %v", err)
+	tracing.Init(exporter, tracing.Options{SampleRatio: 1})
+	_, span := tracing.Start(context.Background(), "op")
+	span.End()
+	tracing.Shutdown()
+
+	data, _ := ioutil.ReadFile(file.Name())
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	util.Equals(t, 1, len(lines), "lines in span file")
+	var stub struct{ Name string }
+	err = json.Unmarshal([]byte(lines[0]), &stub)
+	util.Assert(t, err == nil, "invalid span: %v", err)
+	util.Equals(t, "op", stub.Name, "span name")
+}
+
+// memoryExporter holds the exported spans after shutdown
+type memoryExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (e *memoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
@@ -37,7 +37,11 @@ type PageData struct {
 	// URLItemsJSON is url for items JSON
 	URLItemsJSON string
 	// URLJSON is the url for the current page in JSON
-	URLJSON         string
+	URLJSON string
+	// URLNext and URLPrev are the urls for the next and previous pages of items
+	URLNext         string
+	URLPrev         string
+	Limit           int
 	Group           string
 	Title           string
 	Table           *api.Table
@@ -96,8 +100,8 @@ func loadPageTemplate(curr *template.Template, filename string) *template.Templa
 		return curr
 	}
 	return createTemplate(
-		conf.Configuration.Server.AssetsPath+"/page.gohtml",
-		conf.Configuration.Server.AssetsPath+"/"+filename)
+		conf.Current().Server.AssetsPath+"/page.gohtml",
+		conf.Current().Server.AssetsPath+"/"+filename)
 }
 
 func loadMapPageTemplate(curr *template.Template, filename string) *template.Template {
@@ -105,9 +109,9 @@ func loadMapPageTemplate(curr *template.Template, filename string) *template.Tem
 		return curr
 	}
 	return createTemplate(
-		conf.Configuration.Server.AssetsPath+"/page.gohtml",
-		conf.Configuration.Server.AssetsPath+"/map_script.gohtml",
-		conf.Configuration.Server.AssetsPath+"/"+filename)
+		conf.Current().Server.AssetsPath+"/page.gohtml",
+		conf.Current().Server.AssetsPath+"/map_script.gohtml",
+		conf.Current().Server.AssetsPath+"/"+filename)
 }
 
 func PageHome() *template.Template {
@@ -119,7 +123,7 @@ func PageConformance() *template.Template {
 	return htmlTemp.conformance
 }
 func PageAPI() *template.Template {
-	htmlTemp.api = loadTemplate(htmlTemp.api, conf.Configuration.Server.AssetsPath+"/api.gohtml")
+	htmlTemp.api = loadTemplate(htmlTemp.api, conf.Current().Server.AssetsPath+"/api.gohtml")
 	return htmlTemp.api
 }
 func PageCollections() *template.Template {
@@ -148,17 +152,17 @@ func PageFunction() *template.Template {
 }
 func PageFunctionItems() *template.Template {
 	htmlTemp.functionItems = loadTemplate(htmlTemp.functionItems,
-		conf.Configuration.Server.AssetsPath+"/page.gohtml",
-		conf.Configuration.Server.AssetsPath+"/items.gohtml",
-		conf.Configuration.Server.AssetsPath+"/map_script.gohtml",
-		conf.Configuration.Server.AssetsPath+"/fun_script.gohtml")
+		conf.Current().Server.AssetsPath+"/page.gohtml",
+		conf.Current().Server.AssetsPath+"/items.gohtml",
+		conf.Current().Server.AssetsPath+"/map_script.gohtml",
+		conf.Current().Server.AssetsPath+"/fun_script.gohtml")
 	return htmlTemp.functionItems
 }
 
 // RenderHTML tbd
 func RenderHTML(temp *template.Template, content interface{}, context interface{}) ([]byte, error) {
 	bodyData := map[string]interface{}{
-		"config":  conf.Configuration,
+		"config":  conf.Current(),
 		"context": context,
 		"data":    content}
 	contentBytes, err := renderTemplate(temp, bodyData)
@@ -82,7 +82,7 @@ func main() {
 		log.Info("Running in development mode")
 	}
 	// Commandline over-rides config file for debugging
-	if flagDebugOn || conf.Configuration.Server.Debug {
+	if flagDebugOn || conf.Current().Server.Debug {
 		log.SetLevel(log.TraceLevel)
 		log.Debugf("Log level = DEBUG\n")
 	}
@@ -95,8 +95,8 @@ func main() {
 	} else {
 		catalog = data.CatDBInstance()
 	}
-	includes := conf.Configuration.Database.TableIncludes
-	excludes := conf.Configuration.Database.TableExcludes
+	includes := conf.Current().Database.TableIncludes
+	excludes := conf.Current().Database.TableExcludes
 	catalog.Initialize(includes, excludes)
 
 	//-- Start up service